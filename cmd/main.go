@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
@@ -9,10 +10,22 @@ import (
 	"notes/services/migrator"
 	"os"
 	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"notes/server"
+	"notes/services/collab"
+	"notes/services/elastic"
+	"notes/services/entities"
+	"notes/services/jobs"
+	"notes/services/notes"
+	"notes/services/render"
+	"notes/services/search"
+	"notes/services/tokens"
 	"notes/services/tracing"
+	"notes/services/users"
+	"notes/services/webhooks"
 
 	"github.com/getsentry/sentry-go"
 	_ "github.com/go-sql-driver/mysql"
@@ -25,7 +38,17 @@ import (
 )
 
 func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	// deferred first so it runs last, once the deferred shutdowns flushed the logs.
+	// Every failure sets it, so supervisors do not take a crash for a clean exit.
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// Knative stops revisions with SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	env := os.Getenv("ENVIRONMENT")
@@ -55,26 +78,87 @@ func main() {
 	slog.InfoContext(ctx, "starting up see slog", "day", "today", "time",
 		time.Now(), "item", uuid.NewString(), "content", `{"message": "hello world"}`)
 
-	db, err := migrator.SetupDB(ctx, getDsn())
+	if len(os.Args) > 1 {
+		switch command := os.Args[1]; command {
+		case "reindex":
+			if err := reindex(ctx, os.Args[2:]); err != nil {
+				slog.ErrorContext(ctx, "reindex failed", "error", err)
+				exitCode = 1
+			}
+		default:
+			slog.ErrorContext(ctx, "unknown command", "command", command)
+			exitCode = 1
+		}
+		return
+	}
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		slog.ErrorContext(ctx, "JWT_SECRET is required to sign auth tokens")
+		exitCode = 1
+		return
+	}
+
+	storage, err := setupStorage(ctx)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to setup db", "error", err)
+		slog.ErrorContext(ctx, "failed to setup storage", "error", err)
+		exitCode = 1
 		return
 	}
 	defer func() {
-		if err := db.Close(); err != nil {
-			slog.ErrorContext(ctx, "failed to close db", "error", err)
+		if err := storage.close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close storage", "error", err)
 		}
 	}()
 
-	if err := migrator.Migrate(ctx, db, getDsn()); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
-			log.Fatal(err)
-		}
+	index, backend, err := setupSearch(ctx, storage)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to setup search", "error", err)
+		exitCode = 1
+		return
+	}
 
-		slog.InfoContext(ctx, "database is already up to date", "error", err)
+	service := notes.New(storage.notes,
+		notes.WithIndexer(index),
+		notes.WithUsers(storage.users),
+		notes.WithRevisionLimit(intEnv(ctx, "NOTE_REVISIONS_KEEP", 50)),
+		notes.WithEventBuffer(intEnv(ctx, "EVENTS_REPLAY_SIZE", 1000)))
+	if backend == "memory" {
+		indexed, err := service.IndexAll(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to build the search index", "error", err)
+			exitCode = 1
+			return
+		}
+		slog.InfoContext(ctx, "search index built", "notes", indexed)
 	}
+	service.StartPurger(ctx,
+		durationEnv(ctx, "TRASH_RETENTION", 30*24*time.Hour),
+		durationEnv(ctx, "TRASH_PURGE_INTERVAL", time.Hour))
+
+	dispatcher := webhooks.New(storage.webhooks, service,
+		webhooks.WithInterval(durationEnv(ctx, "WEBHOOK_DISPATCH_INTERVAL", 5*time.Second)),
+		webhooks.WithMaxAttempts(intEnv(ctx, "WEBHOOK_MAX_ATTEMPTS", 8)),
+		webhooks.WithBackoff(durationEnv(ctx, "WEBHOOK_BACKOFF", 30*time.Second), time.Hour))
+	dispatcher.Start(ctx)
+
+	runner := setupJobs(ctx, storage, service, dispatcher)
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		runner.Run(ctx)
+	}()
 
-	svr := server.New()
+	svr := server.New(server.Services{
+		Notes:     service,
+		Users:     users.New(storage.users, []byte(secret), durationEnv(ctx, "JWT_TTL", 24*time.Hour)),
+		Tokens:    tokens.New(storage.tokens),
+		Search:    search.New(index),
+		Collab:    collab.New(service, collab.WithSaveInterval(durationEnv(ctx, "COLLAB_SAVE_INTERVAL", 5*time.Second))),
+		Renderer:  render.New(intEnv(ctx, "RENDER_CACHE_SIZE", 1024), intEnv(ctx, "RENDER_CACHE_BYTES", 64<<20)),
+		Webhooks:  dispatcher,
+		Reminders: runner,
+	})
 	appPort := os.Getenv("APP_PORT")
 	if appPort == "" {
 		appPort = ":80"
@@ -91,16 +175,191 @@ func main() {
 	select {
 	case err := <-svrErr:
 		slog.ErrorContext(ctx, "an error occurred from the server", "error", err)
-		return
+		exitCode = 1
 
 	case <-ctx.Done():
 		slog.Info("shutting down")
-		stop()
 	}
+	// the runner finishes the reminders it is firing before it returns
+	stop()
+	<-jobsDone
 
 	slog.Info("shutdown complete")
 }
 
+// storage holds the stores for the backend selected by STORAGE_DRIVER
+type storage struct {
+	notes     notes.Store
+	users     users.Store
+	tokens    tokens.Store
+	webhooks  webhooks.Store
+	reminders jobs.Store
+	// db is the database behind the stores, nil for the memory driver
+	db    *sql.DB
+	close func() error
+}
+
+// setupStorage opens the storage selected by STORAGE_DRIVER (mysql, sqlite or memory)
+func setupStorage(ctx context.Context) (storage, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "mysql":
+		db, err := migrator.SetupDB(ctx, getDsn())
+		if err != nil {
+			return storage{}, err
+		}
+		if err := migrator.Migrate(ctx, db, getDsn()); err != nil {
+			if !errors.Is(err, migrate.ErrNoChange) {
+				return storage{}, errors.Join(err, db.Close())
+			}
+			slog.InfoContext(ctx, "database is already up to date", "error", err)
+		}
+		return storage{
+			notes:     notes.NewMySQLStore(db),
+			users:     users.NewSQLStore(db),
+			tokens:    tokens.NewSQLStore(db),
+			webhooks:  webhooks.NewSQLStore(db),
+			reminders: jobs.NewMySQLStore(db),
+			db:        db,
+			close:     db.Close,
+		}, nil
+
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "notes.db"
+		}
+		db, err := migrator.SetupSQLite(ctx, path)
+		if err != nil {
+			return storage{}, err
+		}
+		if err := migrator.MigrateSQLite(ctx, db); err != nil {
+			if !errors.Is(err, migrate.ErrNoChange) {
+				return storage{}, errors.Join(err, db.Close())
+			}
+			slog.InfoContext(ctx, "database is already up to date", "error", err)
+		}
+		return storage{
+			notes:     notes.NewSQLiteStore(db),
+			users:     users.NewSQLStore(db),
+			tokens:    tokens.NewSQLStore(db),
+			webhooks:  webhooks.NewSQLStore(db),
+			reminders: jobs.NewSQLiteStore(db),
+			db:        db,
+			close:     db.Close,
+		}, nil
+
+	case "memory":
+		slog.WarnContext(ctx, "notes are kept in memory and will be lost on restart")
+		return storage{
+			notes:     notes.NewMemoryStore(),
+			users:     users.NewMemoryStore(),
+			tokens:    tokens.NewMemoryStore(),
+			webhooks:  webhooks.NewMemoryStore(),
+			reminders: jobs.NewMemoryStore(),
+			close:     func() error { return nil },
+		}, nil
+
+	default:
+		return storage{}, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+// setupJobs returns the runner that fires reminders. They can always be sent to webhooks,
+// and by email when SMTP_ADDR points at a mail server.
+func setupJobs(ctx context.Context, storage storage, service *notes.Service, dispatcher *webhooks.Service) *jobs.Service {
+	opts := []jobs.Option{
+		jobs.WithInterval(durationEnv(ctx, "REMINDER_POLL_INTERVAL", 15*time.Second)),
+		jobs.WithRetry(intEnv(ctx, "REMINDER_MAX_ATTEMPTS", 5), durationEnv(ctx, "REMINDER_RETRY_DELAY", time.Minute)),
+		jobs.WithNotifier(entities.ChannelWebhook, jobs.NewWebhookNotifier(dispatcher)),
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		from := os.Getenv("SMTP_FROM")
+		if from == "" {
+			from = "notes@localhost"
+		}
+		opts = append(opts, jobs.WithNotifier(entities.ChannelEmail, jobs.NewEmailNotifier(addr, from, storage.users)))
+	} else {
+		slog.WarnContext(ctx, "SMTP_ADDR is not set, reminders cannot be sent by email")
+	}
+	return jobs.New(storage.reminders, service, opts...)
+}
+
+// setupSearch opens the search backend selected by SEARCH_BACKEND (elasticsearch, mysql or memory)
+// and returns it with its name. When unset, Elasticsearch is used if ELASTICSEARCH_URL is set,
+// then the MySQL FULLTEXT index when notes are stored in MySQL and otherwise an in-memory index,
+// which has to be built on startup.
+func setupSearch(ctx context.Context, storage storage) (search.Index, string, error) {
+	backend := os.Getenv("SEARCH_BACKEND")
+	if backend == "" {
+		switch driver := os.Getenv("STORAGE_DRIVER"); {
+		case os.Getenv("ELASTICSEARCH_URL") != "":
+			backend = "elasticsearch"
+		case driver == "" || driver == "mysql":
+			backend = "mysql"
+		default:
+			backend = "memory"
+		}
+	}
+	slog.InfoContext(ctx, "search backend selected", "backend", backend)
+
+	switch backend {
+	case "elasticsearch":
+		url := os.Getenv("ELASTICSEARCH_URL")
+		if url == "" {
+			return nil, "", errors.New("ELASTICSEARCH_URL is required for the elasticsearch search backend")
+		}
+		name := os.Getenv("ELASTICSEARCH_INDEX")
+		if name == "" {
+			name = "notes"
+		}
+		index := elastic.New(url, name)
+		if err := index.EnsureIndex(ctx); err != nil {
+			return nil, "", err
+		}
+		return index, backend, nil
+
+	case "mysql":
+		if driver := os.Getenv("STORAGE_DRIVER"); driver != "" && driver != "mysql" {
+			return nil, "", fmt.Errorf("the mysql search backend needs STORAGE_DRIVER=mysql, not %q", driver)
+		}
+		return search.NewMySQLIndex(storage.db), backend, nil
+
+	case "memory":
+		return search.NewMemoryIndex(), backend, nil
+
+	default:
+		return nil, "", fmt.Errorf("unknown search backend %q", backend)
+	}
+}
+
+// durationEnv reads a duration such as "720h" from the environment, falling back when it is unset or invalid
+func durationEnv(ctx context.Context, name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.WarnContext(ctx, "invalid duration, using the default", "name", name, "value", value, "default", fallback.String())
+		return fallback
+	}
+	return d
+}
+
+// intEnv reads a non-negative integer from the environment, falling back when it is unset or invalid
+func intEnv(ctx context.Context, name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		slog.WarnContext(ctx, "invalid number, using the default", "name", name, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
 func getDsn() string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&timeout=5s",
 		os.Getenv("DB_USER"),
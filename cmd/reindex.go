@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	"notes/services/elastic"
+	"notes/services/entities"
+	"notes/services/notes"
+	"notes/services/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// reindex rebuilds the Elasticsearch index from the notes table. The notes are
+// loaded into a fresh index which replaces the live one once it is complete,
+// after catching it up with the notes changed or trashed while it ran. Notes
+// purged outright in that time are not seen and stay searchable until the next run.
+func reindex(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	batchSize := flags.Int("batch-size", 500, "number of notes read and indexed at a time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *batchSize < 1 {
+		return errors.New("batch-size must be at least 1")
+	}
+
+	url := os.Getenv("ELASTICSEARCH_URL")
+	if url == "" {
+		return errors.New("ELASTICSEARCH_URL is required, the in-memory index is rebuilt on every start")
+	}
+	name := os.Getenv("ELASTICSEARCH_INDEX")
+	if name == "" {
+		name = "notes"
+	}
+
+	storage, err := setupStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := storage.close(); err != nil {
+			slog.ErrorContext(ctx, "failed to close storage", "error", err)
+		}
+	}()
+	if storage.db == nil {
+		return errors.New("reindex reads from the database, set STORAGE_DRIVER to mysql or sqlite")
+	}
+
+	meter := tracing.Meter()
+	indexedCounter, err := meter.Int64Counter("notes.reindex.indexed",
+		metric.WithDescription("Notes loaded into the new search index"),
+		metric.WithUnit("{note}"))
+	if err != nil {
+		return err
+	}
+	batchCounter, err := meter.Int64Counter("notes.reindex.batches",
+		metric.WithDescription("Batches of notes bulk loaded into the new search index"),
+		metric.WithUnit("{batch}"))
+	if err != nil {
+		return err
+	}
+	duration, err := meter.Float64Histogram("notes.reindex.duration",
+		metric.WithDescription("Time taken to rebuild the search index"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "reindex")
+	defer span.End()
+
+	start := time.Now()
+	indexed := 0
+	// counted passes batches on to bulk, reporting progress
+	counted := func(bulk func([]entities.Note) error) func([]entities.Note) error {
+		return func(batch []entities.Note) error {
+			if err := bulk(batch); err != nil {
+				return err
+			}
+			indexed += len(batch)
+			indexedCounter.Add(ctx, int64(len(batch)))
+			batchCounter.Add(ctx, 1)
+			slog.InfoContext(ctx, "reindex progress", "indexed", indexed)
+			return nil
+		}
+	}
+	index, err := elastic.New(url, name).Reindex(ctx,
+		func(bulk func([]entities.Note) error) error {
+			return notes.Batches(ctx, storage.db, *batchSize, counted(bulk))
+		},
+		func(since time.Time, bulk func([]entities.Note) error) error {
+			slog.InfoContext(ctx, "reindex catching up", "since", since)
+			return notes.ChangedSince(ctx, storage.db, since, *batchSize, counted(bulk))
+		},
+	)
+	elapsed := time.Since(start)
+
+	status := "ok"
+	if err != nil {
+		status = "failed"
+	}
+	duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attribute.String("status", status)))
+	span.SetAttributes(attribute.Int("indexed", indexed), attribute.String("status", status))
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "reindex complete", "alias", name, "index", index, "indexed", indexed, "duration", elapsed.String())
+	return nil
+}
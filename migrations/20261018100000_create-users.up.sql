@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id            BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id       VARCHAR(100) NOT NULL,
+    email         VARCHAR(255) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id),
+    UNIQUE (email)
+);
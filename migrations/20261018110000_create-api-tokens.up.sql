@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS api_tokens
+(
+    id           BIGINT PRIMARY KEY AUTO_INCREMENT,
+    token_id     VARCHAR(100) NOT NULL,
+    user_id      VARCHAR(100) NOT NULL,
+    name         VARCHAR(100) NOT NULL,
+    token_hash   VARCHAR(64)  NOT NULL,
+    scopes       VARCHAR(255) NOT NULL,
+    expires_at   TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (token_id),
+    UNIQUE (token_hash),
+    INDEX (user_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE notes
+    DROP INDEX notes_fulltext;
@@ -0,0 +1,2 @@
+ALTER TABLE notes
+    ADD FULLTEXT INDEX notes_fulltext (title, content);
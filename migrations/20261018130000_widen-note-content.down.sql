@@ -0,0 +1,4 @@
+ALTER TABLE notes
+    MODIFY title VARCHAR(100) NOT NULL,
+    MODIFY content VARCHAR(100) NOT NULL,
+    DROP COLUMN content_type;
@@ -0,0 +1,4 @@
+ALTER TABLE notes
+    MODIFY title VARCHAR(255) CHARACTER SET utf8mb4 NOT NULL,
+    MODIFY content MEDIUMTEXT CHARACTER SET utf8mb4 NOT NULL,
+    ADD COLUMN content_type VARCHAR(20) NOT NULL DEFAULT 'plain';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tags
+(
+    id         BIGINT PRIMARY KEY AUTO_INCREMENT,
+    user_id    VARCHAR(100) NOT NULL,
+    name       VARCHAR(50) CHARACTER SET utf8mb4 NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS note_tags;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS note_tags
+(
+    note_id VARCHAR(100) NOT NULL,
+    tag_id  BIGINT       NOT NULL,
+    PRIMARY KEY (note_id, tag_id),
+    INDEX (tag_id)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS notebooks
+(
+    id          BIGINT PRIMARY KEY AUTO_INCREMENT,
+    notebook_id VARCHAR(100) NOT NULL,
+    user_id     VARCHAR(100) NOT NULL,
+    parent_id   VARCHAR(100),
+    name        VARCHAR(255) CHARACTER SET utf8mb4 NOT NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (notebook_id),
+    INDEX (user_id),
+    INDEX (parent_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE notes
+    DROP COLUMN notebook_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE notes
+    ADD COLUMN notebook_id VARCHAR(100),
+    ADD INDEX notes_notebook_id (notebook_id);
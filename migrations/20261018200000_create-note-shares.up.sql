@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS note_shares
+(
+    note_id    VARCHAR(100) NOT NULL,
+    user_id    VARCHAR(100) NOT NULL,
+    role       VARCHAR(20)  NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (note_id, user_id),
+    INDEX (user_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS comments
+(
+    id         BIGINT PRIMARY KEY AUTO_INCREMENT,
+    comment_id VARCHAR(100) NOT NULL,
+    note_id    VARCHAR(100) NOT NULL,
+    user_id    VARCHAR(100) NOT NULL,
+    parent_id  VARCHAR(100),
+    body       TEXT CHARACTER SET utf8mb4 NOT NULL,
+    resolved   BOOLEAN   NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+    UNIQUE (comment_id),
+    INDEX (note_id),
+    INDEX (parent_id)
+);
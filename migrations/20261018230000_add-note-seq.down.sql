@@ -0,0 +1,3 @@
+ALTER TABLE notes
+    DROP INDEX notes_user_id_seq,
+    DROP COLUMN seq;
\ No newline at end of file
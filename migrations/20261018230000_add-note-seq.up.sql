@@ -0,0 +1,3 @@
+ALTER TABLE notes
+    ADD COLUMN seq BIGINT NOT NULL DEFAULT 0,
+    ADD INDEX notes_user_id_seq (user_id, seq);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         BIGINT PRIMARY KEY AUTO_INCREMENT,
+    webhook_id VARCHAR(100)  NOT NULL,
+    user_id    VARCHAR(100)  NOT NULL,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(100)  NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (webhook_id),
+    INDEX (user_id)
+);
@@ -4,3 +4,8 @@ import "embed"
 
 //go:embed *.sql
 var Migrations embed.FS
+
+// SQLite holds the migrations for the embedded sqlite database
+//
+//go:embed sqlite/*.sql
+var SQLite embed.FS
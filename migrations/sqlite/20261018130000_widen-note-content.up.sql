@@ -0,0 +1,3 @@
+-- sqlite does not enforce column lengths, so only the content type is added
+ALTER TABLE notes
+    ADD COLUMN content_type VARCHAR(20) NOT NULL DEFAULT 'plain';
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS note_revisions
+(
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id      VARCHAR(100) NOT NULL,
+    revision     INT          NOT NULL,
+    title        VARCHAR(255) NOT NULL,
+    content      TEXT         NOT NULL,
+    content_type VARCHAR(20)  NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (note_id, revision)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE notes
+    ADD COLUMN version INT NOT NULL DEFAULT 1;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tags
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    VARCHAR(100) NOT NULL,
+    name       VARCHAR(50)  NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS note_tags
+(
+    note_id VARCHAR(100) NOT NULL,
+    tag_id  INTEGER      NOT NULL,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS note_tags_tag_id ON note_tags (tag_id);
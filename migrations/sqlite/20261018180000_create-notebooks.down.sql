@@ -0,0 +1 @@
+DROP TABLE IF EXISTS notebooks;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS notebooks
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    notebook_id VARCHAR(100) NOT NULL,
+    user_id     VARCHAR(100) NOT NULL,
+    parent_id   VARCHAR(100),
+    name        VARCHAR(255) NOT NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (notebook_id)
+);
+
+CREATE INDEX IF NOT EXISTS notebooks_user_id ON notebooks (user_id);
+
+CREATE INDEX IF NOT EXISTS notebooks_parent_id ON notebooks (parent_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS notes_notebook_id;
+
+ALTER TABLE notes
+    DROP COLUMN notebook_id;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE notes
+    ADD COLUMN notebook_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS notes_notebook_id ON notes (notebook_id);
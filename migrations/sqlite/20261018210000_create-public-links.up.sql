@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS public_links
+(
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id       VARCHAR(100) NOT NULL,
+    note_id       VARCHAR(100) NOT NULL,
+    token_hash    VARCHAR(64)  NOT NULL,
+    password_hash VARCHAR(100) NULL,
+    expires_at    TIMESTAMP NULL,
+    max_views     INT NULL,
+    views         INT NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (link_id),
+    UNIQUE (token_hash)
+);
+
+CREATE INDEX IF NOT EXISTS public_links_note_id ON public_links (note_id);
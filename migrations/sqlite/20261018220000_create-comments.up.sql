@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS comments
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    comment_id VARCHAR(100) NOT NULL,
+    note_id    VARCHAR(100) NOT NULL,
+    user_id    VARCHAR(100) NOT NULL,
+    parent_id  VARCHAR(100),
+    body       TEXT      NOT NULL,
+    resolved   BOOLEAN   NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+    UNIQUE (comment_id)
+);
+
+CREATE INDEX IF NOT EXISTS comments_note_id ON comments (note_id);
+
+CREATE INDEX IF NOT EXISTS comments_parent_id ON comments (parent_id);
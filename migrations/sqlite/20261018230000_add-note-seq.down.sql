@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS notes_user_id_seq;
+
+ALTER TABLE notes
+    DROP COLUMN seq;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE notes
+    ADD COLUMN seq BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS notes_user_id_seq ON notes (user_id, seq);
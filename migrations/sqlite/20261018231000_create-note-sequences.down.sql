@@ -0,0 +1 @@
+DROP TABLE IF EXISTS note_sequences;
\ No newline at end of file
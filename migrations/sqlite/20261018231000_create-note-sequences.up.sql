@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS note_sequences
+(
+    user_id    VARCHAR(100) NOT NULL PRIMARY KEY,
+    seq        BIGINT       NOT NULL,
+    purged_seq BIGINT       NOT NULL
+);
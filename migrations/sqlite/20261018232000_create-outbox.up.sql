@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id   VARCHAR(100) NOT NULL,
+    user_id    VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50)  NOT NULL,
+    payload    TEXT         NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id VARCHAR(100)  NOT NULL,
+    user_id    VARCHAR(100)  NOT NULL,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(100)  NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (webhook_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_user_id ON webhooks (user_id);
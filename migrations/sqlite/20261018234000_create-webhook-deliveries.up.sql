@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    delivery_id     VARCHAR(100)  NOT NULL,
+    webhook_id      VARCHAR(100)  NOT NULL,
+    event_id        VARCHAR(100)  NOT NULL,
+    event_type      VARCHAR(50)   NOT NULL,
+    payload         TEXT          NOT NULL,
+    status          VARCHAR(20)   NOT NULL,
+    attempts        INT           NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NULL,
+    last_error      VARCHAR(1000) NOT NULL DEFAULT '',
+    response_status INT           NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (delivery_id),
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at ON webhook_deliveries (status, next_attempt_at);
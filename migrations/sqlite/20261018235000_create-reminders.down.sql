@@ -0,0 +1 @@
+DROP TABLE IF EXISTS reminders;
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS reminders
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    reminder_id VARCHAR(100)  NOT NULL,
+    note_id     VARCHAR(100)  NOT NULL,
+    user_id     VARCHAR(100)  NOT NULL,
+    remind_at   TIMESTAMP     NOT NULL,
+    recurrence  VARCHAR(100)  NOT NULL DEFAULT '',
+    channel     VARCHAR(20)   NOT NULL,
+    status      VARCHAR(20)   NOT NULL,
+    next_run_at TIMESTAMP     NOT NULL,
+    attempts    INT           NOT NULL DEFAULT 0,
+    last_error  VARCHAR(1000) NOT NULL DEFAULT '',
+    fired_at    TIMESTAMP NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (reminder_id)
+);
+
+CREATE INDEX IF NOT EXISTS reminders_user_id_note_id ON reminders (user_id, note_id);
+CREATE INDEX IF NOT EXISTS reminders_status_next_run_at ON reminders (status, next_run_at);
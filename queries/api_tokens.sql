@@ -0,0 +1,30 @@
+-- name: CreateAPIToken :execlastid
+INSERT INTO api_tokens (token_id, user_id, name, token_hash, scopes, expires_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: FindAPIToken :one
+SELECT *
+FROM api_tokens
+WHERE id = ?;
+
+-- name: FindAPITokenByHash :one
+SELECT *
+FROM api_tokens
+WHERE token_hash = ?;
+
+-- name: FindAPITokensByUserID :many
+SELECT *
+FROM api_tokens
+WHERE user_id = ?
+ORDER BY created_at, id;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE token_id = ?;
+
+-- name: DeleteAPIToken :execrows
+DELETE
+FROM api_tokens
+WHERE token_id = ?
+  AND user_id = ?;
@@ -0,0 +1,56 @@
+-- name: CreateComment :exec
+INSERT INTO comments (comment_id, note_id, user_id, parent_id, body, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+-- name: FindComment :one
+SELECT *
+FROM comments
+WHERE comment_id = ?
+  AND deleted_at IS NULL;
+
+-- name: FindCommentsByNoteID :many
+SELECT *
+FROM comments
+WHERE note_id = ?
+  AND deleted_at IS NULL
+ORDER BY created_at, id;
+
+-- name: CountCommentsByNoteIDs :many
+SELECT note_id, COUNT(*) AS comments
+FROM comments
+WHERE note_id IN (sqlc.slice(note_ids))
+  AND deleted_at IS NULL
+GROUP BY note_id;
+
+-- name: UpdateComment :execrows
+UPDATE comments
+SET body       = ?,
+    resolved   = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE comment_id = ?
+  AND deleted_at IS NULL;
+
+-- name: DeleteComment :execrows
+UPDATE comments
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE (comment_id = sqlc.arg(comment_id) OR parent_id = sqlc.arg(comment_id))
+  AND deleted_at IS NULL;
+
+-- name: DeleteComments :exec
+DELETE
+FROM comments
+WHERE note_id = ?;
+
+-- name: PurgeDeletedComments :exec
+DELETE
+FROM comments
+WHERE deleted_at IS NOT NULL
+  AND deleted_at < ?;
+
+-- name: PurgeDeletedNoteComments :exec
+DELETE
+FROM comments
+WHERE note_id IN (SELECT note_id
+                  FROM notes
+                  WHERE deleted_at IS NOT NULL
+                    AND deleted_at < ?);
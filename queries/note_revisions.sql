@@ -0,0 +1,41 @@
+-- name: CreateNoteRevision :exec
+INSERT INTO note_revisions (note_id, revision, title, content, content_type, created_at)
+VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: FindLatestNoteRevision :one
+SELECT revision
+FROM note_revisions
+WHERE note_id = ?
+ORDER BY revision DESC
+LIMIT 1;
+
+-- name: FindNoteRevision :one
+SELECT *
+FROM note_revisions
+WHERE note_id = ?
+  AND revision = ?;
+
+-- name: FindNoteRevisions :many
+SELECT id, note_id, revision, title, content_type, created_at
+FROM note_revisions
+WHERE note_id = ?
+ORDER BY revision DESC;
+
+-- name: DeleteNoteRevisions :exec
+DELETE
+FROM note_revisions
+WHERE note_id = ?;
+
+-- name: PruneNoteRevisions :execrows
+DELETE
+FROM note_revisions
+WHERE note_id = ?
+  AND revision <= ?;
+
+-- name: PurgeDeletedNoteRevisions :execrows
+DELETE
+FROM note_revisions
+WHERE note_id IN (SELECT note_id
+                  FROM notes
+                  WHERE deleted_at IS NOT NULL
+                    AND deleted_at < ?);
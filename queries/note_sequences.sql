@@ -0,0 +1,19 @@
+-- name: CreateNoteSequence :exec
+INSERT INTO note_sequences (user_id, seq, purged_seq)
+VALUES (?, 1, 0);
+
+-- name: FindNoteSequence :one
+SELECT *
+FROM note_sequences
+WHERE user_id = ?;
+
+-- name: IncrementNoteSequence :execrows
+UPDATE note_sequences
+SET seq = seq + 1
+WHERE user_id = ?;
+
+-- name: RaisePurgedSeq :exec
+UPDATE note_sequences
+SET purged_seq = sqlc.arg(purged_seq)
+WHERE user_id = sqlc.arg(user_id)
+  AND purged_seq < sqlc.arg(purged_seq);
@@ -0,0 +1,41 @@
+-- name: CreateNoteShare :exec
+INSERT INTO note_shares (note_id, user_id, role, created_at, updated_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+-- name: UpdateNoteShare :execrows
+UPDATE note_shares
+SET role       = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE note_id = ?
+  AND user_id = ?;
+
+-- name: FindNoteShare :one
+SELECT *
+FROM note_shares
+WHERE note_id = ?
+  AND user_id = ?;
+
+-- name: FindNoteShares :many
+SELECT *
+FROM note_shares
+WHERE note_id = ?
+ORDER BY created_at, user_id;
+
+-- name: DeleteNoteShare :execrows
+DELETE
+FROM note_shares
+WHERE note_id = ?
+  AND user_id = ?;
+
+-- name: DeleteNoteShares :exec
+DELETE
+FROM note_shares
+WHERE note_id = ?;
+
+-- name: PurgeDeletedNoteShares :exec
+DELETE
+FROM note_shares
+WHERE note_id IN (SELECT note_id
+                  FROM notes
+                  WHERE deleted_at IS NOT NULL
+                    AND deleted_at < ?);
@@ -0,0 +1,32 @@
+-- name: CreateNotebook :exec
+INSERT INTO notebooks (notebook_id, user_id, parent_id, name, created_at, updated_at)
+VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+-- name: FindNotebookByNotebookID :one
+SELECT *
+FROM notebooks
+WHERE notebook_id = ?;
+
+-- name: FindNotebooksByUserID :many
+SELECT *
+FROM notebooks
+WHERE user_id = ?
+ORDER BY name, notebook_id;
+
+-- name: UpdateNotebook :execrows
+UPDATE notebooks
+SET name       = ?,
+    parent_id  = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE notebook_id = ?;
+
+-- name: ReparentNotebooks :exec
+UPDATE notebooks
+SET parent_id  = sqlc.narg(to_parent_id),
+    updated_at = CURRENT_TIMESTAMP
+WHERE parent_id = sqlc.narg(from_parent_id);
+
+-- name: DeleteNotebooks :exec
+DELETE
+FROM notebooks
+WHERE notebook_id IN (sqlc.slice(notebook_ids));
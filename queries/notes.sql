@@ -1,40 +1,103 @@
--- name: FindAllNotes :many
+-- name: FindNoteByNoteID :one
 SELECT *
 FROM notes
-WHERE deleted_at IS NULL;
+WHERE note_id = ?
+  AND deleted_at IS NULL;
 
--- name: FindNote :one
+-- name: FindNoteByNoteIDWithDeleted :one
 SELECT *
 FROM notes
-WHERE id = ?
-  AND deleted_at IS NULL;
+WHERE note_id = ?;
 
--- name: FindNoteByTitle :one
+-- name: FindNoteChanges :many
 SELECT *
 FROM notes
-WHERE title = ?
-  AND deleted_at IS NULL;
+WHERE user_id = ?
+  AND seq > ?
+ORDER BY seq, id;
+
+-- name: FindDeletedNoteSeqs :many
+SELECT user_id, CAST(MAX(seq) AS SIGNED) AS seq
+FROM notes
+WHERE deleted_at IS NOT NULL
+  AND deleted_at < ?
+GROUP BY user_id;
 
--- name: FindNoteByIDs :many
+-- name: FindLiveNotesAfterID :many
 SELECT *
 FROM notes
-WHERE id IN (?)
-  AND deleted_at IS NULL;
+WHERE id > ?
+  AND deleted_at IS NULL
+ORDER BY id
+LIMIT ?;
+
+-- name: FindNotesChangedAfterID :many
+SELECT *
+FROM notes
+WHERE id > sqlc.arg(id)
+  AND (updated_at >= sqlc.arg(since) OR deleted_at >= sqlc.arg(since))
+ORDER BY id
+LIMIT ?;
 
 -- name: CreateNote :exec
-INSERT INTO notes (note_id,title, content, user_id, created_at, updated_at)
-VALUES (?,?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+INSERT INTO notes (note_id,title, content, content_type, notebook_id, user_id, seq, created_at, updated_at)
+VALUES (?,?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+-- name: UpdateNoteByNoteID :execrows
+UPDATE notes
+SET title        = ?,
+    content      = ?,
+    content_type = ?,
+    notebook_id  = ?,
+    version      = version + 1,
+    seq          = ?,
+    updated_at   = CURRENT_TIMESTAMP
+WHERE note_id = ?
+  AND version = ?
+  AND deleted_at IS NULL;
 
--- name: UpdateNote :exec
+-- name: DeleteNoteByNoteID :execrows
 UPDATE notes
-SET title      = ?,
-    content    = ?,
-    updated_at = now()
-WHERE id = ?
+SET deleted_at = CURRENT_TIMESTAMP,
+    version    = version + 1,
+    seq        = ?
+WHERE note_id = ?
+  AND version = ?
   AND deleted_at IS NULL;
 
--- name: DeleteNote :exec
+-- name: RestoreNoteByNoteID :execrows
+UPDATE notes
+SET deleted_at = NULL,
+    version    = version + 1,
+    seq        = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE note_id = ?
+  AND deleted_at IS NOT NULL;
+
+-- name: PurgeNoteByNoteID :execrows
+DELETE
+FROM notes
+WHERE note_id = ?
+  AND version = ?;
+
+-- name: PurgeDeletedNotes :execrows
+DELETE
+FROM notes
+WHERE deleted_at IS NOT NULL
+  AND deleted_at < ?;
+
+-- name: MoveNotesToNotebook :exec
+UPDATE notes
+SET notebook_id = sqlc.narg(to_notebook_id),
+    version     = version + 1,
+    seq         = sqlc.arg(seq),
+    updated_at  = CURRENT_TIMESTAMP
+WHERE notebook_id = sqlc.narg(from_notebook_id);
+
+-- name: TrashNotesByNotebookIDs :exec
 UPDATE notes
-SET deleted_at = now()
-WHERE id = ?
-  AND deleted_at IS NULL;
\ No newline at end of file
+SET notebook_id = NULL,
+    deleted_at  = COALESCE(deleted_at, CURRENT_TIMESTAMP),
+    version     = version + 1,
+    seq         = sqlc.arg(seq)
+WHERE notebook_id IN (sqlc.slice(notebook_ids));
@@ -0,0 +1,14 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox (event_id, user_id, event_type, payload, created_at)
+VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: FindOutboxEvents :many
+SELECT *
+FROM outbox
+ORDER BY id
+LIMIT ?;
+
+-- name: DeleteOutboxEvent :exec
+DELETE
+FROM outbox
+WHERE id = ?;
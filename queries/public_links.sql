@@ -0,0 +1,44 @@
+-- name: CreatePublicLink :exec
+INSERT INTO public_links (link_id, note_id, token_hash, password_hash, expires_at, max_views, created_at)
+VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: FindPublicLink :one
+SELECT *
+FROM public_links
+WHERE link_id = ?;
+
+-- name: FindPublicLinkByHash :one
+SELECT *
+FROM public_links
+WHERE token_hash = ?;
+
+-- name: FindPublicLinksByNoteID :many
+SELECT *
+FROM public_links
+WHERE note_id = ?
+ORDER BY created_at, id;
+
+-- name: ViewPublicLink :execrows
+UPDATE public_links
+SET views = views + 1
+WHERE link_id = ?
+  AND (max_views IS NULL OR views < max_views);
+
+-- name: DeletePublicLink :execrows
+DELETE
+FROM public_links
+WHERE link_id = ?
+  AND note_id = ?;
+
+-- name: DeletePublicLinks :exec
+DELETE
+FROM public_links
+WHERE note_id = ?;
+
+-- name: PurgeDeletedPublicLinks :exec
+DELETE
+FROM public_links
+WHERE note_id IN (SELECT note_id
+                  FROM notes
+                  WHERE deleted_at IS NOT NULL
+                    AND deleted_at < ?);
@@ -0,0 +1,58 @@
+-- name: CreateReminder :exec
+INSERT INTO reminders (reminder_id, note_id, user_id, remind_at, recurrence, channel, status, next_run_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: FindReminder :one
+SELECT *
+FROM reminders
+WHERE reminder_id = ?;
+
+-- name: FindRemindersByUserID :many
+SELECT *
+FROM reminders
+WHERE user_id = ?
+ORDER BY remind_at, id;
+
+-- name: FindRemindersByNoteID :many
+SELECT *
+FROM reminders
+WHERE user_id = ?
+  AND note_id = ?
+ORDER BY remind_at, id;
+
+-- name: FindDueReminders :many
+SELECT *
+FROM reminders
+WHERE status = 'active'
+  AND next_run_at <= ?
+ORDER BY next_run_at, id
+LIMIT ?;
+
+-- name: LockDueReminders :many
+SELECT *
+FROM reminders
+WHERE status = 'active'
+  AND next_run_at <= ?
+ORDER BY next_run_at, id
+LIMIT ? FOR UPDATE SKIP LOCKED;
+
+-- name: ClaimReminder :exec
+UPDATE reminders
+SET next_run_at = ?
+WHERE reminder_id = ?;
+
+-- name: UpdateReminder :exec
+UPDATE reminders
+SET remind_at   = ?,
+    status      = ?,
+    next_run_at = ?,
+    attempts    = ?,
+    last_error  = ?,
+    fired_at    = ?
+WHERE reminder_id = ?;
+
+-- name: DeleteReminder :execrows
+DELETE
+FROM reminders
+WHERE reminder_id = ?
+  AND user_id = ?;
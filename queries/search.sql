@@ -0,0 +1,25 @@
+-- name: SearchNotesNatural :many
+SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id,
+       MATCH (title, content) AGAINST (sqlc.arg(query) IN NATURAL LANGUAGE MODE) AS score,
+       COUNT(*) OVER () AS total
+FROM notes
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND MATCH (title, content) AGAINST (sqlc.arg(query) IN NATURAL LANGUAGE MODE)
+  AND (sqlc.narg(created_after) IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before) IS NULL OR created_at < sqlc.narg(created_before))
+ORDER BY score DESC, id
+LIMIT ?;
+
+-- name: SearchNotesBoolean :many
+SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id,
+       MATCH (title, content) AGAINST (sqlc.arg(query) IN BOOLEAN MODE) AS score,
+       COUNT(*) OVER () AS total
+FROM notes
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND MATCH (title, content) AGAINST (sqlc.arg(query) IN BOOLEAN MODE)
+  AND (sqlc.narg(created_after) IS NULL OR created_at >= sqlc.narg(created_after))
+  AND (sqlc.narg(created_before) IS NULL OR created_at < sqlc.narg(created_before))
+ORDER BY score DESC, id
+LIMIT ?;
@@ -0,0 +1,77 @@
+-- name: CreateTag :execlastid
+INSERT INTO tags (user_id, name, created_at)
+VALUES (?, ?, CURRENT_TIMESTAMP);
+
+-- name: FindTagByName :one
+SELECT *
+FROM tags
+WHERE user_id = ?
+  AND name = ?;
+
+-- name: FindTagCounts :many
+SELECT tags.name, COUNT(notes.id) AS notes
+FROM tags
+         LEFT JOIN note_tags ON note_tags.tag_id = tags.id
+         LEFT JOIN notes ON notes.note_id = note_tags.note_id AND notes.deleted_at IS NULL
+WHERE tags.user_id = ?
+GROUP BY tags.id, tags.name
+ORDER BY tags.name;
+
+-- name: FindTagsByNoteIDs :many
+SELECT note_tags.note_id, tags.name
+FROM note_tags
+         JOIN tags ON tags.id = note_tags.tag_id
+WHERE note_tags.note_id IN (sqlc.slice(note_ids))
+ORDER BY note_tags.note_id, tags.name;
+
+-- name: RenameTag :exec
+UPDATE tags
+SET name = ?
+WHERE id = ?;
+
+-- name: DeleteTag :exec
+DELETE
+FROM tags
+WHERE id = ?;
+
+-- name: DeleteUnusedTags :exec
+DELETE
+FROM tags
+WHERE user_id = ?
+  AND id NOT IN (SELECT tag_id FROM note_tags);
+
+-- name: CreateNoteTag :exec
+INSERT INTO note_tags (note_id, tag_id)
+VALUES (?, ?);
+
+-- name: DeleteNoteTags :exec
+DELETE
+FROM note_tags
+WHERE note_id = ?;
+
+-- name: DeleteNoteTagsByTagID :exec
+DELETE
+FROM note_tags
+WHERE tag_id = ?;
+
+-- name: MergeNoteTags :exec
+INSERT INTO note_tags (note_id, tag_id)
+SELECT note_id, sqlc.arg(into_id)
+FROM note_tags
+WHERE tag_id = sqlc.arg(from_id)
+  AND note_id NOT IN (SELECT note_id FROM note_tags WHERE tag_id = sqlc.arg(into_id));
+
+-- name: PurgeDeletedNoteTags :exec
+DELETE
+FROM note_tags
+WHERE note_id IN (SELECT note_id
+                  FROM notes
+                  WHERE deleted_at IS NOT NULL
+                    AND deleted_at < ?);
+
+-- name: TouchNotesByTagID :exec
+UPDATE notes
+SET version    = version + 1,
+    seq        = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE note_id IN (SELECT note_id FROM note_tags WHERE tag_id = ?);
@@ -0,0 +1,18 @@
+-- name: CreateUser :execlastid
+INSERT INTO users (user_id, email, password_hash, created_at, updated_at)
+VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+-- name: FindUser :one
+SELECT *
+FROM users
+WHERE id = ?;
+
+-- name: FindUserByUserID :one
+SELECT *
+FROM users
+WHERE user_id = ?;
+
+-- name: FindUserByEmail :one
+SELECT *
+FROM users
+WHERE email = ?;
@@ -0,0 +1,77 @@
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (delivery_id, webhook_id, event_id, event_type, payload, status, next_attempt_at,
+                                created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+-- name: FindWebhookDeliveryByEventID :one
+SELECT *
+FROM webhook_deliveries
+WHERE webhook_id = ?
+  AND event_id = ?;
+
+-- name: FindDueWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE status = 'pending'
+  AND next_attempt_at <= ?
+ORDER BY next_attempt_at, id
+LIMIT ?;
+
+-- name: FindWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE webhook_id = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: FindWebhookDeliveriesByStatus :many
+SELECT *
+FROM webhook_deliveries
+WHERE webhook_id = ?
+  AND status = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: ClaimWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET attempts        = attempts + 1,
+    next_attempt_at = ?,
+    updated_at      = CURRENT_TIMESTAMP
+WHERE delivery_id = ?
+  AND status = 'pending'
+  AND attempts = ?;
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status          = ?,
+    next_attempt_at = ?,
+    last_error      = ?,
+    response_status = ?,
+    updated_at      = CURRENT_TIMESTAMP
+WHERE delivery_id = ?;
+
+-- name: ReplayWebhookDeliveries :execrows
+UPDATE webhook_deliveries
+SET status          = 'pending',
+    attempts        = 0,
+    next_attempt_at = ?,
+    last_error      = '',
+    updated_at      = CURRENT_TIMESTAMP
+WHERE webhook_id = ?
+  AND status = 'failed';
+
+-- name: ReplayWebhookDeliveriesByIDs :execrows
+UPDATE webhook_deliveries
+SET status          = 'pending',
+    attempts        = 0,
+    next_attempt_at = ?,
+    last_error      = '',
+    updated_at      = CURRENT_TIMESTAMP
+WHERE webhook_id = ?
+  AND status = 'failed'
+  AND delivery_id IN (sqlc.slice(delivery_ids));
+
+-- name: DeleteWebhookDeliveries :exec
+DELETE
+FROM webhook_deliveries
+WHERE webhook_id = ?;
@@ -0,0 +1,20 @@
+-- name: CreateWebhook :exec
+INSERT INTO webhooks (webhook_id, user_id, url, secret, created_at)
+VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: FindWebhook :one
+SELECT *
+FROM webhooks
+WHERE webhook_id = ?;
+
+-- name: FindWebhooksByUserID :many
+SELECT *
+FROM webhooks
+WHERE user_id = ?
+ORDER BY created_at, id;
+
+-- name: DeleteWebhook :execrows
+DELETE
+FROM webhooks
+WHERE webhook_id = ?
+  AND user_id = ?;
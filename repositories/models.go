@@ -6,15 +6,162 @@ package repositories
 
 import (
 	"database/sql"
+	"time"
 )
 
-type Note struct {
+type ApiToken struct {
+	ID         int64
+	TokenID    string
+	UserID     string
+	Name       string
+	TokenHash  string
+	Scopes     string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	CreatedAt  sql.NullTime
+}
+
+type Comment struct {
 	ID        int64
+	CommentID string
 	NoteID    string
-	Title     string
-	Content   string
 	UserID    string
+	ParentID  sql.NullString
+	Body      string
+	Resolved  bool
 	CreatedAt sql.NullTime
 	UpdatedAt sql.NullTime
 	DeletedAt sql.NullTime
 }
+
+type Note struct {
+	ID          int64
+	NoteID      string
+	Title       string
+	Content     string
+	UserID      string
+	CreatedAt   sql.NullTime
+	UpdatedAt   sql.NullTime
+	DeletedAt   sql.NullTime
+	ContentType string
+	Version     int32
+	NotebookID  sql.NullString
+	Seq         int64
+}
+
+type NoteRevision struct {
+	ID          int64
+	NoteID      string
+	Revision    int32
+	Title       string
+	Content     string
+	ContentType string
+	CreatedAt   sql.NullTime
+}
+
+type NoteSequence struct {
+	UserID    string
+	Seq       int64
+	PurgedSeq int64
+}
+
+type NoteShare struct {
+	NoteID    string
+	UserID    string
+	Role      string
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
+}
+
+type NoteTag struct {
+	NoteID string
+	TagID  int64
+}
+
+type Notebook struct {
+	ID         int64
+	NotebookID string
+	UserID     string
+	ParentID   sql.NullString
+	Name       string
+	CreatedAt  sql.NullTime
+	UpdatedAt  sql.NullTime
+}
+
+type Outbox struct {
+	ID        int64
+	EventID   string
+	UserID    string
+	EventType string
+	Payload   string
+	CreatedAt sql.NullTime
+}
+
+type PublicLink struct {
+	ID           int64
+	LinkID       string
+	NoteID       string
+	TokenHash    string
+	PasswordHash sql.NullString
+	ExpiresAt    sql.NullTime
+	MaxViews     sql.NullInt32
+	Views        int32
+	CreatedAt    sql.NullTime
+}
+
+type Reminder struct {
+	ID         int64
+	ReminderID string
+	NoteID     string
+	UserID     string
+	RemindAt   time.Time
+	Recurrence string
+	Channel    string
+	Status     string
+	NextRunAt  time.Time
+	Attempts   int32
+	LastError  string
+	FiredAt    sql.NullTime
+	CreatedAt  sql.NullTime
+}
+
+type Tag struct {
+	ID        int64
+	UserID    string
+	Name      string
+	CreatedAt sql.NullTime
+}
+
+type User struct {
+	ID           int64
+	UserID       string
+	Email        string
+	PasswordHash string
+	CreatedAt    sql.NullTime
+	UpdatedAt    sql.NullTime
+}
+
+type Webhook struct {
+	ID        int64
+	WebhookID string
+	UserID    string
+	Url       string
+	Secret    string
+	CreatedAt sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	DeliveryID     string
+	WebhookID      string
+	EventID        string
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  sql.NullTime
+	LastError      string
+	ResponseStatus int32
+	CreatedAt      sql.NullTime
+	UpdatedAt      sql.NullTime
+}
@@ -7,18 +7,23 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"strings"
 )
 
 const createNote = `-- name: CreateNote :exec
-INSERT INTO notes (note_id,title, content, user_id, created_at, updated_at)
-VALUES (?,?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+INSERT INTO notes (note_id,title, content, content_type, notebook_id, user_id, seq, created_at, updated_at)
+VALUES (?,?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 `
 
 type CreateNoteParams struct {
-	NoteID  string
-	Title   string
-	Content string
-	UserID  string
+	NoteID      string
+	Title       string
+	Content     string
+	ContentType string
+	NotebookID  sql.NullString
+	UserID      string
+	Seq         int64
 }
 
 func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) error {
@@ -26,31 +31,90 @@ func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) error {
 		arg.NoteID,
 		arg.Title,
 		arg.Content,
+		arg.ContentType,
+		arg.NotebookID,
 		arg.UserID,
+		arg.Seq,
 	)
 	return err
 }
 
-const deleteNote = `-- name: DeleteNote :exec
+const deleteNoteByNoteID = `-- name: DeleteNoteByNoteID :execrows
 UPDATE notes
-SET deleted_at = now()
-WHERE id = ?
+SET deleted_at = CURRENT_TIMESTAMP,
+    version    = version + 1,
+    seq        = ?
+WHERE note_id = ?
+  AND version = ?
   AND deleted_at IS NULL
 `
 
-func (q *Queries) DeleteNote(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteNote, id)
-	return err
+type DeleteNoteByNoteIDParams struct {
+	Seq     int64
+	NoteID  string
+	Version int32
+}
+
+func (q *Queries) DeleteNoteByNoteID(ctx context.Context, arg DeleteNoteByNoteIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteNoteByNoteID, arg.Seq, arg.NoteID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const findAllNotes = `-- name: FindAllNotes :many
-SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at
+const findDeletedNoteSeqs = `-- name: FindDeletedNoteSeqs :many
+SELECT user_id, CAST(MAX(seq) AS SIGNED) AS seq
 FROM notes
-WHERE deleted_at IS NULL
+WHERE deleted_at IS NOT NULL
+  AND deleted_at < ?
+GROUP BY user_id
 `
 
-func (q *Queries) FindAllNotes(ctx context.Context) ([]Note, error) {
-	rows, err := q.db.QueryContext(ctx, findAllNotes)
+type FindDeletedNoteSeqsRow struct {
+	UserID string
+	Seq    int64
+}
+
+func (q *Queries) FindDeletedNoteSeqs(ctx context.Context, deletedAt sql.NullTime) ([]FindDeletedNoteSeqsRow, error) {
+	rows, err := q.db.QueryContext(ctx, findDeletedNoteSeqs, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindDeletedNoteSeqsRow
+	for rows.Next() {
+		var i FindDeletedNoteSeqsRow
+		if err := rows.Scan(&i.UserID, &i.Seq); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findLiveNotesAfterID = `-- name: FindLiveNotesAfterID :many
+SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id, seq
+FROM notes
+WHERE id > ?
+  AND deleted_at IS NULL
+ORDER BY id
+LIMIT ?
+`
+
+type FindLiveNotesAfterIDParams struct {
+	ID    int64
+	Limit int32
+}
+
+func (q *Queries) FindLiveNotesAfterID(ctx context.Context, arg FindLiveNotesAfterIDParams) ([]Note, error) {
+	rows, err := q.db.QueryContext(ctx, findLiveNotesAfterID, arg.ID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +131,10 @@ func (q *Queries) FindAllNotes(ctx context.Context) ([]Note, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.ContentType,
+			&i.Version,
+			&i.NotebookID,
+			&i.Seq,
 		); err != nil {
 			return nil, err
 		}
@@ -81,15 +149,15 @@ func (q *Queries) FindAllNotes(ctx context.Context) ([]Note, error) {
 	return items, nil
 }
 
-const findNote = `-- name: FindNote :one
-SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at
+const findNoteByNoteID = `-- name: FindNoteByNoteID :one
+SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id, seq
 FROM notes
-WHERE id = ?
+WHERE note_id = ?
   AND deleted_at IS NULL
 `
 
-func (q *Queries) FindNote(ctx context.Context, id int64) (Note, error) {
-	row := q.db.QueryRowContext(ctx, findNote, id)
+func (q *Queries) FindNoteByNoteID(ctx context.Context, noteID string) (Note, error) {
+	row := q.db.QueryRowContext(ctx, findNoteByNoteID, noteID)
 	var i Note
 	err := row.Scan(
 		&i.ID,
@@ -100,19 +168,55 @@ func (q *Queries) FindNote(ctx context.Context, id int64) (Note, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.ContentType,
+		&i.Version,
+		&i.NotebookID,
+		&i.Seq,
 	)
 	return i, err
 }
 
-const findNoteByIDs = `-- name: FindNoteByIDs :many
-SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at
+const findNoteByNoteIDWithDeleted = `-- name: FindNoteByNoteIDWithDeleted :one
+SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id, seq
 FROM notes
-WHERE id IN (?)
-  AND deleted_at IS NULL
+WHERE note_id = ?
 `
 
-func (q *Queries) FindNoteByIDs(ctx context.Context, id int64) ([]Note, error) {
-	rows, err := q.db.QueryContext(ctx, findNoteByIDs, id)
+func (q *Queries) FindNoteByNoteIDWithDeleted(ctx context.Context, noteID string) (Note, error) {
+	row := q.db.QueryRowContext(ctx, findNoteByNoteIDWithDeleted, noteID)
+	var i Note
+	err := row.Scan(
+		&i.ID,
+		&i.NoteID,
+		&i.Title,
+		&i.Content,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.ContentType,
+		&i.Version,
+		&i.NotebookID,
+		&i.Seq,
+	)
+	return i, err
+}
+
+const findNoteChanges = `-- name: FindNoteChanges :many
+SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id, seq
+FROM notes
+WHERE user_id = ?
+  AND seq > ?
+ORDER BY seq, id
+`
+
+type FindNoteChangesParams struct {
+	UserID string
+	Seq    int64
+}
+
+func (q *Queries) FindNoteChanges(ctx context.Context, arg FindNoteChangesParams) ([]Note, error) {
+	rows, err := q.db.QueryContext(ctx, findNoteChanges, arg.UserID, arg.Seq)
 	if err != nil {
 		return nil, err
 	}
@@ -129,6 +233,10 @@ func (q *Queries) FindNoteByIDs(ctx context.Context, id int64) ([]Note, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.ContentType,
+			&i.Version,
+			&i.NotebookID,
+			&i.Seq,
 		); err != nil {
 			return nil, err
 		}
@@ -143,45 +251,206 @@ func (q *Queries) FindNoteByIDs(ctx context.Context, id int64) ([]Note, error) {
 	return items, nil
 }
 
-const findNoteByTitle = `-- name: FindNoteByTitle :one
-SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at
+const findNotesChangedAfterID = `-- name: FindNotesChangedAfterID :many
+SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id, seq
 FROM notes
-WHERE title = ?
-  AND deleted_at IS NULL
+WHERE id > ?
+  AND (updated_at >= ? OR deleted_at >= ?)
+ORDER BY id
+LIMIT ?
 `
 
-func (q *Queries) FindNoteByTitle(ctx context.Context, title string) (Note, error) {
-	row := q.db.QueryRowContext(ctx, findNoteByTitle, title)
-	var i Note
-	err := row.Scan(
-		&i.ID,
-		&i.NoteID,
-		&i.Title,
-		&i.Content,
-		&i.UserID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.DeletedAt,
+type FindNotesChangedAfterIDParams struct {
+	ID    int64
+	Since sql.NullTime
+	Limit int32
+}
+
+func (q *Queries) FindNotesChangedAfterID(ctx context.Context, arg FindNotesChangedAfterIDParams) ([]Note, error) {
+	rows, err := q.db.QueryContext(ctx, findNotesChangedAfterID,
+		arg.ID,
+		arg.Since,
+		arg.Since,
+		arg.Limit,
 	)
-	return i, err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Note
+	for rows.Next() {
+		var i Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.NoteID,
+			&i.Title,
+			&i.Content,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.ContentType,
+			&i.Version,
+			&i.NotebookID,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const updateNote = `-- name: UpdateNote :exec
+const moveNotesToNotebook = `-- name: MoveNotesToNotebook :exec
 UPDATE notes
-SET title      = ?,
-    content    = ?,
-    updated_at = now()
-WHERE id = ?
-  AND deleted_at IS NULL
+SET notebook_id = ?,
+    version     = version + 1,
+    seq         = ?,
+    updated_at  = CURRENT_TIMESTAMP
+WHERE notebook_id = ?
 `
 
-type UpdateNoteParams struct {
-	Title   string
-	Content string
-	ID      int64
+type MoveNotesToNotebookParams struct {
+	ToNotebookID   sql.NullString
+	Seq            int64
+	FromNotebookID sql.NullString
 }
 
-func (q *Queries) UpdateNote(ctx context.Context, arg UpdateNoteParams) error {
-	_, err := q.db.ExecContext(ctx, updateNote, arg.Title, arg.Content, arg.ID)
+func (q *Queries) MoveNotesToNotebook(ctx context.Context, arg MoveNotesToNotebookParams) error {
+	_, err := q.db.ExecContext(ctx, moveNotesToNotebook, arg.ToNotebookID, arg.Seq, arg.FromNotebookID)
 	return err
 }
+
+const purgeDeletedNotes = `-- name: PurgeDeletedNotes :execrows
+DELETE
+FROM notes
+WHERE deleted_at IS NOT NULL
+  AND deleted_at < ?
+`
+
+func (q *Queries) PurgeDeletedNotes(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedNotes, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeNoteByNoteID = `-- name: PurgeNoteByNoteID :execrows
+DELETE
+FROM notes
+WHERE note_id = ?
+  AND version = ?
+`
+
+type PurgeNoteByNoteIDParams struct {
+	NoteID  string
+	Version int32
+}
+
+func (q *Queries) PurgeNoteByNoteID(ctx context.Context, arg PurgeNoteByNoteIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeNoteByNoteID, arg.NoteID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreNoteByNoteID = `-- name: RestoreNoteByNoteID :execrows
+UPDATE notes
+SET deleted_at = NULL,
+    version    = version + 1,
+    seq        = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE note_id = ?
+  AND deleted_at IS NOT NULL
+`
+
+type RestoreNoteByNoteIDParams struct {
+	Seq    int64
+	NoteID string
+}
+
+func (q *Queries) RestoreNoteByNoteID(ctx context.Context, arg RestoreNoteByNoteIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreNoteByNoteID, arg.Seq, arg.NoteID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const trashNotesByNotebookIDs = `-- name: TrashNotesByNotebookIDs :exec
+UPDATE notes
+SET notebook_id = NULL,
+    deleted_at  = COALESCE(deleted_at, CURRENT_TIMESTAMP),
+    version     = version + 1,
+    seq         = ?
+WHERE notebook_id IN (/*SLICE:notebook_ids*/?)
+`
+
+type TrashNotesByNotebookIDsParams struct {
+	Seq         int64
+	NotebookIds []sql.NullString
+}
+
+func (q *Queries) TrashNotesByNotebookIDs(ctx context.Context, arg TrashNotesByNotebookIDsParams) error {
+	query := trashNotesByNotebookIDs
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.Seq)
+	if len(arg.NotebookIds) > 0 {
+		for _, v := range arg.NotebookIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:notebook_ids*/?", strings.Repeat(",?", len(arg.NotebookIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:notebook_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const updateNoteByNoteID = `-- name: UpdateNoteByNoteID :execrows
+UPDATE notes
+SET title        = ?,
+    content      = ?,
+    content_type = ?,
+    notebook_id  = ?,
+    version      = version + 1,
+    seq          = ?,
+    updated_at   = CURRENT_TIMESTAMP
+WHERE note_id = ?
+  AND version = ?
+  AND deleted_at IS NULL
+`
+
+type UpdateNoteByNoteIDParams struct {
+	Title       string
+	Content     string
+	ContentType string
+	NotebookID  sql.NullString
+	Seq         int64
+	NoteID      string
+	Version     int32
+}
+
+func (q *Queries) UpdateNoteByNoteID(ctx context.Context, arg UpdateNoteByNoteIDParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateNoteByNoteID,
+		arg.Title,
+		arg.Content,
+		arg.ContentType,
+		arg.NotebookID,
+		arg.Seq,
+		arg.NoteID,
+		arg.Version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
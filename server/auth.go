@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"notes/services/entities"
+	"notes/services/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	userKey  = "user"
+	tokenKey = "api_token"
+)
+
+// UserService describes the account operations the server depends on
+type UserService interface {
+	Signup(ctx context.Context, req entities.SignupReq) (entities.AuthToken, error)
+	Login(ctx context.Context, req entities.LoginReq) (entities.AuthToken, error)
+	Authenticate(ctx context.Context, token string) (entities.User, error)
+}
+
+// TokenService describes the API token operations the server depends on
+type TokenService interface {
+	CreateToken(ctx context.Context, userID string, req entities.APITokenReq) (entities.APIToken, error)
+	ListTokens(ctx context.Context, userID string) ([]entities.APIToken, error)
+	RevokeToken(ctx context.Context, userID, id string) error
+	Authenticate(ctx context.Context, raw string) (entities.APIToken, error)
+}
+
+// authenticate rejects requests without a valid session or API token and places the user in the context.
+// Requests made with an API token also carry the token so its scopes can be checked.
+func (s *Server) authenticate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		raw, ok := bearerToken(ctx.Request)
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "missing bearer token",
+			})
+			return
+		}
+
+		if tokens.IsAPIToken(raw) {
+			token, err := s.tokens.Authenticate(ctx.Request.Context(), raw)
+			if err != nil {
+				handleError(ctx, err)
+				ctx.Abort()
+				return
+			}
+			ctx.Set(tokenKey, token)
+			ctx.Set(userKey, entities.User{ID: token.UserID})
+			ctx.Next()
+			return
+		}
+
+		user, err := s.users.Authenticate(ctx.Request.Context(), raw)
+		if err != nil {
+			handleError(ctx, err)
+			ctx.Abort()
+			return
+		}
+		ctx.Set(userKey, user)
+		ctx.Next()
+	}
+}
+
+// requireScope rejects requests made with an API token that was not granted the scope.
+// Session tokens carry every scope.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !hasScope(ctx, scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "token is missing the " + scope + " scope",
+			})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// requireSession rejects requests made with an API token, so tokens cannot be used to mint more tokens
+func requireSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := apiToken(ctx); ok {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "this endpoint requires a session token",
+			})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// queryToken uses the access_token query parameter as the bearer token of requests
+// without an Authorization header, for clients such as browser websockets that cannot set one
+func queryToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if token := ctx.Query("access_token"); token != "" && ctx.GetHeader("Authorization") == "" {
+			ctx.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		ctx.Next()
+	}
+}
+
+// hasScope reports whether the request may act with the scope, session tokens carry every scope
+func hasScope(ctx *gin.Context, scope string) bool {
+	token, ok := apiToken(ctx)
+	return !ok || slices.Contains(token.Scopes, scope)
+}
+
+// apiToken returns the API token the request was made with, if any
+func apiToken(ctx *gin.Context) (entities.APIToken, bool) {
+	v, ok := ctx.Get(tokenKey)
+	if !ok {
+		return entities.APIToken{}, false
+	}
+	token, ok := v.(entities.APIToken)
+	return token, ok
+}
+
+// currentUser returns the user placed in the context by the authenticate middleware
+func currentUser(ctx *gin.Context) entities.User {
+	user, _ := ctx.MustGet(userKey).(entities.User)
+	return user
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func (s *Server) signup(ctx *gin.Context) {
+	var req entities.SignupReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	token, err := s.users.Signup(ctx.Request.Context(), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, token)
+}
+
+func (s *Server) login(ctx *gin.Context) {
+	var req entities.LoginReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	token, err := s.users.Login(ctx.Request.Context(), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, token)
+}
+
+func (s *Server) createToken(ctx *gin.Context) {
+	var req entities.APITokenReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	token, err := s.tokens.CreateToken(ctx.Request.Context(), currentUser(ctx).ID, req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, token)
+}
+
+func (s *Server) listTokens(ctx *gin.Context) {
+	result, err := s.tokens.ListTokens(ctx.Request.Context(), currentUser(ctx).ID)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (s *Server) revokeToken(ctx *gin.Context) {
+	if err := s.tokens.RevokeToken(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"notes/services/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag is the entity tag of a note. The version changes on every write, so it is a strong tag.
+func etag(note entities.Note) string {
+	return `"` + strconv.Itoa(note.Version) + `"`
+}
+
+// writeNote responds with the note and its ETag
+func writeNote(ctx *gin.Context, status int, note entities.Note) {
+	ctx.Header("ETag", etag(note))
+	ctx.JSON(status, note)
+}
+
+// ifMatch returns the version named by the If-Match header that a write is
+// conditioned on, zero for "*". Only a single strong ETag is accepted. It
+// writes the error response and returns false when the header is missing or
+// cannot match any version of a note.
+func ifMatch(ctx *gin.Context) (int, bool) {
+	value := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	switch {
+	case value == "":
+		ctx.JSON(http.StatusPreconditionRequired, gin.H{
+			"error": "If-Match header with the ETag of the note is required",
+		})
+		return 0, false
+	case value == "*":
+		return 0, true
+	case strings.Contains(value, ","):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "If-Match must be a single ETag",
+		})
+		return 0, false
+	}
+
+	version, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil || version < 1 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		// weak and foreign tags never match a version of the note
+		ctx.JSON(http.StatusPreconditionFailed, gin.H{
+			"error": "If-Match does not match the note",
+		})
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified responds with 304 Not Modified and returns true when the
+// If-None-Match header names the current ETag of the note. Weak tags are
+// compared by their value, as RFC 9110 asks for GET.
+func notModified(ctx *gin.Context, note entities.Note) bool {
+	value := ctx.GetHeader("If-None-Match")
+	if value == "" {
+		return false
+	}
+	current := etag(note)
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			ctx.Header("ETag", current)
+			ctx.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
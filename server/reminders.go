@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"notes/services/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReminderService describes the reminder operations the server depends on
+type ReminderService interface {
+	CreateReminder(ctx context.Context, userID, noteID string, req entities.ReminderReq) (entities.Reminder, error)
+	ListReminders(ctx context.Context, userID, noteID string) ([]entities.Reminder, error)
+	DeleteReminder(ctx context.Context, userID, noteID, id string) error
+}
+
+func (s *Server) createReminder(ctx *gin.Context) {
+	var req entities.ReminderReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	reminder, err := s.reminders.CreateReminder(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, reminder)
+}
+
+func (s *Server) listReminders(ctx *gin.Context) {
+	result, err := s.reminders.ListReminders(ctx.Request.Context(), currentUser(ctx).ID, "")
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (s *Server) noteReminders(ctx *gin.Context) {
+	result, err := s.reminders.ListReminders(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (s *Server) deleteReminder(ctx *gin.Context) {
+	if err := s.reminders.DeleteReminder(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), ctx.Param("reminder_id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -6,22 +6,115 @@ import (
 	"log/slog"
 	"net/http"
 	"notes/services/entities"
+	"strconv"
 	"time"
 
+	"notes/services/collab"
+	"notes/services/jobs"
+	"notes/services/notes"
+	"notes/services/render"
+	"notes/services/search"
+	"notes/services/tokens"
 	"notes/services/tracing"
+	"notes/services/users"
+	"notes/services/webhooks"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	//"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/net/websocket"
 )
 
-var database map[string]entities.Note
+const (
+	// eventsHeartbeat is how often an idle event stream is kept alive with a comment
+	eventsHeartbeat = 15 * time.Second
+	// maxCollabMessage fits an insert of a whole note of 4 byte characters
+	maxCollabMessage = 5 << 20
+)
+
+// NoteService describes the note operations the server depends on
+type NoteService interface {
+	CreateNote(ctx context.Context, userID string, req entities.NoteReq) (entities.Note, error)
+	GetNotes(ctx context.Context, userID string, query entities.NoteQuery) (entities.NotePage, error)
+	GetNote(ctx context.Context, userID, id string) (entities.Note, error)
+	UpdateNote(ctx context.Context, userID, id string, req entities.NoteReq) (entities.Note, error)
+	PatchNote(ctx context.Context, userID, id string, patch entities.NotePatch) (entities.Note, error)
+	DeleteNote(ctx context.Context, userID, id string, version int) error
+	GetTrash(ctx context.Context, userID string, query entities.NoteQuery) (entities.NotePage, error)
+	RestoreNote(ctx context.Context, userID, id string) (entities.Note, error)
+	PurgeNote(ctx context.Context, userID, id string, version int) error
+	GetRevisions(ctx context.Context, userID, id string) ([]entities.Revision, error)
+	GetRevision(ctx context.Context, userID, id string, revision int) (entities.Revision, error)
+	DiffNote(ctx context.Context, userID, id string, query entities.DiffQuery) (entities.NoteDiff, error)
+	RestoreRevision(ctx context.Context, userID, id string, revision int) (entities.Note, error)
+	ListTags(ctx context.Context, userID string) ([]entities.Tag, error)
+	RenameTag(ctx context.Context, userID, name string, req entities.TagRename) (entities.Tag, error)
+	MergeTags(ctx context.Context, userID string, req entities.TagMerge) (entities.Tag, error)
+	GetNotebooks(ctx context.Context, userID string) ([]entities.Notebook, error)
+	GetNotebook(ctx context.Context, userID, id string) (entities.Notebook, error)
+	CreateNotebook(ctx context.Context, userID string, req entities.NotebookReq) (entities.Notebook, error)
+	RenameNotebook(ctx context.Context, userID, id string, req entities.NotebookRename) (entities.Notebook, error)
+	MoveNotebook(ctx context.Context, userID, id string, req entities.NotebookMove) (entities.Notebook, error)
+	DeleteNotebook(ctx context.Context, userID, id string, query entities.NotebookDelete) error
+	GetShares(ctx context.Context, userID, id string) ([]entities.Share, error)
+	ShareNote(ctx context.Context, userID, id string, req entities.ShareReq) (entities.Share, error)
+	UnshareNote(ctx context.Context, userID, id, collaboratorID string) error
+	GetLinks(ctx context.Context, userID, id string) ([]entities.Link, error)
+	CreateLink(ctx context.Context, userID, id string, req entities.LinkReq) (entities.Link, error)
+	RevokeLink(ctx context.Context, userID, id, linkID string) error
+	OpenLink(ctx context.Context, token, password string) (entities.PublicNote, error)
+	GetComments(ctx context.Context, userID, id string) ([]entities.Comment, error)
+	CreateComment(ctx context.Context, userID, id string, req entities.CommentReq) (entities.Comment, error)
+	EditComment(ctx context.Context, userID, id, commentID string, req entities.CommentEdit) (entities.Comment, error)
+	ResolveComment(ctx context.Context, userID, id, commentID string, resolved bool) (entities.Comment, error)
+	DeleteComment(ctx context.Context, userID, id, commentID string) error
+	Subscribe(ctx context.Context, userID string, lastEventID int64) notes.Subscription
+	GetChanges(ctx context.Context, userID string, since int64) (entities.SyncChanges, error)
+	Sync(ctx context.Context, userID string, req entities.SyncReq) (entities.SyncResponse, error)
+}
+
+// SearchService describes the search operations the server depends on
+type SearchService interface {
+	Search(ctx context.Context, userID string, query entities.SearchQuery) (entities.SearchResults, error)
+}
+
+// CollabService describes the live editing sessions the server hands websockets to
+type CollabService interface {
+	Join(ctx context.Context, userID, noteID string, canWrite bool) (*collab.Participant, error)
+}
+
+// Renderer describes how the server renders note bodies
+type Renderer interface {
+	Render(ctx context.Context, note entities.Note, format string) (string, error)
+}
+
+// Services are the dependencies the server hands requests to
+type Services struct {
+	Notes     NoteService
+	Users     UserService
+	Tokens    TokenService
+	Search    SearchService
+	Collab    CollabService
+	Renderer  Renderer
+	Webhooks  WebhookService
+	Reminders ReminderService
+}
 
 // Server is the server :)
 type Server struct {
-	router *gin.Engine
+	router    *gin.Engine
+	service   NoteService
+	users     UserService
+	tokens    TokenService
+	search    SearchService
+	collab    CollabService
+	renderer  Renderer
+	webhooks  WebhookService
+	reminders ReminderService
 }
 
 func logMiddleware() gin.HandlerFunc {
@@ -32,18 +125,25 @@ func logMiddleware() gin.HandlerFunc {
 	}
 }
 
-// New returns a new
-func New() *Server {
+// New returns a new server that hands requests to the given services
+func New(services Services) *Server {
 	router := gin.New()
 	router.Use(
 		gin.Recovery(),
 		//otelgin.Middleware("notes"),
 		logMiddleware(),
 	)
-	database = make(map[string]entities.Note)
 
 	s := &Server{
-		router: router,
+		router:    router,
+		service:   services.Notes,
+		users:     services.Users,
+		tokens:    services.Tokens,
+		search:    services.Search,
+		collab:    services.Collab,
+		renderer:  services.Renderer,
+		webhooks:  services.Webhooks,
+		reminders: services.Reminders,
 	}
 
 	router.GET("/ping", func(c *gin.Context) {
@@ -64,9 +164,70 @@ func New() *Server {
 		})
 		slog.InfoContext(c.Request.Context(), "all completed!", "time", time.Now().String(), "client", c.ClientIP(), "method", c.Request.Method)
 	})
-	router.POST("/", s.create)
-	router.GET("/", s.all)
-	router.GET("/:id", s.single)
+	router.POST("/signup", s.signup)
+	router.POST("/login", s.login)
+	router.GET("/p/:token", s.publicNote)
+	// browsers cannot set headers on websockets, so the token may come in the query instead
+	router.GET("/:id/collab", queryToken(), s.authenticate(), requireScope(tokens.ScopeNotesRead), s.coedit)
+
+	authorized := router.Group("/", s.authenticate())
+	session := authorized.Group("/tokens", requireSession())
+	session.POST("", s.createToken)
+	session.GET("", s.listTokens)
+	session.DELETE("/:id", s.revokeToken)
+	hooks := authorized.Group("/webhooks", requireSession())
+	hooks.POST("", s.createWebhook)
+	hooks.GET("", s.listWebhooks)
+	hooks.DELETE("/:id", s.deleteWebhook)
+	hooks.POST("/:id/test", s.testWebhook)
+	hooks.GET("/:id/deliveries", s.deliveries)
+	hooks.POST("/:id/replay", s.replayDeliveries)
+
+	read := authorized.Group("/", requireScope(tokens.ScopeNotesRead))
+	read.GET("/", s.all)
+	read.GET("/trash", s.trash)
+	read.GET("/events", s.events)
+	read.GET("/sync", s.changes)
+	read.GET("/search", s.find)
+	read.GET("/tags", s.tags)
+	read.GET("/notebooks", s.notebooks)
+	read.GET("/notebooks/:id", s.notebook)
+	read.GET("/reminders", s.listReminders)
+	read.GET("/:id", s.single)
+	read.GET("/:id/render", s.render)
+	read.GET("/:id/revisions", s.revisions)
+	read.GET("/:id/revisions/:rev", s.revision)
+	read.GET("/:id/diff", s.diff)
+	read.GET("/:id/shares", s.shares)
+	read.GET("/:id/links", s.links)
+	read.GET("/:id/comments", s.comments)
+	read.GET("/:id/reminders", s.noteReminders)
+
+	write := authorized.Group("/", requireScope(tokens.ScopeNotesWrite))
+	write.POST("/", s.create)
+	write.PATCH("/tags/:name", s.renameTag)
+	write.POST("/tags/merge", s.mergeTags)
+	write.POST("/sync", s.sync)
+	write.POST("/notebooks", s.createNotebook)
+	write.PATCH("/notebooks/:id", s.renameNotebook)
+	write.POST("/notebooks/:id/move", s.moveNotebook)
+	write.DELETE("/notebooks/:id", s.deleteNotebook)
+	write.POST("/:id/restore", s.restore)
+	write.POST("/:id/revisions/:rev/restore", s.restoreRevision)
+	write.POST("/:id/shares", s.share)
+	write.DELETE("/:id/shares/:user_id", s.unshare)
+	write.POST("/:id/links", s.createLink)
+	write.DELETE("/:id/links/:link_id", s.revokeLink)
+	write.POST("/:id/comments", s.createComment)
+	write.PATCH("/:id/comments/:comment_id", s.editComment)
+	write.POST("/:id/comments/:comment_id/resolve", s.resolveComment(true))
+	write.POST("/:id/comments/:comment_id/reopen", s.resolveComment(false))
+	write.DELETE("/:id/comments/:comment_id", s.deleteComment)
+	write.POST("/:id/reminders", s.createReminder)
+	write.DELETE("/:id/reminders/:reminder_id", s.deleteReminder)
+	write.PUT("/:id", s.update)
+	write.PATCH("/:id", s.patch)
+	write.DELETE("/:id", s.remove)
 	return s
 }
 
@@ -98,34 +259,687 @@ func (s *Server) create(ctx *gin.Context) {
 	var req entities.NoteReq
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err,
+			"error": err.Error(),
 		})
 		return
 	}
 
-	note := entities.Note{
-		ID:        uuid.NewString(),
-		UserID:    req.UserID,
-		CreatedAt: time.Now(),
-		Title:     req.Title,
-		Content:   req.Content,
+	note, err := s.service.CreateNote(ctx.Request.Context(), currentUser(ctx).ID, req)
+	if err != nil {
+		handleError(ctx, err)
+		return
 	}
-	database[note.ID] = note
-	ctx.JSON(http.StatusCreated, note)
+	writeNote(ctx, http.StatusCreated, note)
 }
 
 func (s *Server) all(ctx *gin.Context) {
-	ctx.JSON(http.StatusOK, database)
+	var query entities.NoteQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, err := s.service.GetNotes(ctx.Request.Context(), currentUser(ctx).ID, query)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (s *Server) trash(ctx *gin.Context) {
+	var query entities.NoteQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, err := s.service.GetTrash(ctx.Request.Context(), currentUser(ctx).ID, query)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// changes returns the caller's notes that changed since the sequence number in since,
+// clients send the seq of the previous response to pick up where they left off
+func (s *Server) changes(ctx *gin.Context) {
+	var since int64
+	if query := ctx.Query("since"); query != "" {
+		seq, err := strconv.ParseInt(query, 10, 64)
+		if err != nil || seq < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "since must be the seq of a previous sync",
+			})
+			return
+		}
+		since = seq
+	}
+
+	changes, err := s.service.GetChanges(ctx.Request.Context(), currentUser(ctx).ID, since)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, changes)
+}
+
+// sync applies the changes a client made while offline, all of them or none
+func (s *Server) sync(ctx *gin.Context) {
+	var req entities.SyncReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response, err := s.service.Sync(ctx.Request.Context(), currentUser(ctx).ID, req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// events streams the changes to the notes the caller can see as Server-Sent Events. After a
+// reconnect clients send the id of the last event they got in Last-Event-ID to resume the stream,
+// a reset event tells them it cannot be resumed and they have to fetch their notes again.
+func (s *Server) events(ctx *gin.Context) {
+	var lastEventID int64
+	if header := ctx.GetHeader("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "Last-Event-ID must be the id of an event",
+			})
+			return
+		}
+		lastEventID = id
+	}
+
+	sub := s.service.Subscribe(ctx.Request.Context(), currentUser(ctx).ID, lastEventID)
+	ctx.Header("Content-Type", sse.ContentType)
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	if sub.Missed {
+		ctx.Render(-1, sse.Event{
+			Id:    strconv.FormatInt(sub.LastEventID, 10),
+			Event: "reset",
+			Data:  gin.H{"error": "the events after Last-Event-ID are no longer available"},
+		})
+	}
+	for _, event := range sub.Replay {
+		sendEvent(ctx, event)
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// the client fell behind, it resumes from Last-Event-ID once it reconnects
+				return
+			}
+			sendEvent(ctx, event)
+		case <-heartbeat.C:
+			if _, err := ctx.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// sendEvent writes the event to the stream in a span that continues the trace of the change
+func sendEvent(ctx *gin.Context, event entities.Event) {
+	c := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.MapCarrier(event.TraceContext))
+	_, span := tracing.Tracer().Start(c, "events.Send")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int64("event_id", event.ID),
+		attribute.String("event_type", event.Type),
+		attribute.String("note_id", event.NoteID),
+	)
+
+	ctx.Render(-1, sse.Event{
+		Id:    strconv.FormatInt(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
+
+// coedit upgrades the request to a websocket taking part in the live editing session of the note.
+// Messages are JSON encoded collab.Message values, a malformed one closes the connection.
+// API tokens without the notes:write scope can only follow the edits.
+func (s *Server) coedit(ctx *gin.Context) {
+	participant, err := s.collab.Join(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), hasScope(ctx, tokens.ScopeNotesWrite))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	defer participant.Leave()
+
+	// the origin is not checked, requests are authenticated with a token rather than cookies
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		conn.MaxPayloadBytes = maxCollabMessage
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// closing the connection once the participant left or fell behind ends the reader below
+			defer conn.Close()
+			for msg := range participant.Messages() {
+				if err := websocket.JSON.Send(conn, msg); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			var msg collab.Message
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				break
+			}
+			participant.Handle(msg)
+		}
+		participant.Leave()
+		<-done
+	}}
+	server.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+func (s *Server) find(ctx *gin.Context) {
+	var query entities.SearchQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, err := s.search.Search(ctx.Request.Context(), currentUser(ctx).ID, query)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
 }
 
 func (s *Server) single(ctx *gin.Context) {
-	id := ctx.Param("id")
-	v, ok := database[id]
+	note, err := s.service.GetNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	if notModified(ctx, note) {
+		return
+	}
+	writeNote(ctx, http.StatusOK, note)
+}
+
+func (s *Server) render(ctx *gin.Context) {
+	note, err := s.service.GetNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+
+	out, err := s.renderer.Render(ctx.Request.Context(), note, ctx.Query("format"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(out))
+}
+
+func (s *Server) update(ctx *gin.Context) {
+	version, ok := ifMatch(ctx)
 	if !ok {
-		ctx.JSON(http.StatusNotFound, gin.H{
-			"error": "key not found",
+		return
+	}
+	var req entities.NoteReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	req.Version = version
+
+	note, err := s.service.UpdateNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeNote(ctx, http.StatusOK, note)
+}
+
+func (s *Server) patch(ctx *gin.Context) {
+	version, ok := ifMatch(ctx)
+	if !ok {
+		return
+	}
+	var req entities.NotePatch
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	req.Version = version
+
+	note, err := s.service.PatchNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeNote(ctx, http.StatusOK, note)
+}
+
+func (s *Server) remove(ctx *gin.Context) {
+	version, ok := ifMatch(ctx)
+	if !ok {
+		return
+	}
+
+	var err error
+	if ctx.Query("permanent") == "true" {
+		err = s.service.PurgeNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), version)
+	} else {
+		err = s.service.DeleteNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), version)
+	}
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *Server) restore(ctx *gin.Context) {
+	note, err := s.service.RestoreNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeNote(ctx, http.StatusOK, note)
+}
+
+func (s *Server) revisions(ctx *gin.Context) {
+	revisions, err := s.service.GetRevisions(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, revisions)
+}
+
+func (s *Server) revision(ctx *gin.Context) {
+	rev, err := strconv.Atoi(ctx.Param("rev"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "revision must be a number",
+		})
+		return
+	}
+
+	revision, err := s.service.GetRevision(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), rev)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, revision)
+}
+
+func (s *Server) diff(ctx *gin.Context) {
+	var query entities.DiffQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	diff, err := s.service.DiffNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), query)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, diff)
+}
+
+func (s *Server) restoreRevision(ctx *gin.Context) {
+	rev, err := strconv.Atoi(ctx.Param("rev"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "revision must be a number",
+		})
+		return
+	}
+
+	note, err := s.service.RestoreRevision(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), rev)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	writeNote(ctx, http.StatusOK, note)
+}
+
+func (s *Server) shares(ctx *gin.Context) {
+	shares, err := s.service.GetShares(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, shares)
+}
+
+func (s *Server) share(ctx *gin.Context) {
+	var req entities.ShareReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	share, err := s.service.ShareNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, share)
+}
+
+func (s *Server) unshare(ctx *gin.Context) {
+	if err := s.service.UnshareNote(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), ctx.Param("user_id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *Server) links(ctx *gin.Context) {
+	links, err := s.service.GetLinks(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, links)
+}
+
+func (s *Server) createLink(ctx *gin.Context) {
+	var req entities.LinkReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	link, err := s.service.CreateLink(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, link)
+}
+
+func (s *Server) revokeLink(ctx *gin.Context) {
+	if err := s.service.RevokeLink(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), ctx.Param("link_id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// publicNote serves the note behind a public link to anyone holding its token,
+// the password of protected links is sent in the X-Link-Password header
+func (s *Server) publicNote(ctx *gin.Context) {
+	c, span := tracing.Tracer().Start(ctx.Request.Context(), "public.OpenLink")
+	defer span.End()
+	span.SetAttributes(attribute.String("client", ctx.ClientIP()), attribute.String("user_agent", ctx.Request.UserAgent()))
+
+	note, err := s.service.OpenLink(c, ctx.Param("token"), ctx.GetHeader("X-Link-Password"))
+	if err != nil {
+		handleError(ctx, err)
+		span.SetAttributes(attribute.Int("status", ctx.Writer.Status()))
+		return
+	}
+	// the link may be limited or revoked, so shared caches must not keep the note
+	ctx.Header("Cache-Control", "private, no-store")
+	ctx.JSON(http.StatusOK, note)
+	span.SetAttributes(attribute.Int("status", http.StatusOK))
+}
+
+func (s *Server) comments(ctx *gin.Context) {
+	comments, err := s.service.GetComments(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, comments)
+}
+
+func (s *Server) createComment(ctx *gin.Context) {
+	var req entities.CommentReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	comment, err := s.service.CreateComment(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, comment)
+}
+
+func (s *Server) editComment(ctx *gin.Context) {
+	var req entities.CommentEdit
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	comment, err := s.service.EditComment(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), ctx.Param("comment_id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// resolveComment resolves the thread the comment started, or reopens it when resolved is false
+func (s *Server) resolveComment(resolved bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		comment, err := s.service.ResolveComment(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), ctx.Param("comment_id"), resolved)
+		if err != nil {
+			handleError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, comment)
+	}
+}
+
+func (s *Server) deleteComment(ctx *gin.Context) {
+	if err := s.service.DeleteComment(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), ctx.Param("comment_id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *Server) tags(ctx *gin.Context) {
+	tags, err := s.service.ListTags(ctx.Request.Context(), currentUser(ctx).ID)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, tags)
+}
+
+func (s *Server) renameTag(ctx *gin.Context) {
+	var req entities.TagRename
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
 		})
 		return
 	}
-	ctx.JSON(http.StatusOK, v)
+
+	tag, err := s.service.RenameTag(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("name"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, tag)
+}
+
+func (s *Server) mergeTags(ctx *gin.Context) {
+	var req entities.TagMerge
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	tag, err := s.service.MergeTags(ctx.Request.Context(), currentUser(ctx).ID, req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, tag)
+}
+
+func (s *Server) notebooks(ctx *gin.Context) {
+	notebooks, err := s.service.GetNotebooks(ctx.Request.Context(), currentUser(ctx).ID)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, notebooks)
+}
+
+func (s *Server) notebook(ctx *gin.Context) {
+	notebook, err := s.service.GetNotebook(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, notebook)
+}
+
+func (s *Server) createNotebook(ctx *gin.Context) {
+	var req entities.NotebookReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	notebook, err := s.service.CreateNotebook(ctx.Request.Context(), currentUser(ctx).ID, req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, notebook)
+}
+
+func (s *Server) renameNotebook(ctx *gin.Context) {
+	var req entities.NotebookRename
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	notebook, err := s.service.RenameNotebook(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, notebook)
+}
+
+func (s *Server) moveNotebook(ctx *gin.Context) {
+	var req entities.NotebookMove
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	notebook, err := s.service.MoveNotebook(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, notebook)
+}
+
+func (s *Server) deleteNotebook(ctx *gin.Context) {
+	var query entities.NotebookDelete
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := s.service.DeleteNotebook(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), query); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// handleError maps service errors to a status code and writes the response
+func handleError(ctx *gin.Context, err error) {
+	status, message := http.StatusInternalServerError, "internal server error"
+	switch {
+	case errors.Is(err, notes.ErrNotFound), errors.Is(err, notes.ErrTagNotFound), errors.Is(err, notes.ErrNotebookNotFound),
+		errors.Is(err, notes.ErrShareNotFound), errors.Is(err, notes.ErrLinkNotFound), errors.Is(err, notes.ErrCommentNotFound),
+		errors.Is(err, tokens.ErrNotFound), errors.Is(err, webhooks.ErrNotFound), errors.Is(err, jobs.ErrNotFound):
+		status, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, notes.ErrInvalidNote), errors.Is(err, notes.ErrInvalidQuery), errors.Is(err, notes.ErrInvalidNotebook),
+		errors.Is(err, notes.ErrInvalidShare), errors.Is(err, notes.ErrInvalidLink), errors.Is(err, notes.ErrInvalidComment),
+		errors.Is(err, notes.ErrInvalidSync), errors.Is(err, users.ErrInvalidUser), errors.Is(err, tokens.ErrInvalidRequest),
+		errors.Is(err, search.ErrInvalidQuery), errors.Is(err, render.ErrUnsupportedFormat),
+		errors.Is(err, webhooks.ErrInvalidWebhook), errors.Is(err, jobs.ErrInvalidReminder):
+		status, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, users.ErrInvalidCredentials), errors.Is(err, users.ErrInvalidToken), errors.Is(err, tokens.ErrInvalidToken),
+		errors.Is(err, notes.ErrLinkPassword):
+		status, message = http.StatusUnauthorized, err.Error()
+	case errors.Is(err, notes.ErrForbidden):
+		status, message = http.StatusForbidden, err.Error()
+	case errors.Is(err, users.ErrEmailTaken), errors.Is(err, notes.ErrTagExists):
+		status, message = http.StatusConflict, err.Error()
+	case errors.Is(err, notes.ErrVersionConflict):
+		status, message = http.StatusPreconditionFailed, err.Error()
+	default:
+		slog.ErrorContext(ctx.Request.Context(), "request failed", "error", err)
+		sentry.CaptureException(err)
+	}
+	ctx.JSON(status, gin.H{
+		"error": message,
+	})
 }
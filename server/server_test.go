@@ -1,20 +1,34 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"notes/services/collab"
 	"notes/services/entities"
+	"notes/services/jobs"
+	"notes/services/notes"
+	"notes/services/render"
+	"notes/services/search"
+	"notes/services/tokens"
+	"notes/services/users"
+	"notes/services/webhooks"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/assert/v2"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
 )
 
 func TestMain(m *testing.M) {
@@ -26,8 +40,8 @@ func TestMain(m *testing.M) {
 }
 
 func TestPing(t *testing.T) {
-	svr := New()
-	w, err := newTestRequest(svr.router, http.MethodGet, "/ping", nil)
+	svr, _ := newTestServer()
+	w, err := newTestRequest(svr.router, http.MethodGet, "/ping", "", nil)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, w.Code)
 
@@ -43,6 +57,50 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, time.Now().Format(time.DateOnly), strings.Split(res.Time, " ")[0])
 }
 
+func TestSignupAndLogin(t *testing.T) {
+	svr, _ := newTestServer()
+	email := uuid.NewString() + "@example.com"
+	b, err := json.Marshal(entities.SignupReq{Email: email, Password: "p@ssword1"})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodPost, "/signup", "", b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var signup entities.AuthToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &signup))
+	require.NotEmpty(t, signup.Token)
+	assert.Equal(t, email, signup.User.Email)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "p@ssword1"))
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/signup", "", b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/login", "", b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var login entities.AuthToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	assert.Equal(t, signup.User.ID, login.User.ID)
+
+	b, err = json.Marshal(entities.LoginReq{Email: email, Password: "wrong password"})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/login", "", b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestUnauthorized(t *testing.T) {
+	svr, _ := newTestServer()
+	for _, token := range []string{"", "not-a-jwt"} {
+		w, err := newTestRequest(svr.router, http.MethodGet, "/", token, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+}
+
 func TestCreate(t *testing.T) {
 	req := entities.NoteReq{
 		Title:   "titles",
@@ -51,8 +109,9 @@ func TestCreate(t *testing.T) {
 
 	b, err := json.Marshal(req)
 	require.NoError(t, err)
-	svr := New()
-	w, err := newTestRequest(svr.router, http.MethodPost, "/", b)
+	svr, _ := newTestServer()
+	auth := signup(t, svr)
+	w, err := newTestRequest(svr.router, http.MethodPost, "/", auth.Token, b)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusCreated, w.Code)
 
@@ -64,8 +123,9 @@ func TestCreate(t *testing.T) {
 	require.NotEmpty(t, res.ID)
 	assert.Equal(t, req.Title, res.Title)
 	assert.Equal(t, req.Content, res.Content)
+	assert.Equal(t, auth.User.ID, res.UserID)
 
-	w, err = newTestRequest(svr.router, http.MethodGet, "/"+res.ID, nil)
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+res.ID, auth.Token, nil)
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, w.Code)
 
@@ -74,36 +134,1319 @@ func TestCreate(t *testing.T) {
 }
 
 func TestCreate_BadRequest(t *testing.T) {
-	svr := New()
-	w, err := newTestRequest(svr.router, http.MethodPost, "/", nil)
+	svr, _ := newTestServer()
+	auth := signup(t, svr)
+	w, err := newTestRequest(svr.router, http.MethodPost, "/", auth.Token, nil)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	for name, req := range map[string]entities.NoteReq{
+		"long title":   {Title: strings.Repeat("t", entities.MaxTitleLength+1), Content: "content"},
+		"content type": {Title: "title", Content: "content", ContentType: "html"},
+	} {
+		b, err := json.Marshal(req)
+		require.NoError(t, err)
+		w, err := newTestRequest(svr.router, http.MethodPost, "/", auth.Token, b)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, w.Code, name)
+		require.Contains(t, w.Body.String(), "failed on the", name)
+	}
+}
+
+func TestCreate_Markdown(t *testing.T) {
+	svr, _ := newTestServer()
+	auth := signup(t, svr)
+	b, err := json.Marshal(entities.NoteReq{
+		Title:       "Readme",
+		Content:     "# Notes\n\n" + strings.Repeat("A long paragraph. ", 1000),
+		ContentType: entities.ContentTypeMarkdown,
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodPost, "/", auth.Token, b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var note entities.Note
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &note))
+	assert.Equal(t, entities.ContentTypeMarkdown, note.ContentType)
+}
+
+func TestRender(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:       "Readme",
+		Content:     "# Hello\n\n- [x] done\n\n<script>alert(1)</script>",
+		ContentType: entities.ContentTypeMarkdown,
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/render?format=html", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "<h1>Hello</h1>")
+	require.NotContains(t, w.Body.String(), "<script>")
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/render?format=pdf", auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/render", signup(t, svr).Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestGet_FindNonExistentNote(t *testing.T) {
-	svr := New()
+	svr, _ := newTestServer()
 	if svr.router == nil {
 		t.Fatal("server router is not initialized")
 	}
-	w, err := newTestRequest(svr.router, http.MethodGet, "/123", nil)
+	w, err := newTestRequest(svr.router, http.MethodGet, "/123", signup(t, svr).Token, nil)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestGet_OtherUsersNote(t *testing.T) {
+	svr, service := newTestServer()
+	owner, other := signup(t, svr), signup(t, svr)
+	note, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		w, err := newTestRequestWithHeaders(svr.router, method, "/"+note.ID, other.Token, matchHeader("*"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	}
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/", other.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res entities.NotePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, 0, len(res.Items))
+}
+
+func TestGet_NotModified(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/"+note.ID, auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	tag := w.Header().Get("ETag")
+	assert.Equal(t, `"1"`, tag)
+
+	for _, header := range []string{tag, `W/` + tag, `"7", ` + tag, "*"} {
+		w, err = newTestRequestWithHeaders(svr.router, http.MethodGet, "/"+note.ID, auth.Token, map[string]string{"If-None-Match": header}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, tag, w.Header().Get("ETag"))
+		assert.Equal(t, 0, w.Body.Len())
+	}
+
+	_, err = service.UpdateNote(t.Context(), auth.User.ID, note.ID, entities.NoteReq{Title: "title", Content: "changed"})
+	require.NoError(t, err)
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodGet, "/"+note.ID, auth.Token, map[string]string{"If-None-Match": tag}, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+}
+
 func TestGet_All(t *testing.T) {
-	svr := New()
-	database[uuid.NewString()] = entities.Note{
-		ID:        uuid.NewString(),
-		CreatedAt: time.Now(),
-		Title:     "title",
-		Content:   "content",
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/", auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var res entities.NotePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.Items, 1)
+	assert.Equal(t, note.ID, res.Items[0].ID)
+	assert.Equal(t, "", res.NextCursor)
+}
+
+func TestGet_AllPaginated(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	for _, title := range []string{"delta", "alpha", "charlie", "bravo", "echo"} {
+		_, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+			Title:   title,
+			Content: "content",
+		})
+		require.NoError(t, err)
 	}
-	w, err := newTestRequest(svr.router, http.MethodGet, "/", nil)
+	_, err := service.CreateNote(t.Context(), uuid.NewString(), entities.NoteReq{
+		Title:   "other user",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	var titles []string
+	path := "/?sort=title&order=asc&limit=2"
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		w, err := newTestRequest(svr.router, http.MethodGet, path, auth.Token, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res entities.NotePage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		for _, v := range res.Items {
+			titles = append(titles, v.Title)
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		path = "/?sort=title&order=asc&limit=2&cursor=" + res.NextCursor
+	}
+	assert.Equal(t, []string{"alpha", "bravo", "charlie", "delta", "echo"}, titles)
+
+	for _, path := range []string{"/?sort=content", "/?order=up", "/?limit=1000", "/?cursor=bad", "/?created_after=yesterday"} {
+		w, err := newTestRequest(svr.router, http.MethodGet, path, auth.Token, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	b, err := json.Marshal(entities.NoteReq{
+		Title:   "new title",
+		Content: "new content",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodPut, "/"+note.ID, auth.Token, b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodPut, "/"+note.ID, auth.Token, matchHeader(`"1"`), b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	var res entities.Note
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, note.ID, res.ID)
+	assert.Equal(t, "new title", res.Title)
+	assert.Equal(t, "new content", res.Content)
+	assert.Equal(t, 2, res.Version)
+
+	// a second writer still holding the first version is turned away
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodPut, "/"+note.ID, auth.Token, matchHeader(`"1"`), b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodPut, "/"+note.ID, auth.Token, matchHeader(`W/"2"`), b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodPut, "/"+uuid.NewString(), auth.Token, matchHeader("*"), b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPatch(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequestWithHeaders(svr.router, http.MethodPatch, "/"+note.ID, auth.Token, matchHeader(`"1"`), []byte(`{"title":"patched"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res entities.Note
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "patched", res.Title)
+	assert.Equal(t, note.Content, res.Content)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodPatch, "/"+note.ID, auth.Token, matchHeader(`"1"`), []byte(`{"title":"again"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodPatch, "/"+note.ID, auth.Token, matchHeader("*"), []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodPatch, "/"+uuid.NewString(), auth.Token, matchHeader("*"), []byte(`{"title":"patched"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRevisions(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "first line\nsecond line",
+	})
+	require.NoError(t, err)
+	_, err = service.UpdateNote(t.Context(), auth.User.ID, note.ID, entities.NoteReq{
+		Title:   "new title",
+		Content: "first line\nchanged line",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/revisions", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var revisions []entities.Revision
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &revisions))
+	require.Len(t, revisions, 1)
+	assert.Equal(t, 1, revisions[0].Revision)
+	assert.Equal(t, "title", revisions[0].Title)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/revisions/1", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var revision entities.Revision
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &revision))
+	assert.Equal(t, note.Content, revision.Content)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/diff?from=1", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var diff entities.NoteDiff
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Equal(t, 1, diff.From)
+	require.Contains(t, diff.Diff, "-second line")
+	require.Contains(t, diff.Diff, "+changed line")
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/revisions/1/restore", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res entities.Note
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "title", res.Title)
+	assert.Equal(t, note.Content, res.Content)
+
+	for path, status := range map[string]int{
+		"/" + note.ID + "/revisions/9":        http.StatusNotFound,
+		"/" + note.ID + "/revisions/latest":   http.StatusBadRequest,
+		"/" + note.ID + "/diff":               http.StatusBadRequest,
+		"/" + note.ID + "/diff?from=1&to=9":   http.StatusNotFound,
+		"/" + uuid.NewString() + "/revisions": http.StatusNotFound,
+	} {
+		w, err = newTestRequest(svr.router, http.MethodGet, path, auth.Token, nil)
+		require.NoError(t, err)
+		assert.Equal(t, status, w.Code)
+	}
+}
+
+func TestTags(t *testing.T) {
+	svr, _ := newTestServer()
+	auth := signup(t, svr)
+	for _, req := range []entities.NoteReq{
+		{Title: "report", Content: "content", Tags: []string{"work", "Urgent"}},
+		{Title: "taxes", Content: "content", Tags: []string{"home", "urgent"}},
+	} {
+		b, err := json.Marshal(req)
+		require.NoError(t, err)
+		w, err := newTestRequest(svr.router, http.MethodPost, "/", auth.Token, b)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	for path, titles := range map[string][]string{
+		"/?tag=urgent":                     {"report", "taxes"},
+		"/?tag=urgent&tag=home":            {"taxes"},
+		"/?tag=work&tag=home&tag_mode=any": {"report", "taxes"},
+	} {
+		w, err := newTestRequest(svr.router, http.MethodGet, path, auth.Token, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var page entities.NotePage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		var got []string
+		for _, note := range page.Items {
+			got = append(got, note.Title)
+		}
+		require.ElementsMatch(t, titles, got, path)
+	}
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/tags", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var tags []entities.Tag
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tags))
+	assert.Equal(t, []entities.Tag{{Name: "home", Notes: 1}, {Name: "urgent", Notes: 2}, {Name: "work", Notes: 1}}, tags)
+
+	w, err = newTestRequest(svr.router, http.MethodPatch, "/tags/work", auth.Token, []byte(`{"name":"job"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var tag entities.Tag
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tag))
+	assert.Equal(t, entities.Tag{Name: "job", Notes: 1}, tag)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/tags/merge", auth.Token, []byte(`{"from":["job","home"],"into":"todo"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tag))
+	assert.Equal(t, entities.Tag{Name: "todo", Notes: 2}, tag)
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPatch, "/tags/missing", `{"name":"found"}`, http.StatusNotFound},
+		{http.MethodPatch, "/tags/todo", `{"name":"urgent"}`, http.StatusConflict},
+		{http.MethodPatch, "/tags/todo", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/tags/merge", `{"from":[],"into":"todo"}`, http.StatusBadRequest},
+		{http.MethodGet, "/?tag=todo&tag_mode=some", "", http.StatusBadRequest},
+	} {
+		w, err = newTestRequest(svr.router, c.method, c.path, auth.Token, []byte(c.body))
+		require.NoError(t, err)
+		assert.Equal(t, c.status, w.Code)
+	}
+}
+
+func TestNotebooks(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+
+	w, err := newTestRequest(svr.router, http.MethodPost, "/notebooks", auth.Token, []byte(`{"name":"Work"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var work entities.Notebook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &work))
+	assert.Equal(t, "Work", work.Name)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/notebooks", auth.Token, []byte(`{"name":"Projects","parent_id":"`+work.ID+`"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var projects entities.Notebook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &projects))
+	assert.Equal(t, work.ID, projects.ParentID)
+
+	b, err := json.Marshal(entities.NoteReq{Title: "roadmap", Content: "content", NotebookID: projects.ID})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/", auth.Token, b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	_, err = service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{Title: "loose", Content: "content"})
+	require.NoError(t, err)
+
+	for path, count := range map[string]int{
+		"/?notebook_id=" + work.ID:                       0,
+		"/?notebook_id=" + work.ID + "&descendants=true": 1,
+		"/?notebook_id=" + projects.ID:                   1,
+		"/":                                              2,
+	} {
+		w, err := newTestRequest(svr.router, http.MethodGet, path, auth.Token, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code)
+		var page entities.NotePage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		require.Len(t, page.Items, count, path)
+	}
+
+	w, err = newTestRequest(svr.router, http.MethodPatch, "/notebooks/"+projects.ID, auth.Token, []byte(`{"name":"Plans"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/notebooks/"+projects.ID+"/move", auth.Token, []byte(`{"parent_id":""}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/notebooks", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var notebooks []entities.Notebook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &notebooks))
+	require.Len(t, notebooks, 2)
+	assert.Equal(t, "Plans", notebooks[0].Name)
+	require.Empty(t, notebooks[0].ParentID)
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/notebooks/missing", "", http.StatusNotFound},
+		{http.MethodPost, "/notebooks", `{"name":""}`, http.StatusBadRequest},
+		{http.MethodPost, "/notebooks", `{"name":"Lost","parent_id":"missing"}`, http.StatusBadRequest},
+		{http.MethodPost, "/notebooks/" + work.ID + "/move", `{"parent_id":"` + work.ID + `"}`, http.StatusBadRequest},
+		{http.MethodGet, "/?notebook_id=missing", "", http.StatusNotFound},
+		{http.MethodDelete, "/notebooks/" + work.ID + "?mode=shred", "", http.StatusBadRequest},
+	} {
+		w, err = newTestRequest(svr.router, c.method, c.path, auth.Token, []byte(c.body))
+		require.NoError(t, err)
+		require.Equal(t, c.status, w.Code, c.path)
+	}
+
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/notebooks/"+projects.ID+"?mode=cascade", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	page, err := service.GetTrash(t.Context(), auth.User.ID, entities.NoteQuery{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "roadmap", page.Items[0].Title)
+}
+
+func TestShares(t *testing.T) {
+	svr, service := newTestServer()
+	owner, collaborator := signup(t, svr), signup(t, svr)
+	note, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+
+	body := []byte(`{"email":"` + collaborator.User.Email + `","role":"viewer"}`)
+	w, err := newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/shares", owner.Token, body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var share entities.Share
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &share))
+	assert.Equal(t, collaborator.User.ID, share.UserID)
+	assert.Equal(t, entities.RoleViewer, share.Role)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID, collaborator.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/?shared_with_me=true", collaborator.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var page entities.NotePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, note.ID, page.Items[0].ID)
+
+	for _, c := range []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{http.MethodPut, "/" + note.ID, collaborator.Token, `{"title":"title","note":"changed"}`, http.StatusForbidden},
+		{http.MethodGet, "/" + note.ID + "/shares", collaborator.Token, "", http.StatusForbidden},
+		{http.MethodPost, "/" + note.ID + "/shares", owner.Token, `{"email":"nobody@example.com","role":"viewer"}`, http.StatusBadRequest},
+		{http.MethodPost, "/" + note.ID + "/shares", owner.Token, `{"email":"` + collaborator.User.Email + `","role":"owner"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/" + note.ID + "/shares/" + owner.User.ID, owner.Token, "", http.StatusNotFound},
+	} {
+		w, err := newTestRequestWithHeaders(svr.router, c.method, c.path, c.token, matchHeader("*"), []byte(c.body))
+		require.NoError(t, err)
+		require.Equal(t, c.status, w.Code, c.method+" "+c.path)
+	}
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/shares", owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var shares []entities.Share
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shares))
+	require.Len(t, shares, 1)
+	assert.Equal(t, collaborator.User.Email, shares[0].Email)
+
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/"+note.ID+"/shares/"+collaborator.User.ID, owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID, collaborator.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLinks(t *testing.T) {
+	svr, service := newTestServer()
+	owner, other := signup(t, svr), signup(t, svr)
+	note, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/links", owner.Token, []byte(`{"password":"secret","max_views":1}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link entities.Link
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.Equal(t, true, link.Protected)
+	require.NotEmpty(t, link.Token)
+
+	for _, c := range []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{http.MethodPost, "/" + note.ID + "/links", owner.Token, `{"max_views":0}`, http.StatusBadRequest},
+		{http.MethodPost, "/" + note.ID + "/links", owner.Token, `{"expires_at":"2000-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{http.MethodPost, "/" + note.ID + "/links", other.Token, `{}`, http.StatusNotFound},
+		{http.MethodGet, "/" + note.ID + "/links", other.Token, "", http.StatusNotFound},
+		{http.MethodGet, "/p/unknown", "", "", http.StatusNotFound},
+		{http.MethodGet, "/p/" + link.Token, "", "", http.StatusUnauthorized},
+	} {
+		w, err := newTestRequest(svr.router, c.method, c.path, c.token, []byte(c.body))
+		require.NoError(t, err)
+		require.Equal(t, c.status, w.Code, c.method+" "+c.path)
+	}
+
+	headers := map[string]string{"X-Link-Password": "secret"}
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodGet, "/p/"+link.Token, "", headers, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, no-store", w.Header().Get("Cache-Control"))
+	var public entities.PublicNote
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &public))
+	assert.Equal(t, "content", public.Content)
+	require.NotContains(t, w.Body.String(), owner.User.ID)
+
+	// the only view is used up
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodGet, "/p/"+link.Token, "", headers, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/links", owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var links []entities.Link
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &links))
+	require.Len(t, links, 1)
+	assert.Equal(t, 1, links[0].Views)
+	assert.Equal(t, "", links[0].Token)
+
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/"+note.ID+"/links/"+link.ID, owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/"+note.ID+"/links/"+link.ID, owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestComments(t *testing.T) {
+	svr, service := newTestServer()
+	owner, commenter := signup(t, svr), signup(t, svr)
+	note, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	_, err = service.ShareNote(t.Context(), owner.User.ID, note.ID, entities.ShareReq{Email: commenter.User.Email, Role: entities.RoleCommenter})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/comments", commenter.Token, []byte(`{"body":"typo in line 2"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var thread entities.Comment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &thread))
+	assert.Equal(t, commenter.User.ID, thread.UserID)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/comments", owner.Token, []byte(`{"body":"fixed","parent_id":"`+thread.ID+`"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var reply entities.Comment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reply))
+
+	for _, c := range []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{http.MethodPost, "/" + note.ID + "/comments", owner.Token, `{"body":""}`, http.StatusBadRequest},
+		{http.MethodPost, "/" + note.ID + "/comments", owner.Token, `{"body":"deep","parent_id":"` + reply.ID + `"}`, http.StatusBadRequest},
+		{http.MethodPatch, "/" + note.ID + "/comments/" + thread.ID, owner.Token, `{"body":"mine now"}`, http.StatusForbidden},
+		{http.MethodPost, "/" + note.ID + "/comments/" + reply.ID + "/resolve", owner.Token, "", http.StatusBadRequest},
+		{http.MethodDelete, "/" + note.ID + "/comments/" + reply.ID, commenter.Token, "", http.StatusForbidden},
+		{http.MethodDelete, "/" + note.ID + "/comments/unknown", owner.Token, "", http.StatusNotFound},
+	} {
+		w, err := newTestRequest(svr.router, c.method, c.path, c.token, []byte(c.body))
+		require.NoError(t, err)
+		require.Equal(t, c.status, w.Code, c.method+" "+c.path)
+	}
+
+	w, err = newTestRequest(svr.router, http.MethodPatch, "/"+note.ID+"/comments/"+thread.ID, commenter.Token, []byte(`{"body":"typo in line 3"}`))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/comments/"+thread.ID+"/resolve", owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/comments", commenter.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var comments []entities.Comment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &comments))
+	require.Len(t, comments, 2)
+	assert.Equal(t, "typo in line 3", comments[0].Body)
+	assert.Equal(t, true, comments[0].Resolved)
+	assert.Equal(t, thread.ID, comments[1].ParentID)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/", owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var page entities.NotePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	require.NotNil(t, page.Items[0].Comments)
+	assert.Equal(t, 2, *page.Items[0].Comments)
+
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/"+note.ID+"/comments/"+thread.ID, commenter.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/comments", owner.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+func TestEvents(t *testing.T) {
+	svr, service := newTestServer()
+	owner, other := signup(t, svr), signup(t, svr)
+	ts := httptest.NewServer(svr.router)
+	t.Cleanup(ts.Close)
+
+	stream := func(token, lastEventID string) (*bufio.Reader, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(t.Context())
+		t.Cleanup(cancel)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Last-Event-ID", lastEventID)
+		res, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, sse.ContentType, res.Header.Get("Content-Type"))
+		return bufio.NewReader(res.Body), func() {
+			cancel()
+			res.Body.Close()
+		}
+	}
+	// next reads the next event off the stream, skipping heartbeats
+	next := func(r *bufio.Reader) (string, string, entities.Event) {
+		var id, name string
+		var event entities.Event
+		for {
+			line, err := r.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && name != "":
+				return id, name, event
+			case strings.HasPrefix(line, "id:"):
+				id = line[len("id:"):]
+			case strings.HasPrefix(line, "event:"):
+				name = line[len("event:"):]
+			case strings.HasPrefix(line, "data:"):
+				require.NoError(t, json.Unmarshal([]byte(line[len("data:"):]), &event))
+			}
+		}
+	}
+
+	ownerEvents, closeOwner := stream(owner.Token, "")
+	otherEvents, _ := stream(other.Token, "")
+
+	private, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{Title: "private", Content: "content"})
+	require.NoError(t, err)
+	id, name, event := next(ownerEvents)
+	assert.Equal(t, entities.EventNoteCreated, name)
+	assert.Equal(t, private.ID, event.NoteID)
+	assert.Equal(t, "private", event.Note.Title)
+	first := id
+
+	shared, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{Title: "shared", Content: "content"})
+	require.NoError(t, err)
+	_, err = service.ShareNote(t.Context(), owner.User.ID, shared.ID, entities.ShareReq{Email: other.User.Email, Role: entities.RoleViewer})
+	require.NoError(t, err)
+	// the other user never sees the private note
+	_, name, event = next(otherEvents)
+	assert.Equal(t, entities.EventNoteCreated, name)
+	assert.Equal(t, shared.ID, event.NoteID)
+	closeOwner()
+
+	require.NoError(t, service.DeleteNote(t.Context(), owner.User.ID, private.ID, 0))
+	ownerEvents, _ = stream(owner.Token, first)
+	_, _, event = next(ownerEvents)
+	assert.Equal(t, shared.ID, event.NoteID)
+	_, name, event = next(ownerEvents)
+	assert.Equal(t, entities.EventNoteDeleted, name)
+	assert.Equal(t, private.ID, event.NoteID)
+
+	w, err := newTestRequestWithHeaders(svr.router, http.MethodGet, "/events", owner.Token, map[string]string{"Last-Event-ID": "latest"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	// the stream cannot be resumed from an id that is no longer buffered
+	resetEvents, _ := stream(owner.Token, "1")
+	_, name, _ = next(resetEvents)
+	assert.Equal(t, "reset", name)
+}
+
+func TestCollab(t *testing.T) {
+	svr, service := newTestServer()
+	owner, editor, stranger := signup(t, svr), signup(t, svr), signup(t, svr)
+	ts := httptest.NewServer(svr.router)
+	t.Cleanup(ts.Close)
+
+	note, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	_, err = service.ShareNote(t.Context(), owner.User.ID, note.ID, entities.ShareReq{Email: editor.User.Email, Role: entities.RoleEditor})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/collab", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID+"/collab?access_token="+stranger.Token, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	dial := func(query string, headers map[string]string) *websocket.Conn {
+		config, err := websocket.NewConfig("ws"+strings.TrimPrefix(ts.URL, "http")+"/"+note.ID+"/collab"+query, "http://localhost/")
+		require.NoError(t, err)
+		for k, v := range headers {
+			config.Header.Set(k, v)
+		}
+		conn, err := websocket.DialConfig(config)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	receive := func(conn *websocket.Conn) collab.Message {
+		var msg collab.Message
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		require.NoError(t, websocket.JSON.Receive(conn, &msg))
+		return msg
+	}
+
+	ownerConn := dial("", map[string]string{"Authorization": "Bearer " + owner.Token})
+	init := receive(ownerConn)
+	assert.Equal(t, collab.TypeInit, init.Type)
+	assert.Equal(t, "content", init.Content)
+	// browsers pass the token in the query
+	editorConn := dial("?access_token="+editor.Token, nil)
+	init = receive(editorConn)
+	assert.Equal(t, "content", init.Content)
+	assert.Equal(t, true, init.CanEdit)
+	assert.Equal(t, collab.TypeJoined, receive(ownerConn).Type)
+
+	var op collab.Operation
+	require.NoError(t, json.Unmarshal([]byte(`[7, " edited"]`), &op))
+	require.NoError(t, websocket.JSON.Send(editorConn, collab.Message{Type: collab.TypeOp, Revision: 0, Operation: op}))
+	assert.Equal(t, collab.Message{Type: collab.TypeAck, Revision: 1}, receive(editorConn))
+	msg := receive(ownerConn)
+	assert.Equal(t, collab.TypeOp, msg.Type)
+	assert.Equal(t, editor.User.ID, msg.UserID)
+	assert.Equal(t, op, msg.Operation)
+
+	cursor := 3
+	require.NoError(t, websocket.JSON.Send(ownerConn, collab.Message{Type: collab.TypeCursor, Cursor: &cursor}))
+	msg = receive(editorConn)
+	assert.Equal(t, collab.TypeCursor, msg.Type)
+	assert.Equal(t, 3, *msg.Cursor)
+
+	// the note is saved once everyone left
+	require.NoError(t, ownerConn.Close())
+	assert.Equal(t, collab.TypeLeft, receive(editorConn).Type)
+	require.NoError(t, editorConn.Close())
+	require.Eventually(t, func() bool {
+		saved, err := service.GetNote(t.Context(), owner.User.ID, note.ID)
+		return err == nil && saved.Content == "content edited"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCollabReadOnlyToken(t *testing.T) {
+	svr, service := newTestServer()
+	owner := signup(t, svr)
+	ts := httptest.NewServer(svr.router)
+	t.Cleanup(ts.Close)
+
+	note, err := service.CreateNote(t.Context(), owner.User.ID, entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	b, err := json.Marshal(entities.APITokenReq{Name: "reader", Scopes: []string{tokens.ScopeNotesRead}})
+	require.NoError(t, err)
+	w, err := newTestRequest(svr.router, http.MethodPost, "/tokens", owner.Token, b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var token entities.APIToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/"+note.ID+"/collab?access_token="+token.Token, "", "http://localhost/")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	receive := func() collab.Message {
+		var msg collab.Message
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		require.NoError(t, websocket.JSON.Receive(conn, &msg))
+		return msg
+	}
+
+	// the owner follows along with a notes:read token, but cannot write through it
+	init := receive()
+	assert.Equal(t, collab.TypeInit, init.Type)
+	assert.Equal(t, false, init.CanEdit)
+	var op collab.Operation
+	require.NoError(t, json.Unmarshal([]byte(`[7, " edited"]`), &op))
+	require.NoError(t, websocket.JSON.Send(conn, collab.Message{Type: collab.TypeOp, Revision: 0, Operation: op}))
+	assert.Equal(t, collab.TypeError, receive().Type)
+
+	require.NoError(t, conn.Close())
+	require.Never(t, func() bool {
+		saved, err := service.GetNote(t.Context(), owner.User.ID, note.ID)
+		return err != nil || saved.Content != "content" || saved.Version != note.Version
+	}, 200*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestDelete(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodDelete, "/"+note.ID, auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodDelete, "/"+note.ID, auth.Token, matchHeader(`"2"`), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodDelete, "/"+note.ID, auth.Token, matchHeader(`"1"`), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID, auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodDelete, "/"+note.ID, auth.Token, matchHeader("*"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "title",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequestWithHeaders(svr.router, http.MethodDelete, "/"+note.ID, auth.Token, matchHeader("*"), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/trash", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var trash entities.NotePage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trash))
+	require.Len(t, trash.Items, 1)
+	assert.Equal(t, note.ID, trash.Items[0].ID)
+	require.NotNil(t, trash.Items[0].DeletedAt)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/restore", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+note.ID, auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/restore", auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodDelete, "/"+note.ID+"?permanent=true", auth.Token, matchHeader(`"3"`), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+note.ID+"/restore", auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSync(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	note, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{Title: "synced", Content: "content"})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/sync", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var changes entities.SyncChanges
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &changes))
+	require.Len(t, changes.Notes, 1)
+	assert.Equal(t, note.ID, changes.Notes[0].ID)
+	assert.Equal(t, note.Seq, changes.Seq)
+
+	offline := uuid.NewString()
+	b, err := json.Marshal(entities.SyncReq{Changes: []entities.SyncChange{
+		{ID: offline, Title: "offline", Content: "written on a plane", UpdatedAt: time.Now()},
+		{ID: note.ID, Deleted: true, Version: note.Version, UpdatedAt: time.Now()},
+	}})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/sync", auth.Token, b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response entities.SyncResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, entities.SyncApplied, response.Results[0].Status)
+	assert.Equal(t, offline, response.Results[0].Note.ID)
+	assert.Equal(t, entities.SyncApplied, response.Results[1].Status)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/sync?since="+strconv.FormatInt(changes.Seq, 10), auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &changes))
+	require.Len(t, changes.Notes, 1)
+	assert.Equal(t, offline, changes.Notes[0].ID)
+	require.Len(t, changes.Deleted, 1)
+	assert.Equal(t, note.ID, changes.Deleted[0].ID)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/sync?since=yesterday", ""},
+		{http.MethodGet, "/sync?since=-1", ""},
+		{http.MethodPost, "/sync", `{}`},
+		{http.MethodPost, "/sync", `{"changes":[{"id":"not-a-uuid","title":"t","note":"c","updated_at":"2026-10-18T12:00:00Z"}]}`},
+		{http.MethodPost, "/sync", `{"changes":[{"id":"` + offline + `","updated_at":"2026-10-18T12:00:00Z"}]}`},
+	} {
+		w, err = newTestRequest(svr.router, tc.method, tc.path, auth.Token, []byte(tc.body))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+
+	// the note of another user cannot be synced over
+	other := signup(t, svr)
+	b, err = json.Marshal(entities.SyncReq{Changes: []entities.SyncChange{
+		{ID: offline, Title: "mine", Content: "now", Version: 1, UpdatedAt: time.Now()},
+	}})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/sync", other.Token, b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSearch(t *testing.T) {
+	svr, service := newTestServer()
+	auth := signup(t, svr)
+	other := signup(t, svr)
+
+	groceries, err := service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "Groceries",
+		Content: "buy milk, eggs and bread",
+	})
+	require.NoError(t, err)
+	_, err = service.CreateNote(t.Context(), auth.User.ID, entities.NoteReq{
+		Title:   "Standup",
+		Content: "talk about the release",
+	})
+	require.NoError(t, err)
+	_, err = service.CreateNote(t.Context(), other.User.ID, entities.NoteReq{
+		Title:   "Milk",
+		Content: "someone else's milk",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodGet, "/search?q=milk", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var results entities.SearchResults
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results.Hits, 1)
+	assert.Equal(t, 1, results.Total)
+	assert.Equal(t, groceries.ID, results.Hits[0].Note.ID)
+	assert.Equal(t, []string{"buy <em>milk</em>, eggs and bread"}, results.Hits[0].Highlights["content"])
+
+	w, err = newTestRequestWithHeaders(svr.router, http.MethodDelete, "/"+groceries.ID, auth.Token, matchHeader("*"), nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/search?q=milk", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Equal(t, 0, len(results.Hits))
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/search", auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPITokens(t *testing.T) {
+	svr, _ := newTestServer()
+	auth := signup(t, svr)
+	b, err := json.Marshal(entities.APITokenReq{
+		Name:   "backup script",
+		Scopes: []string{tokens.ScopeNotesRead},
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodPost, "/tokens", auth.Token, b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var token entities.APIToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	require.True(t, tokens.IsAPIToken(token.Token))
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/", token.Token, nil)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	note, err := json.Marshal(entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/", token.Token, note)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/tokens", token.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/tokens", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list []entities.APIToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, token.ID, list[0].ID)
+	assert.Equal(t, "", list[0].Token)
+	require.NotNil(t, list[0].LastUsedAt)
+
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/tokens/"+token.ID, auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/", token.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestWebhooks(t *testing.T) {
+	svr, _ := newTestServer()
+	auth := signup(t, svr)
+
+	var received []*http.Request
+	var mu sync.Mutex
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+	}))
+	defer receiver.Close()
+
+	b, err := json.Marshal(entities.WebhookReq{URL: "not a url"})
+	require.NoError(t, err)
+	w, err := newTestRequest(svr.router, http.MethodPost, "/webhooks", auth.Token, b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	b, err = json.Marshal(entities.WebhookReq{URL: receiver.URL})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/webhooks", auth.Token, b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var webhook entities.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &webhook))
+	require.NotEmpty(t, webhook.Secret)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/webhooks", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []entities.Webhook
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, "", list[0].Secret)
+
+	note, err := json.Marshal(entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/", auth.Token, note)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, svr.webhooks.(*webhooks.Service).Dispatch(t.Context()))
+	mu.Lock()
+	require.Len(t, received, 1)
+	assert.Equal(t, entities.EventNoteCreated, received[0].Header.Get(webhooks.HeaderEvent))
+	mu.Unlock()
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/webhooks/"+webhook.ID+"/deliveries", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var deliveries []entities.Delivery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deliveries))
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, entities.DeliveryDelivered, deliveries[0].Status)
+
+	w, err = newTestRequest(svr.router, http.MethodGet, "/webhooks/"+webhook.ID+"/deliveries?status=lost", auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/webhooks/"+webhook.ID+"/test", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var ping entities.Delivery
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ping))
+	assert.Equal(t, entities.EventPing, ping.EventType)
+	assert.Equal(t, entities.DeliveryDelivered, ping.Status)
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/webhooks/"+webhook.ID+"/replay", auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, w.Code)
+	var replayed entities.ReplayResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &replayed))
+	assert.Equal(t, int64(0), replayed.Replayed)
+
+	other := signup(t, svr)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/webhooks/"+webhook.ID+"/test", other.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/webhooks/"+webhook.ID, auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/webhooks/"+webhook.ID, auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestReminders(t *testing.T) {
+	svr, _ := newTestServer()
+	auth := signup(t, svr)
+
+	note, err := json.Marshal(entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	w, err := newTestRequest(svr.router, http.MethodPost, "/", auth.Token, note)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created entities.Note
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+created.ID+"/reminders", auth.Token, []byte(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	b, err := json.Marshal(entities.ReminderReq{RemindAt: time.Now().Add(time.Hour), Recurrence: "FREQ=MINUTELY"})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+created.ID+"/reminders", auth.Token, b)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	b, err = json.Marshal(entities.ReminderReq{
+		RemindAt:   time.Now().Add(time.Hour),
+		Recurrence: "FREQ=WEEKLY",
+		Channel:    entities.ChannelWebhook,
+	})
+	require.NoError(t, err)
+	w, err = newTestRequest(svr.router, http.MethodPost, "/"+created.ID+"/reminders", auth.Token, b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var reminder entities.Reminder
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reminder))
+	assert.Equal(t, created.ID, reminder.NoteID)
+	assert.Equal(t, entities.ReminderActive, reminder.Status)
+	assert.Equal(t, entities.ChannelWebhook, reminder.Channel)
+
+	for _, path := range []string{"/reminders", "/" + created.ID + "/reminders"} {
+		w, err = newTestRequest(svr.router, http.MethodGet, path, auth.Token, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code)
+		var list []entities.Reminder
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		require.Len(t, list, 1)
+		assert.Equal(t, reminder.ID, list[0].ID)
+	}
+
+	other := signup(t, svr)
+	w, err = newTestRequest(svr.router, http.MethodGet, "/"+created.ID+"/reminders", other.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/"+created.ID+"/reminders/"+reminder.ID, other.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/"+created.ID+"/reminders/"+reminder.ID, auth.Token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	w, err = newTestRequest(svr.router, http.MethodDelete, "/"+created.ID+"/reminders/"+reminder.ID, auth.Token, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func newTestRequest(router *gin.Engine, method, path string, payload []byte) (*httptest.ResponseRecorder, error) {
+func newTestServer() (*Server, *notes.Service) {
+	index := search.NewMemoryIndex()
+	userStore := users.NewMemoryStore()
+	service := notes.New(notes.NewMemoryStore(), notes.WithIndexer(index), notes.WithUsers(userStore))
+	// the test receivers listen on loopback, which the default client refuses
+	hooks := webhooks.New(webhooks.NewMemoryStore(), service, webhooks.WithClient(&http.Client{Timeout: 10 * time.Second}))
+	return New(Services{
+		Notes:    service,
+		Users:    users.New(userStore, []byte("test-secret"), time.Hour),
+		Tokens:   tokens.New(tokens.NewMemoryStore()),
+		Search:   search.New(index),
+		Collab:   collab.New(service),
+		Renderer: render.New(100, 1<<20),
+		Webhooks: hooks,
+		Reminders: jobs.New(jobs.NewMemoryStore(), service,
+			jobs.WithNotifier(entities.ChannelEmail, jobs.NewEmailNotifier("localhost:1025", "notes@localhost", userStore)),
+			jobs.WithNotifier(entities.ChannelWebhook, jobs.NewWebhookNotifier(hooks))),
+	}), service
+}
+
+// signup creates a new account through the API and returns its token
+func signup(t *testing.T, svr *Server) entities.AuthToken {
+	t.Helper()
+	b, err := json.Marshal(entities.SignupReq{
+		Email:    uuid.NewString() + "@example.com",
+		Password: "p@ssword1",
+	})
+	require.NoError(t, err)
+
+	w, err := newTestRequest(svr.router, http.MethodPost, "/signup", "", b)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var res entities.AuthToken
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return res
+}
+
+// matchHeader conditions a write on the note being at the given ETag
+func matchHeader(etag string) map[string]string {
+	return map[string]string{"If-Match": etag}
+}
+
+func newTestRequest(router *gin.Engine, method, path, token string, payload []byte) (*httptest.ResponseRecorder, error) {
+	return newTestRequestWithHeaders(router, method, path, token, nil, payload)
+}
+
+func newTestRequestWithHeaders(router *gin.Engine, method, path, token string, headers map[string]string, payload []byte) (*httptest.ResponseRecorder, error) {
 	w := httptest.NewRecorder()
 	var (
 		req *http.Request
@@ -117,6 +1460,12 @@ func newTestRequest(router *gin.Engine, method, path string, payload []byte) (*h
 	if err != nil {
 		return nil, err
 	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 
 	router.ServeHTTP(w, req)
 	return w, nil
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"notes/services/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookService describes the webhook operations the server depends on
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, userID string, req entities.WebhookReq) (entities.Webhook, error)
+	ListWebhooks(ctx context.Context, userID string) ([]entities.Webhook, error)
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	TestWebhook(ctx context.Context, userID, id string) (entities.Delivery, error)
+	ListDeliveries(ctx context.Context, userID, id, status string) ([]entities.Delivery, error)
+	ReplayDeliveries(ctx context.Context, userID, id string, req entities.ReplayReq) (entities.ReplayResponse, error)
+}
+
+func (s *Server) createWebhook(ctx *gin.Context) {
+	var req entities.WebhookReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	webhook, err := s.webhooks.CreateWebhook(ctx.Request.Context(), currentUser(ctx).ID, req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, webhook)
+}
+
+func (s *Server) listWebhooks(ctx *gin.Context) {
+	result, err := s.webhooks.ListWebhooks(ctx.Request.Context(), currentUser(ctx).ID)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (s *Server) deleteWebhook(ctx *gin.Context) {
+	if err := s.webhooks.DeleteWebhook(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id")); err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+func (s *Server) testWebhook(ctx *gin.Context) {
+	delivery, err := s.webhooks.TestWebhook(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, delivery)
+}
+
+func (s *Server) deliveries(ctx *gin.Context) {
+	result, err := s.webhooks.ListDeliveries(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), ctx.Query("status"))
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func (s *Server) replayDeliveries(ctx *gin.Context) {
+	var req entities.ReplayReq
+	// an empty body replays every failed delivery
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	result, err := s.webhooks.ReplayDeliveries(ctx.Request.Context(), currentUser(ctx).ID, ctx.Param("id"), req)
+	if err != nil {
+		handleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,534 @@
+package collab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/notes"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// defaultSaveInterval is how often the document of a session is saved while it changes
+	defaultSaveInterval = 5 * time.Second
+	// saveTimeout bounds a save, which runs outside of any request
+	saveTimeout = 10 * time.Second
+	// outboxSize is the number of messages a participant can fall behind before it is dropped
+	outboxSize = 256
+	// maxHistory is the number of operations a session keeps. Once there are more, the older
+	// half is dropped and edits made on those revisions are rejected.
+	maxHistory = 1000
+)
+
+// Types of the messages exchanged with participants
+const (
+	// TypeInit is sent to a participant once it joined, with the document and who else is editing it
+	TypeInit = "init"
+	// TypeOp carries an edit, from a participant based on Revision or to the others once it is applied as Revision
+	TypeOp = "op"
+	// TypeAck confirms to the participant that its edit was applied as Revision
+	TypeAck = "ack"
+	// TypeCursor carries the cursor position of a participant
+	TypeCursor = "cursor"
+	// TypeJoined announces a participant to the others
+	TypeJoined = "joined"
+	// TypeLeft tells the others a participant left
+	TypeLeft = "left"
+	// TypeError tells the participant its last message was rejected
+	TypeError = "error"
+)
+
+// Notes are the note operations the sessions depend on
+type Notes interface {
+	GetRole(ctx context.Context, userID, id string) (entities.Note, string, error)
+	GetNote(ctx context.Context, userID, id string) (entities.Note, error)
+	UpdateNote(ctx context.Context, userID, id string, req entities.NoteReq) (entities.Note, error)
+}
+
+// Message is exchanged with the participants of a session
+type Message struct {
+	Type          string     `json:"type"`
+	Revision      int        `json:"revision,omitempty"`
+	Operation     Operation  `json:"operation,omitempty"`
+	Content       string     `json:"content,omitempty"`
+	ParticipantID string     `json:"participant_id,omitempty"`
+	UserID        string     `json:"user_id,omitempty"`
+	Cursor        *int       `json:"cursor,omitempty"`
+	CanEdit       bool       `json:"can_edit,omitempty"`
+	Participants  []Presence `json:"participants,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// Presence is a participant of a session and where its cursor is
+type Presence struct {
+	ParticipantID string `json:"participant_id"`
+	UserID        string `json:"user_id"`
+	Cursor        *int   `json:"cursor,omitempty"`
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithSaveInterval saves the documents being edited every interval
+func WithSaveInterval(interval time.Duration) Option {
+	return func(m *Manager) {
+		m.interval = interval
+	}
+}
+
+// Manager runs one editing session per note being edited. Every edit goes through the
+// session, which orders the edits, transforms those made on an older revision of the
+// document against the ones applied since and sends the result to the other participants.
+type Manager struct {
+	notes    Notes
+	interval time.Duration
+
+	// mu guards the maps only and is never held while waiting on anything else
+	mu       sync.Mutex
+	sessions map[string]*session
+	// locks of the notes being joined or left
+	locks map[string]*noteLock
+}
+
+// noteLock orders the sessions of a note: it is held while the note is loaded into a session
+// and while an ending session saves it, so a new session never loads the note before that
+type noteLock struct {
+	mu sync.Mutex
+	// refs counts those holding or waiting for the lock, guarded by the manager's lock
+	refs int
+}
+
+// New returns a Manager that loads and saves notes through the given service
+func New(notes Notes, opts ...Option) *Manager {
+	m := &Manager{
+		notes:    notes,
+		interval: defaultSaveInterval,
+		sessions: make(map[string]*session),
+		locks:    make(map[string]*noteLock),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// session is the document of a note while it is being edited
+type session struct {
+	manager *Manager
+	noteID  string
+
+	mu  sync.Mutex
+	doc []rune
+	// history holds the latest operations applied in the session, the first of them as revision offset+1
+	history      []Operation
+	offset       int
+	participants map[*Participant]struct{}
+	// base is the content of the note at version, pending the operations applied to the document
+	// since, so a change made to the note outside of the session can be merged into it
+	base    []rune
+	version int
+	pending []Operation
+	// editor is the last user who changed the document
+	editor string
+
+	// ended is set once the last participant left, guarded by the note's lock
+	ended bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// Participant is a connection taking part in a session. Its messages are read from
+// Messages and the ones it sends are handed to Handle.
+type Participant struct {
+	ID      string
+	UserID  string
+	CanEdit bool
+
+	session *session
+	// outbox is guarded by the session's lock and closed when the participant leaves
+	outbox chan Message
+	closed bool
+	cursor *int
+	leave  sync.Once
+}
+
+// Join adds the user to the session of the note, starting it if nobody is editing the note yet.
+// Viewers and commenters can follow the edits, editors and the owner can make them when canWrite is set,
+// which callers clear for API tokens lacking the notes:write scope.
+func (m *Manager) Join(ctx context.Context, userID, noteID string, canWrite bool) (*Participant, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "collab.Join")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("note_id", noteID))
+
+	unlock := m.lockNote(noteID)
+	defer unlock()
+
+	// read under the note's lock, so the note is not loaded while a session ending is still saving it
+	note, role, err := m.notes.GetRole(ctx, userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("role", role))
+
+	m.mu.Lock()
+	s, ok := m.sessions[noteID]
+	if !ok {
+		s = &session{
+			manager:      m,
+			noteID:       noteID,
+			doc:          []rune(note.Content),
+			participants: make(map[*Participant]struct{}),
+			base:         []rune(note.Content),
+			version:      note.Version,
+			stop:         make(chan struct{}),
+			done:         make(chan struct{}),
+		}
+		m.sessions[noteID] = s
+		go s.saveEvery(m.interval)
+	}
+	m.mu.Unlock()
+
+	p := &Participant{
+		ID:      uuid.NewString(),
+		UserID:  userID,
+		CanEdit: canWrite && (role == entities.RoleEditor || role == entities.RoleOwner),
+		session: s,
+		outbox:  make(chan Message, outboxSize),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	init := Message{
+		Type:          TypeInit,
+		Revision:      s.revision(),
+		Content:       string(s.doc),
+		ParticipantID: p.ID,
+		UserID:        userID,
+		CanEdit:       p.CanEdit,
+	}
+	for other := range s.participants {
+		init.Participants = append(init.Participants, other.presence())
+	}
+	p.outbox <- init
+	s.broadcast(p, Message{Type: TypeJoined, ParticipantID: p.ID, UserID: userID})
+	s.participants[p] = struct{}{}
+	return p, nil
+}
+
+// Messages delivers the messages for the participant. It is closed when the participant
+// leaves or falls too far behind, the connection should then be closed.
+func (p *Participant) Messages() <-chan Message {
+	return p.outbox
+}
+
+// Handle applies a message the participant sent. Rejected messages are answered with a TypeError message.
+func (p *Participant) Handle(msg Message) {
+	s := p.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+	var err error
+	switch msg.Type {
+	case TypeOp:
+		err = s.apply(p, msg)
+	case TypeCursor:
+		err = s.moveCursor(p, msg.Cursor)
+	default:
+		err = fmt.Errorf("unknown message type %q", msg.Type)
+	}
+	if err != nil {
+		s.send(p, Message{Type: TypeError, Error: err.Error()})
+	}
+}
+
+// Leave removes the participant from its session. The last one to leave ends the
+// session, saving the document if it changed since the last save.
+func (p *Participant) Leave() {
+	p.leave.Do(func() {
+		s := p.session
+		m := s.manager
+		// held while the session ends, so a new session cannot load the note before it is saved
+		unlock := m.lockNote(s.noteID)
+		defer unlock()
+
+		s.mu.Lock()
+		s.drop(p)
+		last := len(s.participants) == 0
+		s.mu.Unlock()
+		// participants dropped for falling behind leave after the session may have ended
+		if !last || s.ended {
+			return
+		}
+
+		s.ended = true
+		m.mu.Lock()
+		delete(m.sessions, s.noteID)
+		m.mu.Unlock()
+		close(s.stop)
+		<-s.done
+		s.save()
+	})
+}
+
+// lockNote takes the note's lock and returns the function that releases it. Joins and leaves
+// of other notes go on while it is held.
+func (m *Manager) lockNote(noteID string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[noteID]
+	if !ok {
+		l = &noteLock{}
+		m.locks[noteID] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(m.locks, noteID)
+		}
+	}
+}
+
+func (p *Participant) presence() Presence {
+	return Presence{ParticipantID: p.ID, UserID: p.UserID, Cursor: p.cursor}
+}
+
+// apply transforms the participant's operation against the ones applied since the
+// revision it was made on, applies it and passes it on to the other participants
+func (s *session) apply(p *Participant, msg Message) error {
+	if !p.CanEdit {
+		return errors.New("only editors can change the note")
+	}
+	if msg.Revision < 0 || msg.Revision > s.revision() {
+		return fmt.Errorf("revision %d does not exist, the document is at %d", msg.Revision, s.revision())
+	}
+	if msg.Revision < s.offset {
+		return fmt.Errorf("revision %d is too old to edit, join again to get the document at %d", msg.Revision, s.revision())
+	}
+
+	op := msg.Operation
+	for _, applied := range s.history[msg.Revision-s.offset:] {
+		var err error
+		if op, _, err = Transform(op, applied); err != nil {
+			return err
+		}
+	}
+	doc, err := op.Apply(s.doc)
+	if err != nil {
+		return err
+	}
+	if len(doc) > entities.MaxContentLength {
+		return fmt.Errorf("a note can have at most %d characters", entities.MaxContentLength)
+	}
+
+	s.editor = p.UserID
+	s.pending = append(s.pending, op)
+	revision := s.push(op, doc)
+	s.send(p, Message{Type: TypeAck, Revision: revision})
+	s.broadcast(p, Message{Type: TypeOp, Revision: revision, Operation: op, ParticipantID: p.ID, UserID: p.UserID})
+	return nil
+}
+
+// push makes doc, which op turned the document into, the session's document and returns its
+// revision. The caller holds the lock.
+func (s *session) push(op Operation, doc []rune) int {
+	s.doc = doc
+	s.history = append(s.history, op)
+	if len(s.history) > maxHistory {
+		dropped := len(s.history) - maxHistory/2
+		s.history = slices.Clone(s.history[dropped:])
+		s.offset += dropped
+	}
+	for p := range s.participants {
+		if p.cursor != nil {
+			cursor := TransformIndex(op, *p.cursor)
+			p.cursor = &cursor
+		}
+	}
+	return s.revision()
+}
+
+// revision is the revision of the document, the caller holds the lock
+func (s *session) revision() int {
+	return s.offset + len(s.history)
+}
+
+// merge applies the changes made to the note outside of the session since the version the
+// session is based on, and passes them on to the participants as an edit. The caller holds the lock.
+func (s *session) merge(note entities.Note) error {
+	content := []rune(note.Content)
+	outside := Diff(s.base, content)
+	pending := make([]Operation, 0, len(s.pending))
+	for _, op := range s.pending {
+		var err error
+		if outside, op, err = Transform(outside, op); err != nil {
+			return err
+		}
+		pending = append(pending, op)
+	}
+	doc, err := outside.Apply(s.doc)
+	if err != nil {
+		return err
+	}
+
+	s.base, s.version, s.pending = content, note.Version, pending
+	if outside.IsNoop() {
+		return nil
+	}
+	revision := s.push(outside, doc)
+	s.broadcast(nil, Message{Type: TypeOp, Revision: revision, Operation: outside})
+	return nil
+}
+
+// moveCursor places the participant's cursor, nil hides it
+func (s *session) moveCursor(p *Participant, cursor *int) error {
+	if cursor != nil && (*cursor < 0 || *cursor > len(s.doc)) {
+		return fmt.Errorf("cursor %d is outside of the document", *cursor)
+	}
+	p.cursor = cursor
+	s.broadcast(p, Message{Type: TypeCursor, ParticipantID: p.ID, UserID: p.UserID, Cursor: cursor})
+	return nil
+}
+
+// broadcast sends the message to everyone but the sender, the caller holds the lock
+func (s *session) broadcast(sender *Participant, msg Message) {
+	for p := range s.participants {
+		if p != sender {
+			s.send(p, msg)
+		}
+	}
+}
+
+// send queues the message for the participant, dropping participants that fall too
+// far behind so one slow connection does not hold up the session. The caller holds the lock.
+func (s *session) send(p *Participant, msg Message) {
+	if p.closed {
+		return
+	}
+	select {
+	case p.outbox <- msg:
+	default:
+		s.drop(p)
+	}
+}
+
+// drop removes the participant and tells the others it left, the caller holds the lock
+func (s *session) drop(p *Participant) {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.outbox)
+	delete(s.participants, p)
+	s.broadcast(p, Message{Type: TypeLeft, ParticipantID: p.ID, UserID: p.UserID})
+}
+
+// saveEvery saves the document every interval until the session ends
+func (s *session) saveEvery(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.save()
+		}
+	}
+}
+
+// save writes the document to the note if it changed since the last save. The note is saved
+// as whoever edited it last, with the title as it is stored. Changes made to the note elsewhere
+// in the meantime are merged into the document first, so they are not overwritten.
+func (s *session) save() {
+	s.mu.Lock()
+	changed, revision := len(s.pending) > 0, s.revision()
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	ctx, span := tracing.Tracer().Start(ctx, "collab.Save")
+	defer span.End()
+	span.SetAttributes(attribute.String("note_id", s.noteID), attribute.Int("revision", revision))
+
+	if err := s.update(ctx); err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to save the edited note", "note_id", s.noteID, "error", err)
+	}
+}
+
+// update stores the document, merging in the changes made to the note since the session last
+// read it, and retrying when the note changes between reading and writing it
+func (s *session) update(ctx context.Context) error {
+	notesService := s.manager.notes
+	for attempt := 0; ; attempt++ {
+		s.mu.Lock()
+		editor := s.editor
+		s.mu.Unlock()
+		note, err := notesService.GetNote(ctx, editor, s.noteID)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		if note.Version != s.version {
+			if err := s.merge(note); err != nil {
+				s.mu.Unlock()
+				return err
+			}
+		}
+		content, saving := s.doc, len(s.pending)
+		s.mu.Unlock()
+		if len(content) == 0 {
+			// notes cannot be empty, the note keeps its content until something is written again
+			return nil
+		}
+		if string(content) == note.Content {
+			s.saved(note, content, saving)
+			return nil
+		}
+
+		updated, err := notesService.UpdateNote(ctx, editor, s.noteID, entities.NoteReq{
+			Title:   note.Title,
+			Content: string(content),
+			Version: note.Version,
+		})
+		if err == nil {
+			s.saved(updated, content, saving)
+			return nil
+		}
+		if !errors.Is(err, notes.ErrVersionConflict) || attempt == 2 {
+			return err
+		}
+	}
+}
+
+// saved records that the note at its version holds content, the document once the first n
+// pending operations were applied
+func (s *session) saved(note entities.Note, content []rune, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.base, s.version = content, note.Version
+	s.pending = slices.Clone(s.pending[n:])
+}
@@ -0,0 +1,514 @@
+package collab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/notes"
+	"notes/services/users"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperation(t *testing.T) {
+	op := Operation{}.Retain(2).Delete(1).Insert("ü").Retain(3)
+	require.Equal(t, Operation{{Retain: 2}, {Insert: "ü"}, {Delete: 1}, {Retain: 3}}, op)
+	require.Equal(t, 6, op.BaseLength())
+	require.Equal(t, 6, op.TargetLength())
+	require.False(t, op.IsNoop())
+	require.True(t, Operation{}.Retain(3).IsNoop())
+
+	doc, err := op.Apply([]rune("abcdef"))
+	require.NoError(t, err)
+	require.Equal(t, "abüdef", string(doc))
+	_, err = op.Apply([]rune("abc"))
+	require.ErrorIs(t, err, ErrInvalidOperation)
+
+	data, err := json.Marshal(op)
+	require.NoError(t, err)
+	require.JSONEq(t, `[2, "ü", -1, 3]`, string(data))
+	var decoded Operation
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, op, decoded)
+	for _, invalid := range []string{`[0]`, `[1.5]`, `[""]`, `[true]`, `{}`} {
+		require.Error(t, json.Unmarshal([]byte(invalid), &decoded), invalid)
+	}
+
+	// positions after an edit move with it, text inserted at the position pushes it along
+	require.Equal(t, 3, TransformIndex(Operation{}.Insert("x").Retain(6), 2))
+	require.Equal(t, 1, TransformIndex(Operation{}.Retain(2).Insert("x").Retain(4), 1))
+	require.Equal(t, 3, TransformIndex(Operation{}.Retain(2).Insert("x").Retain(4), 2))
+	require.Equal(t, 1, TransformIndex(Operation{}.Retain(1).Delete(3).Retain(2), 3))
+	require.Equal(t, 2, TransformIndex(Operation{}.Retain(1).Delete(3).Retain(2), 5))
+
+	// a diff replaces what lies between the common start and end
+	require.Equal(t, Operation{}.Retain(2).Insert("ü").Delete(1).Retain(3), Diff([]rune("abcdef"), []rune("abüdef")))
+	require.Equal(t, Operation{}.Retain(3), Diff([]rune("abc"), []rune("abc")))
+	require.Equal(t, Operation{}.Insert("abc"), Diff(nil, []rune("abc")))
+	require.Equal(t, Operation{}.Retain(2).Delete(1), Diff([]rune("aaa"), []rune("aa")))
+}
+
+func TestTransform(t *testing.T) {
+	doc := []rune("hello")
+	a := Operation{}.Retain(5).Insert(" world")
+	b := Operation{}.Retain(5).Insert("!")
+	aPrime, bPrime, err := Transform(a, b)
+	require.NoError(t, err)
+	require.Equal(t, "hello world!", string(apply(t, apply(t, doc, a), bPrime)))
+	require.Equal(t, "hello world!", string(apply(t, apply(t, doc, b), aPrime)))
+
+	// both deleting the same text removes it once
+	a = Operation{}.Delete(2).Retain(3)
+	b = Operation{}.Retain(1).Delete(3).Retain(1)
+	aPrime, bPrime, err = Transform(a, b)
+	require.NoError(t, err)
+	require.Equal(t, "o", string(apply(t, apply(t, doc, a), bPrime)))
+	require.Equal(t, "o", string(apply(t, apply(t, doc, b), aPrime)))
+
+	_, _, err = Transform(a, Operation{}.Retain(4))
+	require.ErrorIs(t, err, ErrInvalidOperation)
+
+	// concurrent edits converge whichever order they are applied in
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range 1000 {
+		doc := randomText(rnd, rnd.IntN(20))
+		a, b := randomOperation(rnd, doc), randomOperation(rnd, doc)
+		aPrime, bPrime, err := Transform(a, b)
+		require.NoError(t, err)
+		require.Equal(t, string(apply(t, apply(t, doc, a), bPrime)), string(apply(t, apply(t, doc, b), aPrime)), "case %d", i)
+	}
+}
+
+func TestSession(t *testing.T) {
+	ctx := t.Context()
+	service, note, owner, collaborators := newNote(t, "hello", entities.RoleViewer)
+	viewer := collaborators[0]
+	manager := New(service, WithSaveInterval(time.Hour))
+
+	_, err := manager.Join(ctx, uuid.NewString(), note.ID, true)
+	require.ErrorIs(t, err, notes.ErrNotFound)
+
+	alice, err := manager.Join(ctx, owner, note.ID, true)
+	require.NoError(t, err)
+	init := receive(t, alice)
+	require.Equal(t, TypeInit, init.Type)
+	require.Equal(t, "hello", init.Content)
+	require.Equal(t, 0, init.Revision)
+	require.True(t, init.CanEdit)
+	require.Empty(t, init.Participants)
+
+	bob, err := manager.Join(ctx, viewer, note.ID, true)
+	require.NoError(t, err)
+	init = receive(t, bob)
+	require.False(t, init.CanEdit)
+	require.Len(t, init.Participants, 1)
+	require.Equal(t, alice.ID, init.Participants[0].ParticipantID)
+	joined := receive(t, alice)
+	require.Equal(t, TypeJoined, joined.Type)
+	require.Equal(t, bob.ID, joined.ParticipantID)
+
+	// viewers follow the edits but cannot make them
+	bob.Handle(Message{Type: TypeOp, Operation: Operation{}.Insert("x").Retain(5)})
+	require.Equal(t, TypeError, receive(t, bob).Type)
+	cursor := 5
+	bob.Handle(Message{Type: TypeCursor, Cursor: &cursor})
+	moved := receive(t, alice)
+	require.Equal(t, TypeCursor, moved.Type)
+	require.Equal(t, 5, *moved.Cursor)
+
+	alice.Handle(Message{Type: TypeOp, Revision: 0, Operation: Operation{}.Insert("oh ").Retain(5)})
+	require.Equal(t, Message{Type: TypeAck, Revision: 1}, receive(t, alice))
+	op := receive(t, bob)
+	require.Equal(t, TypeOp, op.Type)
+	require.Equal(t, 1, op.Revision)
+	require.Equal(t, alice.ID, op.ParticipantID)
+
+	// an edit made on an older revision is transformed against the ones applied since
+	alice.Handle(Message{Type: TypeOp, Revision: 0, Operation: Operation{}.Retain(5).Insert("!")})
+	require.Equal(t, 2, receive(t, alice).Revision)
+	require.Equal(t, Operation{}.Retain(8).Insert("!"), receive(t, bob).Operation)
+	for _, msg := range []Message{
+		{Type: TypeOp, Revision: 3, Operation: Operation{}.Retain(9)},
+		{Type: TypeOp, Revision: 2, Operation: Operation{}.Retain(4)},
+		{Type: TypeCursor, Cursor: &[]int{10}[0]},
+		{Type: "unknown"},
+	} {
+		alice.Handle(msg)
+		require.Equal(t, TypeError, receive(t, alice).Type)
+	}
+
+	// participants joining later see the cursors where the edits moved them
+	carol, err := manager.Join(ctx, owner, note.ID, true)
+	require.NoError(t, err)
+	init = receive(t, carol)
+	require.Equal(t, "oh hello!", init.Content)
+	require.Equal(t, 2, init.Revision)
+	for _, presence := range init.Participants {
+		if presence.ParticipantID == bob.ID {
+			require.Equal(t, 9, *presence.Cursor)
+		}
+	}
+
+	alice.Leave()
+	alice.Leave()
+	drain(alice)
+	_, ok := <-alice.Messages()
+	require.False(t, ok)
+	left := drain(bob)
+	require.Equal(t, TypeLeft, left[len(left)-1].Type)
+	require.Equal(t, alice.ID, left[len(left)-1].ParticipantID)
+	bob.Leave()
+	carol.Leave()
+
+	// the last one to leave saves the document as whoever edited it last
+	saved, err := service.GetNote(ctx, owner, note.ID)
+	require.NoError(t, err)
+	require.Equal(t, "oh hello!", saved.Content)
+	require.Equal(t, "plan", saved.Title)
+	require.Equal(t, note.Version+1, saved.Version)
+
+	// participants that fall behind are dropped
+	dave, err := manager.Join(ctx, owner, note.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, "oh hello!", receive(t, dave).Content)
+	erin, err := manager.Join(ctx, owner, note.ID, true)
+	require.NoError(t, err)
+	for range outboxSize + 1 {
+		erin.Handle(Message{Type: TypeCursor, Cursor: &cursor})
+	}
+	require.Len(t, drain(dave), outboxSize)
+	dave.Handle(Message{Type: TypeCursor, Cursor: &cursor})
+	dave.Leave()
+	erin.Leave()
+}
+
+// TestConcurrentEdits races editors making random edits on the same note, each one keeping its
+// own copy of the document the way a client does, and checks they all end up with the same text
+func TestConcurrentEdits(t *testing.T) {
+	const edits = 50
+	ctx := t.Context()
+	service, note, owner, editors := newNote(t, "the quick brown fox", entities.RoleEditor, entities.RoleEditor, entities.RoleEditor)
+	manager := New(service, WithSaveInterval(5*time.Millisecond))
+
+	var clients []*client
+	for i, userID := range append(editors, owner) {
+		participant, err := manager.Join(ctx, userID, note.ID, true)
+		require.NoError(t, err)
+		clients = append(clients, &client{participant: participant, rnd: rand.New(rand.NewPCG(uint64(i), 7))})
+	}
+	total := len(clients) * edits
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(clients))
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.run(edits, total)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	s := clients[0].participant.session
+	s.mu.Lock()
+	doc := string(s.doc)
+	require.Len(t, s.history, total)
+	s.mu.Unlock()
+	for _, c := range clients {
+		require.Equal(t, doc, string(c.doc))
+	}
+
+	for _, c := range clients {
+		c.participant.Leave()
+	}
+	saved, err := service.GetNote(ctx, owner, note.ID)
+	require.NoError(t, err)
+	require.Equal(t, doc, saved.Content)
+}
+
+func TestOutsideChanges(t *testing.T) {
+	ctx := t.Context()
+	service, note, owner, _ := newNote(t, "hello")
+	manager := New(service, WithSaveInterval(time.Hour))
+
+	alice, err := manager.Join(ctx, owner, note.ID, true)
+	require.NoError(t, err)
+	receive(t, alice)
+	alice.Handle(Message{Type: TypeOp, Revision: 0, Operation: Operation{}.Retain(5).Insert("!")})
+	require.Equal(t, 1, receive(t, alice).Revision)
+
+	// a change made through the API while the note is edited is merged into the session, not overwritten
+	_, err = service.UpdateNote(ctx, owner, note.ID, entities.NoteReq{Title: "plan", Content: "oh hello", Version: note.Version})
+	require.NoError(t, err)
+	alice.session.save()
+	merged := receive(t, alice)
+	require.Equal(t, TypeOp, merged.Type)
+	require.Equal(t, 2, merged.Revision)
+	require.Equal(t, Operation{}.Insert("oh ").Retain(6), merged.Operation)
+
+	saved, err := service.GetNote(ctx, owner, note.ID)
+	require.NoError(t, err)
+	require.Equal(t, "oh hello!", saved.Content)
+
+	// the session goes on from the saved note
+	alice.Handle(Message{Type: TypeOp, Revision: 2, Operation: Operation{}.Retain(9).Insert("?")})
+	require.Equal(t, 3, receive(t, alice).Revision)
+	alice.Leave()
+	saved, err = service.GetNote(ctx, owner, note.ID)
+	require.NoError(t, err)
+	require.Equal(t, "oh hello!?", saved.Content)
+}
+
+func TestHistoryLimit(t *testing.T) {
+	ctx := t.Context()
+	service, note, owner, _ := newNote(t, "a")
+	manager := New(service, WithSaveInterval(time.Hour))
+
+	alice, err := manager.Join(ctx, owner, note.ID, true)
+	require.NoError(t, err)
+	receive(t, alice)
+	for revision := range maxHistory + 1 {
+		alice.Handle(Message{Type: TypeOp, Revision: revision, Operation: Operation{}.Insert("b").Delete(1)})
+		require.Equal(t, revision+1, receive(t, alice).Revision)
+	}
+
+	// the older half of the history is dropped, edits made on it are rejected
+	alice.session.mu.Lock()
+	require.Len(t, alice.session.history, maxHistory/2)
+	alice.session.mu.Unlock()
+	alice.Handle(Message{Type: TypeOp, Revision: 1, Operation: Operation{}.Insert("c").Delete(1)})
+	require.Equal(t, TypeError, receive(t, alice).Type)
+	alice.Handle(Message{Type: TypeOp, Revision: maxHistory + 1 - maxHistory/2, Operation: Operation{}.Insert("c").Delete(1)})
+	require.Equal(t, maxHistory+2, receive(t, alice).Revision)
+	alice.Leave()
+}
+
+// slowNotes holds up saving a note until release is closed
+type slowNotes struct {
+	*notes.Service
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (n *slowNotes) UpdateNote(ctx context.Context, userID, id string, req entities.NoteReq) (entities.Note, error) {
+	close(n.saving)
+	<-n.release
+	return n.Service.UpdateNote(ctx, userID, id, req)
+}
+
+func TestJoinWhileSaving(t *testing.T) {
+	ctx := t.Context()
+	service, note, owner, _ := newNote(t, "hello")
+	other, err := service.CreateNote(ctx, owner, entities.NoteReq{Title: "other", Content: "other"})
+	require.NoError(t, err)
+	slow := &slowNotes{Service: service, saving: make(chan struct{}), release: make(chan struct{})}
+	manager := New(slow, WithSaveInterval(time.Hour))
+
+	alice, err := manager.Join(ctx, owner, note.ID, true)
+	require.NoError(t, err)
+	receive(t, alice)
+	alice.Handle(Message{Type: TypeOp, Operation: Operation{}.Retain(5).Insert("!")})
+	receive(t, alice)
+	left := make(chan struct{})
+	go func() {
+		defer close(left)
+		alice.Leave()
+	}()
+	<-slow.saving
+
+	// other notes can be joined while the note is saved
+	bob, err := manager.Join(ctx, owner, other.ID, true)
+	require.NoError(t, err)
+	require.Equal(t, "other", receive(t, bob).Content)
+	bob.Leave()
+
+	// the note itself is only loaded again once it is saved
+	joined := make(chan *Participant)
+	go func() {
+		carol, err := manager.Join(ctx, owner, note.ID, true)
+		require.NoError(t, err)
+		joined <- carol
+	}()
+	select {
+	case <-joined:
+		t.Fatal("joined before the note was saved")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(slow.release)
+	<-left
+	carol := <-joined
+	require.Equal(t, "hello!", receive(t, carol).Content)
+	carol.Leave()
+}
+
+// client edits the document the way the clients of a session do: it sends one edit at a
+// time and transforms the edits of the others against the one it is waiting on
+type client struct {
+	participant *Participant
+	rnd         *rand.Rand
+	joined      bool
+	doc         []rune
+	revision    int
+	pending     Operation
+}
+
+// run makes the edits and returns once the client has seen all of the session's edits
+func (c *client) run(edits, total int) error {
+	timeout := time.After(10 * time.Second)
+	for c.revision < total || c.pending != nil {
+		if c.joined && c.pending == nil && edits > 0 {
+			op := randomEdit(c.rnd, c.doc)
+			doc, err := op.Apply(c.doc)
+			if err != nil {
+				return err
+			}
+			c.doc, c.pending = doc, op
+			edits--
+			c.participant.Handle(Message{Type: TypeOp, Revision: c.revision, Operation: op})
+		}
+
+		var msg Message
+		select {
+		case m, ok := <-c.participant.Messages():
+			if !ok {
+				return fmt.Errorf("participant %s was dropped", c.participant.ID)
+			}
+			msg = m
+		case <-timeout:
+			return fmt.Errorf("participant %s is stuck at revision %d", c.participant.ID, c.revision)
+		}
+		switch msg.Type {
+		case TypeInit:
+			c.joined, c.doc, c.revision = true, []rune(msg.Content), msg.Revision
+		case TypeAck:
+			c.pending, c.revision = nil, msg.Revision
+		case TypeOp:
+			op := msg.Operation
+			if c.pending != nil {
+				var err error
+				if c.pending, op, err = Transform(c.pending, op); err != nil {
+					return err
+				}
+			}
+			doc, err := op.Apply(c.doc)
+			if err != nil {
+				return err
+			}
+			c.doc, c.revision = doc, msg.Revision
+		case TypeError:
+			return fmt.Errorf("participant %s: %s", c.participant.ID, msg.Error)
+		}
+	}
+	return nil
+}
+
+// newNote returns a service with a note of the owner, shared with a collaborator for each role
+func newNote(t *testing.T, content string, roles ...string) (*notes.Service, entities.Note, string, []string) {
+	ctx := t.Context()
+	directory := users.NewMemoryStore()
+	service := notes.New(notes.NewMemoryStore(), notes.WithUsers(directory))
+	user := func() entities.User {
+		id := uuid.NewString()
+		u, err := directory.CreateUser(ctx, entities.User{ID: id, Email: id + "@example.com"})
+		require.NoError(t, err)
+		return u
+	}
+
+	owner := user()
+	note, err := service.CreateNote(ctx, owner.ID, entities.NoteReq{Title: "plan", Content: content})
+	require.NoError(t, err)
+	var collaborators []string
+	for _, role := range roles {
+		collaborator := user()
+		_, err := service.ShareNote(ctx, owner.ID, note.ID, entities.ShareReq{Email: collaborator.Email, Role: role})
+		require.NoError(t, err)
+		collaborators = append(collaborators, collaborator.ID)
+	}
+	return service, note, owner.ID, collaborators
+}
+
+func apply(t *testing.T, doc []rune, op Operation) []rune {
+	result, err := op.Apply(doc)
+	require.NoError(t, err)
+	return result
+}
+
+// receive returns the next message for the participant
+func receive(t *testing.T, p *Participant) Message {
+	select {
+	case msg := <-p.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message for the participant")
+		return Message{}
+	}
+}
+
+// drain returns the messages waiting for the participant
+func drain(p *Participant) []Message {
+	var messages []Message
+	for {
+		select {
+		case msg, ok := <-p.Messages():
+			if !ok {
+				return messages
+			}
+			messages = append(messages, msg)
+		default:
+			return messages
+		}
+	}
+}
+
+// randomText returns n random letters, some of them outside of ASCII
+func randomText(rnd *rand.Rand, n int) []rune {
+	letters := []rune("abcdeéü✓")
+	text := make([]rune, n)
+	for i := range text {
+		text[i] = letters[rnd.IntN(len(letters))]
+	}
+	return text
+}
+
+// randomOperation returns an operation of random retains, inserts and deletes over the document
+func randomOperation(rnd *rand.Rand, doc []rune) Operation {
+	var op Operation
+	for left := len(doc); left > 0 || rnd.IntN(3) == 0; {
+		n := min(left, 1+rnd.IntN(4))
+		switch rnd.IntN(3) {
+		case 0:
+			op = op.Insert(string(randomText(rnd, 1+rnd.IntN(3))))
+			if left == 0 {
+				return op
+			}
+		case 1:
+			op = op.Delete(n)
+			left -= n
+		default:
+			op = op.Retain(n)
+			left -= n
+		}
+	}
+	return op
+}
+
+// randomEdit returns an edit a user would make, typing or deleting a few characters at one position
+func randomEdit(rnd *rand.Rand, doc []rune) Operation {
+	pos := rnd.IntN(len(doc) + 1)
+	op := Operation{}.Retain(pos)
+	if n := min(len(doc)-pos, 1+rnd.IntN(3)); n > 0 && len(doc) > 10 && rnd.IntN(3) == 0 {
+		return op.Delete(n).Retain(len(doc) - pos - n)
+	}
+	return op.Insert(string(randomText(rnd, 1+rnd.IntN(3)))).Retain(len(doc) - pos)
+}
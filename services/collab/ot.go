@@ -0,0 +1,283 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrInvalidOperation is returned when an operation does not fit the document it is applied to
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// Component is one step of an Operation, exactly one of its fields is set
+type Component struct {
+	// Retain skips over that many characters
+	Retain int
+	// Insert inserts the text at the current position
+	Insert string
+	// Delete removes that many characters
+	Delete int
+}
+
+// Operation is a text edit that walks over the whole document, the same model as ot.js.
+// Positions and lengths count Unicode code points. On the wire an operation is a JSON
+// array of its components: positive numbers retain, negative numbers delete and
+// strings are inserted, so [3, "abc", -2] inserts abc after the third character and
+// removes the two characters that followed it.
+type Operation []Component
+
+// Retain returns the operation followed by skipping n characters
+func (o Operation) Retain(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+	if len(o) > 0 && o[len(o)-1].Retain > 0 {
+		o[len(o)-1].Retain += n
+		return o
+	}
+	return append(o, Component{Retain: n})
+}
+
+// Insert returns the operation followed by inserting s. Inserts are kept before
+// deletes at the same position so equal edits always look the same.
+func (o Operation) Insert(s string) Operation {
+	if s == "" {
+		return o
+	}
+	n := len(o)
+	switch {
+	case n > 0 && o[n-1].Insert != "":
+		o[n-1].Insert += s
+		return o
+	case n > 0 && o[n-1].Delete > 0:
+		if n > 1 && o[n-2].Insert != "" {
+			o[n-2].Insert += s
+			return o
+		}
+		o = append(o, o[n-1])
+		o[n-1] = Component{Insert: s}
+		return o
+	}
+	return append(o, Component{Insert: s})
+}
+
+// Delete returns the operation followed by removing n characters
+func (o Operation) Delete(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+	if len(o) > 0 && o[len(o)-1].Delete > 0 {
+		o[len(o)-1].Delete += n
+		return o
+	}
+	return append(o, Component{Delete: n})
+}
+
+// Diff returns an operation that turns from into to, replacing what lies between the
+// text they start and end with
+func Diff(from, to []rune) Operation {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+	return Operation{}.
+		Retain(prefix).
+		Insert(string(to[prefix : len(to)-suffix])).
+		Delete(len(from) - prefix - suffix).
+		Retain(suffix)
+}
+
+// BaseLength is the length of the documents the operation can be applied to
+func (o Operation) BaseLength() int {
+	n := 0
+	for _, c := range o {
+		n += c.Retain + c.Delete
+	}
+	return n
+}
+
+// TargetLength is the length of the document after applying the operation
+func (o Operation) TargetLength() int {
+	n := 0
+	for _, c := range o {
+		n += c.Retain + utf8.RuneCountInString(c.Insert)
+	}
+	return n
+}
+
+// IsNoop reports whether the operation leaves the document as it is
+func (o Operation) IsNoop() bool {
+	for _, c := range o {
+		if c.Insert != "" || c.Delete > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the document after the operation
+func (o Operation) Apply(doc []rune) ([]rune, error) {
+	if o.BaseLength() != len(doc) {
+		return nil, fmt.Errorf("%w: it expects %d characters, the document has %d", ErrInvalidOperation, o.BaseLength(), len(doc))
+	}
+	result := make([]rune, 0, o.TargetLength())
+	pos := 0
+	for _, c := range o {
+		switch {
+		case c.Retain > 0:
+			result = append(result, doc[pos:pos+c.Retain]...)
+			pos += c.Retain
+		case c.Insert != "":
+			result = append(result, []rune(c.Insert)...)
+		default:
+			pos += c.Delete
+		}
+	}
+	return result, nil
+}
+
+// Transform takes two operations a and b made concurrently on the same document and returns
+// a' and b', so applying a then b' gives the same document as applying b then a'.
+// When both insert at the same position, the text of a ends up first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLength() != b.BaseLength() {
+		return nil, nil, fmt.Errorf("%w: the operations were made on documents of different lengths", ErrInvalidOperation)
+	}
+
+	var aPrime, bPrime Operation
+	i, j := 0, 0
+	var ca, cb Component
+	nextA := func() {
+		ca = Component{}
+		if i < len(a) {
+			ca = a[i]
+			i++
+		}
+	}
+	nextB := func() {
+		cb = Component{}
+		if j < len(b) {
+			cb = b[j]
+			j++
+		}
+	}
+	nextA()
+	nextB()
+
+	for ca != (Component{}) || cb != (Component{}) {
+		switch {
+		case ca.Insert != "":
+			aPrime = aPrime.Insert(ca.Insert)
+			bPrime = bPrime.Retain(utf8.RuneCountInString(ca.Insert))
+			nextA()
+		case cb.Insert != "":
+			aPrime = aPrime.Retain(utf8.RuneCountInString(cb.Insert))
+			bPrime = bPrime.Insert(cb.Insert)
+			nextB()
+		case ca == (Component{}) || cb == (Component{}):
+			return nil, nil, fmt.Errorf("%w: the operations do not cover the same document", ErrInvalidOperation)
+		case ca.Retain > 0 && cb.Retain > 0:
+			n := min(ca.Retain, cb.Retain)
+			aPrime = aPrime.Retain(n)
+			bPrime = bPrime.Retain(n)
+			ca.Retain -= n
+			cb.Retain -= n
+		case ca.Delete > 0 && cb.Delete > 0:
+			// both removed the same text, there is nothing left to remove
+			n := min(ca.Delete, cb.Delete)
+			ca.Delete -= n
+			cb.Delete -= n
+		case ca.Delete > 0:
+			n := min(ca.Delete, cb.Retain)
+			aPrime = aPrime.Delete(n)
+			ca.Delete -= n
+			cb.Retain -= n
+		default:
+			n := min(ca.Retain, cb.Delete)
+			bPrime = bPrime.Delete(n)
+			ca.Retain -= n
+			cb.Delete -= n
+		}
+		if ca.Retain == 0 && ca.Delete == 0 && ca.Insert == "" {
+			nextA()
+		}
+		if cb.Retain == 0 && cb.Delete == 0 && cb.Insert == "" {
+			nextB()
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// TransformIndex returns where a position in the document ends up after the operation,
+// text inserted at the position pushes it along
+func TransformIndex(o Operation, index int) int {
+	moved := index
+	for _, c := range o {
+		switch {
+		case c.Retain > 0:
+			index -= c.Retain
+		case c.Insert != "":
+			moved += utf8.RuneCountInString(c.Insert)
+		default:
+			moved -= min(index, c.Delete)
+			index -= c.Delete
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return moved
+}
+
+// MarshalJSON encodes the operation in the ot.js format
+func (o Operation) MarshalJSON() ([]byte, error) {
+	parts := make([]any, 0, len(o))
+	for _, c := range o {
+		switch {
+		case c.Retain > 0:
+			parts = append(parts, c.Retain)
+		case c.Insert != "":
+			parts = append(parts, c.Insert)
+		default:
+			parts = append(parts, -c.Delete)
+		}
+	}
+	return json.Marshal(parts)
+}
+
+// UnmarshalJSON decodes an operation in the ot.js format
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var parts []any
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return err
+	}
+	var op Operation
+	for _, part := range parts {
+		switch v := part.(type) {
+		case string:
+			if v == "" || !utf8.ValidString(v) {
+				return fmt.Errorf("%w: inserts must be non-empty UTF-8 text", ErrInvalidOperation)
+			}
+			op = op.Insert(v)
+		case float64:
+			n := int(v)
+			if float64(n) != v || n == 0 {
+				return fmt.Errorf("%w: retains and deletes must be non-zero integers", ErrInvalidOperation)
+			}
+			if n > 0 {
+				op = op.Retain(n)
+			} else {
+				op = op.Delete(-n)
+			}
+		default:
+			return fmt.Errorf("%w: components must be numbers or strings", ErrInvalidOperation)
+		}
+	}
+	*o = op
+	return nil
+}
@@ -1,8 +1,570 @@
 package elastic
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/search"
+	"notes/services/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// mappings of the notes index, user_id is a keyword so searches can be filtered on it exactly
+var mappings = map[string]any{
+	"properties": map[string]any{
+		"note_id":      map[string]any{"type": "keyword"},
+		"user_id":      map[string]any{"type": "keyword"},
+		"title":        map[string]any{"type": "text"},
+		"content":      map[string]any{"type": "text"},
+		"content_type": map[string]any{"type": "keyword"},
+		"notebook_id":  map[string]any{"type": "keyword"},
+		"tags":         map[string]any{"type": "keyword"},
+		"version":      map[string]any{"type": "integer"},
+		"created_at":   map[string]any{"type": "date"},
+		"updated_at":   map[string]any{"type": "date"},
+	},
+}
+
+// catchUpMargin widens the catch-up passes of Reindex. updated_at is stored to the second and set by
+// the database clock, indexing a few notes twice is harmless as the versions keep the newest.
+const catchUpMargin = time.Minute
+
+// searchFields are the fields a query matches, title matches count double
+var searchFields = []string{"title^2", "content"}
+
+var _ search.Index = (*Service)(nil)
+
+// Service is a search.Index stored in Elasticsearch, it speaks to the REST API directly.
+// Notes are read and written through an alias so Reindex can swap the index behind it.
 type Service struct {
+	baseURL string
+	index   string
+	client  *http.Client
+}
+
+// New returns a client for the given Elasticsearch URL that keeps notes behind the alias index
+func New(baseURL, index string) *Service {
+	return &Service{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		index:   index,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type document struct {
+	NoteID      string    `json:"note_id"`
+	UserID      string    `json:"user_id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"content"`
+	ContentType string    `json:"content_type"`
+	NotebookID  string    `json:"notebook_id,omitempty"`
+	Tags        []string  `json:"tags"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func toDocument(note entities.Note) document {
+	return document{
+		NoteID:      note.ID,
+		UserID:      note.UserID,
+		Title:       note.Title,
+		Content:     note.Content,
+		ContentType: note.ContentType,
+		NotebookID:  note.NotebookID,
+		Tags:        note.Tags,
+		Version:     note.Version,
+		CreatedAt:   note.CreatedAt,
+		UpdatedAt:   note.UpdatedAt,
+	}
+}
+
+// EnsureIndex creates an index behind the alias if neither exists yet
+func (s *Service) EnsureIndex(ctx context.Context) error {
+	ctx, span := tracing.Tracer().Start(ctx, "elastic.EnsureIndex")
+	defer span.End()
+
+	exists, err := s.exists(ctx, s.index)
+	if err != nil || exists {
+		return err
+	}
+	return s.createIndex(ctx, s.newIndexName(), true)
+}
+
+// Reindex rebuilds the index without downtime. It creates a fresh index,
+// calls load to stream every note into it through bulk, then atomically
+// points the alias at it and deletes the indices it replaced. Searches are
+// served by the old index until the swap. It returns the new index name.
+//
+// Notes written while it runs only reach the old index, so changed is called
+// to stream the notes changed since a point in time, deleted ones included:
+// once before the swap for everything since the start, and once after it for
+// what was written during that first pass. Documents carry the note version,
+// so a stale bulk write never replaces a newer one.
+func (s *Service) Reindex(
+	ctx context.Context,
+	load func(bulk func([]entities.Note) error) error,
+	changed func(since time.Time, bulk func([]entities.Note) error) error,
+) (string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "elastic.Reindex")
+	defer span.End()
+
+	start := time.Now()
+	name := s.newIndexName()
+	span.SetAttributes(attribute.String("index", name))
+	if err := s.createIndex(ctx, name, false); err != nil {
+		return "", err
+	}
+
+	bulk := func(notes []entities.Note) error {
+		return s.bulk(ctx, name, notes)
+	}
+	err := load(bulk)
+	caughtUp := time.Now()
+	if err == nil {
+		err = changed(start.Add(-catchUpMargin), bulk)
+	}
+	if err == nil {
+		err = s.refresh(ctx, name)
+	}
+	if err != nil {
+		// the alias still points at the old index, only the new one has to go
+		return "", errors.Join(err, s.deleteIndex(ctx, name))
+	}
+
+	if err := s.swapAlias(ctx, name); err != nil {
+		return "", errors.Join(err, s.deleteIndex(ctx, name))
+	}
+	// writes made during the first pass may only have reached the old index, the alias now leads to the new one
+	if err := changed(caughtUp.Add(-catchUpMargin), bulk); err != nil {
+		return name, fmt.Errorf("catching up after swapping alias %s: %w", s.index, err)
+	}
+	return name, nil
+}
+
+// IndexNote adds the note to the index or replaces an older version of it
+func (s *Service) IndexNote(ctx context.Context, note entities.Note) error {
+	ctx, span := tracing.Tracer().Start(ctx, "elastic.IndexNote")
+	defer span.End()
+
+	body, err := json.Marshal(toDocument(note))
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s?version=%d&version_type=external_gte", s.docPath(note.ID), note.Version)
+	res, err := s.do(ctx, http.MethodPut, path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	// a newer version of the note is already indexed
+	if res.StatusCode == http.StatusConflict {
+		res.Body.Close()
+		return nil
+	}
+	return checkResponse(res, "indexing note")
+}
+
+// DeleteNote removes the note from the index
+func (s *Service) DeleteNote(ctx context.Context, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "elastic.DeleteNote")
+	defer span.End()
+
+	res, err := s.do(ctx, http.MethodDelete, s.docPath(id), nil)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil
+	}
+	return checkResponse(res, "deleting note")
+}
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			ID        string              `json:"_id"`
+			Score     float64             `json:"_score"`
+			Source    document            `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// Search runs a multi_match query over title and content, boosting title matches
+func (s *Service) Search(ctx context.Context, query string, filters search.Filters) (entities.SearchResults, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "elastic.Search")
+	defer span.End()
+
+	body, err := json.Marshal(searchBody(query, filters))
+	if err != nil {
+		return entities.SearchResults{}, err
+	}
+	res, err := s.do(ctx, http.MethodPost, "/"+s.index+"/_search", bytes.NewReader(body))
+	if err != nil {
+		return entities.SearchResults{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return entities.SearchResults{}, responseError(res, "searching notes")
+	}
+
+	var result searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return entities.SearchResults{}, fmt.Errorf("decoding search response: %w", err)
+	}
+
+	results := entities.SearchResults{
+		Total: result.Hits.Total.Value,
+		Hits:  make([]entities.SearchHit, 0, len(result.Hits.Hits)),
+	}
+	for _, hit := range result.Hits.Hits {
+		results.Hits = append(results.Hits, entities.SearchHit{
+			Note: entities.Note{
+				ID:          hit.ID,
+				UserID:      hit.Source.UserID,
+				Title:       hit.Source.Title,
+				Content:     hit.Source.Content,
+				ContentType: hit.Source.ContentType,
+				NotebookID:  hit.Source.NotebookID,
+				Tags:        hit.Source.Tags,
+				Version:     hit.Source.Version,
+				CreatedAt:   hit.Source.CreatedAt,
+				UpdatedAt:   hit.Source.UpdatedAt,
+			},
+			Score:      hit.Score,
+			Highlights: hit.Highlight,
+		})
+	}
+	return results, nil
+}
+
+func searchBody(query string, filters search.Filters) map[string]any {
+	var filter []any
+	if filters.UserID != "" {
+		filter = append(filter, map[string]any{"term": map[string]any{"user_id": filters.UserID}})
+	}
+	if !filters.CreatedAfter.IsZero() || !filters.CreatedBefore.IsZero() {
+		created := map[string]any{}
+		if !filters.CreatedAfter.IsZero() {
+			created["gte"] = filters.CreatedAfter.Format(time.RFC3339Nano)
+		}
+		if !filters.CreatedBefore.IsZero() {
+			created["lt"] = filters.CreatedBefore.Format(time.RFC3339Nano)
+		}
+		filter = append(filter, map[string]any{"range": map[string]any{"created_at": created}})
+	}
+
+	must := map[string]any{
+		"multi_match": map[string]any{
+			"query":  query,
+			"fields": searchFields,
+		},
+	}
+	if filters.Mode == search.ModeBoolean {
+		must = booleanQuery(query)
+	}
+
+	body := map[string]any{
+		"query": map[string]any{
+			"bool": map[string]any{
+				"must":   must,
+				"filter": filter,
+			},
+		},
+		"highlight": map[string]any{
+			"encoder":   "html",
+			"pre_tags":  []string{"<em>"},
+			"post_tags": []string{"</em>"},
+			"fields": map[string]any{
+				"title":   map[string]any{"number_of_fragments": 0},
+				"content": map[string]any{"fragment_size": 100, "number_of_fragments": 3},
+			},
+		},
+	}
+	if filters.Limit > 0 {
+		body["size"] = filters.Limit
+	}
+	return body
+}
+
+// newIndexName returns a unique name for a concrete index behind the alias
+func (s *Service) newIndexName() string {
+	return fmt.Sprintf("%s-%d", s.index, time.Now().UnixNano())
+}
+
+func (s *Service) exists(ctx context.Context, name string) (bool, error) {
+	res, err := s.do(ctx, http.MethodHead, "/"+name, nil)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("checking index %s: unexpected status %d", name, res.StatusCode)
+	}
+}
+
+func (s *Service) createIndex(ctx context.Context, name string, aliased bool) error {
+	body := map[string]any{"mappings": mappings}
+	if aliased {
+		body["aliases"] = map[string]any{s.index: map[string]any{}}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodPut, "/"+name, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	return checkResponse(res, "creating index "+name)
+}
+
+func (s *Service) deleteIndex(ctx context.Context, name string) error {
+	res, err := s.do(ctx, http.MethodDelete, "/"+name, nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(res, "deleting index "+name)
+}
+
+func (s *Service) refresh(ctx context.Context, name string) error {
+	res, err := s.do(ctx, http.MethodPost, "/"+name+"/_refresh", nil)
+	if err != nil {
+		return err
+	}
+	return checkResponse(res, "refreshing index "+name)
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk indexes the notes into the named index with a single _bulk request, removing the deleted ones.
+// Writes are versioned by the note version, one for an older version than the indexed one is skipped.
+func (s *Service) bulk(ctx context.Context, name string, notes []entities.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, note := range notes {
+		meta := map[string]any{"_id": note.ID, "version": note.Version, "version_type": "external_gte"}
+		if note.DeletedAt != nil {
+			if err := enc.Encode(map[string]any{"delete": meta}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := enc.Encode(map[string]any{"index": meta}); err != nil {
+			return err
+		}
+		if err := enc.Encode(toDocument(note)); err != nil {
+			return err
+		}
+	}
+
+	res, err := s.send(ctx, http.MethodPost, "/"+name+"/_bulk", "application/x-ndjson", &body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError(res, "bulk indexing notes")
+	}
+
+	var result bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	failed := 0
+	var first error
+	for _, item := range result.Items {
+		for action, op := range item {
+			// a newer version is already indexed, or the deleted note never was
+			if op.Status < 300 || op.Status == http.StatusConflict || (action == "delete" && op.Status == http.StatusNotFound) {
+				continue
+			}
+			failed++
+			if first == nil {
+				first = fmt.Errorf("note %s: %s", op.ID, op.Error)
+			}
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("bulk indexing notes: %d of %d failed, first: %w", failed, len(notes), first)
+}
+
+// swapAlias points the alias at the named index and deletes the indices it
+// pointed at before. The alias is moved in one _aliases call so searches never
+// see a missing or partial index. An index that was created under the alias
+// name itself is removed in the same call.
+func (s *Service) swapAlias(ctx context.Context, name string) error {
+	current, err := s.aliased(ctx)
+	if err != nil {
+		return err
+	}
+
+	actions := []any{
+		map[string]any{"add": map[string]any{"index": name, "alias": s.index}},
+	}
+	for _, old := range current {
+		actions = append(actions, map[string]any{"remove": map[string]any{"index": old, "alias": s.index}})
+	}
+	if len(current) == 0 {
+		concrete, err := s.exists(ctx, s.index)
+		if err != nil {
+			return err
+		}
+		if concrete {
+			actions = append(actions, map[string]any{"remove_index": map[string]any{"index": s.index}})
+		}
+	}
+
+	b, err := json.Marshal(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodPost, "/_aliases", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(res, "swapping alias "+s.index); err != nil {
+		return err
+	}
+
+	for _, old := range current {
+		if err := s.deleteIndex(ctx, old); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aliased returns the indices the alias currently points at
+func (s *Service) aliased(ctx context.Context) ([]string, error) {
+	res, err := s.do(ctx, http.MethodGet, "/_alias/"+s.index, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res, "reading alias "+s.index)
+	}
+
+	var indices map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return nil, fmt.Errorf("decoding alias response: %w", err)
+	}
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// booleanQuery translates a boolean mode query into a bool query, so the
+// operators mean the same as they do for the MySQL and in-memory indices
+func booleanQuery(query string) map[string]any {
+	var must, mustNot, should []any
+	for _, c := range search.ParseQuery(query, search.ModeBoolean) {
+		match := map[string]any{
+			"query":  c.Term,
+			"fields": searchFields,
+		}
+		if c.Prefix {
+			match["type"] = "phrase_prefix"
+		}
+		clause := map[string]any{"multi_match": match}
+		switch {
+		case c.Required:
+			must = append(must, clause)
+		case c.Excluded:
+			mustNot = append(mustNot, clause)
+		default:
+			should = append(should, clause)
+		}
+	}
+
+	b := map[string]any{}
+	if len(must) > 0 {
+		b["must"] = must
+	} else {
+		// without required words at least one of the optional ones has to match
+		b["minimum_should_match"] = 1
+	}
+	if len(mustNot) > 0 {
+		b["must_not"] = mustNot
+	}
+	if len(should) > 0 {
+		b["should"] = should
+	}
+	return map[string]any{"bool": b}
+}
+
+func (s *Service) docPath(id string) string {
+	return "/" + s.index + "/_doc/" + url.PathEscape(id)
+}
+
+func (s *Service) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	return s.send(ctx, method, path, "application/json", body)
+}
+
+func (s *Service) send(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return s.client.Do(req)
+}
+
+// checkResponse closes the body and returns an error for non 2xx responses
+func checkResponse(res *http.Response, action string) error {
+	defer res.Body.Close()
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	return responseError(res, action)
 }
 
-func New() *Service {
-	return &Service{}
+func responseError(res *http.Response, action string) error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return fmt.Errorf("%s: elasticsearch returned %d: %s", action, res.StatusCode, bytes.TrimSpace(msg))
 }
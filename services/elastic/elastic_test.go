@@ -0,0 +1,457 @@
+package elastic
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/search"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeElastic is a stand-in for the parts of the Elasticsearch REST API the
+// client uses. It keeps indices and aliases in maps and matches a search on
+// a case-insensitive substring of the content.
+type fakeElastic struct {
+	mu      sync.Mutex
+	indices map[string]map[string]document
+	// versions keeps the external version of every document written, deleted ones included
+	versions map[string]map[string]int
+	mappings map[string]map[string]any
+	aliases  map[string][]string
+	searches []map[string]any
+}
+
+func newFakeElastic(t *testing.T) (*fakeElastic, *Service) {
+	t.Helper()
+	fake := &fakeElastic{
+		indices:  make(map[string]map[string]document),
+		versions: make(map[string]map[string]int),
+		mappings: make(map[string]map[string]any),
+		aliases:  make(map[string][]string),
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, New(srv.URL, "notes")
+}
+
+// resolve returns the index behind a name, following a single-index alias
+func (f *fakeElastic) resolve(name string) (string, bool) {
+	if _, ok := f.indices[name]; ok {
+		return name, true
+	}
+	if indices := f.aliases[name]; len(indices) == 1 {
+		return indices[0], true
+	}
+	return "", false
+}
+
+func (f *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodHead:
+		if _, ok := f.resolve(parts[0]); !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		if _, ok := f.resolve(parts[0]); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			Mappings map[string]any `json:"mappings"`
+			Aliases  map[string]any `json:"aliases"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.indices[parts[0]] = make(map[string]document)
+		f.versions[parts[0]] = make(map[string]int)
+		f.mappings[parts[0]] = body.Mappings
+		for alias := range body.Aliases {
+			f.aliases[alias] = append(f.aliases[alias], parts[0])
+		}
+
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		if _, ok := f.indices[parts[0]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.indices, parts[0])
+
+	case len(parts) == 1 && parts[0] == "_aliases" && r.Method == http.MethodPost:
+		var body struct {
+			Actions []map[string]map[string]string `json:"actions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, action := range body.Actions {
+			switch {
+			case action["add"] != nil:
+				f.aliases[action["add"]["alias"]] = append(f.aliases[action["add"]["alias"]], action["add"]["index"])
+			case action["remove"] != nil:
+				alias, index := action["remove"]["alias"], action["remove"]["index"]
+				var kept []string
+				for _, name := range f.aliases[alias] {
+					if name != index {
+						kept = append(kept, name)
+					}
+				}
+				f.aliases[alias] = kept
+			case action["remove_index"] != nil:
+				delete(f.indices, action["remove_index"]["index"])
+			}
+		}
+
+	case len(parts) == 2 && parts[0] == "_alias" && r.Method == http.MethodGet:
+		if len(f.aliases[parts[1]]) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		res := map[string]any{}
+		for _, index := range f.aliases[parts[1]] {
+			res[index] = map[string]any{"aliases": map[string]any{parts[1]: map[string]any{}}}
+		}
+		_ = json.NewEncoder(w).Encode(res)
+
+	case len(parts) == 2 && parts[1] == "_refresh" && r.Method == http.MethodPost:
+		if _, ok := f.resolve(parts[0]); !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+	case len(parts) == 2 && parts[1] == "_bulk" && r.Method == http.MethodPost:
+		index, ok := f.resolve(parts[0])
+		if !ok || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		var items []any
+		failed := false
+		for scanner.Scan() {
+			var action map[string]struct {
+				ID      string `json:"_id"`
+				Version int    `json:"version"`
+			}
+			_ = json.Unmarshal(scanner.Bytes(), &action)
+			for name, meta := range action {
+				var doc *document
+				if name == "index" {
+					scanner.Scan()
+					doc = &document{}
+					_ = json.Unmarshal(scanner.Bytes(), doc)
+				}
+				status := f.write(index, meta.ID, meta.Version, true, doc)
+				failed = failed || status >= 300
+				items = append(items, map[string]any{name: map[string]any{"_id": meta.ID, "status": status}})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": failed, "items": items})
+
+	case len(parts) == 2 && parts[1] == "_search" && r.Method == http.MethodPost:
+		index, ok := f.resolve(parts[0])
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.searches = append(f.searches, body)
+		f.search(w, index, body)
+
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodPut:
+		index, ok := f.resolve(parts[0])
+		var doc document
+		if err := json.NewDecoder(r.Body).Decode(&doc); !ok || err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		version, err := strconv.Atoi(r.URL.Query().Get("version"))
+		w.WriteHeader(f.write(index, parts[2], version, err == nil, &doc))
+
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodDelete:
+		index, ok := f.resolve(parts[0])
+		if _, found := f.indices[index][parts[2]]; !ok || !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.indices[index], parts[2])
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// write stores the document, or deletes it when doc is nil, unless a higher version was written
+// before. It returns the status Elasticsearch answers with for external_gte versioning.
+func (f *fakeElastic) write(index, id string, version int, versioned bool, doc *document) int {
+	if versioned {
+		if current, ok := f.versions[index][id]; ok && version < current {
+			return http.StatusConflict
+		}
+		f.versions[index][id] = version
+	}
+	if doc != nil {
+		f.indices[index][id] = *doc
+		return http.StatusCreated
+	}
+	if _, ok := f.indices[index][id]; !ok {
+		return http.StatusNotFound
+	}
+	delete(f.indices[index], id)
+	return http.StatusOK
+}
+
+func (f *fakeElastic) search(w http.ResponseWriter, index string, body map[string]any) {
+	boolQuery := body["query"].(map[string]any)["bool"].(map[string]any)
+	// only natural mode queries are matched, anything else finds nothing
+	multiMatch, ok := boolQuery["must"].(map[string]any)["multi_match"].(map[string]any)
+	if !ok {
+		_, _ = w.Write([]byte(`{"hits":{"total":{"value":0},"hits":[]}}`))
+		return
+	}
+	query := multiMatch["query"].(string)
+	var userID string
+	for _, filter := range boolQuery["filter"].([]any) {
+		if term, ok := filter.(map[string]any)["term"]; ok {
+			userID = term.(map[string]any)["user_id"].(string)
+		}
+	}
+
+	type hit struct {
+		ID        string              `json:"_id"`
+		Score     float64             `json:"_score"`
+		Source    document            `json:"_source"`
+		Highlight map[string][]string `json:"highlight"`
+	}
+	var hits []hit
+	for id, doc := range f.indices[index] {
+		if userID != "" && doc.UserID != userID {
+			continue
+		}
+		if strings.Contains(strings.ToLower(doc.Content), strings.ToLower(query)) {
+			hits = append(hits, hit{
+				ID:     id,
+				Score:  1.5,
+				Source: doc,
+				Highlight: map[string][]string{
+					"content": {strings.ReplaceAll(doc.Content, query, "<em>"+query+"</em>")},
+				},
+			})
+		}
+	}
+
+	res := map[string]any{
+		"hits": map[string]any{
+			"total": map[string]any{"value": len(hits), "relation": "eq"},
+			"hits":  hits,
+		},
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func TestEnsureIndex(t *testing.T) {
+	fake, svc := newFakeElastic(t)
+
+	require.NoError(t, svc.EnsureIndex(t.Context()))
+	require.Len(t, fake.indices, 1)
+	require.Len(t, fake.aliases["notes"], 1)
+	index := fake.aliases["notes"][0]
+	require.True(t, strings.HasPrefix(index, "notes-"))
+	require.Contains(t, fake.mappings[index], "properties")
+
+	// a second call finds the alias and leaves it alone
+	require.NoError(t, svc.EnsureIndex(t.Context()))
+	require.Len(t, fake.indices, 1)
+}
+
+func TestIndexSearchDelete(t *testing.T) {
+	fake, svc := newFakeElastic(t)
+	ctx := t.Context()
+	require.NoError(t, svc.EnsureIndex(ctx))
+	index := fake.aliases["notes"][0]
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	require.NoError(t, svc.IndexNote(ctx, entities.Note{
+		ID:        "note-1",
+		UserID:    "u1",
+		Title:     "Groceries",
+		Content:   "buy milk",
+		CreatedAt: created,
+		UpdatedAt: created,
+	}))
+	require.NoError(t, svc.IndexNote(ctx, entities.Note{ID: "note-2", UserID: "u2", Title: "Other", Content: "more milk"}))
+	require.Equal(t, "u1", fake.indices[index]["note-1"].UserID)
+
+	results, err := svc.Search(ctx, "milk", search.Filters{UserID: "u1", CreatedAfter: created, Limit: 5})
+	require.NoError(t, err)
+	require.Equal(t, 1, results.Total)
+	require.Len(t, results.Hits, 1)
+	hit := results.Hits[0]
+	require.Equal(t, "note-1", hit.Note.ID)
+	require.Equal(t, "Groceries", hit.Note.Title)
+	require.True(t, created.Equal(hit.Note.CreatedAt))
+	require.Equal(t, 1.5, hit.Score)
+	require.Equal(t, []string{"buy <em>milk</em>"}, hit.Highlights["content"])
+
+	body := fake.searches[0]
+	require.Equal(t, float64(5), body["size"])
+	filters := body["query"].(map[string]any)["bool"].(map[string]any)["filter"].([]any)
+	require.Len(t, filters, 2)
+	require.Contains(t, body, "highlight")
+
+	require.NoError(t, svc.DeleteNote(ctx, "note-1"))
+	require.NotContains(t, fake.indices[index], "note-1")
+	// deleting a note that is not indexed is not an error
+	require.NoError(t, svc.DeleteNote(ctx, "note-1"))
+}
+
+func TestSearch_BooleanMode(t *testing.T) {
+	fake, svc := newFakeElastic(t)
+	require.NoError(t, svc.EnsureIndex(t.Context()))
+
+	_, err := svc.Search(t.Context(), "+milk -bread egg*", search.Filters{Mode: search.ModeBoolean})
+	require.NoError(t, err)
+
+	b, err := json.Marshal(fake.searches[0]["query"].(map[string]any)["bool"].(map[string]any)["must"])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"bool": {
+		"must": [{"multi_match": {"query": "milk", "fields": ["title^2", "content"]}}],
+		"must_not": [{"multi_match": {"query": "bread", "fields": ["title^2", "content"]}}],
+		"should": [{"multi_match": {"query": "egg", "fields": ["title^2", "content"], "type": "phrase_prefix"}}]
+	}}`, string(b))
+}
+
+// unchanged is a catch-up that finds no notes written while reindexing
+func unchanged(time.Time, func([]entities.Note) error) error {
+	return nil
+}
+
+func TestReindex(t *testing.T) {
+	fake, svc := newFakeElastic(t)
+	ctx := t.Context()
+	require.NoError(t, svc.EnsureIndex(ctx))
+	old := fake.aliases["notes"][0]
+	require.NoError(t, svc.IndexNote(ctx, entities.Note{ID: "stale", UserID: "u1", Content: "stale milk"}))
+
+	batches := [][]entities.Note{
+		{{ID: "1", UserID: "u1", Content: "fresh milk"}, {ID: "2", UserID: "u1", Content: "bread"}},
+		{{ID: "3", UserID: "u1", Content: "more milk"}},
+	}
+	name, err := svc.Reindex(ctx, func(bulk func([]entities.Note) error) error {
+		for _, batch := range batches {
+			if err := bulk(batch); err != nil {
+				return err
+			}
+			// searches keep using the old index while the new one loads
+			require.Equal(t, []string{old}, fake.aliases["notes"])
+		}
+		return nil
+	}, unchanged)
+	require.NoError(t, err)
+	require.NotEqual(t, old, name)
+	require.Equal(t, []string{name}, fake.aliases["notes"])
+	require.NotContains(t, fake.indices, old)
+	require.Len(t, fake.indices[name], 3)
+
+	results, err := svc.Search(ctx, "milk", search.Filters{UserID: "u1"})
+	require.NoError(t, err)
+	require.Equal(t, 2, results.Total)
+
+	// a failed load drops the new index and keeps the alias where it was
+	_, err = svc.Reindex(ctx, func(bulk func([]entities.Note) error) error {
+		return errors.New("database went away")
+	}, unchanged)
+	require.ErrorContains(t, err, "database went away")
+	require.Equal(t, []string{name}, fake.aliases["notes"])
+	require.Len(t, fake.indices, 1)
+}
+
+func TestReindex_CatchesUp(t *testing.T) {
+	fake, svc := newFakeElastic(t)
+	ctx := t.Context()
+	require.NoError(t, svc.EnsureIndex(ctx))
+	trashed := time.Now()
+
+	start := time.Now()
+	var passes []time.Time
+	name, err := svc.Reindex(ctx, func(bulk func([]entities.Note) error) error {
+		return bulk([]entities.Note{
+			{ID: "1", UserID: "u1", Content: "milk", Version: 1},
+			{ID: "2", UserID: "u1", Content: "bread", Version: 1},
+			{ID: "3", UserID: "u1", Content: "eggs", Version: 1},
+		})
+	}, func(since time.Time, bulk func([]entities.Note) error) error {
+		passes = append(passes, since)
+		if len(passes) == 1 {
+			// written to the old index while the notes loaded
+			return bulk([]entities.Note{
+				{ID: "1", UserID: "u1", Content: "oat milk", Version: 2},
+				{ID: "2", UserID: "u1", Content: "bread", Version: 2, DeletedAt: &trashed},
+			})
+		}
+		// the alias already leads to the new index, where a live write got ahead of the catch-up
+		require.NoError(t, svc.IndexNote(ctx, entities.Note{ID: "3", UserID: "u1", Content: "fresh eggs", Version: 3}))
+		return bulk([]entities.Note{
+			{ID: "1", UserID: "u1", Content: "milk", Version: 1},
+			{ID: "3", UserID: "u1", Content: "eggs", Version: 2},
+		})
+	})
+	require.NoError(t, err)
+	require.Len(t, passes, 2)
+	require.False(t, passes[0].After(start))
+	require.True(t, passes[1].After(passes[0]))
+
+	require.Equal(t, []string{name}, fake.aliases["notes"])
+	require.Len(t, fake.indices[name], 2)
+	require.Equal(t, "oat milk", fake.indices[name]["1"].Content)
+	require.NotContains(t, fake.indices[name], "2")
+	require.Equal(t, "fresh eggs", fake.indices[name]["3"].Content)
+
+	// an older version of a note is not indexed over a newer one
+	require.NoError(t, svc.IndexNote(ctx, entities.Note{ID: "1", UserID: "u1", Content: "milk", Version: 1}))
+	require.Equal(t, "oat milk", fake.indices[name]["1"].Content)
+}
+
+func TestReindex_ReplacesIndexNamedLikeAlias(t *testing.T) {
+	fake, svc := newFakeElastic(t)
+	fake.indices["notes"] = map[string]document{"old": {Content: "old"}}
+
+	name, err := svc.Reindex(t.Context(), func(bulk func([]entities.Note) error) error {
+		return bulk([]entities.Note{{ID: "1", Content: "new"}})
+	}, unchanged)
+	require.NoError(t, err)
+	require.NotContains(t, fake.indices, "notes")
+	require.Equal(t, []string{name}, fake.aliases["notes"])
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"cluster unavailable"}`))
+	}))
+	defer srv.Close()
+	svc := New(srv.URL, "notes")
+
+	err := svc.IndexNote(t.Context(), entities.Note{ID: "1"})
+	require.ErrorContains(t, err, "cluster unavailable")
+	_, err = svc.Search(t.Context(), "milk", search.Filters{})
+	require.ErrorContains(t, err, "503")
+	require.Error(t, svc.EnsureIndex(t.Context()))
+}
@@ -0,0 +1,32 @@
+package entities
+
+import "time"
+
+// MaxCommentLength is the longest comment body in characters
+const MaxCommentLength = 10000
+
+// Comment a remark on a note. Comments without ParentID start a thread, which can be
+// resolved once it is settled, the others are replies to the thread ParentID started.
+type Comment struct {
+	ID        string    `json:"id"`
+	NoteID    string    `json:"note_id"`
+	UserID    string    `json:"user_id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Body      string    `json:"body"`
+	Resolved  bool      `json:"resolved"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// DeletedAt is set on comments that were deleted, they are no longer returned
+	DeletedAt *time.Time `json:"-"`
+}
+
+// CommentReq request for commenting on a note, or replying to the thread ParentID when it is set
+type CommentReq struct {
+	Body     string `json:"body" binding:"required,max=10000"`
+	ParentID string `json:"parent_id"`
+}
+
+// CommentEdit request for changing the body of a comment
+type CommentEdit struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
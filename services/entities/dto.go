@@ -2,18 +2,91 @@ package entities
 
 import "time"
 
+// Limits of a note, in characters. The binding tags of NoteReq mirror them.
+const (
+	// MaxTitleLength is the size of the title column
+	MaxTitleLength = 255
+	// MaxContentLength keeps a body of 4 byte characters well within a MEDIUMTEXT column
+	MaxContentLength = 1 << 20
+	// MaxTags is the number of tags a note can have
+	MaxTags = 20
+	// MaxTagLength is the size of the tag name column
+	MaxTagLength = 50
+)
+
+// Content types tell clients how to render the body of a note
+const (
+	ContentTypePlain    = "plain"
+	ContentTypeMarkdown = "markdown"
+)
+
 // Note a basic notes struct
 type Note struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"note"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"note"`
+	ContentType string     `json:"content_type"`
+	NotebookID  string     `json:"notebook_id,omitempty"`
+	Tags        []string   `json:"tags"`
+	Version     int        `json:"version"`
+	Seq         int64      `json:"seq"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	// Comments is the number of comments on the note, only set in listings
+	Comments *int `json:"comments,omitempty"`
 }
 
-// NoteReq request for creating notes
+// NoteReq request for creating notes. ContentType defaults to plain and NotebookID to the top
+// level on create, both are left as they are on update when empty, as are Tags when nil.
+// Version is the version the update was based on, taken from the If-Match header, zero skips the check.
 type NoteReq struct {
-	UserID  string `json:"user_id"`
-	Title   string `json:"title" binding:"required"`
-	Content string `json:"note" binding:"required"`
+	Title       string   `json:"title" binding:"required,max=255"`
+	Content     string   `json:"note" binding:"required,max=1048576"`
+	ContentType string   `json:"content_type" binding:"omitempty,oneof=plain markdown"`
+	NotebookID  string   `json:"notebook_id"`
+	Tags        []string `json:"tags" binding:"max=20,dive,max=50"`
+	Version     int      `json:"-"`
+}
+
+// NotePatch request for partially updating notes, only the fields that are set get updated.
+// An empty NotebookID takes the note out of its notebook. Version works as in NoteReq.
+type NotePatch struct {
+	Title       *string  `json:"title"`
+	Content     *string  `json:"note"`
+	ContentType *string  `json:"content_type"`
+	NotebookID  *string  `json:"notebook_id"`
+	Tags        []string `json:"tags"`
+	Version     int      `json:"-"`
+}
+
+// NoteQuery query parameters for listing notes.
+// Dates are RFC3339, the After bounds are inclusive and the Before bounds exclusive.
+// Notes with all of the Tags are listed, or with any of them when TagMode is any.
+// NotebookID lists the notes of a notebook, and of the notebooks inside it when Descendants is set.
+// SharedWithMe lists the notes other users shared with the user instead of their own.
+// UserID is set from the authenticated user.
+type NoteQuery struct {
+	UserID        string    `form:"-"`
+	TitlePrefix   string    `form:"title_prefix"`
+	Tags          []string  `form:"tag"`
+	TagMode       string    `form:"tag_mode"`
+	NotebookID    string    `form:"notebook_id"`
+	Descendants   bool      `form:"descendants"`
+	SharedWithMe  bool      `form:"shared_with_me"`
+	CreatedAfter  time.Time `form:"created_after"`
+	CreatedBefore time.Time `form:"created_before"`
+	UpdatedAfter  time.Time `form:"updated_after"`
+	UpdatedBefore time.Time `form:"updated_before"`
+	Sort          string    `form:"sort"`
+	Order         string    `form:"order"`
+	Limit         int       `form:"limit"`
+	Cursor        string    `form:"cursor"`
+}
+
+// NotePage a page of notes, NextCursor is empty on the last page
+type NotePage struct {
+	Items      []Note `json:"items"`
+	NextCursor string `json:"next_cursor"`
 }
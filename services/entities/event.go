@@ -0,0 +1,29 @@
+package entities
+
+import "time"
+
+// Types of the events sent when notes change
+const (
+	// EventNoteCreated is sent when a note is created, restored from the trash or shared with the user
+	EventNoteCreated = "note.created"
+	// EventNoteUpdated is sent when a note is changed
+	EventNoteUpdated = "note.updated"
+	// EventNoteDeleted is sent when a note is moved to the trash, purged or no longer shared with the user
+	EventNoteDeleted = "note.deleted"
+)
+
+// Event a change to a note. IDs grow with every event, so a client that reconnects
+// can resume after the last one it saw.
+type Event struct {
+	ID      int64     `json:"id"`
+	Type    string    `json:"type"`
+	NoteID  string    `json:"note_id"`
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	// Note is the note after the change, it is left out of deleted events
+	Note *Note `json:"note,omitempty"`
+	// TraceContext carries the trace of the request that made the change, as W3C trace context headers
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// Audience are the ids of the users the event is sent to
+	Audience []string `json:"-"`
+}
@@ -0,0 +1,39 @@
+package entities
+
+import "time"
+
+// MaxLinkPasswordLength is the longest password bcrypt can hash
+const MaxLinkPasswordLength = 72
+
+// Link a public link that lets anyone holding its token read the note without an account.
+// Token is only set in the response to creating it, only its hash is stored.
+type Link struct {
+	ID           string     `json:"id"`
+	NoteID       string     `json:"note_id"`
+	Token        string     `json:"token,omitempty"`
+	TokenHash    string     `json:"-"`
+	PasswordHash string     `json:"-"`
+	Protected    bool       `json:"protected"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxViews     *int       `json:"max_views,omitempty"`
+	Views        int        `json:"views"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// LinkReq request for creating a public link. It never expires when ExpiresAt is empty,
+// can be opened any number of times when MaxViews is empty and needs no password when Password is empty.
+type LinkReq struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxViews  *int       `json:"max_views" binding:"omitempty,min=1"`
+	Password  string     `json:"password" binding:"omitempty,max=72"`
+}
+
+// PublicNote the read-only copy of a note served through a public link, without
+// anything that identifies its owner
+type PublicNote struct {
+	Title       string    `json:"title"`
+	Content     string    `json:"note"`
+	ContentType string    `json:"content_type"`
+	Tags        []string  `json:"tags,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
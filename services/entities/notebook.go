@@ -0,0 +1,39 @@
+package entities
+
+import "time"
+
+// MaxNotebookNameLength is the size of the notebook name column
+const MaxNotebookNameLength = 255
+
+// Notebook a folder of the user's notes, nested in the ParentID notebook or at the top level when it is empty
+type Notebook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotebookReq request for creating a notebook inside ParentID, or at the top level when it is empty
+type NotebookReq struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	ParentID string `json:"parent_id"`
+}
+
+// NotebookRename request for renaming a notebook
+type NotebookRename struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+// NotebookMove request for moving a notebook with everything in it into ParentID, or to the top level when it is empty
+type NotebookMove struct {
+	ParentID string `json:"parent_id"`
+}
+
+// NotebookDelete query parameters for deleting a notebook. Mode cascade moves the notes of the
+// notebook and of the notebooks inside it to the trash and deletes them all, while reparent, the
+// default, only deletes the notebook and moves its notes and notebooks into its parent.
+type NotebookDelete struct {
+	Mode string `form:"mode" binding:"omitempty,oneof=cascade reparent"`
+}
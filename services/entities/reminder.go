@@ -0,0 +1,47 @@
+package entities
+
+import "time"
+
+// EventReminderDue is the type of the webhook event sent when a reminder fires
+const EventReminderDue = "reminder.due"
+
+// States of a reminder
+const (
+	// ReminderActive is a reminder waiting for its next occurrence
+	ReminderActive = "active"
+	// ReminderDone is a one-off reminder that fired, or one whose note is gone
+	ReminderDone = "done"
+)
+
+// Channels a reminder is sent through
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Reminder a reminder about a note, for the user who set it. RemindAt is when it fires next,
+// Recurrence the rule it repeats by, empty for reminders that fire once.
+type Reminder struct {
+	ID         string     `json:"id"`
+	NoteID     string     `json:"note_id"`
+	UserID     string     `json:"user_id"`
+	RemindAt   time.Time  `json:"remind_at"`
+	Recurrence string     `json:"recurrence,omitempty"`
+	Channel    string     `json:"channel"`
+	Status     string     `json:"status"`
+	LastError  string     `json:"last_error,omitempty"`
+	FiredAt    *time.Time `json:"fired_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// NextRunAt is when the runner picks the reminder up, later than RemindAt while a failed one is retried
+	NextRunAt time.Time `json:"-"`
+	// Attempts counts the failed attempts at sending the current occurrence
+	Attempts int `json:"-"`
+}
+
+// ReminderReq request for setting a reminder on a note. Recurrence is a rule like
+// FREQ=WEEKLY;INTERVAL=2, the channel defaults to email.
+type ReminderReq struct {
+	RemindAt   time.Time `json:"remind_at" binding:"required"`
+	Recurrence string    `json:"recurrence" binding:"max=100"`
+	Channel    string    `json:"channel" binding:"omitempty,oneof=email webhook"`
+}
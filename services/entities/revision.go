@@ -0,0 +1,28 @@
+package entities
+
+import "time"
+
+// Revision an earlier version of a note, kept when the note is updated.
+// Content is left out when revisions are listed.
+type Revision struct {
+	Revision    int       `json:"revision"`
+	NoteID      string    `json:"note_id"`
+	Title       string    `json:"title"`
+	Content     string    `json:"note,omitempty"`
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DiffQuery query parameters for comparing two versions of a note, an empty To is the current note
+type DiffQuery struct {
+	From int `form:"from" binding:"required,min=1"`
+	To   int `form:"to" binding:"omitempty,min=1"`
+}
+
+// NoteDiff a line diff of the content between two versions of a note in the unified format.
+// To is left out when comparing against the current note.
+type NoteDiff struct {
+	From int    `json:"from"`
+	To   int    `json:"to,omitempty"`
+	Diff string `json:"diff"`
+}
@@ -0,0 +1,28 @@
+package entities
+
+import "time"
+
+// SearchQuery query parameters for searching notes. Mode is natural (default) or
+// boolean, which supports +required, -excluded and prefix* words.
+// Dates are RFC3339, the After bound is inclusive and the Before bound exclusive.
+type SearchQuery struct {
+	Query         string    `form:"q"`
+	Mode          string    `form:"mode"`
+	CreatedAfter  time.Time `form:"created_after"`
+	CreatedBefore time.Time `form:"created_before"`
+	Limit         int       `form:"limit"`
+}
+
+// SearchHit a note matching a search, Highlights maps a field to the
+// fragments that matched with the matching terms wrapped in <em> tags
+type SearchHit struct {
+	Note       Note                `json:"note"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// SearchResults the best matches of a search, ordered by relevance
+type SearchResults struct {
+	Total int         `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
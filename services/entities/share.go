@@ -0,0 +1,31 @@
+package entities
+
+import "time"
+
+// Roles a note is shared with, each one allows what the roles before it do
+const (
+	// RoleViewer can read the note and its revisions
+	RoleViewer = "viewer"
+	// RoleCommenter can also comment on the note
+	RoleCommenter = "commenter"
+	// RoleEditor can also change the title, body and tags of the note
+	RoleEditor = "editor"
+	// RoleOwner is the user who created the note, only they can delete, share or file it in a notebook
+	RoleOwner = "owner"
+)
+
+// Share a collaborator of a note and the role they were given on it
+type Share struct {
+	NoteID    string    `json:"note_id"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ShareReq request for sharing a note with the user registered with Email, or changing their role
+type ShareReq struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=viewer commenter editor"`
+}
@@ -0,0 +1,76 @@
+package entities
+
+import "time"
+
+// MaxSyncChanges is the number of changes a client can upload at once
+const MaxSyncChanges = 500
+
+// Outcomes of a change uploaded by a client
+const (
+	// SyncApplied means the note was at the version the change was based on, or did
+	// not exist yet, and the change was written as it is
+	SyncApplied = "applied"
+	// SyncConflict means the note changed since the version the change was based on.
+	// The newest of the two changes won, the other one is kept as a conflict copy.
+	SyncConflict = "conflict"
+)
+
+// Winners of a conflict
+const (
+	SyncWinnerClient = "client"
+	SyncWinnerServer = "server"
+)
+
+// SyncChanges the user's notes that changed since the sequence number a client synced up to.
+// Seq is the sequence number to ask for changes since next time. Reset is set when the changes
+// since the client's sequence number are no longer known, Notes then holds every note outside
+// the trash and the client should replace its copies with them.
+type SyncChanges struct {
+	Seq     int64       `json:"seq"`
+	Reset   bool        `json:"reset"`
+	Notes   []Note      `json:"notes"`
+	Deleted []Tombstone `json:"deleted"`
+}
+
+// Tombstone a note that was moved to the trash
+type Tombstone struct {
+	ID        string    `json:"id"`
+	Version   int       `json:"version"`
+	Seq       int64     `json:"seq"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncChange a change a client made to a note while it was offline. Version is the version of the
+// note the change was based on, zero for notes created on the client, and UpdatedAt when it was made.
+// Empty fields are left as they are, as in NoteReq, and Deleted moves the note to the trash.
+type SyncChange struct {
+	ID          string    `json:"id" binding:"required,uuid"`
+	Title       string    `json:"title" binding:"max=255"`
+	Content     string    `json:"note" binding:"max=1048576"`
+	ContentType string    `json:"content_type" binding:"omitempty,oneof=plain markdown"`
+	NotebookID  string    `json:"notebook_id"`
+	Tags        []string  `json:"tags" binding:"max=20,dive,max=50"`
+	Deleted     bool      `json:"deleted"`
+	Version     int       `json:"version" binding:"min=0"`
+	UpdatedAt   time.Time `json:"updated_at" binding:"required"`
+}
+
+// SyncReq request for uploading the changes a client made while offline, which are applied together
+type SyncReq struct {
+	Changes []SyncChange `json:"changes" binding:"required,max=500,dive"`
+}
+
+// SyncResult the outcome of an uploaded change. Note is the note as it is stored now, nil once it
+// is gone, and ConflictCopy the note the losing side of a conflict was saved as, if it had content to keep.
+type SyncResult struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	Winner       string `json:"winner,omitempty"`
+	Note         *Note  `json:"note,omitempty"`
+	ConflictCopy *Note  `json:"conflict_copy,omitempty"`
+}
+
+// SyncResponse the outcomes of the uploaded changes, in the order they were uploaded
+type SyncResponse struct {
+	Results []SyncResult `json:"results"`
+}
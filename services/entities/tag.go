@@ -0,0 +1,18 @@
+package entities
+
+// Tag a label on the user's notes with the number of notes outside the trash that carry it
+type Tag struct {
+	Name  string `json:"name"`
+	Notes int    `json:"notes"`
+}
+
+// TagRename request for renaming a tag on all of the user's notes
+type TagRename struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+// TagMerge request for replacing the From tags with Into on all of the user's notes
+type TagMerge struct {
+	From []string `json:"from" binding:"required,min=1,dive,required"`
+	Into string   `json:"into" binding:"required,max=50"`
+}
@@ -0,0 +1,33 @@
+package entities
+
+import "time"
+
+// MaxPasswordLength is the longest account password bcrypt can hash
+const MaxPasswordLength = 72
+
+// User an account that owns notes
+type User struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SignupReq request for creating an account
+type SignupReq struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,max=72"`
+}
+
+// LoginReq request for logging in
+type LoginReq struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AuthToken a signed token issued on signup and login
+type AuthToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
@@ -0,0 +1,82 @@
+package entities
+
+import "time"
+
+// EventPing is the type of the event sent when a webhook is tested
+const EventPing = "ping"
+
+// States of a webhook delivery
+const (
+	// DeliveryPending is a delivery that is waiting for its next attempt
+	DeliveryPending = "pending"
+	// DeliveryDelivered is a delivery the receiver answered with a 2xx status
+	DeliveryDelivered = "delivered"
+	// DeliveryFailed is a delivery that used up its attempts. It is kept as a dead letter until it is replayed.
+	DeliveryFailed = "failed"
+)
+
+// Webhook a URL the user's note events are posted to. Secret signs the requests
+// and is only set in the response to creating the webhook.
+type Webhook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookReq request for registering a webhook
+type WebhookReq struct {
+	URL string `json:"url" binding:"required,url,max=2048"`
+}
+
+// WebhookEvent the body posted to a webhook. Note is the note after the change, it is
+// left out of deleted events. Reminder is only set on reminder events.
+type WebhookEvent struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	NoteID   string    `json:"note_id,omitempty"`
+	UserID   string    `json:"user_id"`
+	Version  int       `json:"version,omitempty"`
+	Time     time.Time `json:"time"`
+	Note     *Note     `json:"note,omitempty"`
+	Reminder *Reminder `json:"reminder,omitempty"`
+}
+
+// OutboxEvent a note event waiting to be handed to the user's webhooks. It is written in the
+// same transaction as the change, so no change is lost and none is announced that was rolled back.
+type OutboxEvent struct {
+	ID        int64
+	EventID   string
+	UserID    string
+	Type      string
+	Payload   string
+	CreatedAt time.Time
+}
+
+// Delivery an event posted, or to be posted, to a webhook
+type Delivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhook_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ReplayReq request for sending failed deliveries again. All of the webhook's
+// failed deliveries are replayed when DeliveryIDs is empty.
+type ReplayReq struct {
+	DeliveryIDs []string `json:"delivery_ids" binding:"max=100,dive,uuid"`
+}
+
+// ReplayResponse the number of failed deliveries that were queued again
+type ReplayResponse struct {
+	Replayed int64 `json:"replayed"`
+}
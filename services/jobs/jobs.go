@@ -0,0 +1,274 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"notes/services/entities"
+	"notes/services/notes"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// batchSize is the number of reminders fired in one pass of the runner
+	batchSize = 100
+	// batchTimeout bounds a pass of the runner, which is finished even when the runner is stopped
+	batchTimeout = 30 * time.Second
+	// claimLease is how long claimed reminders are left alone, longer than a pass, so another
+	// runner fires them again only when the one that claimed them stopped
+	claimLease = 2 * time.Minute
+	// recordTimeout bounds storing the outcome of a reminder, which is done even when the pass ran out of time
+	recordTimeout = 5 * time.Second
+	// maxErrorLength is the longest error kept with a reminder
+	maxErrorLength = 1000
+)
+
+var (
+	// ErrNotFound is returned when a reminder does not exist
+	ErrNotFound = errors.New("reminder not found")
+	// ErrInvalidReminder is returned when a reminder request is invalid
+	ErrInvalidReminder = errors.New("invalid reminder")
+)
+
+// Notes looks up the notes reminders are set on, as the user who set them sees them
+type Notes interface {
+	GetNote(ctx context.Context, userID, id string) (entities.Note, error)
+}
+
+// Option configures optional settings of the Service
+type Option func(*Service)
+
+// WithNotifier sends the reminders set on the given channel through notifier
+func WithNotifier(channel string, notifier Notifier) Option {
+	return func(s *Service) {
+		s.notifiers[channel] = notifier
+	}
+}
+
+// WithInterval sets how often the runner looks for due reminders
+func WithInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.interval = interval
+	}
+}
+
+// WithRetry sets how often sending a reminder is attempted and how long to wait between the attempts
+func WithRetry(attempts int, delay time.Duration) Option {
+	return func(s *Service) {
+		s.maxAttempts, s.retryDelay = attempts, delay
+	}
+}
+
+// Service keeps reminders on notes and runs the job that fires them
+type Service struct {
+	store       Store
+	notes       Notes
+	notifiers   map[string]Notifier
+	interval    time.Duration
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+// New returns a jobs service backed by the given store
+func New(store Store, notes Notes, opts ...Option) *Service {
+	s := &Service{
+		store:       store,
+		notes:       notes,
+		notifiers:   make(map[string]Notifier),
+		interval:    15 * time.Second,
+		maxAttempts: 5,
+		retryDelay:  time.Minute,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateReminder sets a reminder for the user on a note they can see
+func (s *Service) CreateReminder(ctx context.Context, userID, noteID string, req entities.ReminderReq) (entities.Reminder, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.CreateReminder")
+	defer span.End()
+
+	if !req.RemindAt.After(time.Now()) {
+		return entities.Reminder{}, fmt.Errorf("%w: remind_at must be in the future", ErrInvalidReminder)
+	}
+	if req.Channel == "" {
+		req.Channel = entities.ChannelEmail
+	}
+	if _, ok := s.notifiers[req.Channel]; !ok {
+		return entities.Reminder{}, fmt.Errorf("%w: reminders cannot be sent by %s", ErrInvalidReminder, req.Channel)
+	}
+	recurrence := ""
+	if req.Recurrence != "" {
+		rule, err := ParseRule(req.Recurrence)
+		if err != nil {
+			return entities.Reminder{}, err
+		}
+		recurrence = rule.String()
+	}
+	if _, err := s.notes.GetNote(ctx, userID, noteID); err != nil {
+		return entities.Reminder{}, err
+	}
+
+	remindAt := req.RemindAt.UTC()
+	return s.store.CreateReminder(ctx, entities.Reminder{
+		ID:         uuid.NewString(),
+		NoteID:     noteID,
+		UserID:     userID,
+		RemindAt:   remindAt,
+		Recurrence: recurrence,
+		Channel:    req.Channel,
+		Status:     entities.ReminderActive,
+		NextRunAt:  remindAt,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// ListReminders returns the user's reminders in the order they fire, only those on the note unless noteID is empty
+func (s *Service) ListReminders(ctx context.Context, userID, noteID string) ([]entities.Reminder, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ListReminders")
+	defer span.End()
+
+	if noteID != "" {
+		if _, err := s.notes.GetNote(ctx, userID, noteID); err != nil {
+			return nil, err
+		}
+	}
+	return s.store.ListReminders(ctx, userID, noteID)
+}
+
+// DeleteReminder removes the user's reminder on the note
+func (s *Service) DeleteReminder(ctx context.Context, userID, noteID, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.DeleteReminder")
+	defer span.End()
+
+	reminder, err := s.store.GetReminder(ctx, id)
+	if err != nil {
+		return err
+	}
+	if reminder.UserID != userID || reminder.NoteID != noteID {
+		return ErrNotFound
+	}
+	return s.store.DeleteReminder(ctx, userID, id)
+}
+
+// Run fires the due reminders every interval until ctx is done. A pass that is under way
+// when ctx is done is finished first, so no reminder is left half fired.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pass, cancel := context.WithTimeout(context.WithoutCancel(ctx), batchTimeout)
+			fired, err := s.FireDue(pass)
+			cancel()
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to fire reminders", "error", err)
+				continue
+			}
+			if fired > 0 {
+				slog.InfoContext(ctx, "reminders fired", "fired", fired)
+			}
+		}
+	}
+}
+
+// FireDue claims the reminders that are due, then sends them and records each outcome on its
+// own, so a reminder that went out is not sent again when another one fails. It returns how
+// many were due.
+func (s *Service) FireDue(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.FireDue")
+	defer span.End()
+
+	now := time.Now()
+	due, err := s.store.ClaimDue(ctx, now, now.Add(claimLease), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, reminder := range due {
+		reminder = s.fire(ctx, reminder, now)
+		record, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+		if err := s.store.UpdateReminder(record, reminder); err != nil {
+			errs = append(errs, fmt.Errorf("reminder %s: %w", reminder.ID, err))
+		}
+		cancel()
+	}
+	span.SetAttributes(attribute.Int("fired", len(due)))
+	return len(due), errors.Join(errs...)
+}
+
+// fire sends the reminder and returns it as it should be stored: moved on to its next
+// occurrence, done, or due again after the retry delay when sending it failed
+func (s *Service) fire(ctx context.Context, reminder entities.Reminder, now time.Time) entities.Reminder {
+	note, err := s.notes.GetNote(ctx, reminder.UserID, reminder.NoteID)
+	if errors.Is(err, notes.ErrNotFound) {
+		// the note was deleted or is no longer shared with the user
+		reminder.Status = entities.ReminderDone
+		reminder.LastError = errorMessage(err)
+		return reminder
+	}
+	if err == nil {
+		err = s.notify(ctx, reminder, note)
+	}
+
+	if err != nil {
+		reminder.Attempts++
+		reminder.LastError = errorMessage(err)
+		slog.WarnContext(ctx, "failed to send reminder", "reminder_id", reminder.ID, "channel", reminder.Channel,
+			"attempts", reminder.Attempts, "error", err)
+		if reminder.Attempts < s.maxAttempts {
+			reminder.NextRunAt = now.Add(s.retryDelay)
+			return reminder
+		}
+		// this occurrence is given up, a recurring reminder still fires at the next one
+	} else {
+		reminder.LastError = ""
+		reminder.FiredAt = &now
+	}
+	reminder.Attempts = 0
+
+	if reminder.Recurrence == "" {
+		reminder.Status = entities.ReminderDone
+		return reminder
+	}
+	rule, err := ParseRule(reminder.Recurrence)
+	if err != nil {
+		reminder.Status = entities.ReminderDone
+		reminder.LastError = errorMessage(err)
+		return reminder
+	}
+	reminder.RemindAt = rule.Next(reminder.RemindAt, now)
+	reminder.NextRunAt = reminder.RemindAt
+	return reminder
+}
+
+// notify sends the reminder through the notifier of its channel
+func (s *Service) notify(ctx context.Context, reminder entities.Reminder, note entities.Note) error {
+	notifier, ok := s.notifiers[reminder.Channel]
+	if !ok {
+		return fmt.Errorf("no notifier for channel %s", reminder.Channel)
+	}
+	return notifier.Notify(ctx, reminder, note)
+}
+
+// errorMessage shortens the error to what a reminder keeps
+func errorMessage(err error) string {
+	message := err.Error()
+	if utf8.RuneCountInString(message) > maxErrorLength {
+		message = string([]rune(message)[:maxErrorLength])
+	}
+	return message
+}
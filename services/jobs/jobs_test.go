@@ -0,0 +1,464 @@
+package jobs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/migrator"
+	"notes/services/notes"
+	"notes/services/users"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	store      Store
+	notesStore notes.Store
+)
+
+func TestMain(m *testing.M) {
+	code := 1
+
+	db, err := migrator.SetupSQLite(context.TODO(), ":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := migrator.MigrateSQLite(context.TODO(), db); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(code)
+	}()
+	store = NewSQLiteStore(db)
+	notesStore = notes.NewSQLiteStore(db)
+	code = m.Run()
+}
+
+// notifier records the reminders it is handed and fails while err is set
+type notifier struct {
+	mu        sync.Mutex
+	err       error
+	reminders []entities.Reminder
+}
+
+func (n *notifier) Notify(_ context.Context, reminder entities.Reminder, _ entities.Note) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.reminders = append(n.reminders, reminder)
+	return n.err
+}
+
+func (n *notifier) setErr(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.err = err
+}
+
+func (n *notifier) notified() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.reminders)
+}
+
+// enqueuer records the webhook events it is handed
+type enqueuer struct {
+	events []entities.WebhookEvent
+}
+
+func (e *enqueuer) Enqueue(_ context.Context, _ string, event entities.WebhookEvent) error {
+	e.events = append(e.events, event)
+	return nil
+}
+
+// smtpServer is a local stand-in for a mail server that records the messages sent to it
+type smtpServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	rcpts    []string
+	messages []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	s := &smtpServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 go ahead")
+			var message strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				message.WriteString(line)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, message.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpServer) sent() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rcpts, s.messages
+}
+
+func TestReminders(t *testing.T) {
+	stores := map[string]struct {
+		reminders Store
+		notes     notes.Store
+	}{
+		"memory": {NewMemoryStore(), notes.NewMemoryStore()},
+		"sql":    {store, notesStore},
+	}
+	for name, stores := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			userID := "reminders-" + name + "-" + uuid.NewString()
+			notesService := notes.New(stores.notes)
+			email := &notifier{}
+			service := New(stores.reminders, notesService, WithNotifier(entities.ChannelEmail, email),
+				WithRetry(2, time.Millisecond))
+
+			note, err := notesService.CreateNote(ctx, userID, entities.NoteReq{Title: "title", Content: "content"})
+			require.NoError(t, err)
+
+			_, err = service.CreateReminder(ctx, userID, note.ID, entities.ReminderReq{RemindAt: time.Now().Add(-time.Minute)})
+			require.ErrorIs(t, err, ErrInvalidReminder)
+			_, err = service.CreateReminder(ctx, userID, note.ID, entities.ReminderReq{
+				RemindAt: time.Now().Add(time.Hour),
+				Channel:  entities.ChannelWebhook,
+			})
+			require.ErrorIs(t, err, ErrInvalidReminder)
+			_, err = service.CreateReminder(ctx, userID, note.ID, entities.ReminderReq{
+				RemindAt:   time.Now().Add(time.Hour),
+				Recurrence: "FREQ=SECONDLY",
+			})
+			require.ErrorIs(t, err, ErrInvalidReminder)
+			_, err = service.CreateReminder(ctx, "someone-else", note.ID, entities.ReminderReq{RemindAt: time.Now().Add(time.Hour)})
+			require.ErrorIs(t, err, notes.ErrNotFound)
+
+			remindAt := time.Now().Add(20 * time.Millisecond)
+			once, err := service.CreateReminder(ctx, userID, note.ID, entities.ReminderReq{RemindAt: remindAt})
+			require.NoError(t, err)
+			require.Equal(t, entities.ChannelEmail, once.Channel)
+			require.Equal(t, entities.ReminderActive, once.Status)
+			require.Empty(t, once.Recurrence)
+
+			recurring, err := service.CreateReminder(ctx, userID, note.ID, entities.ReminderReq{
+				RemindAt:   remindAt.Add(time.Millisecond),
+				Recurrence: "rrule:freq=hourly",
+			})
+			require.NoError(t, err)
+			require.Equal(t, "FREQ=HOURLY", recurring.Recurrence)
+
+			listed, err := service.ListReminders(ctx, userID, note.ID)
+			require.NoError(t, err)
+			require.Len(t, listed, 2)
+			require.Equal(t, once.ID, listed[0].ID)
+			require.Equal(t, recurring.ID, listed[1].ID)
+
+			// nothing is due yet
+			fired, err := service.FireDue(ctx)
+			require.NoError(t, err)
+			require.Zero(t, fired)
+
+			// a failed reminder is retried
+			email.setErr(errors.New("mailbox unavailable"))
+			time.Sleep(30 * time.Millisecond)
+			fired, err = service.FireDue(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 2, fired)
+			require.Equal(t, 2, email.notified())
+
+			retried, err := stores.reminders.GetReminder(ctx, once.ID)
+			require.NoError(t, err)
+			require.Equal(t, entities.ReminderActive, retried.Status)
+			require.Equal(t, 1, retried.Attempts)
+			require.Equal(t, "mailbox unavailable", retried.LastError)
+			require.Nil(t, retried.FiredAt)
+
+			// then sent, the one-off reminder is done and the recurring one moves on to its next occurrence
+			email.setErr(nil)
+			time.Sleep(10 * time.Millisecond)
+			fired, err = service.FireDue(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 2, fired)
+			require.Equal(t, 4, email.notified())
+
+			done, err := stores.reminders.GetReminder(ctx, once.ID)
+			require.NoError(t, err)
+			require.Equal(t, entities.ReminderDone, done.Status)
+			require.Zero(t, done.Attempts)
+			require.Empty(t, done.LastError)
+			require.NotNil(t, done.FiredAt)
+
+			next, err := stores.reminders.GetReminder(ctx, recurring.ID)
+			require.NoError(t, err)
+			require.Equal(t, entities.ReminderActive, next.Status)
+			require.WithinDuration(t, recurring.RemindAt.Add(time.Hour), next.RemindAt, time.Millisecond)
+			require.WithinDuration(t, next.RemindAt, next.NextRunAt, time.Millisecond)
+			require.NotNil(t, next.FiredAt)
+
+			fired, err = service.FireDue(ctx)
+			require.NoError(t, err)
+			require.Zero(t, fired)
+
+			// reminders on a deleted note are done without being sent
+			other, err := notesService.CreateNote(ctx, userID, entities.NoteReq{Title: "other", Content: "content"})
+			require.NoError(t, err)
+			orphan, err := service.CreateReminder(ctx, userID, other.ID, entities.ReminderReq{RemindAt: time.Now().Add(10 * time.Millisecond)})
+			require.NoError(t, err)
+			require.NoError(t, notesService.DeleteNote(ctx, userID, other.ID, 0))
+			time.Sleep(20 * time.Millisecond)
+			fired, err = service.FireDue(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, fired)
+			require.Equal(t, 4, email.notified())
+
+			orphan, err = stores.reminders.GetReminder(ctx, orphan.ID)
+			require.NoError(t, err)
+			require.Equal(t, entities.ReminderDone, orphan.Status)
+			require.NotEmpty(t, orphan.LastError)
+
+			all, err := service.ListReminders(ctx, userID, "")
+			require.NoError(t, err)
+			require.Len(t, all, 3)
+
+			require.ErrorIs(t, service.DeleteReminder(ctx, userID, other.ID, once.ID), ErrNotFound)
+			require.ErrorIs(t, service.DeleteReminder(ctx, "someone-else", note.ID, once.ID), ErrNotFound)
+			require.NoError(t, service.DeleteReminder(ctx, userID, note.ID, once.ID))
+			require.ErrorIs(t, service.DeleteReminder(ctx, userID, note.ID, once.ID), ErrNotFound)
+		})
+	}
+}
+
+func TestClaimDue(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    store,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			now := time.Now().UTC()
+			userID := "claim-" + name + "-" + uuid.NewString()
+			reminder, err := store.CreateReminder(ctx, entities.Reminder{
+				ID:        uuid.NewString(),
+				NoteID:    "note",
+				UserID:    userID,
+				RemindAt:  now.Add(-time.Hour),
+				Channel:   entities.ChannelEmail,
+				Status:    entities.ReminderActive,
+				NextRunAt: now.Add(-time.Hour),
+			})
+			require.NoError(t, err)
+
+			// the claim is a lease, another runner gets the reminder only once it ran out
+			lease := now.Add(time.Minute)
+			claimed, err := store.ClaimDue(ctx, now, lease, 100)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			require.Equal(t, reminder.ID, claimed[0].ID)
+
+			claimed, err = store.ClaimDue(ctx, now, lease, 100)
+			require.NoError(t, err)
+			require.Empty(t, claimed)
+
+			claimed, err = store.ClaimDue(ctx, lease.Add(time.Second), lease.Add(time.Minute), 100)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+
+			// the outcome of a reminder deleted while it fired is dropped
+			require.NoError(t, store.DeleteReminder(ctx, userID, reminder.ID))
+			claimed[0].Status = entities.ReminderDone
+			require.NoError(t, store.UpdateReminder(ctx, claimed[0]))
+			_, err = store.GetReminder(ctx, reminder.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestGiveUp(t *testing.T) {
+	ctx := t.Context()
+	notesService := notes.New(notes.NewMemoryStore())
+	email := &notifier{err: errors.New("connection refused")}
+	reminders := NewMemoryStore()
+	service := New(reminders, notesService, WithNotifier(entities.ChannelEmail, email), WithRetry(2, time.Millisecond))
+
+	note, err := notesService.CreateNote(ctx, "user", entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	reminder, err := service.CreateReminder(ctx, "user", note.ID, entities.ReminderReq{
+		RemindAt:   time.Now().Add(10 * time.Millisecond),
+		Recurrence: "FREQ=DAILY",
+	})
+	require.NoError(t, err)
+
+	for range 2 {
+		time.Sleep(20 * time.Millisecond)
+		fired, err := service.FireDue(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, fired)
+	}
+	require.Equal(t, 2, email.notified())
+
+	// the occurrence is given up, the next one is still fired
+	skipped, err := reminders.GetReminder(ctx, reminder.ID)
+	require.NoError(t, err)
+	require.Equal(t, entities.ReminderActive, skipped.Status)
+	require.Zero(t, skipped.Attempts)
+	require.Equal(t, "connection refused", skipped.LastError)
+	require.Nil(t, skipped.FiredAt)
+	require.WithinDuration(t, reminder.RemindAt.AddDate(0, 0, 1), skipped.RemindAt, time.Millisecond)
+}
+
+func TestRun(t *testing.T) {
+	notesService := notes.New(notes.NewMemoryStore())
+	email := &notifier{}
+	service := New(NewMemoryStore(), notesService, WithNotifier(entities.ChannelEmail, email), WithInterval(5*time.Millisecond))
+
+	note, err := notesService.CreateNote(t.Context(), "user", entities.NoteReq{Title: "title", Content: "content"})
+	require.NoError(t, err)
+	_, err = service.CreateReminder(t.Context(), "user", note.ID, entities.ReminderReq{RemindAt: time.Now().Add(10 * time.Millisecond)})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.Run(ctx)
+	}()
+	require.Eventually(t, func() bool { return email.notified() == 1 }, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	webhooks := &enqueuer{}
+	notifier := NewWebhookNotifier(webhooks)
+	reminder := entities.Reminder{ID: "reminder", UserID: "user", NoteID: "note", RemindAt: time.Now()}
+	note := entities.Note{ID: "note", Title: "title"}
+
+	require.NoError(t, notifier.Notify(t.Context(), reminder, note))
+	require.NoError(t, notifier.Notify(t.Context(), reminder, note))
+	require.Len(t, webhooks.events, 2)
+	event := webhooks.events[0]
+	require.Equal(t, entities.EventReminderDue, event.Type)
+	require.Equal(t, "note", event.NoteID)
+	require.Equal(t, "user", event.UserID)
+	require.Equal(t, "title", event.Note.Title)
+	require.Equal(t, "reminder", event.Reminder.ID)
+	// a retried occurrence keeps its id, so it is delivered once
+	require.Equal(t, event.ID, webhooks.events[1].ID)
+
+	reminder.RemindAt = reminder.RemindAt.Add(time.Hour)
+	require.NoError(t, notifier.Notify(t.Context(), reminder, note))
+	require.NotEqual(t, event.ID, webhooks.events[2].ID)
+}
+
+func TestEmailNotifier(t *testing.T) {
+	server := newSMTPServer(t)
+	usersStore := users.NewMemoryStore()
+	user, err := usersStore.CreateUser(t.Context(), entities.User{ID: "user", Email: "user@example.com"})
+	require.NoError(t, err)
+
+	notifier := NewEmailNotifier(server.listener.Addr().String(), "notes@example.com", usersStore)
+	reminder := entities.Reminder{ID: "reminder", UserID: user.ID, NoteID: "note", RemindAt: time.Now()}
+	note := entities.Note{ID: "note", Title: "Grüße", Content: "content"}
+	require.NoError(t, notifier.Notify(t.Context(), reminder, note))
+
+	rcpts, messages := server.sent()
+	require.Equal(t, []string{"user@example.com"}, rcpts)
+	require.Len(t, messages, 1)
+	require.Contains(t, messages[0], "From: notes@example.com\r\n")
+	require.Contains(t, messages[0], "To: user@example.com\r\n")
+	require.Contains(t, messages[0], "Subject: =?utf-8?q?Reminder:_Gr=C3=BC=C3=9Fe?=\r\n")
+	require.Contains(t, messages[0], "content")
+
+	_, err = usersStore.GetUser(t.Context(), "nobody")
+	require.Error(t, err)
+	reminder.UserID = "nobody"
+	require.Error(t, notifier.Notify(t.Context(), reminder, note))
+
+	closed := NewEmailNotifier("127.0.0.1:1", "notes@example.com", usersStore)
+	reminder.UserID = user.ID
+	require.Error(t, closed.Notify(t.Context(), reminder, note))
+}
+
+func TestRule(t *testing.T) {
+	for _, s := range []string{"", "FREQ", "FREQ=SECONDLY", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;BYDAY=MO", "INTERVAL=2"} {
+		_, err := ParseRule(s)
+		require.ErrorIs(t, err, ErrInvalidReminder, s)
+	}
+
+	rule, err := ParseRule("RRULE:FREQ=WEEKLY;INTERVAL=2")
+	require.NoError(t, err)
+	require.Equal(t, Rule{Freq: FreqWeekly, Interval: 2}, rule)
+	require.Equal(t, "FREQ=WEEKLY;INTERVAL=2", rule.String())
+
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	require.Equal(t, start.AddDate(0, 0, 14), rule.Next(start, start))
+	// missed occurrences are skipped
+	require.Equal(t, start.AddDate(0, 0, 42), rule.Next(start, start.AddDate(0, 0, 30)))
+
+	monthly, err := ParseRule("FREQ=MONTHLY")
+	require.NoError(t, err)
+	require.Equal(t, "FREQ=MONTHLY", monthly.String())
+	require.Equal(t, time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), monthly.Next(start, start))
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+)
+
+// memoryStore keeps reminders in a map, so everything is lost once the process exits.
+type memoryStore struct {
+	mu        sync.RWMutex
+	reminders map[string]entities.Reminder
+}
+
+// NewMemoryStore returns a Store that keeps reminders in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		reminders: make(map[string]entities.Reminder),
+	}
+}
+
+func (m *memoryStore) CreateReminder(_ context.Context, reminder entities.Reminder) (entities.Reminder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if reminder.CreatedAt.IsZero() {
+		reminder.CreatedAt = time.Now()
+	}
+	m.reminders[reminder.ID] = reminder
+	return reminder, nil
+}
+
+func (m *memoryStore) GetReminder(_ context.Context, id string) (entities.Reminder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reminder, ok := m.reminders[id]
+	if !ok {
+		return entities.Reminder{}, ErrNotFound
+	}
+	return reminder, nil
+}
+
+func (m *memoryStore) ListReminders(_ context.Context, userID, noteID string) ([]entities.Reminder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Reminder, 0)
+	for _, v := range m.reminders {
+		if v.UserID == userID && (noteID == "" || v.NoteID == noteID) {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].RemindAt.Equal(result[j].RemindAt) {
+			return result[i].RemindAt.Before(result[j].RemindAt)
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *memoryStore) DeleteReminder(_ context.Context, userID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reminder, ok := m.reminders[id]
+	if !ok || reminder.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.reminders, id)
+	return nil
+}
+
+func (m *memoryStore) ClaimDue(_ context.Context, now, until time.Time, limit int) ([]entities.Reminder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	due := make([]entities.Reminder, 0)
+	for _, v := range m.reminders {
+		if v.Status == entities.ReminderActive && !v.NextRunAt.After(now) {
+			due = append(due, v)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+	due = due[:min(limit, len(due))]
+	for _, reminder := range due {
+		reminder.NextRunAt = until
+		m.reminders[reminder.ID] = reminder
+	}
+	return due, nil
+}
+
+func (m *memoryStore) UpdateReminder(_ context.Context, reminder entities.Reminder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.reminders[reminder.ID]; ok {
+		m.reminders[reminder.ID] = reminder
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"time"
+
+	"notes/services/entities"
+
+	"github.com/google/uuid"
+)
+
+// Notifier sends a due reminder to the user who set it
+type Notifier interface {
+	Notify(ctx context.Context, reminder entities.Reminder, note entities.Note) error
+}
+
+// Enqueuer hands events to the user's webhooks
+type Enqueuer interface {
+	Enqueue(ctx context.Context, userID string, event entities.WebhookEvent) error
+}
+
+// WebhookNotifier sends reminders as reminder.due events to the user's webhooks
+type WebhookNotifier struct {
+	webhooks Enqueuer
+}
+
+// NewWebhookNotifier returns a notifier that sends reminders through webhooks
+func NewWebhookNotifier(webhooks Enqueuer) *WebhookNotifier {
+	return &WebhookNotifier{webhooks: webhooks}
+}
+
+// Notify enqueues a reminder.due event. The event id is derived from the occurrence,
+// so a retried occurrence is not delivered twice.
+func (n *WebhookNotifier) Notify(ctx context.Context, reminder entities.Reminder, note entities.Note) error {
+	return n.webhooks.Enqueue(ctx, reminder.UserID, entities.WebhookEvent{
+		ID:       occurrenceID(reminder),
+		Type:     entities.EventReminderDue,
+		NoteID:   note.ID,
+		UserID:   reminder.UserID,
+		Version:  note.Version,
+		Time:     time.Now().UTC(),
+		Note:     &note,
+		Reminder: &reminder,
+	})
+}
+
+// Users looks up the users reminders are sent to
+type Users interface {
+	GetUser(ctx context.Context, id string) (entities.User, error)
+}
+
+// EmailNotifier sends reminders by email through an SMTP server
+type EmailNotifier struct {
+	addr  string
+	from  string
+	users Users
+}
+
+// NewEmailNotifier returns a notifier that sends reminders from the given address through the SMTP server at addr
+func NewEmailNotifier(addr, from string, users Users) *EmailNotifier {
+	return &EmailNotifier{addr: addr, from: from, users: users}
+}
+
+// Notify emails the reminder to the user's address
+func (n *EmailNotifier) Notify(ctx context.Context, reminder entities.Reminder, note entities.Note) error {
+	user, err := n.users.GetUser(ctx, reminder.UserID)
+	if err != nil {
+		return err
+	}
+	message, err := n.message(user.Email, reminder, note)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	host, _, _ := net.SplitHostPort(n.addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(user.Email); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message returns the email for the reminder
+func (n *EmailNotifier) message(to string, reminder entities.Reminder, note entities.Note) ([]byte, error) {
+	var body bytes.Buffer
+	qp := quotedprintable.NewWriter(&body)
+	fmt.Fprintf(qp, "Your reminder for \"%s\" is due.\r\n\r\n%s\r\n", note.Title, note.Content)
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Reminder: "+note.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@notes>\r\n", occurrenceID(reminder))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// occurrenceID identifies the occurrence of the reminder that is due, it is the same on every attempt
+func occurrenceID(reminder entities.Reminder) string {
+	occurrence := reminder.ID + "@" + reminder.RemindAt.UTC().Format(time.RFC3339)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(occurrence)).String()
+}
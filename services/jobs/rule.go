@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies a reminder can repeat at
+const (
+	FreqHourly  = "HOURLY"
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxInterval keeps the occurrences of a rule within a sensible distance
+const maxInterval = 1000
+
+// Rule is the recurrence rule of a reminder, the FREQ and INTERVAL parts of an
+// RFC 5545 RRULE such as FREQ=WEEKLY;INTERVAL=2
+type Rule struct {
+	Freq     string
+	Interval int
+}
+
+// ParseRule parses a recurrence rule, with or without the RRULE: prefix. Parts other
+// than FREQ and INTERVAL are not supported.
+func ParseRule(s string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("%w: recurrence part %q is not KEY=VALUE", ErrInvalidReminder, part)
+		}
+		switch key {
+		case "FREQ":
+			switch value {
+			case FreqHourly, FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = value
+			default:
+				return Rule{}, fmt.Errorf("%w: unsupported recurrence frequency %q", ErrInvalidReminder, value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 || interval > maxInterval {
+				return Rule{}, fmt.Errorf("%w: recurrence interval must be between 1 and %d", ErrInvalidReminder, maxInterval)
+			}
+			rule.Interval = interval
+		default:
+			return Rule{}, fmt.Errorf("%w: unsupported recurrence part %q", ErrInvalidReminder, key)
+		}
+	}
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("%w: recurrence needs a FREQ", ErrInvalidReminder)
+	}
+	return rule, nil
+}
+
+// String returns the rule in its canonical form
+func (r Rule) String() string {
+	if r.Interval == 1 {
+		return "FREQ=" + r.Freq
+	}
+	return fmt.Sprintf("FREQ=%s;INTERVAL=%d", r.Freq, r.Interval)
+}
+
+// Next returns the first occurrence after the given one that is later than after, skipping
+// those that were missed. Days are counted in UTC, and months that are too short for the
+// day roll over into the next, as with time.AddDate.
+func (r Rule) Next(occurrence, after time.Time) time.Time {
+	for n := 1; ; n++ {
+		next := r.add(occurrence, n*r.Interval)
+		if next.After(after) {
+			return next
+		}
+	}
+}
+
+// add moves t on by n units of the rule's frequency
+func (r Rule) add(t time.Time, n int) time.Time {
+	t = t.UTC()
+	switch r.Freq {
+	case FreqHourly:
+		return t.Add(time.Duration(n) * time.Hour)
+	case FreqDaily:
+		return t.AddDate(0, 0, n)
+	case FreqWeekly:
+		return t.AddDate(0, 0, 7*n)
+	case FreqMonthly:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(n, 0, 0)
+	}
+}
@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"notes/repositories"
+	"notes/services/entities"
+)
+
+// sqlStore stores reminders through the sqlc repository. Times are written in UTC, so
+// sqlite, which keeps them as text, compares them in order.
+type sqlStore struct {
+	db         *sql.DB
+	repository *repositories.Queries
+	// lock is set for MySQL, where due reminders are locked with FOR UPDATE SKIP LOCKED so
+	// replicas sharing the database pass over the ones another replica is claiming
+	lock bool
+}
+
+// NewMySQLStore returns a Store backed by a MySQL database that several runners can share
+func NewMySQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		db:         db,
+		repository: repositories.New(db),
+		lock:       true,
+	}
+}
+
+// NewSQLiteStore returns a Store backed by an embedded sqlite database. Only one process
+// can use the database, so there is no other runner to lock the reminders against.
+func NewSQLiteStore(db *sql.DB) Store {
+	return &sqlStore{
+		db:         db,
+		repository: repositories.New(db),
+	}
+}
+
+func (s *sqlStore) CreateReminder(ctx context.Context, reminder entities.Reminder) (entities.Reminder, error) {
+	err := s.repository.CreateReminder(ctx, repositories.CreateReminderParams{
+		ReminderID: reminder.ID,
+		NoteID:     reminder.NoteID,
+		UserID:     reminder.UserID,
+		RemindAt:   reminder.RemindAt.UTC(),
+		Recurrence: reminder.Recurrence,
+		Channel:    reminder.Channel,
+		Status:     reminder.Status,
+		NextRunAt:  reminder.NextRunAt.UTC(),
+	})
+	if err != nil {
+		return entities.Reminder{}, err
+	}
+	return s.GetReminder(ctx, reminder.ID)
+}
+
+func (s *sqlStore) GetReminder(ctx context.Context, id string) (entities.Reminder, error) {
+	reminder, err := s.repository.FindReminder(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Reminder{}, ErrNotFound
+		}
+		return entities.Reminder{}, err
+	}
+	return toEntity(reminder), nil
+}
+
+func (s *sqlStore) ListReminders(ctx context.Context, userID, noteID string) ([]entities.Reminder, error) {
+	var (
+		reminders []repositories.Reminder
+		err       error
+	)
+	if noteID == "" {
+		reminders, err = s.repository.FindRemindersByUserID(ctx, userID)
+	} else {
+		reminders, err = s.repository.FindRemindersByNoteID(ctx, repositories.FindRemindersByNoteIDParams{
+			UserID: userID,
+			NoteID: noteID,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.Reminder, 0, len(reminders))
+	for i := range reminders {
+		result = append(result, toEntity(reminders[i]))
+	}
+	return result, nil
+}
+
+func (s *sqlStore) DeleteReminder(ctx context.Context, userID, id string) error {
+	rows, err := s.repository.DeleteReminder(ctx, repositories.DeleteReminderParams{
+		ReminderID: id,
+		UserID:     userID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimDue claims the reminders in a transaction, which on MySQL locks them so replicas
+// sharing the database pass over the ones another replica is claiming
+func (s *sqlStore) ClaimDue(ctx context.Context, now, until time.Time, limit int) ([]entities.Reminder, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := s.repository.WithTx(tx)
+
+	var due []repositories.Reminder
+	if s.lock {
+		due, err = q.LockDueReminders(ctx, repositories.LockDueRemindersParams{
+			NextRunAt: now.UTC(),
+			Limit:     int32(limit),
+		})
+	} else {
+		due, err = q.FindDueReminders(ctx, repositories.FindDueRemindersParams{
+			NextRunAt: now.UTC(),
+			Limit:     int32(limit),
+		})
+	}
+	if err != nil {
+		return nil, errors.Join(err, tx.Rollback())
+	}
+
+	result := make([]entities.Reminder, 0, len(due))
+	for _, row := range due {
+		err := q.ClaimReminder(ctx, repositories.ClaimReminderParams{
+			NextRunAt:  until.UTC(),
+			ReminderID: row.ReminderID,
+		})
+		if err != nil {
+			return nil, errors.Join(err, tx.Rollback())
+		}
+		result = append(result, toEntity(row))
+	}
+	return result, tx.Commit()
+}
+
+func (s *sqlStore) UpdateReminder(ctx context.Context, reminder entities.Reminder) error {
+	params := repositories.UpdateReminderParams{
+		RemindAt:   reminder.RemindAt.UTC(),
+		Status:     reminder.Status,
+		NextRunAt:  reminder.NextRunAt.UTC(),
+		Attempts:   int32(reminder.Attempts),
+		LastError:  reminder.LastError,
+		ReminderID: reminder.ID,
+	}
+	if reminder.FiredAt != nil {
+		params.FiredAt = sql.NullTime{Time: reminder.FiredAt.UTC(), Valid: true}
+	}
+	return s.repository.UpdateReminder(ctx, params)
+}
+
+func toEntity(r repositories.Reminder) entities.Reminder {
+	reminder := entities.Reminder{
+		ID:         r.ReminderID,
+		NoteID:     r.NoteID,
+		UserID:     r.UserID,
+		RemindAt:   r.RemindAt,
+		Recurrence: r.Recurrence,
+		Channel:    r.Channel,
+		Status:     r.Status,
+		LastError:  r.LastError,
+		CreatedAt:  r.CreatedAt.Time,
+		NextRunAt:  r.NextRunAt,
+		Attempts:   int(r.Attempts),
+	}
+	if r.FiredAt.Valid {
+		reminder.FiredAt = &r.FiredAt.Time
+	}
+	return reminder
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"notes/services/entities"
+)
+
+// Store persists reminders
+type Store interface {
+	// CreateReminder stores the given reminder and returns the stored copy
+	CreateReminder(ctx context.Context, reminder entities.Reminder) (entities.Reminder, error)
+	// GetReminder returns the reminder with the given id or ErrNotFound
+	GetReminder(ctx context.Context, id string) (entities.Reminder, error)
+	// ListReminders returns the user's reminders in the order they fire, only those on
+	// the note unless noteID is empty
+	ListReminders(ctx context.Context, userID, noteID string) ([]entities.Reminder, error)
+	// DeleteReminder removes the user's reminder with the given id or returns ErrNotFound
+	DeleteReminder(ctx context.Context, userID, id string) error
+	// ClaimDue returns up to limit active reminders whose next run is due at now, most overdue
+	// first, and moves their next run to until so no runner sharing the store claims them again
+	// before then. The claim is committed before the reminders are returned.
+	ClaimDue(ctx context.Context, now, until time.Time, limit int) ([]entities.Reminder, error)
+	// UpdateReminder stores the outcome of firing a claimed reminder. A reminder deleted in
+	// the meantime stays deleted.
+	UpdateReminder(ctx context.Context, reminder entities.Reminder) error
+}
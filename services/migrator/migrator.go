@@ -10,7 +10,9 @@ import (
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func SetupDB(ctx context.Context, connectionString string) (*sql.DB, error) {
@@ -51,3 +53,40 @@ func Migrate(ctx context.Context, db *sql.DB, connectionString string) error {
 
 	return m.Up()
 }
+
+// SetupSQLite opens the sqlite database at the given path, creating it if needed.
+// Use ":memory:" for a throwaway database.
+func SetupSQLite(ctx context.Context, path string) (*sql.DB, error) {
+	slog.InfoContext(ctx, "Setting up sqlite database", "path", path)
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open sqlite db: %w", err)
+	}
+	// sqlite only allows a single writer and every connection to ":memory:" is a new database
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("cannot ping sqlite db: %w", err)
+	}
+	return db, nil
+}
+
+// MigrateSQLite applies the sqlite migrations to the given database
+func MigrateSQLite(ctx context.Context, db *sql.DB) error {
+	slog.InfoContext(ctx, "Migrating sqlite database")
+	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		return fmt.Errorf("cannot initialize sqlite driver: %w", err)
+	}
+
+	source, err := iofs.New(migrations.SQLite, "sqlite")
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite3", driver)
+	if err != nil {
+		return err
+	}
+
+	return m.Up()
+}
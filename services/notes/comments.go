@@ -0,0 +1,174 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrCommentNotFound is returned when a comment does not exist or was deleted
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrInvalidComment is returned when a comment request is invalid
+	ErrInvalidComment = errors.New("invalid comment")
+)
+
+// GetComments returns the comments on the note, oldest first, to anyone who can read it.
+// Clients build the threads from the parent ids.
+func (s *Service) GetComments(ctx context.Context, userID, id string) ([]entities.Comment, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetComments")
+	defer span.End()
+
+	if _, err := s.access(ctx, userID, id, false, entities.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.store.ListComments(ctx, id)
+}
+
+// CreateComment adds the user's comment to the note, or their reply to the thread
+// req.ParentID started. Commenters, editors and the owner can comment.
+func (s *Service) CreateComment(ctx context.Context, userID, id string, req entities.CommentReq) (entities.Comment, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.CreateComment")
+	defer span.End()
+
+	body := strings.TrimSpace(req.Body)
+	if err := validateCommentBody(body); err != nil {
+		return entities.Comment{}, err
+	}
+	if _, err := s.access(ctx, userID, id, false, entities.RoleCommenter); err != nil {
+		return entities.Comment{}, err
+	}
+	if req.ParentID != "" {
+		parent, err := s.store.GetComment(ctx, req.ParentID)
+		if err != nil && !errors.Is(err, ErrCommentNotFound) {
+			return entities.Comment{}, err
+		}
+		if err != nil || parent.NoteID != id {
+			return entities.Comment{}, fmt.Errorf("%w: comment %s does not exist", ErrInvalidComment, req.ParentID)
+		}
+		if parent.ParentID != "" {
+			return entities.Comment{}, fmt.Errorf("%w: replies go to the comment that started the thread", ErrInvalidComment)
+		}
+	}
+	span.SetAttributes(attribute.Bool("reply", req.ParentID != ""))
+
+	return s.store.CreateComment(ctx, entities.Comment{
+		ID:        uuid.NewString(),
+		NoteID:    id,
+		UserID:    userID,
+		ParentID:  req.ParentID,
+		Body:      body,
+		CreatedAt: time.Now(),
+	})
+}
+
+// EditComment changes the body of the user's own comment
+func (s *Service) EditComment(ctx context.Context, userID, id, commentID string, req entities.CommentEdit) (entities.Comment, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.EditComment")
+	defer span.End()
+
+	body := strings.TrimSpace(req.Body)
+	if err := validateCommentBody(body); err != nil {
+		return entities.Comment{}, err
+	}
+	comment, err := s.comment(ctx, userID, id, commentID, entities.RoleCommenter)
+	if err != nil {
+		return entities.Comment{}, err
+	}
+	if comment.UserID != userID {
+		return entities.Comment{}, fmt.Errorf("%w: only the author can edit a comment", ErrForbidden)
+	}
+	comment.Body = body
+	return s.store.UpdateComment(ctx, comment)
+}
+
+// ResolveComment marks the thread the comment started as resolved, or reopens it.
+// Anyone who can comment on the note can resolve its threads.
+func (s *Service) ResolveComment(ctx context.Context, userID, id, commentID string, resolved bool) (entities.Comment, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ResolveComment")
+	defer span.End()
+
+	comment, err := s.comment(ctx, userID, id, commentID, entities.RoleCommenter)
+	if err != nil {
+		return entities.Comment{}, err
+	}
+	if comment.ParentID != "" {
+		return entities.Comment{}, fmt.Errorf("%w: only the comment that started a thread can be resolved", ErrInvalidComment)
+	}
+	span.SetAttributes(attribute.Bool("resolved", resolved))
+	comment.Resolved = resolved
+	return s.store.UpdateComment(ctx, comment)
+}
+
+// DeleteComment deletes the comment, with its replies when it started a thread. Authors
+// can delete their own comments and the owner of the note can delete any of them.
+func (s *Service) DeleteComment(ctx context.Context, userID, id, commentID string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.DeleteComment")
+	defer span.End()
+
+	comment, err := s.comment(ctx, userID, id, commentID, entities.RoleCommenter)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != userID {
+		if _, err := s.access(ctx, userID, id, false, entities.RoleOwner); err != nil {
+			return fmt.Errorf("%w: only the author or the owner of the note can delete a comment", ErrForbidden)
+		}
+	}
+	return s.store.DeleteComment(ctx, commentID)
+}
+
+// comment returns the comment on the note if the user has at least the given role on it.
+// Comments on other notes are reported as ErrCommentNotFound.
+func (s *Service) comment(ctx context.Context, userID, id, commentID, role string) (entities.Comment, error) {
+	if _, err := s.access(ctx, userID, id, false, role); err != nil {
+		return entities.Comment{}, err
+	}
+	comment, err := s.store.GetComment(ctx, commentID)
+	if err != nil {
+		return entities.Comment{}, err
+	}
+	if comment.NoteID != id {
+		return entities.Comment{}, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+// withCommentCounts sets the number of comments on each of the notes
+func (s *Service) withCommentCounts(ctx context.Context, notes []entities.Note) error {
+	ids := make([]string, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	counts, err := s.store.CountComments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range notes {
+		count := counts[notes[i].ID]
+		notes[i].Comments = &count
+	}
+	return nil
+}
+
+// validateCommentBody checks a trimmed comment body is not empty and not too long
+func validateCommentBody(body string) error {
+	switch {
+	case body == "":
+		return fmt.Errorf("%w: body is required", ErrInvalidComment)
+	case !utf8.ValidString(body):
+		return fmt.Errorf("%w: body must be valid UTF-8", ErrInvalidComment)
+	case utf8.RuneCountInString(body) > entities.MaxCommentLength:
+		return fmt.Errorf("%w: body must be at most %d characters", ErrInvalidComment, entities.MaxCommentLength)
+	}
+	return nil
+}
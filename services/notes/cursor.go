@@ -0,0 +1,171 @@
+package notes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"notes/services/entities"
+)
+
+const (
+	// SortCreatedAt orders notes by creation time
+	SortCreatedAt = "created_at"
+	// SortUpdatedAt orders notes by the time they were last updated
+	SortUpdatedAt = "updated_at"
+	// SortTitle orders notes by title
+	SortTitle = "title"
+
+	// OrderAsc sorts in ascending order
+	OrderAsc = "asc"
+	// OrderDesc sorts in descending order
+	OrderDesc = "desc"
+
+	// TagModeAll lists the notes that have every one of the tags
+	TagModeAll = "all"
+	// TagModeAny lists the notes that have at least one of the tags
+	TagModeAny = "any"
+
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// ListParams are the validated options a Store lists notes with
+type ListParams struct {
+	UserID        string
+	TitlePrefix   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// Tags are normalized tag names the notes are filtered on, as set by TagMode
+	Tags    []string
+	TagMode string
+	// NotebookIDs lists the notes in any of the notebooks, all notes when empty
+	NotebookIDs []string
+	Sort        string
+	Order       string
+	Limit       int
+	// Deleted lists the notes in the trash instead of the live ones
+	Deleted bool
+	// SharedWith lists the notes other users shared with this user instead of those of UserID
+	SharedWith string
+	// After is the last note of the previous page, nil for the first page
+	After *Cursor
+}
+
+// Cursor is the position of a note in a listing, made of its sort key and id
+type Cursor struct {
+	Time  time.Time
+	Title string
+	ID    string
+}
+
+// encodedCursor is the opaque cursor handed to clients. It carries the sort
+// and order so a cursor cannot be replayed against a different listing.
+type encodedCursor struct {
+	Sort  string    `json:"s"`
+	Order string    `json:"o"`
+	Time  time.Time `json:"t,omitempty"`
+	Title string    `json:"v,omitempty"`
+	ID    string    `json:"id"`
+}
+
+// listParams validates the query and decodes its cursor
+func listParams(q entities.NoteQuery) (ListParams, error) {
+	params := ListParams{
+		UserID:        q.UserID,
+		TitlePrefix:   q.TitlePrefix,
+		CreatedAfter:  q.CreatedAfter,
+		CreatedBefore: q.CreatedBefore,
+		UpdatedAfter:  q.UpdatedAfter,
+		UpdatedBefore: q.UpdatedBefore,
+		Tags:          normalizeTags(q.Tags),
+		TagMode:       q.TagMode,
+		Sort:          q.Sort,
+		Order:         q.Order,
+		Limit:         q.Limit,
+	}
+	if q.SharedWithMe {
+		params.UserID, params.SharedWith = "", q.UserID
+	}
+	if params.Sort == "" {
+		params.Sort = SortCreatedAt
+	}
+	if params.Order == "" {
+		params.Order = OrderDesc
+	}
+	if params.Limit == 0 {
+		params.Limit = defaultLimit
+	}
+	if params.TagMode == "" {
+		params.TagMode = TagModeAll
+	}
+
+	switch params.Sort {
+	case SortCreatedAt, SortUpdatedAt, SortTitle:
+	default:
+		return ListParams{}, fmt.Errorf("%w: sort must be one of created_at, updated_at or title", ErrInvalidQuery)
+	}
+	if params.Order != OrderAsc && params.Order != OrderDesc {
+		return ListParams{}, fmt.Errorf("%w: order must be asc or desc", ErrInvalidQuery)
+	}
+	if params.Limit < 1 || params.Limit > maxLimit {
+		return ListParams{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, maxLimit)
+	}
+	if params.TagMode != TagModeAll && params.TagMode != TagModeAny {
+		return ListParams{}, fmt.Errorf("%w: tag_mode must be all or any", ErrInvalidQuery)
+	}
+	if len(params.Tags) > entities.MaxTags {
+		return ListParams{}, fmt.Errorf("%w: at most %d tags can be filtered on", ErrInvalidQuery, entities.MaxTags)
+	}
+
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor, params.Sort, params.Order)
+		if err != nil {
+			return ListParams{}, err
+		}
+		params.After = &after
+	}
+	return params, nil
+}
+
+func encodeCursor(note entities.Note, sort, order string) string {
+	c := encodedCursor{
+		Sort:  sort,
+		Order: order,
+		ID:    note.ID,
+	}
+	switch sort {
+	case SortCreatedAt:
+		c.Time = note.CreatedAt
+	case SortUpdatedAt:
+		c.Time = note.UpdatedAt
+	case SortTitle:
+		c.Title = note.Title
+	}
+
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(value, sort, order string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	var c encodedCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.Sort != sort || c.Order != order {
+		return Cursor{}, fmt.Errorf("%w: cursor does not match the sort and order", ErrInvalidQuery)
+	}
+	return Cursor{
+		Time:  c.Time,
+		Title: c.Title,
+		ID:    c.ID,
+	}, nil
+}
@@ -0,0 +1,174 @@
+package notes
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// defaultEventBuffer is the number of events kept for clients resuming their stream
+	defaultEventBuffer = 1000
+	// subscriberBuffer is the number of events a subscriber can fall behind before it is dropped
+	subscriberBuffer = 64
+)
+
+// WithEventBuffer keeps the last size events so clients that reconnect can catch up
+func WithEventBuffer(size int) Option {
+	return func(s *Service) {
+		s.events.size = max(size, 0)
+	}
+}
+
+// Subscription streams the events of the notes a user can see
+type Subscription struct {
+	// Replay are the buffered events after the last event id the client saw, oldest first
+	Replay []entities.Event
+	// Missed is set when some of the events after the last event id are no longer buffered,
+	// so the client has to fetch its notes again
+	Missed bool
+	// LastEventID is the id of the last event published before the subscription started
+	LastEventID int64
+	// Events delivers the events as they happen. It is closed once the subscription's context
+	// is done, or when the client falls too far behind and should reconnect.
+	Events <-chan entities.Event
+}
+
+// hub fans note events out to the subscribers in the same process and keeps the
+// most recent ones for clients resuming their stream
+type hub struct {
+	mu sync.Mutex
+	// last is the id of the last event, it starts at the current time in microseconds
+	// so ids keep growing across restarts
+	last int64
+	// buffer holds the most recent events, oldest first
+	buffer      []entities.Event
+	size        int
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	userID string
+	events chan entities.Event
+}
+
+func newHub() *hub {
+	return &hub{
+		last:        time.Now().UnixMicro(),
+		size:        defaultEventBuffer,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe streams the events of the notes the user can see until ctx is done. A non-zero
+// lastEventID resumes a stream after the event with that id.
+func (s *Service) Subscribe(ctx context.Context, userID string, lastEventID int64) Subscription {
+	h := s.events
+	sub := &subscriber{userID: userID, events: make(chan entities.Event, subscriberBuffer)}
+
+	h.mu.Lock()
+	var result Subscription
+	if lastEventID != 0 {
+		// an id from before the oldest buffered event or from the future, e.g. after
+		// a restart with a slower clock, cannot be resumed from
+		result.Missed = lastEventID > h.last ||
+			(len(h.buffer) == 0 && lastEventID < h.last) ||
+			(len(h.buffer) > 0 && lastEventID < h.buffer[0].ID-1)
+		for _, event := range h.buffer {
+			if event.ID > lastEventID && slices.Contains(event.Audience, userID) {
+				result.Replay = append(result.Replay, event)
+			}
+		}
+	}
+	result.LastEventID = h.last
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.remove(sub)
+	}()
+	result.Events = sub.events
+	return result
+}
+
+// publish sends an event about the note to its owner and the users it is shared with
+func (s *Service) publish(ctx context.Context, eventType string, note entities.Note) {
+	s.publishTo(ctx, eventType, note, s.audience(ctx, note))
+}
+
+// audience returns the ids of the users who can see the note
+func (s *Service) audience(ctx context.Context, note entities.Note) []string {
+	audience := []string{note.UserID}
+	shares, err := s.store.ListShares(ctx, note.ID)
+	if err != nil {
+		// the owner still hears about it, collaborators catch up when they reload
+		slog.ErrorContext(ctx, "failed to find the audience of a note event", "note_id", note.ID, "error", err)
+	}
+	for _, share := range shares {
+		audience = append(audience, share.UserID)
+	}
+	return audience
+}
+
+// publishTo sends an event about the note to the given users
+func (s *Service) publishTo(ctx context.Context, eventType string, note entities.Note, audience []string) {
+	event := entities.Event{
+		Type:         eventType,
+		NoteID:       note.ID,
+		Version:      note.Version,
+		Time:         time.Now(),
+		TraceContext: make(map[string]string),
+		Audience:     audience,
+	}
+	if eventType != entities.EventNoteDeleted {
+		event.Note = &note
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(event.TraceContext))
+	s.events.publish(event)
+}
+
+func (h *hub) publish(event entities.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.last++
+	event.ID = h.last
+	if h.size > 0 {
+		if len(h.buffer) == h.size {
+			h.buffer = slices.Delete(h.buffer, 0, 1)
+		}
+		h.buffer = append(h.buffer, event)
+	}
+
+	for sub := range h.subscribers {
+		if !slices.Contains(event.Audience, sub.userID) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// the client is too slow, it resumes from the buffer once it reconnects
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// remove drops the subscriber unless publish already did
+func (h *hub) remove(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
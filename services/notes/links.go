@@ -0,0 +1,165 @@
+package notes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrLinkNotFound is returned when a public link is unknown, revoked, expired or has used up its views
+	ErrLinkNotFound = errors.New("link not found")
+	// ErrLinkPassword is returned when a public link is opened without its password or with a wrong one
+	ErrLinkPassword = errors.New("wrong link password")
+	// ErrInvalidLink is returned when a link request is invalid
+	ErrInvalidLink = errors.New("invalid link")
+)
+
+// GetLinks returns the public links to the owner's note, oldest first, without their tokens
+func (s *Service) GetLinks(ctx context.Context, userID, id string) ([]entities.Link, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetLinks")
+	defer span.End()
+
+	if _, err := s.access(ctx, userID, id, false, entities.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.store.ListLinks(ctx, id)
+}
+
+// CreateLink creates a public link to the owner's note. The returned token is the
+// only copy of the secret, it cannot be recovered afterwards.
+func (s *Service) CreateLink(ctx context.Context, userID, id string, req entities.LinkReq) (entities.Link, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.CreateLink")
+	defer span.End()
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return entities.Link{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidLink)
+	}
+	if req.MaxViews != nil && *req.MaxViews < 1 {
+		return entities.Link{}, fmt.Errorf("%w: max_views must be at least 1", ErrInvalidLink)
+	}
+	if len(req.Password) > entities.MaxLinkPasswordLength {
+		return entities.Link{}, fmt.Errorf("%w: password must be at most %d bytes", ErrInvalidLink, entities.MaxLinkPasswordLength)
+	}
+	if _, err := s.access(ctx, userID, id, false, entities.RoleOwner); err != nil {
+		return entities.Link{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return entities.Link{}, err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(secret)
+
+	link := entities.Link{
+		ID:        uuid.NewString(),
+		NoteID:    id,
+		TokenHash: hashToken(raw),
+		ExpiresAt: req.ExpiresAt,
+		MaxViews:  req.MaxViews,
+		CreatedAt: time.Now(),
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return entities.Link{}, err
+		}
+		link.PasswordHash = string(hash)
+		link.Protected = true
+	}
+	span.SetAttributes(attribute.String("link_id", link.ID), attribute.Bool("protected", link.Protected))
+
+	link, err := s.store.CreateLink(ctx, link)
+	if err != nil {
+		return entities.Link{}, err
+	}
+	link.Token = raw
+	return link, nil
+}
+
+// RevokeLink deletes the public link to the owner's note so it can no longer be opened
+func (s *Service) RevokeLink(ctx context.Context, userID, id, linkID string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.RevokeLink")
+	defer span.End()
+
+	if _, err := s.access(ctx, userID, id, false, entities.RoleOwner); err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.String("link_id", linkID))
+	return s.store.DeleteLink(ctx, id, linkID)
+}
+
+// OpenLink returns the note behind the public link token and counts the view. Links that are
+// unknown, expired, used up or point to a note in the trash are all reported as ErrLinkNotFound.
+// Every attempt is recorded on the span with its outcome.
+func (s *Service) OpenLink(ctx context.Context, token, password string) (entities.PublicNote, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.OpenLink")
+	defer span.End()
+
+	outcome := func(outcome string) {
+		span.SetAttributes(attribute.String("outcome", outcome))
+	}
+
+	link, err := s.store.GetLinkByHash(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			outcome("unknown")
+		}
+		return entities.PublicNote{}, err
+	}
+	span.SetAttributes(attribute.String("link_id", link.ID), attribute.String("note_id", link.NoteID))
+
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		outcome("expired")
+		return entities.PublicNote{}, ErrLinkNotFound
+	}
+	if link.PasswordHash != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+			outcome("wrong_password")
+			return entities.PublicNote{}, ErrLinkPassword
+		}
+	}
+
+	note, err := s.store.GetNote(ctx, link.NoteID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			outcome("note_deleted")
+			return entities.PublicNote{}, ErrLinkNotFound
+		}
+		return entities.PublicNote{}, err
+	}
+	// counted last, so failed attempts do not use up the views
+	if err := s.store.ViewLink(ctx, link.ID); err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			outcome("used_up")
+		}
+		return entities.PublicNote{}, err
+	}
+	outcome("opened")
+
+	return entities.PublicNote{
+		Title:       note.Title,
+		Content:     note.Content,
+		ContentType: note.ContentType,
+		Tags:        note.Tags,
+		UpdatedAt:   note.UpdatedAt,
+	}, nil
+}
+
+// hashToken returns the hex sha256 of a link token, the only form tokens are stored in
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
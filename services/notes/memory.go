@@ -0,0 +1,936 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+)
+
+// memoryStore keeps notes in a map, so everything is lost once the process exits.
+type memoryStore struct {
+	mu    locker
+	notes map[string]entities.Note
+	// revisions of each note, oldest first
+	revisions map[string][]entities.Revision
+	notebooks map[string]entities.Notebook
+	// shares of each note by the id of the user it is shared with
+	shares map[string]map[string]entities.Share
+	links  map[string]entities.Link
+	// comments by id, including the deleted ones until they are purged
+	comments map[string]entities.Comment
+	// change sequence of each user
+	sequences map[string]Sequence
+	// events waiting for the webhooks, oldest first
+	outbox   []entities.OutboxEvent
+	outboxID int64
+	// journal is set on the store WithTx hands out, to undo its changes when the transaction fails
+	journal *journal
+}
+
+// journal holds the functions that put back the entries a transaction changed, in the order they were changed
+type journal []func()
+
+// remember records the entry under key, so it is put back when the transaction fails
+func remember[K comparable, V any](m *memoryStore, entries map[K]V, key K) {
+	if m.journal == nil {
+		return
+	}
+	old, ok := entries[key]
+	*m.journal = append(*m.journal, func() {
+		if ok {
+			entries[key] = old
+		} else {
+			delete(entries, key)
+		}
+	})
+}
+
+// put stores the entry under key, the caller holds the write lock
+func put[K comparable, V any](m *memoryStore, entries map[K]V, key K, value V) {
+	remember(m, entries, key)
+	entries[key] = value
+}
+
+// remove deletes the entry under key, the caller holds the write lock
+func remove[K comparable, V any](m *memoryStore, entries map[K]V, key K) {
+	remember(m, entries, key)
+	delete(entries, key)
+}
+
+// locker guards the maps of a memoryStore
+type locker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// noLock is the locker of the store WithTx hands out, whose maps are guarded by the lock WithTx holds
+type noLock struct{}
+
+func (noLock) Lock()    {}
+func (noLock) Unlock()  {}
+func (noLock) RLock()   {}
+func (noLock) RUnlock() {}
+
+// NewMemoryStore returns a Store that keeps notes in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		mu:        &sync.RWMutex{},
+		notes:     make(map[string]entities.Note),
+		revisions: make(map[string][]entities.Revision),
+		notebooks: make(map[string]entities.Notebook),
+		shares:    make(map[string]map[string]entities.Share),
+		links:     make(map[string]entities.Link),
+		comments:  make(map[string]entities.Comment),
+		sequences: make(map[string]Sequence),
+	}
+}
+
+// WithTx runs fn on the store while holding the write lock. The changes fn makes are
+// journaled and undone when it fails, so only the entries it touches are copied.
+func (m *memoryStore) WithTx(_ context.Context, fn func(store Store) error) error {
+	if _, ok := m.mu.(noLock); ok {
+		return fn(m)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memoryStore{
+		mu:        noLock{},
+		notes:     m.notes,
+		revisions: m.revisions,
+		notebooks: m.notebooks,
+		shares:    m.shares,
+		links:     m.links,
+		comments:  m.comments,
+		sequences: m.sequences,
+		outbox:    m.outbox,
+		outboxID:  m.outboxID,
+		journal:   &journal{},
+	}
+	if err := fn(tx); err != nil {
+		for i := len(*tx.journal) - 1; i >= 0; i-- {
+			(*tx.journal)[i]()
+		}
+		return err
+	}
+	// events are only appended, so the store's outbox is left as it was when fn fails
+	m.outbox, m.outboxID = tx.outbox, tx.outboxID
+	return nil
+}
+
+func (m *memoryStore) CreateNote(_ context.Context, note entities.Note) (entities.Note, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = time.Now()
+	}
+	note.UpdatedAt = note.CreatedAt
+	note.Tags = append([]string{}, note.Tags...)
+	note.Version = 1
+	note.Seq = m.nextSeq(note.UserID)
+	put(m, m.notes, note.ID, note)
+	return note, nil
+}
+
+func (m *memoryStore) GetNote(_ context.Context, id string) (entities.Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	note, ok := m.notes[id]
+	if !ok || note.DeletedAt != nil {
+		return entities.Note{}, ErrNotFound
+	}
+	return note, nil
+}
+
+func (m *memoryStore) GetNoteWithDeleted(_ context.Context, id string) (entities.Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	note, ok := m.notes[id]
+	if !ok {
+		return entities.Note{}, ErrNotFound
+	}
+	return note, nil
+}
+
+func (m *memoryStore) ListNotes(_ context.Context, params ListParams) ([]entities.Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Note, 0)
+	for _, v := range m.notes {
+		if params.SharedWith != "" {
+			if _, ok := m.shares[v.ID][params.SharedWith]; !ok {
+				continue
+			}
+		}
+		if matches(v, params) {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareNotes(result[i], result[j], params.Sort, params.Order) < 0
+	})
+	if len(result) > params.Limit {
+		result = result[:params.Limit]
+	}
+	return result, nil
+}
+
+func (m *memoryStore) UpdateNote(_ context.Context, note entities.Note) (entities.Note, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.notes[note.ID]
+	if !ok || existing.DeletedAt != nil {
+		return entities.Note{}, ErrNotFound
+	}
+	if existing.Version != note.Version {
+		return entities.Note{}, ErrVersionConflict
+	}
+	revision := 1
+	if revisions := m.revisions[note.ID]; len(revisions) > 0 {
+		revision = revisions[len(revisions)-1].Revision + 1
+	}
+	remember(m, m.revisions, note.ID)
+	m.revisions[note.ID] = append(m.revisions[note.ID], entities.Revision{
+		Revision:    revision,
+		NoteID:      existing.ID,
+		Title:       existing.Title,
+		Content:     existing.Content,
+		ContentType: existing.ContentType,
+		CreatedAt:   time.Now(),
+	})
+
+	existing.Title = note.Title
+	existing.Content = note.Content
+	existing.ContentType = note.ContentType
+	existing.NotebookID = note.NotebookID
+	existing.Tags = append([]string{}, note.Tags...)
+	existing.Version++
+	existing.Seq = m.nextSeq(existing.UserID)
+	existing.UpdatedAt = time.Now()
+	put(m, m.notes, note.ID, existing)
+	return existing, nil
+}
+
+func (m *memoryStore) DeleteNote(_ context.Context, id string, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note, ok := m.notes[id]
+	if !ok || note.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if note.Version != version {
+		return ErrVersionConflict
+	}
+	now := time.Now()
+	note.DeletedAt = &now
+	note.Version++
+	note.Seq = m.nextSeq(note.UserID)
+	put(m, m.notes, id, note)
+	return nil
+}
+
+func (m *memoryStore) RestoreNote(_ context.Context, id string) (entities.Note, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note, ok := m.notes[id]
+	if !ok || note.DeletedAt == nil {
+		return entities.Note{}, ErrNotFound
+	}
+	note.DeletedAt = nil
+	note.Version++
+	note.Seq = m.nextSeq(note.UserID)
+	note.UpdatedAt = time.Now()
+	put(m, m.notes, id, note)
+	return note, nil
+}
+
+func (m *memoryStore) PurgeNote(_ context.Context, id string, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note, ok := m.notes[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if note.Version != version {
+		return ErrVersionConflict
+	}
+	// a note purged out of the trash was last seen with its own seq, a live one takes a new one
+	horizon := note.Seq
+	if note.DeletedAt == nil {
+		horizon = m.nextSeq(note.UserID)
+	}
+	m.raisePurged(note.UserID, horizon)
+	remove(m, m.notes, id)
+	remove(m, m.revisions, id)
+	remove(m, m.shares, id)
+	m.deleteLinks(id)
+	m.deleteComments(id)
+	return nil
+}
+
+func (m *memoryStore) PurgeDeleted(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, note := range m.notes {
+		if note.DeletedAt != nil && note.DeletedAt.Before(before) {
+			m.raisePurged(note.UserID, note.Seq)
+			remove(m, m.notes, id)
+			remove(m, m.revisions, id)
+			remove(m, m.shares, id)
+			m.deleteLinks(id)
+			m.deleteComments(id)
+			purged++
+		}
+	}
+	for id, comment := range m.comments {
+		if comment.DeletedAt != nil && comment.DeletedAt.Before(before) {
+			remove(m, m.comments, id)
+		}
+	}
+	return purged, nil
+}
+
+func (m *memoryStore) ListChanges(_ context.Context, userID string, since int64) ([]entities.Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Note, 0)
+	for _, note := range m.notes {
+		if note.UserID == userID && note.Seq > since {
+			result = append(result, note)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Seq != result[j].Seq {
+			return result[i].Seq < result[j].Seq
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (m *memoryStore) GetSequence(_ context.Context, userID string) (Sequence, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.sequences[userID], nil
+}
+
+func (m *memoryStore) ShareNote(_ context.Context, share entities.Share) (entities.Share, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := m.shares[share.NoteID][share.UserID]; ok {
+		share.CreatedAt = existing.CreatedAt
+	} else {
+		share.CreatedAt = now
+	}
+	share.UpdatedAt = now
+	// the note's shares are replaced rather than changed, so a transaction can put the old ones back
+	shares := maps.Clone(m.shares[share.NoteID])
+	if shares == nil {
+		shares = make(map[string]entities.Share)
+	}
+	shares[share.UserID] = share
+	put(m, m.shares, share.NoteID, shares)
+	return share, nil
+}
+
+func (m *memoryStore) GetShare(_ context.Context, noteID, userID string) (entities.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	share, ok := m.shares[noteID][userID]
+	if !ok {
+		return entities.Share{}, ErrShareNotFound
+	}
+	return share, nil
+}
+
+func (m *memoryStore) ListShares(_ context.Context, noteID string) ([]entities.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Share, 0, len(m.shares[noteID]))
+	for _, share := range m.shares[noteID] {
+		result = append(result, share)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := result[i].CreatedAt.Compare(result[j].CreatedAt); c != 0 {
+			return c < 0
+		}
+		return result[i].UserID < result[j].UserID
+	})
+	return result, nil
+}
+
+func (m *memoryStore) UnshareNote(_ context.Context, noteID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.shares[noteID][userID]; !ok {
+		return ErrShareNotFound
+	}
+	shares := maps.Clone(m.shares[noteID])
+	delete(shares, userID)
+	put(m, m.shares, noteID, shares)
+	return nil
+}
+
+func (m *memoryStore) CreateLink(_ context.Context, link entities.Link) (entities.Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	link.Views = 0
+	put(m, m.links, link.ID, link)
+	return link, nil
+}
+
+func (m *memoryStore) GetLinkByHash(_ context.Context, hash string) (entities.Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, link := range m.links {
+		if link.TokenHash == hash {
+			return link, nil
+		}
+	}
+	return entities.Link{}, ErrLinkNotFound
+}
+
+func (m *memoryStore) ListLinks(_ context.Context, noteID string) ([]entities.Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Link, 0)
+	for _, link := range m.links {
+		if link.NoteID == noteID {
+			result = append(result, link)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := result[i].CreatedAt.Compare(result[j].CreatedAt); c != 0 {
+			return c < 0
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (m *memoryStore) ViewLink(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[id]
+	if !ok || (link.MaxViews != nil && link.Views >= *link.MaxViews) {
+		return ErrLinkNotFound
+	}
+	link.Views++
+	put(m, m.links, id, link)
+	return nil
+}
+
+func (m *memoryStore) DeleteLink(_ context.Context, noteID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link, ok := m.links[id]
+	if !ok || link.NoteID != noteID {
+		return ErrLinkNotFound
+	}
+	remove(m, m.links, id)
+	return nil
+}
+
+func (m *memoryStore) CreateComment(_ context.Context, comment entities.Comment) (entities.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+	comment.UpdatedAt = comment.CreatedAt
+	put(m, m.comments, comment.ID, comment)
+	return comment, nil
+}
+
+func (m *memoryStore) GetComment(_ context.Context, id string) (entities.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	comment, ok := m.comments[id]
+	if !ok || comment.DeletedAt != nil {
+		return entities.Comment{}, ErrCommentNotFound
+	}
+	return comment, nil
+}
+
+func (m *memoryStore) ListComments(_ context.Context, noteID string) ([]entities.Comment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Comment, 0)
+	for _, comment := range m.comments {
+		if comment.NoteID == noteID && comment.DeletedAt == nil {
+			result = append(result, comment)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if c := result[i].CreatedAt.Compare(result[j].CreatedAt); c != 0 {
+			return c < 0
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (m *memoryStore) CountComments(_ context.Context, noteIDs []string) (map[string]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, comment := range m.comments {
+		if comment.DeletedAt == nil && slices.Contains(noteIDs, comment.NoteID) {
+			counts[comment.NoteID]++
+		}
+	}
+	return counts, nil
+}
+
+func (m *memoryStore) UpdateComment(_ context.Context, comment entities.Comment) (entities.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.comments[comment.ID]
+	if !ok || existing.DeletedAt != nil {
+		return entities.Comment{}, ErrCommentNotFound
+	}
+	existing.Body = comment.Body
+	existing.Resolved = comment.Resolved
+	existing.UpdatedAt = time.Now()
+	put(m, m.comments, comment.ID, existing)
+	return existing, nil
+}
+
+func (m *memoryStore) DeleteComment(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if comment, ok := m.comments[id]; !ok || comment.DeletedAt != nil {
+		return ErrCommentNotFound
+	}
+	now := time.Now()
+	for key, comment := range m.comments {
+		if (comment.ID == id || comment.ParentID == id) && comment.DeletedAt == nil {
+			comment.DeletedAt = &now
+			put(m, m.comments, key, comment)
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) ListRevisions(_ context.Context, id string) ([]entities.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := m.revisions[id]
+	result := make([]entities.Revision, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		r := revisions[i]
+		r.Content = ""
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (m *memoryStore) GetRevision(_ context.Context, id string, revision int) (entities.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.revisions[id] {
+		if r.Revision == revision {
+			return r, nil
+		}
+	}
+	return entities.Revision{}, ErrNotFound
+}
+
+func (m *memoryStore) PruneRevisions(_ context.Context, id string, keep int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revisions := m.revisions[id]
+	if len(revisions) <= keep {
+		return 0, nil
+	}
+	pruned := len(revisions) - keep
+	put(m, m.revisions, id, append([]entities.Revision(nil), revisions[pruned:]...))
+	return int64(pruned), nil
+}
+
+func (m *memoryStore) ListTags(_ context.Context, userID string) ([]entities.Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, note := range m.notes {
+		if note.UserID != userID {
+			continue
+		}
+		for _, tag := range note.Tags {
+			if note.DeletedAt == nil {
+				counts[tag]++
+			} else if _, ok := counts[tag]; !ok {
+				counts[tag] = 0
+			}
+		}
+	}
+
+	tags := make([]entities.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, entities.Tag{Name: name, Notes: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (m *memoryStore) RenameTag(_ context.Context, userID, from, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.hasTag(userID, from) {
+		return ErrTagNotFound
+	}
+	if from == to {
+		return nil
+	}
+	if m.hasTag(userID, to) {
+		return ErrTagExists
+	}
+	m.retag(userID, []string{from}, to)
+	return nil
+}
+
+func (m *memoryStore) MergeTags(_ context.Context, userID string, from []string, into string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range from {
+		if tag != into && !m.hasTag(userID, tag) {
+			return fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+		}
+	}
+	m.retag(userID, from, into)
+	return nil
+}
+
+func (m *memoryStore) CreateNotebook(_ context.Context, notebook entities.Notebook) (entities.Notebook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if notebook.CreatedAt.IsZero() {
+		notebook.CreatedAt = time.Now()
+	}
+	notebook.UpdatedAt = notebook.CreatedAt
+	put(m, m.notebooks, notebook.ID, notebook)
+	return notebook, nil
+}
+
+func (m *memoryStore) GetNotebook(_ context.Context, id string) (entities.Notebook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	notebook, ok := m.notebooks[id]
+	if !ok {
+		return entities.Notebook{}, ErrNotebookNotFound
+	}
+	return notebook, nil
+}
+
+func (m *memoryStore) ListNotebooks(_ context.Context, userID string) ([]entities.Notebook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Notebook, 0)
+	for _, notebook := range m.notebooks {
+		if notebook.UserID == userID {
+			result = append(result, notebook)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (m *memoryStore) UpdateNotebook(_ context.Context, notebook entities.Notebook) (entities.Notebook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.notebooks[notebook.ID]
+	if !ok {
+		return entities.Notebook{}, ErrNotebookNotFound
+	}
+	existing.Name = notebook.Name
+	existing.ParentID = notebook.ParentID
+	existing.UpdatedAt = time.Now()
+	put(m, m.notebooks, notebook.ID, existing)
+	return existing, nil
+}
+
+func (m *memoryStore) DeleteNotebook(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notebook, ok := m.notebooks[id]
+	if !ok {
+		return ErrNotebookNotFound
+	}
+	now := time.Now()
+	seq := m.nextSeq(notebook.UserID)
+	for noteID, note := range m.notes {
+		if note.NotebookID == id {
+			note.NotebookID = notebook.ParentID
+			note.Version++
+			note.Seq = seq
+			note.UpdatedAt = now
+			put(m, m.notes, noteID, note)
+		}
+	}
+	for childID, child := range m.notebooks {
+		if child.ParentID == id {
+			child.ParentID = notebook.ParentID
+			child.UpdatedAt = now
+			put(m, m.notebooks, childID, child)
+		}
+	}
+	remove(m, m.notebooks, id)
+	return nil
+}
+
+func (m *memoryStore) TrashNotebooks(_ context.Context, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	// the notebooks are a subtree, so they all belong to the same user
+	notebook, ok := m.notebooks[ids[0]]
+	if !ok {
+		return ErrNotebookNotFound
+	}
+	now := time.Now()
+	seq := m.nextSeq(notebook.UserID)
+	for noteID, note := range m.notes {
+		if !slices.Contains(ids, note.NotebookID) {
+			continue
+		}
+		note.NotebookID = ""
+		if note.DeletedAt == nil {
+			note.DeletedAt = &now
+		}
+		note.Version++
+		note.Seq = seq
+		put(m, m.notes, noteID, note)
+	}
+	for _, id := range ids {
+		remove(m, m.notebooks, id)
+	}
+	return nil
+}
+
+// hasTag reports whether any of the user's notes carries the tag, the caller holds the lock
+func (m *memoryStore) hasTag(userID, tag string) bool {
+	for _, note := range m.notes {
+		if note.UserID == userID && slices.Contains(note.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// retag replaces the from tags with into on the user's notes, the caller holds the write lock
+func (m *memoryStore) retag(userID string, from []string, into string) {
+	now := time.Now()
+	seq := m.nextSeq(userID)
+	for id, note := range m.notes {
+		if note.UserID != userID || !hasTags(note.Tags, from, TagModeAny) {
+			continue
+		}
+		tags := []string{into}
+		for _, tag := range note.Tags {
+			if !slices.Contains(from, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		note.Tags = normalizeTags(tags)
+		note.Version++
+		note.Seq = seq
+		note.UpdatedAt = now
+		put(m, m.notes, id, note)
+	}
+}
+
+// hasTags reports whether the note tags include all of the wanted tags, or any of them in TagModeAny
+func hasTags(tags, wanted []string, mode string) bool {
+	for _, tag := range wanted {
+		found := slices.Contains(tags, tag)
+		if mode == TagModeAny && found {
+			return true
+		}
+		if mode != TagModeAny && !found {
+			return false
+		}
+	}
+	return mode != TagModeAny
+}
+
+// matches reports whether the note passes the filters and comes after the cursor
+func matches(note entities.Note, params ListParams) bool {
+	switch {
+	case params.Deleted != (note.DeletedAt != nil):
+		return false
+	case params.UserID != "" && note.UserID != params.UserID:
+		return false
+	case params.TitlePrefix != "" && !strings.HasPrefix(strings.ToLower(note.Title), strings.ToLower(params.TitlePrefix)):
+		return false
+	case len(params.Tags) > 0 && !hasTags(note.Tags, params.Tags, params.TagMode):
+		return false
+	case len(params.NotebookIDs) > 0 && !slices.Contains(params.NotebookIDs, note.NotebookID):
+		return false
+	case !params.CreatedAfter.IsZero() && note.CreatedAt.Before(params.CreatedAfter):
+		return false
+	case !params.CreatedBefore.IsZero() && !note.CreatedAt.Before(params.CreatedBefore):
+		return false
+	case !params.UpdatedAfter.IsZero() && note.UpdatedAt.Before(params.UpdatedAfter):
+		return false
+	case !params.UpdatedBefore.IsZero() && !note.UpdatedAt.Before(params.UpdatedBefore):
+		return false
+	}
+
+	if params.After == nil {
+		return true
+	}
+	after := entities.Note{
+		ID:        params.After.ID,
+		Title:     params.After.Title,
+		CreatedAt: params.After.Time,
+		UpdatedAt: params.After.Time,
+	}
+	return compareNotes(note, after, params.Sort, params.Order) > 0
+}
+
+// compareNotes compares two notes by the sort key and then by id, flipping the result for descending order
+func compareNotes(a, b entities.Note, sort, order string) int {
+	var c int
+	switch sort {
+	case SortUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case SortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if order == OrderDesc {
+		return -c
+	}
+	return c
+}
+
+// deleteLinks removes the public links to the note, the caller holds the write lock
+func (m *memoryStore) deleteLinks(noteID string) {
+	for id, link := range m.links {
+		if link.NoteID == noteID {
+			remove(m, m.links, id)
+		}
+	}
+}
+
+// deleteComments removes the comments on the note, the caller holds the write lock
+func (m *memoryStore) deleteComments(noteID string) {
+	for id, comment := range m.comments {
+		if comment.NoteID == noteID {
+			remove(m, m.comments, id)
+		}
+	}
+}
+
+// nextSeq takes the user's next change sequence number, the caller holds the write lock
+func (m *memoryStore) nextSeq(userID string) int64 {
+	sequence := m.sequences[userID]
+	sequence.Seq++
+	put(m, m.sequences, userID, sequence)
+	return sequence.Seq
+}
+
+// raisePurged records that a note of the user last changed at seq was removed for good,
+// the caller holds the write lock
+func (m *memoryStore) raisePurged(userID string, seq int64) {
+	sequence := m.sequences[userID]
+	if seq > sequence.Purged {
+		sequence.Purged = seq
+		put(m, m.sequences, userID, sequence)
+	}
+}
+
+func (m *memoryStore) AddOutbox(_ context.Context, event entities.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.outboxID++
+	event.ID = m.outboxID
+	event.CreatedAt = time.Now()
+	m.outbox = append(m.outbox, event)
+	return nil
+}
+
+func (m *memoryStore) ListOutbox(_ context.Context, limit int) ([]entities.OutboxEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := m.outbox[:min(limit, len(m.outbox))]
+	return slices.Clone(events), nil
+}
+
+func (m *memoryStore) DeleteOutbox(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// deleting in place would change the events a transaction still holds
+	m.outbox = slices.DeleteFunc(slices.Clone(m.outbox), func(event entities.OutboxEvent) bool {
+		return event.ID == id
+	})
+	return nil
+}
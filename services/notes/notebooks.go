@@ -0,0 +1,294 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrNotebookNotFound is returned when a notebook does not exist
+	ErrNotebookNotFound = errors.New("notebook not found")
+	// ErrInvalidNotebook is returned when a notebook request is invalid
+	ErrInvalidNotebook = errors.New("invalid notebook")
+)
+
+const (
+	// DeleteModeReparent deletes only the notebook, moving what it holds into its parent
+	DeleteModeReparent = "reparent"
+	// DeleteModeCascade deletes the notebook with the notebooks inside it and moves their notes to the trash
+	DeleteModeCascade = "cascade"
+)
+
+// GetNotebooks returns all of the user's notebooks ordered by name. Clients
+// build the tree from their parent ids.
+func (s *Service) GetNotebooks(ctx context.Context, userID string) ([]entities.Notebook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetNotebooks")
+	defer span.End()
+
+	return s.store.ListNotebooks(ctx, userID)
+}
+
+// GetNotebook returns the user's notebook with the given id
+func (s *Service) GetNotebook(ctx context.Context, userID, id string) (entities.Notebook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetNotebook")
+	defer span.End()
+
+	return s.notebook(ctx, userID, id)
+}
+
+// CreateNotebook stores a new notebook for the user and returns it
+func (s *Service) CreateNotebook(ctx context.Context, userID string, req entities.NotebookReq) (entities.Notebook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.CreateNotebook")
+	defer span.End()
+
+	name := strings.TrimSpace(req.Name)
+	if err := validateNotebookName(name); err != nil {
+		return entities.Notebook{}, err
+	}
+	if req.ParentID != "" {
+		if err := s.checkParent(ctx, userID, req.ParentID); err != nil {
+			return entities.Notebook{}, err
+		}
+	}
+
+	now := time.Now()
+	return s.store.CreateNotebook(ctx, entities.Notebook{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		ParentID:  req.ParentID,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// RenameNotebook renames the user's notebook with the given id
+func (s *Service) RenameNotebook(ctx context.Context, userID, id string, req entities.NotebookRename) (entities.Notebook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.RenameNotebook")
+	defer span.End()
+
+	notebook, err := s.notebook(ctx, userID, id)
+	if err != nil {
+		return entities.Notebook{}, err
+	}
+	notebook.Name = strings.TrimSpace(req.Name)
+	if err := validateNotebookName(notebook.Name); err != nil {
+		return entities.Notebook{}, err
+	}
+	return s.store.UpdateNotebook(ctx, notebook)
+}
+
+// MoveNotebook moves the user's notebook with the given id, and everything in it, into another
+// notebook or to the top level. A notebook cannot be moved into itself or any notebook inside it.
+func (s *Service) MoveNotebook(ctx context.Context, userID, id string, req entities.NotebookMove) (entities.Notebook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.MoveNotebook")
+	defer span.End()
+
+	notebook, err := s.notebook(ctx, userID, id)
+	if err != nil {
+		return entities.Notebook{}, err
+	}
+	if req.ParentID != "" {
+		if err := s.checkParent(ctx, userID, req.ParentID); err != nil {
+			return entities.Notebook{}, err
+		}
+		notebooks, err := s.store.ListNotebooks(ctx, userID)
+		if err != nil {
+			return entities.Notebook{}, err
+		}
+		if slices.Contains(subtree(notebooks, id), req.ParentID) {
+			return entities.Notebook{}, fmt.Errorf("%w: a notebook cannot be moved into itself or a notebook inside it", ErrInvalidNotebook)
+		}
+	}
+	notebook.ParentID = req.ParentID
+	return s.store.UpdateNotebook(ctx, notebook)
+}
+
+// DeleteNotebook deletes the user's notebook with the given id. In DeleteModeCascade the
+// notebooks inside it are deleted as well and all of their notes are moved to the trash,
+// otherwise its notes and notebooks are moved into its parent.
+func (s *Service) DeleteNotebook(ctx context.Context, userID, id string, query entities.NotebookDelete) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.DeleteNotebook")
+	defer span.End()
+
+	mode := query.Mode
+	if mode == "" {
+		mode = DeleteModeReparent
+	}
+	if mode != DeleteModeReparent && mode != DeleteModeCascade {
+		return fmt.Errorf("%w: mode must be cascade or reparent", ErrInvalidNotebook)
+	}
+	if _, err := s.notebook(ctx, userID, id); err != nil {
+		return err
+	}
+
+	ids := []string{id}
+	if mode == DeleteModeCascade {
+		notebooks, err := s.store.ListNotebooks(ctx, userID)
+		if err != nil {
+			return err
+		}
+		ids = subtree(notebooks, id)
+	}
+	span.SetAttributes(attribute.String("mode", mode), attribute.Int("notebooks", len(ids)))
+
+	// the notes are looked up first, as they cannot be found by notebook afterwards
+	var moved []entities.Note
+	params := ListParams{UserID: userID, NotebookIDs: ids, Sort: SortCreatedAt, Order: OrderAsc, Limit: maxLimit}
+	err := s.eachNote(ctx, params, func(note entities.Note) error {
+		moved = append(moved, note)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if mode == DeleteModeCascade {
+		err := s.store.WithTx(ctx, func(store Store) error {
+			if err := store.TrashNotebooks(ctx, ids); err != nil {
+				return err
+			}
+			for _, note := range moved {
+				if err := record(ctx, store, entities.EventNoteDeleted, note); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, note := range moved {
+			s.unindex(ctx, note.ID)
+			s.publish(ctx, entities.EventNoteDeleted, note)
+		}
+		return nil
+	}
+
+	err = s.store.WithTx(ctx, func(store Store) error {
+		if err := store.DeleteNotebook(ctx, id); err != nil {
+			return err
+		}
+		for i, note := range moved {
+			note, err := store.GetNote(ctx, note.ID)
+			if err != nil {
+				return err
+			}
+			if err := record(ctx, store, entities.EventNoteUpdated, note); err != nil {
+				return err
+			}
+			moved[i] = note
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, note := range moved {
+		s.index(ctx, note)
+		s.publish(ctx, entities.EventNoteUpdated, note)
+	}
+	return nil
+}
+
+// notebookIDs returns the ids of the notebooks a listing is filtered on: the
+// query's notebook, with the notebooks inside it when Descendants is set
+func (s *Service) notebookIDs(ctx context.Context, userID string, query entities.NoteQuery) ([]string, error) {
+	if query.NotebookID == "" {
+		return nil, nil
+	}
+	if _, err := s.notebook(ctx, userID, query.NotebookID); err != nil {
+		return nil, err
+	}
+	if !query.Descendants {
+		return []string{query.NotebookID}, nil
+	}
+	notebooks, err := s.store.ListNotebooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return subtree(notebooks, query.NotebookID), nil
+}
+
+// notebook returns the notebook if it belongs to the user. Other users'
+// notebooks are reported as ErrNotebookNotFound, as notes are in access.
+func (s *Service) notebook(ctx context.Context, userID, id string) (entities.Notebook, error) {
+	notebook, err := s.store.GetNotebook(ctx, id)
+	if err != nil {
+		return entities.Notebook{}, err
+	}
+	if notebook.UserID != userID {
+		return entities.Notebook{}, ErrNotebookNotFound
+	}
+	return notebook, nil
+}
+
+// checkParent fails with ErrInvalidNotebook unless the user has the parent notebook
+func (s *Service) checkParent(ctx context.Context, userID, parentID string) error {
+	_, err := s.notebook(ctx, userID, parentID)
+	if errors.Is(err, ErrNotebookNotFound) {
+		return fmt.Errorf("%w: parent notebook %s does not exist", ErrInvalidNotebook, parentID)
+	}
+	return err
+}
+
+// checkNotebook fails unless the user can file the note in their notebook with the
+// given id, an empty id keeps it at the top level. Only the owner files a note.
+func (s *Service) checkNotebook(ctx context.Context, userID string, note entities.Note, id string) error {
+	if note.UserID != userID {
+		return fmt.Errorf("%w: only the owner can move the note between notebooks", ErrForbidden)
+	}
+	if id == "" {
+		return nil
+	}
+	_, err := s.notebook(ctx, userID, id)
+	if errors.Is(err, ErrNotebookNotFound) {
+		return fmt.Errorf("%w: notebook %s does not exist", ErrInvalidNote, id)
+	}
+	return err
+}
+
+// subtree returns the id of the notebook followed by the ids of all the notebooks nested inside it
+func subtree(notebooks []entities.Notebook, id string) []string {
+	children := make(map[string][]string, len(notebooks))
+	for _, notebook := range notebooks {
+		if notebook.ParentID != "" {
+			children[notebook.ParentID] = append(children[notebook.ParentID], notebook.ID)
+		}
+	}
+
+	ids := []string{id}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			// guards against a loop left behind by two moves racing each other
+			if !slices.Contains(ids, child) {
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+// validateNotebookName checks a trimmed notebook name fits the notebooks table
+func validateNotebookName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidNotebook)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("%w: name must be valid UTF-8", ErrInvalidNotebook)
+	case utf8.RuneCountInString(name) > entities.MaxNotebookNameLength:
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalidNotebook, entities.MaxNotebookNameLength)
+	}
+	return nil
+}
@@ -2,66 +2,481 @@ package notes
 
 import (
 	"context"
-	"database/sql"
-	"github.com/google/uuid"
-	"notes/repositories"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
 	"notes/services/entities"
 	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var (
+	// ErrNotFound is returned when a note does not exist
+	ErrNotFound = errors.New("note not found")
+	// ErrInvalidNote is returned when a note request is missing required fields
+	ErrInvalidNote = errors.New("invalid note")
+	// ErrInvalidQuery is returned when the options for listing notes are invalid
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrVersionConflict is returned when a note was changed since the version a write is based on
+	ErrVersionConflict = errors.New("note has been changed")
+)
+
+// Indexer keeps a search index in step with the stored notes
+type Indexer interface {
+	IndexNote(ctx context.Context, note entities.Note) error
+	DeleteNote(ctx context.Context, id string) error
+}
+
+// Option configures optional dependencies of the Service
+type Option func(*Service)
+
+// WithIndexer indexes notes for search as they are written
+func WithIndexer(indexer Indexer) Option {
+	return func(s *Service) {
+		s.indexer = indexer
+	}
+}
+
+// Service handles notes kept in a Store
 type Service struct {
-	db         *sql.DB
-	repository *repositories.Queries
+	store   Store
+	indexer Indexer
+	// revisionLimit is the number of revisions kept per note, zero keeps them all
+	revisionLimit int
+	users         Users
+	events        *hub
 }
 
-func New(db *sql.DB) *Service {
-	return &Service{
-		db:         db,
-		repository: repositories.New(db),
+// New returns a new notes service backed by the given store
+func New(store Store, opts ...Option) *Service {
+	s := &Service{
+		store:  store,
+		events: newHub(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *Service) GetNotes(ctx context.Context) ([]entities.Note, error) {
+// GetNotes returns a page of the user's notes matching the query
+func (s *Service) GetNotes(ctx context.Context, userID string, query entities.NoteQuery) (entities.NotePage, error) {
 	ctx, span := tracing.Tracer().Start(ctx, "svc.GetNotes")
 	defer span.End()
 
-	notes, err := s.repository.FindAllNotes(ctx)
+	query.UserID = userID
+	params, err := listParams(query)
 	if err != nil {
-		return nil, err
+		return entities.NotePage{}, err
 	}
-	if len(notes) == 0 {
-		return nil, sql.ErrNoRows
+	if params.NotebookIDs, err = s.notebookIDs(ctx, userID, query); err != nil {
+		return entities.NotePage{}, err
 	}
+	return s.list(ctx, params)
+}
 
-	result := make([]entities.Note, 0, len(notes))
-	for i := range notes {
-		result = append(result, entities.Note{
-			ID:        notes[i].NoteID,
-			UserID:    notes[i].UserID,
-			Title:     notes[i].Title,
-			Content:   notes[i].Content,
-			CreatedAt: notes[i].CreatedAt.Time,
-		})
+// GetTrash returns a page of the user's deleted notes matching the query
+func (s *Service) GetTrash(ctx context.Context, userID string, query entities.NoteQuery) (entities.NotePage, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetTrash")
+	defer span.End()
+
+	query.UserID = userID
+	params, err := listParams(query)
+	if err != nil {
+		return entities.NotePage{}, err
+	}
+	if params.NotebookIDs, err = s.notebookIDs(ctx, userID, query); err != nil {
+		return entities.NotePage{}, err
 	}
-	return result, nil
+	params.Deleted = true
+	return s.list(ctx, params)
 }
 
-func (s *Service) CreateNote(ctx context.Context, noteReq entities.NoteReq) error {
+func (s *Service) list(ctx context.Context, params ListParams) (entities.NotePage, error) {
+	// fetch one extra note to find out if there is another page
+	limit := params.Limit
+	params.Limit++
+	notes, err := s.store.ListNotes(ctx, params)
+	if err != nil {
+		return entities.NotePage{}, err
+	}
+
+	page := entities.NotePage{
+		Items: notes,
+	}
+	if page.Items == nil {
+		page.Items = []entities.Note{}
+	}
+	if len(notes) > limit {
+		page.Items = notes[:limit]
+		page.NextCursor = encodeCursor(notes[limit-1], params.Sort, params.Order)
+	}
+	if err := s.withCommentCounts(ctx, page.Items); err != nil {
+		return entities.NotePage{}, err
+	}
+	return page, nil
+}
+
+// GetNote returns the user's note with the given public id
+func (s *Service) GetNote(ctx context.Context, userID, id string) (entities.Note, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetNote")
+	defer span.End()
+
+	return s.access(ctx, userID, id, false, entities.RoleViewer)
+}
+
+// CreateNote stores a new note for the user and returns it
+func (s *Service) CreateNote(ctx context.Context, userID string, noteReq entities.NoteReq) (entities.Note, error) {
 	ctx, span := tracing.Tracer().Start(ctx, "svc.CreateNote")
 	defer span.End()
 
-	if noteReq.UserID == "" || noteReq.Title == "" || noteReq.Content == "" {
-		return sql.ErrNoRows // or a custom error
+	if userID == "" || noteReq.Title == "" || noteReq.Content == "" {
+		return entities.Note{}, fmt.Errorf("%w: user_id, title and content are required", ErrInvalidNote)
+	}
+
+	now := time.Now()
+	note := entities.Note{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		Title:       noteReq.Title,
+		Content:     noteReq.Content,
+		ContentType: noteReq.ContentType,
+		NotebookID:  noteReq.NotebookID,
+		Tags:        normalizeTags(noteReq.Tags),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if note.ContentType == "" {
+		note.ContentType = entities.ContentTypePlain
+	}
+	if err := validate(note); err != nil {
+		return entities.Note{}, err
+	}
+	if err := s.checkNotebook(ctx, userID, note, note.NotebookID); err != nil {
+		return entities.Note{}, err
+	}
+
+	err := s.store.WithTx(ctx, func(store Store) error {
+		var err error
+		if note, err = store.CreateNote(ctx, note); err != nil {
+			return err
+		}
+		return record(ctx, store, entities.EventNoteCreated, note)
+	})
+	if err != nil {
+		return entities.Note{}, err
+	}
+	s.index(ctx, note)
+	s.publish(ctx, entities.EventNoteCreated, note)
+	return note, nil
+}
+
+// UpdateNote replaces the title and content of the note with the given id
+func (s *Service) UpdateNote(ctx context.Context, userID, id string, noteReq entities.NoteReq) (entities.Note, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.UpdateNote")
+	defer span.End()
+
+	if noteReq.Title == "" || noteReq.Content == "" {
+		return entities.Note{}, fmt.Errorf("%w: title and content are required", ErrInvalidNote)
+	}
+
+	note, err := s.access(ctx, userID, id, false, entities.RoleEditor)
+	if err != nil {
+		return entities.Note{}, err
+	}
+	if err := checkVersion(note, noteReq.Version); err != nil {
+		return entities.Note{}, err
+	}
+	note.Title = noteReq.Title
+	note.Content = noteReq.Content
+	if noteReq.ContentType != "" {
+		note.ContentType = noteReq.ContentType
+	}
+	if noteReq.NotebookID != "" && noteReq.NotebookID != note.NotebookID {
+		if err := s.checkNotebook(ctx, userID, note, noteReq.NotebookID); err != nil {
+			return entities.Note{}, err
+		}
+		note.NotebookID = noteReq.NotebookID
+	}
+	if noteReq.Tags != nil {
+		note.Tags = normalizeTags(noteReq.Tags)
+	}
+	return s.update(ctx, note)
+}
+
+// PatchNote updates only the fields that are set on the patch
+func (s *Service) PatchNote(ctx context.Context, userID, id string, patch entities.NotePatch) (entities.Note, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.PatchNote")
+	defer span.End()
+
+	if patch.Title == nil && patch.Content == nil && patch.ContentType == nil && patch.NotebookID == nil && patch.Tags == nil {
+		return entities.Note{}, fmt.Errorf("%w: nothing to update", ErrInvalidNote)
+	}
+
+	note, err := s.access(ctx, userID, id, false, entities.RoleEditor)
+	if err != nil {
+		return entities.Note{}, err
+	}
+	if err := checkVersion(note, patch.Version); err != nil {
+		return entities.Note{}, err
+	}
+	if patch.Title != nil {
+		note.Title = *patch.Title
+	}
+	if patch.Content != nil {
+		note.Content = *patch.Content
+	}
+	if patch.ContentType != nil {
+		note.ContentType = *patch.ContentType
+	}
+	if patch.NotebookID != nil && *patch.NotebookID != note.NotebookID {
+		if err := s.checkNotebook(ctx, userID, note, *patch.NotebookID); err != nil {
+			return entities.Note{}, err
+		}
+		note.NotebookID = *patch.NotebookID
+	}
+	if patch.Tags != nil {
+		note.Tags = normalizeTags(patch.Tags)
+	}
+	if note.Title == "" || note.Content == "" {
+		return entities.Note{}, fmt.Errorf("%w: title and content cannot be empty", ErrInvalidNote)
+	}
+	return s.update(ctx, note)
+}
+
+func (s *Service) update(ctx context.Context, note entities.Note) (entities.Note, error) {
+	if err := validate(note); err != nil {
+		return entities.Note{}, err
+	}
+	err := s.store.WithTx(ctx, func(store Store) error {
+		var err error
+		if note, err = store.UpdateNote(ctx, note); err != nil {
+			return err
+		}
+		return record(ctx, store, entities.EventNoteUpdated, note)
+	})
+	if err != nil {
+		return entities.Note{}, err
+	}
+	s.prune(ctx, note.ID)
+	s.index(ctx, note)
+	s.publish(ctx, entities.EventNoteUpdated, note)
+	return note, nil
+}
+
+// DeleteNote moves the user's note with the given id to the trash, as long as
+// it is still at version. A zero version deletes whichever version is stored.
+func (s *Service) DeleteNote(ctx context.Context, userID, id string, version int) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.DeleteNote")
+	defer span.End()
+
+	note, err := s.access(ctx, userID, id, false, entities.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(note, version); err != nil {
+		return err
 	}
+	err = s.store.WithTx(ctx, func(store Store) error {
+		if err := store.DeleteNote(ctx, id, note.Version); err != nil {
+			return err
+		}
+		return record(ctx, store, entities.EventNoteDeleted, note)
+	})
+	if err != nil {
+		return err
+	}
+	s.unindex(ctx, id)
+	s.publish(ctx, entities.EventNoteDeleted, note)
+	return nil
+}
 
-	err := s.repository.CreateNote(ctx, repositories.CreateNoteParams{
-		NoteID:  uuid.NewString(),
-		Title:   noteReq.Title,
-		Content: noteReq.Content,
-		UserID:  noteReq.UserID,
+// RestoreNote takes the user's note with the given id out of the trash
+func (s *Service) RestoreNote(ctx context.Context, userID, id string) (entities.Note, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.RestoreNote")
+	defer span.End()
+
+	if _, err := s.access(ctx, userID, id, true, entities.RoleOwner); err != nil {
+		return entities.Note{}, err
+	}
+	var note entities.Note
+	err := s.store.WithTx(ctx, func(store Store) error {
+		var err error
+		if note, err = store.RestoreNote(ctx, id); err != nil {
+			return err
+		}
+		return record(ctx, store, entities.EventNoteCreated, note)
 	})
+	if err != nil {
+		return entities.Note{}, err
+	}
+	s.index(ctx, note)
+	s.publish(ctx, entities.EventNoteCreated, note)
+	return note, nil
+}
+
+// PurgeNote permanently deletes the user's note with the given id, the version works as in DeleteNote
+func (s *Service) PurgeNote(ctx context.Context, userID, id string, version int) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.PurgeNote")
+	defer span.End()
+
+	note, err := s.access(ctx, userID, id, true, entities.RoleOwner)
 	if err != nil {
 		return err
 	}
+	if err := checkVersion(note, version); err != nil {
+		return err
+	}
+	// the shares are purged with the note
+	audience := s.audience(ctx, note)
+	err = s.store.WithTx(ctx, func(store Store) error {
+		if err := store.PurgeNote(ctx, id, note.Version); err != nil {
+			return err
+		}
+		// notes in the trash were announced as deleted when they were moved there
+		if note.DeletedAt != nil {
+			return nil
+		}
+		return record(ctx, store, entities.EventNoteDeleted, note)
+	})
+	if err != nil {
+		return err
+	}
+	s.unindex(ctx, id)
+	if note.DeletedAt == nil {
+		s.publishTo(ctx, entities.EventNoteDeleted, note, audience)
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes the notes that have been in the trash for longer than retention
+func (s *Service) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.PurgeTrash")
+	defer span.End()
+
+	purged, err := s.store.PurgeDeleted(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int64("purged", purged))
+	return purged, nil
+}
+
+// StartPurger empties the trash of notes older than retention every interval until ctx is done
+func (s *Service) StartPurger(ctx context.Context, retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := s.PurgeTrash(ctx, retention)
+				if err != nil {
+					slog.ErrorContext(ctx, "failed to purge trash", "error", err)
+					continue
+				}
+				slog.InfoContext(ctx, "trash purged", "purged", purged, "retention", retention.String())
+			}
+		}
+	}()
+}
+
+// IndexAll adds every note outside the trash to the search index and
+// returns how many were indexed, it fills an in-memory index on startup
+func (s *Service) IndexAll(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.IndexAll")
+	defer span.End()
+
+	if s.indexer == nil {
+		return 0, nil
+	}
+
+	params := ListParams{Sort: SortCreatedAt, Order: OrderAsc, Limit: maxLimit}
+	indexed := 0
+	err := s.eachNote(ctx, params, func(note entities.Note) error {
+		if err := s.indexer.IndexNote(ctx, note); err != nil {
+			return err
+		}
+		indexed++
+		return nil
+	})
+	if err != nil {
+		return indexed, err
+	}
+	span.SetAttributes(attribute.Int("indexed", indexed))
+	return indexed, nil
+}
+
+// eachNote calls fn with every note matching params, which are listed params.Limit
+// at a time in order of creation, and stops at the first error
+func (s *Service) eachNote(ctx context.Context, params ListParams, fn func(entities.Note) error) error {
+	for {
+		batch, err := s.store.ListNotes(ctx, params)
+		if err != nil {
+			return err
+		}
+		for _, note := range batch {
+			if err := fn(note); err != nil {
+				return err
+			}
+		}
+		if len(batch) < params.Limit {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		params.After = &Cursor{Time: last.CreatedAt, ID: last.ID}
+	}
+}
+
+// validate checks the note fits the notes table, so every store accepts the same notes
+func validate(note entities.Note) error {
+	switch {
+	case !utf8.ValidString(note.Title) || !utf8.ValidString(note.Content):
+		return fmt.Errorf("%w: title and content must be valid UTF-8", ErrInvalidNote)
+	case utf8.RuneCountInString(note.Title) > entities.MaxTitleLength:
+		return fmt.Errorf("%w: title must be at most %d characters", ErrInvalidNote, entities.MaxTitleLength)
+	case utf8.RuneCountInString(note.Content) > entities.MaxContentLength:
+		return fmt.Errorf("%w: content must be at most %d characters", ErrInvalidNote, entities.MaxContentLength)
+	case note.ContentType != entities.ContentTypePlain && note.ContentType != entities.ContentTypeMarkdown:
+		return fmt.Errorf("%w: content_type must be plain or markdown", ErrInvalidNote)
+	}
+	return validateTags(note.Tags)
+}
+
+// checkVersion fails with ErrVersionConflict when the note is no longer at the
+// version a write is based on. The stores check it again when writing.
+func checkVersion(note entities.Note, version int) error {
+	if version != 0 && note.Version != version {
+		return fmt.Errorf("%w: the note is at version %d, not %d", ErrVersionConflict, note.Version, version)
+	}
 	return nil
 }
+
+// index adds the note to the search index. Failures are logged rather than
+// returned since the note is already stored and the index can be rebuilt.
+func (s *Service) index(ctx context.Context, note entities.Note) {
+	if s.indexer == nil {
+		return
+	}
+	if err := s.indexer.IndexNote(ctx, note); err != nil {
+		slog.ErrorContext(ctx, "failed to index note", "note_id", note.ID, "error", err)
+	}
+}
+
+// unindex removes the note from the search index, failures are logged like in index
+func (s *Service) unindex(ctx context.Context, id string) {
+	if s.indexer == nil {
+		return
+	}
+	if err := s.indexer.DeleteNote(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "failed to remove note from index", "note_id", id, "error", err)
+	}
+}
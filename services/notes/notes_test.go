@@ -3,36 +3,41 @@ package notes
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
-	"github.com/golang-migrate/migrate/v4"
 	"log"
 	"log/slog"
-	"notes/services/migrator"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"notes/services/entities"
+	"notes/services/migrator"
+	"notes/services/search"
+	"notes/services/users"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
-var db *sql.DB
+var (
+	db     *sql.DB
+	dbType string
+)
 
+// TestMain runs against an in-memory sqlite database unless TEST_DB_DRIVER is set to mysql,
+// in which case the database from docker-compose is used.
 func TestMain(m *testing.M) {
 	code := 1
 
+	dbType = os.Getenv("TEST_DB_DRIVER")
 	dbase, err := setupDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
-	if err := migrator.Migrate(context.TODO(), dbase, getDsn()); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
-			log.Fatal(err)
-		} else {
-			slog.Info("database is already up to date", "error", err)
-		}
-	}
 	defer func() {
 		if err := dbase.Close(); err != nil {
 			log.Fatal(err)
@@ -44,27 +49,1278 @@ func TestMain(m *testing.M) {
 }
 
 func TestCreateNote(t *testing.T) {
-	service := New(db)
+	service := New(newStore())
 	req := entities.NoteReq{
-		UserID:  "test-user",
 		Title:   "Test Note",
 		Content: "This is a test note content.",
 	}
 
-	err := service.CreateNote(t.Context(), req)
+	note, err := service.CreateNote(t.Context(), "test-user", req)
+	require.NoError(t, err)
+	require.NotEmpty(t, note.ID)
+
+	res, err := service.GetNote(t.Context(), "test-user", note.ID)
+	require.NoError(t, err)
+	require.Equal(t, note.Title, res.Title)
+	require.Equal(t, note.Content, res.Content)
+
+	_, err = service.GetNote(t.Context(), "another-user", note.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCreateNote_Invalid(t *testing.T) {
+	service := New(newStore())
+	_, err := service.CreateNote(t.Context(), "", entities.NoteReq{Title: "no user", Content: "content"})
+	require.ErrorIs(t, err, ErrInvalidNote)
+}
+
+func TestNoteLimits(t *testing.T) {
+	service := New(newStore())
+	note, err := service.CreateNote(t.Context(), "test-user", entities.NoteReq{Title: "Limits", Content: "content"})
+	require.NoError(t, err)
+	require.Equal(t, entities.ContentTypePlain, note.ContentType)
+
+	tests := map[string]entities.NoteReq{
+		"long title":   {Title: strings.Repeat("é", entities.MaxTitleLength+1), Content: "content"},
+		"long content": {Title: "title", Content: strings.Repeat("a", entities.MaxContentLength+1)},
+		"invalid utf8": {Title: "title", Content: "\xff\xfe"},
+		"content type": {Title: "title", Content: "content", ContentType: "html"},
+	}
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.CreateNote(t.Context(), "test-user", req)
+			require.ErrorIs(t, err, ErrInvalidNote)
+			_, err = service.UpdateNote(t.Context(), "test-user", note.ID, req)
+			require.ErrorIs(t, err, ErrInvalidNote)
+		})
+	}
+
+	// limits count characters, not bytes
+	_, err = service.CreateNote(t.Context(), "test-user", entities.NoteReq{
+		Title:   strings.Repeat("é", entities.MaxTitleLength),
+		Content: "content",
+	})
 	require.NoError(t, err)
+
+	markdown := entities.ContentTypeMarkdown
+	patched, err := service.PatchNote(t.Context(), "test-user", note.ID, entities.NotePatch{ContentType: &markdown})
+	require.NoError(t, err)
+	require.Equal(t, entities.ContentTypeMarkdown, patched.ContentType)
+
+	// an update without a content type keeps the current one
+	updated, err := service.UpdateNote(t.Context(), "test-user", note.ID, entities.NoteReq{Title: "Limits", Content: "# heading"})
+	require.NoError(t, err)
+	require.Equal(t, entities.ContentTypeMarkdown, updated.ContentType)
+}
+
+func TestUpdateNote(t *testing.T) {
+	service := New(newStore())
+	note, err := service.CreateNote(t.Context(), "test-user", entities.NoteReq{
+		Title:   "Test Note",
+		Content: "content",
+	})
+	require.NoError(t, err)
+
+	_, err = service.UpdateNote(t.Context(), "another-user", note.ID, entities.NoteReq{Title: "Updated", Content: "updated content"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	updated, err := service.UpdateNote(t.Context(), "test-user", note.ID, entities.NoteReq{Title: "Updated", Content: "updated content"})
+	require.NoError(t, err)
+	require.Equal(t, "Updated", updated.Title)
+	require.Equal(t, "updated content", updated.Content)
+	require.Equal(t, note.Version+1, updated.Version)
+
+	stale := entities.NoteReq{Title: "Stale", Content: "stale content", Version: note.Version}
+	_, err = service.UpdateNote(t.Context(), "test-user", note.ID, stale)
+	require.ErrorIs(t, err, ErrVersionConflict)
+	_, err = service.PatchNote(t.Context(), "test-user", note.ID, entities.NotePatch{Title: &stale.Title, Version: note.Version})
+	require.ErrorIs(t, err, ErrVersionConflict)
+	require.ErrorIs(t, service.DeleteNote(t.Context(), "test-user", note.ID, note.Version), ErrVersionConflict)
+
+	title := "Patched"
+	patched, err := service.PatchNote(t.Context(), "test-user", note.ID, entities.NotePatch{Title: &title})
+	require.NoError(t, err)
+	require.Equal(t, "Patched", patched.Title)
+	require.Equal(t, "updated content", patched.Content)
+
+	empty := ""
+	_, err = service.PatchNote(t.Context(), "test-user", note.ID, entities.NotePatch{Content: &empty})
+	require.ErrorIs(t, err, ErrInvalidNote)
+
+	require.ErrorIs(t, service.DeleteNote(t.Context(), "another-user", note.ID, 0), ErrNotFound)
+	require.NoError(t, service.DeleteNote(t.Context(), "test-user", note.ID, 0))
+	_, err = service.UpdateNote(t.Context(), "test-user", note.ID, entities.NoteReq{Title: "Updated", Content: "updated content"})
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRevisions(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			service := New(store, WithRevisionLimit(3))
+			userID := uuid.NewString()
+			note, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "v1", Content: "one\ntwo\n"})
+			require.NoError(t, err)
+
+			revisions, err := service.GetRevisions(ctx, userID, note.ID)
+			require.NoError(t, err)
+			require.Empty(t, revisions)
+
+			_, err = service.UpdateNote(ctx, userID, note.ID, entities.NoteReq{Title: "v2", Content: "one\nthree\n"})
+			require.NoError(t, err)
+			_, err = service.UpdateNote(ctx, userID, note.ID, entities.NoteReq{
+				Title:       "v3",
+				Content:     "# one",
+				ContentType: entities.ContentTypeMarkdown,
+			})
+			require.NoError(t, err)
+
+			revisions, err = service.GetRevisions(ctx, userID, note.ID)
+			require.NoError(t, err)
+			require.Len(t, revisions, 2)
+			require.Equal(t, 2, revisions[0].Revision)
+			require.Equal(t, "v2", revisions[0].Title)
+			require.Empty(t, revisions[0].Content)
+			require.Equal(t, 1, revisions[1].Revision)
+
+			revision, err := service.GetRevision(ctx, userID, note.ID, 1)
+			require.NoError(t, err)
+			require.Equal(t, "v1", revision.Title)
+			require.Equal(t, "one\ntwo\n", revision.Content)
+			require.Equal(t, entities.ContentTypePlain, revision.ContentType)
+
+			_, err = service.GetRevision(ctx, userID, note.ID, 9)
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = service.GetRevisions(ctx, "another-user", note.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			diff, err := service.DiffNote(ctx, userID, note.ID, entities.DiffQuery{From: 1, To: 2})
+			require.NoError(t, err)
+			require.Contains(t, diff.Diff, "--- revision 1")
+			require.Contains(t, diff.Diff, "+++ revision 2")
+			require.Contains(t, diff.Diff, "-two\n")
+			require.Contains(t, diff.Diff, "+three\n")
+
+			diff, err = service.DiffNote(ctx, userID, note.ID, entities.DiffQuery{From: 2})
+			require.NoError(t, err)
+			require.Contains(t, diff.Diff, "+++ current")
+			require.Contains(t, diff.Diff, "+# one")
+
+			_, err = service.DiffNote(ctx, userID, note.ID, entities.DiffQuery{})
+			require.ErrorIs(t, err, ErrInvalidQuery)
+
+			restored, err := service.RestoreRevision(ctx, userID, note.ID, 1)
+			require.NoError(t, err)
+			require.Equal(t, "v1", restored.Title)
+			require.Equal(t, "one\ntwo\n", restored.Content)
+			require.Equal(t, entities.ContentTypePlain, restored.ContentType)
+
+			// the restore kept v3 as revision 3 and the next update pushes out revision 1
+			_, err = service.UpdateNote(ctx, userID, note.ID, entities.NoteReq{Title: "v5", Content: "five"})
+			require.NoError(t, err)
+			revisions, err = service.GetRevisions(ctx, userID, note.ID)
+			require.NoError(t, err)
+			require.Len(t, revisions, 3)
+			require.Equal(t, 4, revisions[0].Revision)
+			require.Equal(t, 2, revisions[2].Revision)
+
+			require.NoError(t, service.DeleteNote(ctx, userID, note.ID, 0))
+			require.NoError(t, service.PurgeNote(ctx, userID, note.ID, 0))
+			revisions, err = store.ListRevisions(ctx, note.ID)
+			require.NoError(t, err)
+			require.Empty(t, revisions)
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			service := New(store)
+			userID := uuid.NewString()
+			create := func(title string, tags ...string) entities.Note {
+				note, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: title, Content: "content", Tags: tags})
+				require.NoError(t, err)
+				return note
+			}
+			titles := func(query entities.NoteQuery) []string {
+				page, err := service.GetNotes(ctx, userID, query)
+				require.NoError(t, err)
+				var titles []string
+				for _, note := range page.Items {
+					titles = append(titles, note.Title)
+				}
+				return titles
+			}
+
+			report := create("report", " Work", "work", "urgent")
+			require.Equal(t, []string{"urgent", "work"}, report.Tags)
+			create("groceries", "home")
+			create("taxes", "home", "urgent")
+			untagged := create("untagged")
+			require.NotNil(t, untagged.Tags)
+			require.Empty(t, untagged.Tags)
+
+			require.ElementsMatch(t, []string{"report", "taxes"}, titles(entities.NoteQuery{Tags: []string{"urgent"}}))
+			require.ElementsMatch(t, []string{"taxes"}, titles(entities.NoteQuery{Tags: []string{"URGENT", "home"}}))
+			require.ElementsMatch(t, []string{"report", "groceries", "taxes"},
+				titles(entities.NoteQuery{Tags: []string{"work", "home"}, TagMode: TagModeAny}))
+			_, err := service.GetNotes(ctx, userID, entities.NoteQuery{Tags: []string{"work"}, TagMode: "some"})
+			require.ErrorIs(t, err, ErrInvalidQuery)
+
+			// tags stay on the note unless the update sets them
+			updated, err := service.UpdateNote(ctx, userID, report.ID, entities.NoteReq{Title: "report", Content: "changed"})
+			require.NoError(t, err)
+			require.Equal(t, []string{"urgent", "work"}, updated.Tags)
+			patched, err := service.PatchNote(ctx, userID, untagged.ID, entities.NotePatch{Tags: []string{"misc"}})
+			require.NoError(t, err)
+			require.Equal(t, []string{"misc"}, patched.Tags)
+			patched, err = service.PatchNote(ctx, userID, untagged.ID, entities.NotePatch{Tags: []string{}})
+			require.NoError(t, err)
+			require.Empty(t, patched.Tags)
+
+			tags, err := service.ListTags(ctx, userID)
+			require.NoError(t, err)
+			require.Equal(t, []entities.Tag{{Name: "home", Notes: 2}, {Name: "urgent", Notes: 2}, {Name: "work", Notes: 1}}, tags)
+
+			_, err = service.RenameTag(ctx, userID, "work", entities.TagRename{Name: "home"})
+			require.ErrorIs(t, err, ErrTagExists)
+			_, err = service.RenameTag(ctx, userID, "missing", entities.TagRename{Name: "found"})
+			require.ErrorIs(t, err, ErrTagNotFound)
+			tag, err := service.RenameTag(ctx, userID, "Work", entities.TagRename{Name: "Job"})
+			require.NoError(t, err)
+			require.Equal(t, entities.Tag{Name: "job", Notes: 1}, tag)
+
+			renamed, err := service.GetNote(ctx, userID, report.ID)
+			require.NoError(t, err)
+			require.Equal(t, []string{"job", "urgent"}, renamed.Tags)
+			require.Greater(t, renamed.Version, updated.Version)
+
+			_, err = service.MergeTags(ctx, userID, entities.TagMerge{From: []string{"job", "missing"}, Into: "todo"})
+			require.ErrorIs(t, err, ErrTagNotFound)
+			tag, err = service.MergeTags(ctx, userID, entities.TagMerge{From: []string{"job", "urgent"}, Into: "todo"})
+			require.NoError(t, err)
+			require.Equal(t, entities.Tag{Name: "todo", Notes: 2}, tag)
+
+			merged, err := service.GetNote(ctx, userID, report.ID)
+			require.NoError(t, err)
+			require.Equal(t, []string{"todo"}, merged.Tags)
+			tags, err = service.ListTags(ctx, userID)
+			require.NoError(t, err)
+			require.Equal(t, []entities.Tag{{Name: "home", Notes: 2}, {Name: "todo", Notes: 2}}, tags)
+
+			// other users' tags are untouched
+			others, err := service.ListTags(ctx, uuid.NewString())
+			require.NoError(t, err)
+			require.Empty(t, others)
+
+			_, err = service.CreateNote(ctx, userID, entities.NoteReq{Title: "t", Content: "c", Tags: []string{" "}})
+			require.ErrorIs(t, err, ErrInvalidNote)
+			_, err = service.CreateNote(ctx, userID, entities.NoteReq{Title: "t", Content: "c", Tags: []string{strings.Repeat("x", 51)}})
+			require.ErrorIs(t, err, ErrInvalidNote)
+		})
+	}
+}
+
+func TestNotebooks(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			service := New(store)
+			userID := uuid.NewString()
+			notebook := func(name, parentID string) entities.Notebook {
+				nb, err := service.CreateNotebook(ctx, userID, entities.NotebookReq{Name: name, ParentID: parentID})
+				require.NoError(t, err)
+				return nb
+			}
+			create := func(title, notebookID string) entities.Note {
+				note, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: title, Content: "content", NotebookID: notebookID})
+				require.NoError(t, err)
+				return note
+			}
+			titles := func(query entities.NoteQuery) []string {
+				page, err := service.GetNotes(ctx, userID, query)
+				require.NoError(t, err)
+				var titles []string
+				for _, note := range page.Items {
+					titles = append(titles, note.Title)
+				}
+				return titles
+			}
+
+			work := notebook(" Work ", "")
+			require.Equal(t, "Work", work.Name)
+			projects := notebook("Projects", work.ID)
+			archive := notebook("Archive", projects.ID)
+			home := notebook("Home", "")
+
+			report := create("report", work.ID)
+			require.Equal(t, work.ID, report.NotebookID)
+			create("roadmap", projects.ID)
+			create("old plan", archive.ID)
+			create("groceries", home.ID)
+			create("loose", "")
+
+			require.ElementsMatch(t, []string{"report"}, titles(entities.NoteQuery{NotebookID: work.ID}))
+			require.ElementsMatch(t, []string{"report", "roadmap", "old plan"}, titles(entities.NoteQuery{NotebookID: work.ID, Descendants: true}))
+			require.ElementsMatch(t, []string{"roadmap", "old plan"}, titles(entities.NoteQuery{NotebookID: projects.ID, Descendants: true}))
+			_, err := service.GetNotes(ctx, userID, entities.NoteQuery{NotebookID: uuid.NewString()})
+			require.ErrorIs(t, err, ErrNotebookNotFound)
+			_, err = service.GetNotes(ctx, uuid.NewString(), entities.NoteQuery{NotebookID: work.ID})
+			require.ErrorIs(t, err, ErrNotebookNotFound)
+
+			_, err = service.CreateNote(ctx, userID, entities.NoteReq{Title: "lost", Content: "content", NotebookID: uuid.NewString()})
+			require.ErrorIs(t, err, ErrInvalidNote)
+			_, err = service.CreateNotebook(ctx, uuid.NewString(), entities.NotebookReq{Name: "stolen", ParentID: work.ID})
+			require.ErrorIs(t, err, ErrInvalidNotebook)
+			_, err = service.CreateNotebook(ctx, userID, entities.NotebookReq{Name: "  "})
+			require.ErrorIs(t, err, ErrInvalidNotebook)
+
+			// notes keep their notebook unless the update sets one, a patch can take them out of it
+			updated, err := service.UpdateNote(ctx, userID, report.ID, entities.NoteReq{Title: "report", Content: "changed"})
+			require.NoError(t, err)
+			require.Equal(t, work.ID, updated.NotebookID)
+			patched, err := service.PatchNote(ctx, userID, report.ID, entities.NotePatch{NotebookID: &home.ID})
+			require.NoError(t, err)
+			require.Equal(t, home.ID, patched.NotebookID)
+			top := ""
+			patched, err = service.PatchNote(ctx, userID, report.ID, entities.NotePatch{NotebookID: &top})
+			require.NoError(t, err)
+			require.Empty(t, patched.NotebookID)
+
+			renamed, err := service.RenameNotebook(ctx, userID, projects.ID, entities.NotebookRename{Name: "Plans"})
+			require.NoError(t, err)
+			require.Equal(t, "Plans", renamed.Name)
+			require.Equal(t, work.ID, renamed.ParentID)
+
+			_, err = service.MoveNotebook(ctx, userID, work.ID, entities.NotebookMove{ParentID: archive.ID})
+			require.ErrorIs(t, err, ErrInvalidNotebook)
+			_, err = service.MoveNotebook(ctx, userID, work.ID, entities.NotebookMove{ParentID: work.ID})
+			require.ErrorIs(t, err, ErrInvalidNotebook)
+			moved, err := service.MoveNotebook(ctx, userID, projects.ID, entities.NotebookMove{ParentID: home.ID})
+			require.NoError(t, err)
+			require.Equal(t, home.ID, moved.ParentID)
+			require.ElementsMatch(t, []string{"groceries", "roadmap", "old plan"}, titles(entities.NoteQuery{NotebookID: home.ID, Descendants: true}))
+			moved, err = service.MoveNotebook(ctx, userID, projects.ID, entities.NotebookMove{})
+			require.NoError(t, err)
+			require.Empty(t, moved.ParentID)
+
+			notebooks, err := service.GetNotebooks(ctx, userID)
+			require.NoError(t, err)
+			var names []string
+			for _, nb := range notebooks {
+				names = append(names, nb.Name)
+			}
+			require.Equal(t, []string{"Archive", "Home", "Plans", "Work"}, names)
+
+			// deleting a notebook moves its notes and notebooks into its parent
+			_, err = service.MoveNotebook(ctx, userID, projects.ID, entities.NotebookMove{ParentID: work.ID})
+			require.NoError(t, err)
+			require.Equal(t, []string{"roadmap"}, titles(entities.NoteQuery{NotebookID: projects.ID}))
+			require.NoError(t, service.DeleteNotebook(ctx, userID, projects.ID, entities.NotebookDelete{}))
+			_, err = service.GetNotebook(ctx, userID, projects.ID)
+			require.ErrorIs(t, err, ErrNotebookNotFound)
+			require.ElementsMatch(t, []string{"roadmap"}, titles(entities.NoteQuery{NotebookID: work.ID}))
+			child, err := service.GetNotebook(ctx, userID, archive.ID)
+			require.NoError(t, err)
+			require.Equal(t, work.ID, child.ParentID)
+
+			// cascading moves the notes of the whole tree to the trash
+			require.ErrorIs(t, service.DeleteNotebook(ctx, userID, work.ID, entities.NotebookDelete{Mode: "shred"}), ErrInvalidNotebook)
+			require.ErrorIs(t, service.DeleteNotebook(ctx, uuid.NewString(), work.ID, entities.NotebookDelete{}), ErrNotebookNotFound)
+			require.NoError(t, service.DeleteNotebook(ctx, userID, work.ID, entities.NotebookDelete{Mode: DeleteModeCascade}))
+			notebooks, err = service.GetNotebooks(ctx, userID)
+			require.NoError(t, err)
+			require.Len(t, notebooks, 1)
+			require.ElementsMatch(t, []string{"groceries", "report", "loose"}, titles(entities.NoteQuery{}))
+
+			trash, err := service.GetTrash(ctx, userID, entities.NoteQuery{})
+			require.NoError(t, err)
+			require.Len(t, trash.Items, 2)
+			for _, note := range trash.Items {
+				require.Empty(t, note.NotebookID)
+				restored, err := service.RestoreNote(ctx, userID, note.ID)
+				require.NoError(t, err)
+				require.Empty(t, restored.NotebookID)
+			}
+		})
+	}
+}
+
+func TestShares(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			directory := users.NewMemoryStore()
+			service := New(store, WithUsers(directory))
+			user := func() entities.User {
+				id := uuid.NewString()
+				u, err := directory.CreateUser(ctx, entities.User{ID: id, Email: id + "@example.com"})
+				require.NoError(t, err)
+				return u
+			}
+			owner, viewer, editor, stranger := user(), user(), user(), user()
+
+			note, err := service.CreateNote(ctx, owner.ID, entities.NoteReq{Title: "plan", Content: "content", Tags: []string{"work"}})
+			require.NoError(t, err)
+			share, err := service.ShareNote(ctx, owner.ID, note.ID, entities.ShareReq{Email: viewer.Email, Role: entities.RoleCommenter})
+			require.NoError(t, err)
+			require.Equal(t, viewer.ID, share.UserID)
+			require.Equal(t, viewer.Email, share.Email)
+			// sharing again changes the role
+			share, err = service.ShareNote(ctx, owner.ID, note.ID, entities.ShareReq{Email: " " + strings.ToUpper(viewer.Email), Role: entities.RoleViewer})
+			require.NoError(t, err)
+			require.Equal(t, entities.RoleViewer, share.Role)
+			_, err = service.ShareNote(ctx, owner.ID, note.ID, entities.ShareReq{Email: editor.Email, Role: entities.RoleEditor})
+			require.NoError(t, err)
+
+			for _, req := range []entities.ShareReq{
+				{Email: "nobody@example.com", Role: entities.RoleViewer},
+				{Email: owner.Email, Role: entities.RoleViewer},
+				{Email: stranger.Email, Role: entities.RoleOwner},
+			} {
+				_, err = service.ShareNote(ctx, owner.ID, note.ID, req)
+				require.ErrorIs(t, err, ErrInvalidShare)
+			}
+			_, err = service.ShareNote(ctx, editor.ID, note.ID, entities.ShareReq{Email: stranger.Email, Role: entities.RoleViewer})
+			require.ErrorIs(t, err, ErrForbidden)
+
+			shares, err := service.GetShares(ctx, owner.ID, note.ID)
+			require.NoError(t, err)
+			require.Len(t, shares, 2)
+			require.ElementsMatch(t, []string{viewer.Email, editor.Email}, []string{shares[0].Email, shares[1].Email})
+			_, err = service.GetShares(ctx, viewer.ID, note.ID)
+			require.ErrorIs(t, err, ErrForbidden)
+
+			for user, want := range map[string]string{owner.ID: entities.RoleOwner, viewer.ID: entities.RoleViewer, editor.ID: entities.RoleEditor} {
+				_, role, err := service.GetRole(ctx, user, note.ID)
+				require.NoError(t, err)
+				require.Equal(t, want, role)
+			}
+			_, _, err = service.GetRole(ctx, stranger.ID, note.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			// viewers can read, editors can also write, only the owner deletes
+			_, err = service.GetNote(ctx, stranger.ID, note.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+			got, err := service.GetNote(ctx, viewer.ID, note.ID)
+			require.NoError(t, err)
+			require.Equal(t, note.ID, got.ID)
+			_, err = service.UpdateNote(ctx, viewer.ID, note.ID, entities.NoteReq{Title: "plan", Content: "viewer"})
+			require.ErrorIs(t, err, ErrForbidden)
+			title := "plan b"
+			patched, err := service.PatchNote(ctx, editor.ID, note.ID, entities.NotePatch{Title: &title})
+			require.NoError(t, err)
+			require.Equal(t, owner.ID, patched.UserID)
+			revisions, err := service.GetRevisions(ctx, viewer.ID, note.ID)
+			require.NoError(t, err)
+			require.Len(t, revisions, 1)
+			_, err = service.RestoreRevision(ctx, viewer.ID, note.ID, 1)
+			require.ErrorIs(t, err, ErrForbidden)
+			require.ErrorIs(t, service.DeleteNote(ctx, editor.ID, note.ID, 0), ErrForbidden)
+			notebook, err := service.CreateNotebook(ctx, editor.ID, entities.NotebookReq{Name: "Mine"})
+			require.NoError(t, err)
+			_, err = service.PatchNote(ctx, editor.ID, note.ID, entities.NotePatch{NotebookID: &notebook.ID})
+			require.ErrorIs(t, err, ErrForbidden)
+
+			page, err := service.GetNotes(ctx, viewer.ID, entities.NoteQuery{SharedWithMe: true})
+			require.NoError(t, err)
+			require.Len(t, page.Items, 1)
+			require.Equal(t, "plan b", page.Items[0].Title)
+			require.Equal(t, []string{"work"}, page.Items[0].Tags)
+			page, err = service.GetNotes(ctx, viewer.ID, entities.NoteQuery{})
+			require.NoError(t, err)
+			require.Empty(t, page.Items)
+			page, err = service.GetNotes(ctx, owner.ID, entities.NoteQuery{SharedWithMe: true})
+			require.NoError(t, err)
+			require.Empty(t, page.Items)
+
+			// collaborators can only leave, the owner can remove anyone
+			require.ErrorIs(t, service.UnshareNote(ctx, viewer.ID, note.ID, editor.ID), ErrForbidden)
+			require.NoError(t, service.UnshareNote(ctx, viewer.ID, note.ID, viewer.ID))
+			_, err = service.GetNote(ctx, viewer.ID, note.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+			require.NoError(t, service.UnshareNote(ctx, owner.ID, note.ID, editor.ID))
+			require.ErrorIs(t, service.UnshareNote(ctx, owner.ID, note.ID, editor.ID), ErrShareNotFound)
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			service := New(store)
+			owner := uuid.NewString()
+			note, err := service.CreateNote(ctx, owner, entities.NoteReq{Title: "recipe", Content: "flour", Tags: []string{"baking"}})
+			require.NoError(t, err)
+
+			maxViews := 2
+			link, err := service.CreateLink(ctx, owner, note.ID, entities.LinkReq{MaxViews: &maxViews})
+			require.NoError(t, err)
+			require.NotEmpty(t, link.Token)
+			require.Equal(t, hashToken(link.Token), link.TokenHash)
+			require.False(t, link.Protected)
+
+			public, err := service.OpenLink(ctx, link.Token, "")
+			require.NoError(t, err)
+			require.Equal(t, "recipe", public.Title)
+			require.Equal(t, "flour", public.Content)
+			require.Equal(t, []string{"baking"}, public.Tags)
+			_, err = service.OpenLink(ctx, link.Token, "")
+			require.NoError(t, err)
+			// the view limit is used up
+			_, err = service.OpenLink(ctx, link.Token, "")
+			require.ErrorIs(t, err, ErrLinkNotFound)
+			_, err = service.OpenLink(ctx, "unknown", "")
+			require.ErrorIs(t, err, ErrLinkNotFound)
+
+			protected, err := service.CreateLink(ctx, owner, note.ID, entities.LinkReq{Password: "open sesame"})
+			require.NoError(t, err)
+			require.True(t, protected.Protected)
+			_, err = service.OpenLink(ctx, protected.Token, "")
+			require.ErrorIs(t, err, ErrLinkPassword)
+			_, err = service.OpenLink(ctx, protected.Token, "open sesame")
+			require.NoError(t, err)
+
+			past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+			zero := 0
+			for _, req := range []entities.LinkReq{{ExpiresAt: &past}, {MaxViews: &zero}} {
+				_, err = service.CreateLink(ctx, owner, note.ID, req)
+				require.ErrorIs(t, err, ErrInvalidLink)
+			}
+			expiring, err := service.CreateLink(ctx, owner, note.ID, entities.LinkReq{ExpiresAt: &future})
+			require.NoError(t, err)
+			require.NotNil(t, expiring.ExpiresAt)
+			_, err = service.CreateLink(ctx, uuid.NewString(), note.ID, entities.LinkReq{})
+			require.ErrorIs(t, err, ErrNotFound)
+
+			links, err := service.GetLinks(ctx, owner, note.ID)
+			require.NoError(t, err)
+			require.Len(t, links, 3)
+			require.Equal(t, link.ID, links[0].ID)
+			require.Equal(t, 2, links[0].Views)
+			require.Empty(t, links[0].Token)
+			require.Equal(t, 1, links[1].Views)
+
+			// revoked links and links to notes in the trash cannot be opened
+			require.NoError(t, service.RevokeLink(ctx, owner, note.ID, protected.ID))
+			require.ErrorIs(t, service.RevokeLink(ctx, owner, note.ID, protected.ID), ErrLinkNotFound)
+			_, err = service.OpenLink(ctx, protected.Token, "open sesame")
+			require.ErrorIs(t, err, ErrLinkNotFound)
+			require.NoError(t, service.DeleteNote(ctx, owner, note.ID, 0))
+			_, err = service.OpenLink(ctx, expiring.Token, "")
+			require.ErrorIs(t, err, ErrLinkNotFound)
+
+			require.NoError(t, service.PurgeNote(ctx, owner, note.ID, 0))
+			links, err = store.ListLinks(ctx, note.ID)
+			require.NoError(t, err)
+			require.Empty(t, links)
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			directory := users.NewMemoryStore()
+			service := New(store, WithUsers(directory))
+			user := func() entities.User {
+				id := uuid.NewString()
+				u, err := directory.CreateUser(ctx, entities.User{ID: id, Email: id + "@example.com"})
+				require.NoError(t, err)
+				return u
+			}
+			owner, commenter, viewer := user(), user(), user()
+
+			note, err := service.CreateNote(ctx, owner.ID, entities.NoteReq{Title: "plan", Content: "content"})
+			require.NoError(t, err)
+			for collaborator, role := range map[string]string{commenter.Email: entities.RoleCommenter, viewer.Email: entities.RoleViewer} {
+				_, err = service.ShareNote(ctx, owner.ID, note.ID, entities.ShareReq{Email: collaborator, Role: role})
+				require.NoError(t, err)
+			}
+
+			thread, err := service.CreateComment(ctx, commenter.ID, note.ID, entities.CommentReq{Body: " why? "})
+			require.NoError(t, err)
+			require.Equal(t, "why?", thread.Body)
+			require.Equal(t, commenter.ID, thread.UserID)
+			reply, err := service.CreateComment(ctx, owner.ID, note.ID, entities.CommentReq{Body: "because", ParentID: thread.ID})
+			require.NoError(t, err)
+			require.Equal(t, thread.ID, reply.ParentID)
+
+			_, err = service.CreateComment(ctx, viewer.ID, note.ID, entities.CommentReq{Body: "me too"})
+			require.ErrorIs(t, err, ErrForbidden)
+			for _, req := range []entities.CommentReq{
+				{Body: " "},
+				{Body: "nested", ParentID: reply.ID},
+				{Body: "unknown", ParentID: uuid.NewString()},
+			} {
+				_, err = service.CreateComment(ctx, commenter.ID, note.ID, req)
+				require.ErrorIs(t, err, ErrInvalidComment)
+			}
+
+			comments, err := service.GetComments(ctx, viewer.ID, note.ID)
+			require.NoError(t, err)
+			require.Len(t, comments, 2)
+			require.Equal(t, thread.ID, comments[0].ID)
+			page, err := service.GetNotes(ctx, owner.ID, entities.NoteQuery{})
+			require.NoError(t, err)
+			require.Len(t, page.Items, 1)
+			require.NotNil(t, page.Items[0].Comments)
+			require.Equal(t, 2, *page.Items[0].Comments)
+
+			// only authors edit their comments, anyone who can comment resolves threads
+			_, err = service.EditComment(ctx, owner.ID, note.ID, thread.ID, entities.CommentEdit{Body: "hijacked"})
+			require.ErrorIs(t, err, ErrForbidden)
+			edited, err := service.EditComment(ctx, commenter.ID, note.ID, thread.ID, entities.CommentEdit{Body: "why not?"})
+			require.NoError(t, err)
+			require.Equal(t, "why not?", edited.Body)
+			resolved, err := service.ResolveComment(ctx, owner.ID, note.ID, thread.ID, true)
+			require.NoError(t, err)
+			require.True(t, resolved.Resolved)
+			_, err = service.ResolveComment(ctx, owner.ID, note.ID, reply.ID, true)
+			require.ErrorIs(t, err, ErrInvalidComment)
+			_, err = service.ResolveComment(ctx, viewer.ID, note.ID, thread.ID, false)
+			require.ErrorIs(t, err, ErrForbidden)
+			other, err := service.CreateNote(ctx, owner.ID, entities.NoteReq{Title: "other", Content: "content"})
+			require.NoError(t, err)
+			_, err = service.ResolveComment(ctx, owner.ID, other.ID, thread.ID, true)
+			require.ErrorIs(t, err, ErrCommentNotFound)
+
+			// deleting the thread deletes its replies, the owner can delete anyone's comments
+			require.ErrorIs(t, service.DeleteComment(ctx, commenter.ID, note.ID, reply.ID), ErrForbidden)
+			require.NoError(t, service.DeleteComment(ctx, owner.ID, note.ID, thread.ID))
+			require.ErrorIs(t, service.DeleteComment(ctx, owner.ID, note.ID, reply.ID), ErrCommentNotFound)
+			comments, err = service.GetComments(ctx, owner.ID, note.ID)
+			require.NoError(t, err)
+			require.Empty(t, comments)
+			page, err = service.GetNotes(ctx, owner.ID, entities.NoteQuery{})
+			require.NoError(t, err)
+			for _, item := range page.Items {
+				require.Equal(t, 0, *item.Comments)
+			}
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := t.Context()
+	directory := users.NewMemoryStore()
+	service := New(NewMemoryStore(), WithUsers(directory), WithEventBuffer(3))
+	owner, err := directory.CreateUser(ctx, entities.User{ID: uuid.NewString(), Email: "owner@example.com"})
+	require.NoError(t, err)
+	collaborator, err := directory.CreateUser(ctx, entities.User{ID: uuid.NewString(), Email: "collaborator@example.com"})
+	require.NoError(t, err)
+
+	ownerCtx, cancel := context.WithCancel(ctx)
+	ownerSub := service.Subscribe(ownerCtx, owner.ID, 0)
+	collaboratorSub := service.Subscribe(ctx, collaborator.ID, 0)
+
+	private, err := service.CreateNote(ctx, owner.ID, entities.NoteReq{Title: "private", Content: "content"})
+	require.NoError(t, err)
+	shared, err := service.CreateNote(ctx, owner.ID, entities.NoteReq{Title: "shared", Content: "content"})
+	require.NoError(t, err)
+	_, err = service.ShareNote(ctx, owner.ID, shared.ID, entities.ShareReq{Email: collaborator.Email, Role: entities.RoleEditor})
+	require.NoError(t, err)
+	title := "renamed"
+	_, err = service.PatchNote(ctx, collaborator.ID, shared.ID, entities.NotePatch{Title: &title})
+	require.NoError(t, err)
+
+	created := <-ownerSub.Events
+	require.Equal(t, entities.EventNoteCreated, created.Type)
+	require.Equal(t, private.ID, created.NoteID)
+	require.Equal(t, "private", created.Note.Title)
+	require.Equal(t, shared.ID, (<-ownerSub.Events).NoteID)
+	updated := <-ownerSub.Events
+	require.Equal(t, entities.EventNoteUpdated, updated.Type)
+	require.Equal(t, "renamed", updated.Note.Title)
+
+	// the collaborator only hears about the note once it is shared with them
+	event := <-collaboratorSub.Events
+	require.Equal(t, entities.EventNoteCreated, event.Type)
+	require.Equal(t, shared.ID, event.NoteID)
+	require.Equal(t, updated.ID, (<-collaboratorSub.Events).ID)
+
+	cancel()
+	_, ok := <-ownerSub.Events
+	require.False(t, ok)
+
+	require.NoError(t, service.DeleteNote(ctx, owner.ID, shared.ID, 0))
+	deleted := <-collaboratorSub.Events
+	require.Equal(t, entities.EventNoteDeleted, deleted.Type)
+	require.Nil(t, deleted.Note)
+
+	// a client resuming after the update gets what it missed, one that is too far behind is told to start over
+	resumed := service.Subscribe(ctx, owner.ID, updated.ID)
+	require.False(t, resumed.Missed)
+	require.Len(t, resumed.Replay, 1)
+	require.Equal(t, deleted.ID, resumed.Replay[0].ID)
+	require.Equal(t, deleted.ID, resumed.LastEventID)
+	resumed = service.Subscribe(ctx, owner.ID, created.ID)
+	require.True(t, resumed.Missed)
+	resumed = service.Subscribe(ctx, owner.ID, deleted.ID+1)
+	require.True(t, resumed.Missed)
+
+	// subscribers that fall behind are dropped
+	slow := service.Subscribe(ctx, owner.ID, 0)
+	for range subscriberBuffer + 1 {
+		_, err = service.CreateNote(ctx, owner.ID, entities.NoteReq{Title: "flood", Content: "content"})
+		require.NoError(t, err)
+	}
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	require.Equal(t, subscriberBuffer, received)
+}
+
+func TestSync(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			service := New(store)
+			userID := uuid.NewString()
+
+			changes, err := service.GetChanges(ctx, userID, 0)
+			require.NoError(t, err)
+			require.Zero(t, changes.Seq)
+			require.Empty(t, changes.Notes)
+
+			kept, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "kept", Content: "first"})
+			require.NoError(t, err)
+			trashed, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "trashed", Content: "second"})
+			require.NoError(t, err)
+			require.Greater(t, trashed.Seq, kept.Seq)
+			changes, err = service.GetChanges(ctx, userID, 0)
+			require.NoError(t, err)
+			require.Len(t, changes.Notes, 2)
+			require.Equal(t, trashed.Seq, changes.Seq)
+			synced := changes.Seq
+
+			kept, err = service.UpdateNote(ctx, userID, kept.ID, entities.NoteReq{Title: "kept", Content: "edited"})
+			require.NoError(t, err)
+			require.NoError(t, service.DeleteNote(ctx, userID, trashed.ID, 0))
+			changes, err = service.GetChanges(ctx, userID, synced)
+			require.NoError(t, err)
+			require.False(t, changes.Reset)
+			require.Len(t, changes.Notes, 1)
+			require.Equal(t, "edited", changes.Notes[0].Content)
+			require.Len(t, changes.Deleted, 1)
+			require.Equal(t, trashed.ID, changes.Deleted[0].ID)
+			synced = changes.Seq
+
+			// changes based on the stored version are applied, notes created offline keep their id
+			offline := uuid.NewString()
+			response, err := service.Sync(ctx, userID, entities.SyncReq{Changes: []entities.SyncChange{
+				{ID: offline, Title: "offline", Content: "written on a plane", Tags: []string{"Travel"}, UpdatedAt: time.Now()},
+				{ID: kept.ID, Title: "kept", Content: "synced", Version: kept.Version, UpdatedAt: time.Now()},
+			}})
+			require.NoError(t, err)
+			require.Len(t, response.Results, 2)
+			for _, result := range response.Results {
+				require.Equal(t, entities.SyncApplied, result.Status)
+				require.Nil(t, result.ConflictCopy)
+			}
+			require.Equal(t, offline, response.Results[0].Note.ID)
+			require.Equal(t, []string{"travel"}, response.Results[0].Note.Tags)
+			kept = *response.Results[1].Note
+			require.Equal(t, "synced", kept.Content)
+
+			changes, err = service.GetChanges(ctx, userID, synced)
+			require.NoError(t, err)
+			require.Len(t, changes.Notes, 2)
+			require.Empty(t, changes.Deleted)
+
+			// the server changed since, an older change loses and is kept as a copy
+			response, err = service.Sync(ctx, userID, entities.SyncReq{Changes: []entities.SyncChange{
+				{ID: kept.ID, Title: "kept", Content: "stale", Version: kept.Version - 1, UpdatedAt: time.Now().Add(-time.Hour)},
+			}})
+			require.NoError(t, err)
+			result := response.Results[0]
+			require.Equal(t, entities.SyncConflict, result.Status)
+			require.Equal(t, entities.SyncWinnerServer, result.Winner)
+			require.Equal(t, "synced", result.Note.Content)
+			require.NotNil(t, result.ConflictCopy)
+			require.Equal(t, "kept (conflict copy)", result.ConflictCopy.Title)
+			require.Equal(t, "stale", result.ConflictCopy.Content)
+
+			// a newer one wins and the server's content is kept as a copy
+			response, err = service.Sync(ctx, userID, entities.SyncReq{Changes: []entities.SyncChange{
+				{ID: kept.ID, Title: "kept", Content: "newest", Version: kept.Version - 1, UpdatedAt: time.Now().Add(time.Hour)},
+			}})
+			require.NoError(t, err)
+			result = response.Results[0]
+			require.Equal(t, entities.SyncWinnerClient, result.Winner)
+			require.Equal(t, "newest", result.Note.Content)
+			require.Equal(t, "synced", result.ConflictCopy.Content)
+			kept = *result.Note
+
+			// the same content on both sides needs no copy
+			response, err = service.Sync(ctx, userID, entities.SyncReq{Changes: []entities.SyncChange{
+				{ID: kept.ID, Title: "kept", Content: "newest", Version: 1, UpdatedAt: time.Now().Add(-time.Hour)},
+			}})
+			require.NoError(t, err)
+			require.Equal(t, entities.SyncConflict, response.Results[0].Status)
+			require.Nil(t, response.Results[0].ConflictCopy)
+
+			// a batch is applied as a whole or not at all
+			lost := uuid.NewString()
+			_, err = service.Sync(ctx, userID, entities.SyncReq{Changes: []entities.SyncChange{
+				{ID: lost, Title: "lost", Content: "rolled back", UpdatedAt: time.Now()},
+				{ID: kept.ID, Title: "kept", Content: "elsewhere", NotebookID: "missing", Version: kept.Version, UpdatedAt: time.Now()},
+			}})
+			require.ErrorIs(t, err, ErrInvalidNote)
+			_, err = service.GetNote(ctx, userID, lost)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			_, err = service.Sync(ctx, uuid.NewString(), entities.SyncReq{Changes: []entities.SyncChange{
+				{ID: kept.ID, Title: "kept", Content: "not mine", Version: kept.Version, UpdatedAt: time.Now()},
+			}})
+			require.ErrorIs(t, err, ErrNotFound)
+
+			for _, req := range []entities.SyncReq{
+				{Changes: []entities.SyncChange{{ID: "not-a-uuid", Title: "t", Content: "c", UpdatedAt: time.Now()}}},
+				{Changes: []entities.SyncChange{{ID: lost, UpdatedAt: time.Now()}}},
+				{Changes: []entities.SyncChange{{ID: lost, Deleted: true}}},
+				{Changes: []entities.SyncChange{{ID: lost, Deleted: true, UpdatedAt: time.Now()}, {ID: lost, Deleted: true, UpdatedAt: time.Now()}}},
+			} {
+				_, err = service.Sync(ctx, userID, req)
+				require.ErrorIs(t, err, ErrInvalidSync)
+			}
+
+			// a delete at the stored version moves the note to the trash
+			response, err = service.Sync(ctx, userID, entities.SyncReq{Changes: []entities.SyncChange{
+				{ID: offline, Deleted: true, Version: 1, UpdatedAt: time.Now()},
+			}})
+			require.NoError(t, err)
+			require.Nil(t, response.Results[0].Note)
+			changes, err = service.GetChanges(ctx, userID, kept.Seq)
+			require.NoError(t, err)
+			require.Len(t, changes.Deleted, 1)
+			require.Equal(t, offline, changes.Deleted[0].ID)
+			synced = changes.Seq
+
+			// clients that synced before a purged note went to the trash start over
+			require.NoError(t, service.PurgeNote(ctx, userID, offline, 0))
+			changes, err = service.GetChanges(ctx, userID, kept.Seq)
+			require.NoError(t, err)
+			require.True(t, changes.Reset)
+			require.Empty(t, changes.Deleted)
+			for _, note := range changes.Notes {
+				require.Nil(t, note.DeletedAt)
+			}
+			changes, err = service.GetChanges(ctx, userID, synced)
+			require.NoError(t, err)
+			require.False(t, changes.Reset)
+			require.Empty(t, changes.Notes)
+
+			_, err = service.GetChanges(ctx, userID, -1)
+			require.ErrorIs(t, err, ErrInvalidSync)
+		})
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			service := New(store)
+			userID := uuid.NewString()
+
+			// the outbox is shared by the tests, so only the user's events are looked at
+			outbox := func() []entities.OutboxEvent {
+				events, err := service.ListOutbox(ctx, 100000)
+				require.NoError(t, err)
+				var own []entities.OutboxEvent
+				for _, event := range events {
+					if event.UserID == userID {
+						own = append(own, event)
+					}
+				}
+				return own
+			}
+
+			note, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "title", Content: "content"})
+			require.NoError(t, err)
+			_, err = service.UpdateNote(ctx, userID, note.ID, entities.NoteReq{Title: "stale", Content: "content", Version: 5})
+			require.ErrorIs(t, err, ErrVersionConflict)
+			_, err = service.UpdateNote(ctx, userID, note.ID, entities.NoteReq{Title: "changed", Content: "content"})
+			require.NoError(t, err)
+			require.NoError(t, service.DeleteNote(ctx, userID, note.ID, 0))
+			require.NoError(t, service.PurgeNote(ctx, userID, note.ID, 0))
+
+			events := outbox()
+			require.Len(t, events, 3)
+			types := []string{entities.EventNoteCreated, entities.EventNoteUpdated, entities.EventNoteDeleted}
+			for i, event := range events {
+				require.Equal(t, types[i], event.Type)
+				var payload entities.WebhookEvent
+				require.NoError(t, json.Unmarshal([]byte(event.Payload), &payload))
+				require.Equal(t, event.EventID, payload.ID)
+				require.Equal(t, note.ID, payload.NoteID)
+				require.Equal(t, event.Type == entities.EventNoteDeleted, payload.Note == nil)
+			}
+			require.Less(t, events[0].ID, events[1].ID)
+
+			for _, event := range events {
+				require.NoError(t, service.DeleteOutbox(ctx, event.ID))
+			}
+			require.Empty(t, outbox())
+		})
+	}
+}
+
+func TestGetNotes(t *testing.T) {
+	service := New(newStore())
+	userID := uuid.NewString()
+	for _, title := range []string{"Groceries", "gym plan", "Books", "garden", "Games"} {
+		_, err := service.CreateNote(t.Context(), userID, entities.NoteReq{
+			Title:   title,
+			Content: "content",
+		})
+		require.NoError(t, err)
+	}
+
+	query := entities.NoteQuery{TitlePrefix: "g", Sort: SortCreatedAt, Order: OrderAsc, Limit: 2}
+	var titles []string
+	for {
+		page, err := service.GetNotes(t.Context(), userID, query)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Items), 2)
+		for _, v := range page.Items {
+			titles = append(titles, v.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	require.ElementsMatch(t, []string{"Groceries", "gym plan", "garden", "Games"}, titles)
+
+	page, err := service.GetNotes(t.Context(), userID, entities.NoteQuery{CreatedAfter: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.Empty(t, page.Items)
+
+	page, err = service.GetNotes(t.Context(), userID, entities.NoteQuery{Sort: SortTitle, Order: OrderDesc, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.NotEmpty(t, page.NextCursor)
+
+	_, err = service.GetNotes(t.Context(), userID, entities.NoteQuery{Sort: SortCreatedAt, Order: OrderDesc, Cursor: page.NextCursor})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	service := New(newStore())
+	userID := uuid.NewString()
+	note, err := service.CreateNote(t.Context(), userID, entities.NoteReq{
+		Title:   "Trash me",
+		Content: "content",
+	})
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteNote(t.Context(), userID, note.ID, 0))
+
+	trash, err := service.GetTrash(t.Context(), userID, entities.NoteQuery{})
+	require.NoError(t, err)
+	require.Len(t, trash.Items, 1)
+	require.NotNil(t, trash.Items[0].DeletedAt)
+
+	purged, err := service.PurgeTrash(t.Context(), time.Hour)
+	require.NoError(t, err)
+	require.Zero(t, purged)
+
+	purged, err = service.PurgeTrash(t.Context(), -time.Hour)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, purged, int64(1))
+
+	_, err = service.RestoreNote(t.Context(), userID, note.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestIndexer(t *testing.T) {
+	ctx := t.Context()
+	store := NewMemoryStore()
+	userID := uuid.NewString()
+	existing, err := New(store).CreateNote(ctx, userID, entities.NoteReq{Title: "Existing", Content: "written before indexing"})
+	require.NoError(t, err)
+
+	index := search.NewMemoryIndex()
+	service := New(store, WithIndexer(index))
+	indexed, err := service.IndexAll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, indexed)
+
+	note, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "Fresh", Content: "indexed on create"})
+	require.NoError(t, err)
+
+	hits := func(q string) []string {
+		results, err := index.Search(ctx, q, search.Filters{UserID: userID})
+		require.NoError(t, err)
+		var ids []string
+		for _, hit := range results.Hits {
+			ids = append(ids, hit.Note.ID)
+		}
+		return ids
+	}
+	require.Equal(t, []string{existing.ID}, hits("before"))
+	require.Equal(t, []string{note.ID}, hits("create"))
+
+	_, err = service.UpdateNote(ctx, userID, note.ID, entities.NoteReq{Title: "Fresh", Content: "indexed on update"})
+	require.NoError(t, err)
+	require.Empty(t, hits("create"))
+	require.Equal(t, []string{note.ID}, hits("update"))
+
+	require.NoError(t, service.DeleteNote(ctx, userID, note.ID, 0))
+	require.Empty(t, hits("update"))
+
+	_, err = service.RestoreNote(ctx, userID, note.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{note.ID}, hits("update"))
+}
+
+func TestBatches(t *testing.T) {
+	ctx := t.Context()
+	service := New(newStore())
+	userID := uuid.NewString()
+	created := make(map[string]bool)
+	for range 5 {
+		note, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "Batch", Content: "content"})
+		require.NoError(t, err)
+		created[note.ID] = true
+	}
+	deleted, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "Batch", Content: "in the trash"})
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteNote(ctx, userID, deleted.ID, 0))
+
+	// other tests share the database, so only this user's notes are counted
+	seen := make(map[string]bool)
+	batches := 0
+	err = Batches(ctx, db, 2, func(batch []entities.Note) error {
+		require.LessOrEqual(t, len(batch), 2)
+		batches++
+		for _, note := range batch {
+			require.False(t, seen[note.ID], "note %s streamed twice", note.ID)
+			seen[note.ID] = true
+			require.NotEqual(t, deleted.ID, note.ID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, batches, 3)
+	for id := range created {
+		require.True(t, seen[id])
+	}
+
+	stop := errors.New("stop")
+	err = Batches(ctx, db, 2, func([]entities.Note) error { return stop })
+	require.ErrorIs(t, err, stop)
+}
+
+func TestChangedSince(t *testing.T) {
+	ctx := t.Context()
+	service := New(newStore())
+	userID := uuid.NewString()
+	note, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "Changed", Content: "content"})
+	require.NoError(t, err)
+	deleted, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "Changed", Content: "in the trash"})
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteNote(ctx, userID, deleted.ID, 0))
+
+	changed := func(since time.Time) map[string]entities.Note {
+		notes := make(map[string]entities.Note)
+		err := ChangedSince(ctx, db, since, 1, func(batch []entities.Note) error {
+			for _, n := range batch {
+				if n.UserID == userID {
+					notes[n.ID] = n
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		return notes
+	}
+
+	// notes moved to the trash are streamed too, so they can be removed from the index
+	notes := changed(time.Now().Add(-time.Hour))
+	require.Len(t, notes, 2)
+	require.Nil(t, notes[note.ID].DeletedAt)
+	require.NotNil(t, notes[deleted.ID].DeletedAt)
+	require.Empty(t, changed(time.Now().Add(time.Hour)))
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"sql":    newStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			note, err := store.CreateNote(ctx, entities.Note{
+				ID:          "store-" + name,
+				UserID:      "test-user",
+				Title:       "title",
+				Content:     "content",
+				ContentType: entities.ContentTypeMarkdown,
+			})
+			require.NoError(t, err)
+			require.False(t, note.CreatedAt.IsZero())
+			require.Equal(t, entities.ContentTypeMarkdown, note.ContentType)
+			require.Equal(t, 1, note.Version)
+
+			// a body well past the old 100 character column, with 4 byte characters
+			content := strings.Repeat("🗒️ notes ", 10000)
+			note.Title = "new title"
+			note.Content = content
+			note.ContentType = entities.ContentTypePlain
+			updated, err := store.UpdateNote(ctx, note)
+			require.NoError(t, err)
+			require.Equal(t, "new title", updated.Title)
+			require.Equal(t, content, updated.Content)
+			require.Equal(t, entities.ContentTypePlain, updated.ContentType)
+			require.Equal(t, 2, updated.Version)
+
+			// note still has the version the update was based on
+			_, err = store.UpdateNote(ctx, note)
+			require.ErrorIs(t, err, ErrVersionConflict)
+
+			list, err := store.ListNotes(ctx, ListParams{UserID: "test-user", Sort: SortCreatedAt, Order: OrderAsc, Limit: 100})
+			require.NoError(t, err)
+			require.NotEmpty(t, list)
+			for _, n := range list {
+				require.NotEmpty(t, n.ContentType)
+			}
+
+			require.ErrorIs(t, store.DeleteNote(ctx, note.ID, note.Version), ErrVersionConflict)
+			require.NoError(t, store.DeleteNote(ctx, note.ID, updated.Version))
+			_, err = store.GetNote(ctx, note.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+			require.ErrorIs(t, store.DeleteNote(ctx, note.ID, updated.Version+1), ErrNotFound)
+
+			restored, err := store.RestoreNote(ctx, note.ID)
+			require.NoError(t, err)
+			require.Nil(t, restored.DeletedAt)
+			require.Equal(t, 4, restored.Version)
+
+			require.ErrorIs(t, store.PurgeNote(ctx, note.ID, 3), ErrVersionConflict)
+			require.NoError(t, store.PurgeNote(ctx, note.ID, restored.Version))
+			require.ErrorIs(t, store.PurgeNote(ctx, note.ID, restored.Version), ErrNotFound)
+
+			sequence, err := store.GetSequence(ctx, "test-user")
+			require.NoError(t, err)
+			require.Equal(t, sequence.Seq, sequence.Purged)
+
+			// nothing written in a failed transaction is kept
+			kept, err := store.CreateNote(ctx, entities.Note{ID: "kept-" + name, UserID: "test-user", Title: "t", Content: "c", ContentType: entities.ContentTypePlain})
+			require.NoError(t, err)
+			sequence, err = store.GetSequence(ctx, "test-user")
+			require.NoError(t, err)
+			outbox, err := store.ListOutbox(ctx, 1000)
+			require.NoError(t, err)
+
+			failed := errors.New("failed")
+			err = store.WithTx(ctx, func(tx Store) error {
+				_, err := tx.CreateNote(ctx, entities.Note{ID: "tx-" + name, UserID: "test-user", Title: "t", Content: "c", ContentType: entities.ContentTypePlain})
+				require.NoError(t, err)
+				_, err = tx.GetNote(ctx, "tx-"+name)
+				require.NoError(t, err)
+				changed := kept
+				changed.Title = "changed"
+				_, err = tx.UpdateNote(ctx, changed)
+				require.NoError(t, err)
+				_, err = tx.ShareNote(ctx, entities.Share{NoteID: kept.ID, UserID: "other-user", Role: entities.RoleViewer})
+				require.NoError(t, err)
+				require.NoError(t, tx.AddOutbox(ctx, entities.OutboxEvent{EventID: "tx-" + name, UserID: "test-user", Type: entities.EventNoteUpdated, Payload: "{}"}))
+				return failed
+			})
+			require.ErrorIs(t, err, failed)
+			_, err = store.GetNote(ctx, "tx-"+name)
+			require.ErrorIs(t, err, ErrNotFound)
+			after, err := store.GetSequence(ctx, "test-user")
+			require.NoError(t, err)
+			require.Equal(t, sequence, after)
+			unchanged, err := store.GetNote(ctx, kept.ID)
+			require.NoError(t, err)
+			require.Equal(t, "t", unchanged.Title)
+			require.Equal(t, kept.Version, unchanged.Version)
+			revisions, err := store.ListRevisions(ctx, kept.ID)
+			require.NoError(t, err)
+			require.Empty(t, revisions)
+			shares, err := store.ListShares(ctx, kept.ID)
+			require.NoError(t, err)
+			require.Empty(t, shares)
+			events, err := store.ListOutbox(ctx, 1000)
+			require.NoError(t, err)
+			require.Equal(t, outbox, events)
+		})
+	}
+}
+
+func newStore() Store {
+	if dbType == "mysql" {
+		return NewMySQLStore(db)
+	}
+	return NewSQLiteStore(db)
 }
 
 func setupDatabase() (*sql.DB, error) {
+	if dbType != "mysql" {
+		dbase, err := migrator.SetupSQLite(context.TODO(), ":memory:")
+		if err != nil {
+			return nil, err
+		}
+		return dbase, migrator.MigrateSQLite(context.TODO(), dbase)
+	}
+
 	dsn := getDsn()
-	db, err := sql.Open("mysql", dsn)
+	dbase, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, err
 	}
-	if err := db.Ping(); err != nil {
+	if err := dbase.Ping(); err != nil {
 		return nil, err
 	}
-	return db, nil
+	if err := migrator.Migrate(context.TODO(), dbase, dsn); err != nil {
+		if !errors.Is(err, migrate.ErrNoChange) {
+			return nil, err
+		}
+		slog.Info("database is already up to date", "error", err)
+	}
+	return dbase, nil
 }
 
 func getDsn() string {
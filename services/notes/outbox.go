@@ -0,0 +1,47 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"notes/services/entities"
+
+	"github.com/google/uuid"
+)
+
+// record writes an event about the note to the outbox of the store, which WithTx binds to
+// the transaction of the change. The event goes to the webhooks of the note's owner.
+func record(ctx context.Context, store Store, eventType string, note entities.Note) error {
+	event := entities.WebhookEvent{
+		ID:      uuid.NewString(),
+		Type:    eventType,
+		NoteID:  note.ID,
+		UserID:  note.UserID,
+		Version: note.Version,
+		Time:    time.Now().UTC(),
+	}
+	if eventType != entities.EventNoteDeleted {
+		event.Note = &note
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return store.AddOutbox(ctx, entities.OutboxEvent{
+		EventID: event.ID,
+		UserID:  note.UserID,
+		Type:    eventType,
+		Payload: string(payload),
+	})
+}
+
+// ListOutbox returns up to limit note events waiting to be handed to the webhooks, oldest first
+func (s *Service) ListOutbox(ctx context.Context, limit int) ([]entities.OutboxEvent, error) {
+	return s.store.ListOutbox(ctx, limit)
+}
+
+// DeleteOutbox removes an event from the outbox once it was handed to the webhooks
+func (s *Service) DeleteOutbox(ctx context.Context, id int64) error {
+	return s.store.DeleteOutbox(ctx, id)
+}
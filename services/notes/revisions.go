@@ -0,0 +1,131 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// diffContext is the number of unchanged lines shown around each change in a diff
+const diffContext = 3
+
+// WithRevisionLimit keeps only the newest keep revisions of each note, zero keeps them all
+func WithRevisionLimit(keep int) Option {
+	return func(s *Service) {
+		s.revisionLimit = keep
+	}
+}
+
+// GetRevisions returns the earlier versions of the user's note, newest first
+func (s *Service) GetRevisions(ctx context.Context, userID, id string) ([]entities.Revision, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetRevisions")
+	defer span.End()
+
+	if _, err := s.access(ctx, userID, id, false, entities.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.store.ListRevisions(ctx, id)
+}
+
+// GetRevision returns the given revision of the user's note
+func (s *Service) GetRevision(ctx context.Context, userID, id string, revision int) (entities.Revision, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetRevision")
+	defer span.End()
+
+	if _, err := s.access(ctx, userID, id, false, entities.RoleViewer); err != nil {
+		return entities.Revision{}, err
+	}
+	return s.revision(ctx, id, revision)
+}
+
+// DiffNote compares the content of two versions of the user's note, or of a
+// revision and the current note when query.To is empty
+func (s *Service) DiffNote(ctx context.Context, userID, id string, query entities.DiffQuery) (entities.NoteDiff, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.DiffNote")
+	defer span.End()
+
+	if query.From < 1 || query.To < 0 {
+		return entities.NoteDiff{}, fmt.Errorf("%w: from and to must be revision numbers", ErrInvalidQuery)
+	}
+
+	note, err := s.access(ctx, userID, id, false, entities.RoleViewer)
+	if err != nil {
+		return entities.NoteDiff{}, err
+	}
+	from, err := s.revision(ctx, id, query.From)
+	if err != nil {
+		return entities.NoteDiff{}, err
+	}
+	to, toName := note.Content, "current"
+	if query.To != 0 {
+		revision, err := s.revision(ctx, id, query.To)
+		if err != nil {
+			return entities.NoteDiff{}, err
+		}
+		to, toName = revision.Content, "revision "+strconv.Itoa(query.To)
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from.Content),
+		B:        difflib.SplitLines(to),
+		FromFile: "revision " + strconv.Itoa(query.From),
+		ToFile:   toName,
+		Context:  diffContext,
+	})
+	if err != nil {
+		return entities.NoteDiff{}, err
+	}
+	return entities.NoteDiff{From: query.From, To: query.To, Diff: diff}, nil
+}
+
+// RestoreRevision makes the given revision the current version of the user's
+// note. The version it replaces is kept as a new revision, so it can be undone.
+func (s *Service) RestoreRevision(ctx context.Context, userID, id string, revision int) (entities.Note, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.RestoreRevision")
+	defer span.End()
+
+	note, err := s.access(ctx, userID, id, false, entities.RoleEditor)
+	if err != nil {
+		return entities.Note{}, err
+	}
+	r, err := s.revision(ctx, id, revision)
+	if err != nil {
+		return entities.Note{}, err
+	}
+	note.Title = r.Title
+	note.Content = r.Content
+	note.ContentType = r.ContentType
+	return s.update(ctx, note)
+}
+
+func (s *Service) revision(ctx context.Context, id string, revision int) (entities.Revision, error) {
+	r, err := s.store.GetRevision(ctx, id, revision)
+	if err != nil {
+		return entities.Revision{}, fmt.Errorf("revision %d: %w", revision, err)
+	}
+	return r, nil
+}
+
+// prune drops the revisions of the note past the limit. Failures are logged
+// since the update they follow has already been stored.
+func (s *Service) prune(ctx context.Context, id string) {
+	if s.revisionLimit <= 0 {
+		return
+	}
+	pruned, err := s.store.PruneRevisions(ctx, id, s.revisionLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to prune revisions", "note_id", id, "error", err)
+		return
+	}
+	if pruned > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("revisions_pruned", pruned))
+	}
+}
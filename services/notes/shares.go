@@ -0,0 +1,196 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+	"notes/services/users"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrForbidden is returned when the user's role on a note does not allow the action
+	ErrForbidden = errors.New("not allowed")
+	// ErrShareNotFound is returned when a note is not shared with the user
+	ErrShareNotFound = errors.New("share not found")
+	// ErrInvalidShare is returned when a share request is invalid
+	ErrInvalidShare = errors.New("invalid share")
+)
+
+// roleRanks orders the roles, a higher rank allows everything a lower one does
+var roleRanks = map[string]int{
+	entities.RoleViewer:    1,
+	entities.RoleCommenter: 2,
+	entities.RoleEditor:    3,
+	entities.RoleOwner:     4,
+}
+
+// Users looks up the accounts notes are shared with, users.Store is one
+type Users interface {
+	GetUser(ctx context.Context, id string) (entities.User, error)
+	GetUserByEmail(ctx context.Context, email string) (entities.User, error)
+}
+
+// WithUsers lets notes be shared with the users found in the given directory
+func WithUsers(users Users) Option {
+	return func(s *Service) {
+		s.users = users
+	}
+}
+
+// GetShares returns the users the owner shared the note with, in the order it was shared with them
+func (s *Service) GetShares(ctx context.Context, userID, id string) ([]entities.Share, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetShares")
+	defer span.End()
+
+	if _, err := s.access(ctx, userID, id, false, entities.RoleOwner); err != nil {
+		return nil, err
+	}
+	shares, err := s.store.ListShares(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shares {
+		if err := s.withEmail(ctx, &shares[i]); err != nil {
+			return nil, err
+		}
+	}
+	return shares, nil
+}
+
+// ShareNote gives the user registered with req.Email req.Role on the owner's note,
+// or changes the role they already have
+func (s *Service) ShareNote(ctx context.Context, userID, id string, req entities.ShareReq) (entities.Share, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ShareNote")
+	defer span.End()
+
+	if req.Role == entities.RoleOwner || roleRanks[req.Role] == 0 {
+		return entities.Share{}, fmt.Errorf("%w: role must be viewer, commenter or editor", ErrInvalidShare)
+	}
+	if s.users == nil {
+		return entities.Share{}, errors.New("sharing notes needs WithUsers")
+	}
+	note, err := s.access(ctx, userID, id, false, entities.RoleOwner)
+	if err != nil {
+		return entities.Share{}, err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	collaborator, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, users.ErrNotFound) {
+			return entities.Share{}, fmt.Errorf("%w: no user is registered with %s", ErrInvalidShare, email)
+		}
+		return entities.Share{}, err
+	}
+	if collaborator.ID == userID {
+		return entities.Share{}, fmt.Errorf("%w: the note already belongs to you", ErrInvalidShare)
+	}
+	span.SetAttributes(attribute.String("role", req.Role))
+
+	share, err := s.store.ShareNote(ctx, entities.Share{NoteID: id, UserID: collaborator.ID, Role: req.Role})
+	if err != nil {
+		return entities.Share{}, err
+	}
+	s.publishTo(ctx, entities.EventNoteCreated, note, []string{collaborator.ID})
+	share.Email = collaborator.Email
+	return share, nil
+}
+
+// UnshareNote takes the note away from the collaborator. The owner can remove anyone,
+// while collaborators can only remove themselves.
+func (s *Service) UnshareNote(ctx context.Context, userID, id, collaboratorID string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.UnshareNote")
+	defer span.End()
+
+	role := entities.RoleOwner
+	if collaboratorID == userID {
+		role = entities.RoleViewer
+	}
+	note, err := s.access(ctx, userID, id, false, role)
+	if err != nil {
+		return err
+	}
+	if err := s.store.UnshareNote(ctx, id, collaboratorID); err != nil {
+		return err
+	}
+	s.publishTo(ctx, entities.EventNoteDeleted, note, []string{collaboratorID})
+	return nil
+}
+
+// GetRole returns the note and the user's role on it, RoleOwner for its owner
+// or the role it is shared with them with
+func (s *Service) GetRole(ctx context.Context, userID, id string) (entities.Note, string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetRole")
+	defer span.End()
+
+	note, err := s.store.GetNote(ctx, id)
+	if err != nil {
+		return entities.Note{}, "", err
+	}
+	if note.UserID == userID {
+		return note, entities.RoleOwner, nil
+	}
+	share, err := s.store.GetShare(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, ErrShareNotFound) {
+			return entities.Note{}, "", ErrNotFound
+		}
+		return entities.Note{}, "", err
+	}
+	return note, share.Role, nil
+}
+
+// access returns the note if the user owns it or it is shared with them with at least
+// the given role. Notes the user cannot see are reported as ErrNotFound so their
+// existence is not revealed, while a role too low for the action is ErrForbidden.
+func (s *Service) access(ctx context.Context, userID, id string, withDeleted bool, role string) (entities.Note, error) {
+	var (
+		note entities.Note
+		err  error
+	)
+	if withDeleted {
+		note, err = s.store.GetNoteWithDeleted(ctx, id)
+	} else {
+		note, err = s.store.GetNote(ctx, id)
+	}
+	if err != nil {
+		return entities.Note{}, err
+	}
+	if note.UserID == userID {
+		return note, nil
+	}
+
+	share, err := s.store.GetShare(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, ErrShareNotFound) {
+			return entities.Note{}, ErrNotFound
+		}
+		return entities.Note{}, err
+	}
+	if roleRanks[share.Role] < roleRanks[role] {
+		return entities.Note{}, fmt.Errorf("%w: a %s of the note cannot do this", ErrForbidden, share.Role)
+	}
+	return note, nil
+}
+
+// withEmail sets the email of the collaborator when the service can look users up
+func (s *Service) withEmail(ctx context.Context, share *entities.Share) error {
+	if s.users == nil {
+		return nil
+	}
+	user, err := s.users.GetUser(ctx, share.UserID)
+	if err != nil {
+		if errors.Is(err, users.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	share.Email = user.Email
+	return nil
+}
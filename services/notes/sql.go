@@ -0,0 +1,1239 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"notes/repositories"
+	"notes/services/entities"
+)
+
+type dialect int
+
+const (
+	mysqlDialect dialect = iota
+	sqliteDialect
+)
+
+// sqlStore stores notes through the sqlc repository. The queries are kept
+// portable so the same store serves both MySQL and sqlite.
+type sqlStore struct {
+	db         *sql.DB
+	dialect    dialect
+	repository *repositories.Queries
+	// tx is set on the store WithTx hands out, every query then runs in it
+	tx *sql.Tx
+}
+
+// NewMySQLStore returns a Store backed by a MySQL database
+func NewMySQLStore(db *sql.DB) Store {
+	return newSQLStore(db, mysqlDialect)
+}
+
+// NewSQLiteStore returns a Store backed by an embedded sqlite database
+func NewSQLiteStore(db *sql.DB) Store {
+	return newSQLStore(db, sqliteDialect)
+}
+
+func newSQLStore(db *sql.DB, d dialect) *sqlStore {
+	return &sqlStore{
+		db:         db,
+		dialect:    d,
+		repository: repositories.New(db),
+	}
+}
+
+func (s *sqlStore) WithTx(ctx context.Context, fn func(store Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	store := &sqlStore{
+		db:         s.db,
+		dialect:    s.dialect,
+		repository: s.repository.WithTx(tx),
+		tx:         tx,
+	}
+	if err := fn(store); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) CreateNote(ctx context.Context, note entities.Note) (entities.Note, error) {
+	err := s.withTx(ctx, func(q *repositories.Queries) error {
+		seq, err := nextSeq(ctx, q, note.UserID)
+		if err != nil {
+			return err
+		}
+		err = q.CreateNote(ctx, repositories.CreateNoteParams{
+			NoteID:      note.ID,
+			Title:       note.Title,
+			Content:     note.Content,
+			ContentType: note.ContentType,
+			NotebookID:  nullString(note.NotebookID),
+			UserID:      note.UserID,
+			Seq:         seq,
+		})
+		if err != nil {
+			return err
+		}
+		return setTags(ctx, q, note.UserID, note.ID, note.Tags)
+	})
+	if err != nil {
+		return entities.Note{}, err
+	}
+	return s.GetNote(ctx, note.ID)
+}
+
+func (s *sqlStore) GetNote(ctx context.Context, id string) (entities.Note, error) {
+	note, err := s.repository.FindNoteByNoteID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Note{}, ErrNotFound
+		}
+		return entities.Note{}, err
+	}
+	return s.withTags(ctx, toEntity(note))
+}
+
+func (s *sqlStore) GetNoteWithDeleted(ctx context.Context, id string) (entities.Note, error) {
+	note, err := s.repository.FindNoteByNoteIDWithDeleted(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Note{}, ErrNotFound
+		}
+		return entities.Note{}, err
+	}
+	return s.withTags(ctx, toEntity(note))
+}
+
+func (s *sqlStore) ListNotes(ctx context.Context, params ListParams) ([]entities.Note, error) {
+	query, args := s.listQuery(params)
+	rows, err := s.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []entities.Note
+	for rows.Next() {
+		var i repositories.Note
+		if err := rows.Scan(
+			&i.ID,
+			&i.NoteID,
+			&i.Title,
+			&i.Content,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.ContentType,
+			&i.Version,
+			&i.NotebookID,
+			&i.Seq,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, toEntity(i))
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := loadTags(ctx, s.repository, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *sqlStore) UpdateNote(ctx context.Context, note entities.Note) (entities.Note, error) {
+	err := s.withTx(ctx, func(q *repositories.Queries) error {
+		current, err := q.FindNoteByNoteID(ctx, note.ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if int(current.Version) != note.Version {
+			return ErrVersionConflict
+		}
+		seq, err := nextSeq(ctx, q, current.UserID)
+		if err != nil {
+			return err
+		}
+
+		latest, err := q.FindLatestNoteRevision(ctx, note.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		err = q.CreateNoteRevision(ctx, repositories.CreateNoteRevisionParams{
+			NoteID:      note.ID,
+			Revision:    latest + 1,
+			Title:       current.Title,
+			Content:     current.Content,
+			ContentType: current.ContentType,
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err := q.UpdateNoteByNoteID(ctx, repositories.UpdateNoteByNoteIDParams{
+			Title:       note.Title,
+			Content:     note.Content,
+			ContentType: note.ContentType,
+			NotebookID:  nullString(note.NotebookID),
+			Seq:         seq,
+			NoteID:      note.ID,
+			Version:     current.Version,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrVersionConflict
+		}
+		return setTags(ctx, q, current.UserID, note.ID, note.Tags)
+	})
+	if err != nil {
+		return entities.Note{}, err
+	}
+	return s.GetNote(ctx, note.ID)
+}
+
+func (s *sqlStore) DeleteNote(ctx context.Context, id string, version int) error {
+	return s.withTx(ctx, func(q *repositories.Queries) error {
+		note, err := q.FindNoteByNoteID(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if int(note.Version) != version {
+			return ErrVersionConflict
+		}
+		seq, err := nextSeq(ctx, q, note.UserID)
+		if err != nil {
+			return err
+		}
+		rows, err := q.DeleteNoteByNoteID(ctx, repositories.DeleteNoteByNoteIDParams{
+			Seq:     seq,
+			NoteID:  id,
+			Version: int32(version),
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrVersionConflict
+		}
+		return nil
+	})
+}
+
+const listNotes = `SELECT id, note_id, title, content, user_id, created_at, updated_at, deleted_at, content_type, version, notebook_id, seq
+FROM notes
+WHERE `
+
+var sortColumns = map[string]string{
+	SortCreatedAt: "created_at",
+	SortUpdatedAt: "updated_at",
+	SortTitle:     "title",
+}
+
+func (s *sqlStore) RestoreNote(ctx context.Context, id string) (entities.Note, error) {
+	err := s.withTx(ctx, func(q *repositories.Queries) error {
+		note, err := q.FindNoteByNoteIDWithDeleted(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		seq, err := nextSeq(ctx, q, note.UserID)
+		if err != nil {
+			return err
+		}
+		rows, err := q.RestoreNoteByNoteID(ctx, repositories.RestoreNoteByNoteIDParams{Seq: seq, NoteID: id})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return entities.Note{}, err
+	}
+	return s.GetNote(ctx, id)
+}
+
+func (s *sqlStore) PurgeNote(ctx context.Context, id string, version int) error {
+	return s.withTx(ctx, func(q *repositories.Queries) error {
+		note, err := q.FindNoteByNoteIDWithDeleted(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+		rows, err := q.PurgeNoteByNoteID(ctx, repositories.PurgeNoteByNoteIDParams{
+			NoteID:  id,
+			Version: int32(version),
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrVersionConflict
+		}
+		if err := q.DeleteNoteRevisions(ctx, id); err != nil {
+			return err
+		}
+		if err := q.DeleteNoteShares(ctx, id); err != nil {
+			return err
+		}
+		if err := q.DeletePublicLinks(ctx, id); err != nil {
+			return err
+		}
+		if err := q.DeleteComments(ctx, id); err != nil {
+			return err
+		}
+		// a note purged out of the trash was last seen with its own seq, a live one takes a new one
+		horizon := note.Seq
+		if !note.DeletedAt.Valid {
+			if horizon, err = nextSeq(ctx, q, note.UserID); err != nil {
+				return err
+			}
+		}
+		if err := q.RaisePurgedSeq(ctx, repositories.RaisePurgedSeqParams{PurgedSeq: horizon, UserID: note.UserID}); err != nil {
+			return err
+		}
+		return setTags(ctx, q, note.UserID, id, nil)
+	})
+}
+
+func (s *sqlStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	deletedAt := sql.NullTime{Time: before.UTC(), Valid: true}
+	var purged int64
+	err := s.withTx(ctx, func(q *repositories.Queries) error {
+		horizons, err := q.FindDeletedNoteSeqs(ctx, deletedAt)
+		if err != nil {
+			return err
+		}
+		for _, horizon := range horizons {
+			err := q.RaisePurgedSeq(ctx, repositories.RaisePurgedSeqParams{PurgedSeq: horizon.Seq, UserID: horizon.UserID})
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := q.PurgeDeletedNoteRevisions(ctx, deletedAt); err != nil {
+			return err
+		}
+		// tags left without notes are dropped on the next write of their owner
+		if err := q.PurgeDeletedNoteTags(ctx, deletedAt); err != nil {
+			return err
+		}
+		if err := q.PurgeDeletedNoteShares(ctx, deletedAt); err != nil {
+			return err
+		}
+		if err := q.PurgeDeletedPublicLinks(ctx, deletedAt); err != nil {
+			return err
+		}
+		if err := q.PurgeDeletedNoteComments(ctx, deletedAt); err != nil {
+			return err
+		}
+		if err := q.PurgeDeletedComments(ctx, deletedAt); err != nil {
+			return err
+		}
+		purged, err = q.PurgeDeletedNotes(ctx, deletedAt)
+		return err
+	})
+	return purged, err
+}
+
+func (s *sqlStore) ListChanges(ctx context.Context, userID string, since int64) ([]entities.Note, error) {
+	rows, err := s.repository.FindNoteChanges(ctx, repositories.FindNoteChangesParams{UserID: userID, Seq: since})
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]entities.Note, 0, len(rows))
+	for _, row := range rows {
+		notes = append(notes, toEntity(row))
+	}
+	if err := loadTags(ctx, s.repository, notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func (s *sqlStore) GetSequence(ctx context.Context, userID string) (Sequence, error) {
+	sequence, err := s.repository.FindNoteSequence(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Sequence{}, nil
+		}
+		return Sequence{}, err
+	}
+	return Sequence{Seq: sequence.Seq, Purged: sequence.PurgedSeq}, nil
+}
+
+func (s *sqlStore) ShareNote(ctx context.Context, share entities.Share) (entities.Share, error) {
+	var stored repositories.NoteShare
+	err := s.withTx(ctx, func(q *repositories.Queries) error {
+		rows, err := q.UpdateNoteShare(ctx, repositories.UpdateNoteShareParams{
+			Role:   share.Role,
+			NoteID: share.NoteID,
+			UserID: share.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			err = q.CreateNoteShare(ctx, repositories.CreateNoteShareParams{
+				NoteID: share.NoteID,
+				UserID: share.UserID,
+				Role:   share.Role,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		stored, err = q.FindNoteShare(ctx, repositories.FindNoteShareParams{NoteID: share.NoteID, UserID: share.UserID})
+		return err
+	})
+	if err != nil {
+		return entities.Share{}, err
+	}
+	return toShare(stored), nil
+}
+
+func (s *sqlStore) GetShare(ctx context.Context, noteID, userID string) (entities.Share, error) {
+	share, err := s.repository.FindNoteShare(ctx, repositories.FindNoteShareParams{NoteID: noteID, UserID: userID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Share{}, ErrShareNotFound
+		}
+		return entities.Share{}, err
+	}
+	return toShare(share), nil
+}
+
+func (s *sqlStore) ListShares(ctx context.Context, noteID string) ([]entities.Share, error) {
+	rows, err := s.repository.FindNoteShares(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]entities.Share, 0, len(rows))
+	for _, row := range rows {
+		shares = append(shares, toShare(row))
+	}
+	return shares, nil
+}
+
+func (s *sqlStore) UnshareNote(ctx context.Context, noteID, userID string) error {
+	rows, err := s.repository.DeleteNoteShare(ctx, repositories.DeleteNoteShareParams{NoteID: noteID, UserID: userID})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateLink(ctx context.Context, link entities.Link) (entities.Link, error) {
+	params := repositories.CreatePublicLinkParams{
+		LinkID:       link.ID,
+		NoteID:       link.NoteID,
+		TokenHash:    link.TokenHash,
+		PasswordHash: nullString(link.PasswordHash),
+	}
+	if link.ExpiresAt != nil {
+		params.ExpiresAt = sql.NullTime{Time: link.ExpiresAt.UTC(), Valid: true}
+	}
+	if link.MaxViews != nil {
+		params.MaxViews = sql.NullInt32{Int32: int32(*link.MaxViews), Valid: true}
+	}
+	if err := s.repository.CreatePublicLink(ctx, params); err != nil {
+		return entities.Link{}, err
+	}
+
+	created, err := s.repository.FindPublicLink(ctx, link.ID)
+	if err != nil {
+		return entities.Link{}, err
+	}
+	return toLink(created), nil
+}
+
+func (s *sqlStore) GetLinkByHash(ctx context.Context, hash string) (entities.Link, error) {
+	link, err := s.repository.FindPublicLinkByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Link{}, ErrLinkNotFound
+		}
+		return entities.Link{}, err
+	}
+	return toLink(link), nil
+}
+
+func (s *sqlStore) ListLinks(ctx context.Context, noteID string) ([]entities.Link, error) {
+	rows, err := s.repository.FindPublicLinksByNoteID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]entities.Link, 0, len(rows))
+	for _, row := range rows {
+		links = append(links, toLink(row))
+	}
+	return links, nil
+}
+
+func (s *sqlStore) ViewLink(ctx context.Context, id string) error {
+	rows, err := s.repository.ViewPublicLink(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteLink(ctx context.Context, noteID, id string) error {
+	rows, err := s.repository.DeletePublicLink(ctx, repositories.DeletePublicLinkParams{LinkID: id, NoteID: noteID})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateComment(ctx context.Context, comment entities.Comment) (entities.Comment, error) {
+	err := s.repository.CreateComment(ctx, repositories.CreateCommentParams{
+		CommentID: comment.ID,
+		NoteID:    comment.NoteID,
+		UserID:    comment.UserID,
+		ParentID:  nullString(comment.ParentID),
+		Body:      comment.Body,
+	})
+	if err != nil {
+		return entities.Comment{}, err
+	}
+	return s.GetComment(ctx, comment.ID)
+}
+
+func (s *sqlStore) GetComment(ctx context.Context, id string) (entities.Comment, error) {
+	comment, err := s.repository.FindComment(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Comment{}, ErrCommentNotFound
+		}
+		return entities.Comment{}, err
+	}
+	return toComment(comment), nil
+}
+
+func (s *sqlStore) ListComments(ctx context.Context, noteID string) ([]entities.Comment, error) {
+	rows, err := s.repository.FindCommentsByNoteID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	comments := make([]entities.Comment, 0, len(rows))
+	for _, row := range rows {
+		comments = append(comments, toComment(row))
+	}
+	return comments, nil
+}
+
+func (s *sqlStore) CountComments(ctx context.Context, noteIDs []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(noteIDs) == 0 {
+		return counts, nil
+	}
+	rows, err := s.repository.CountCommentsByNoteIDs(ctx, noteIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.NoteID] = int(row.Comments)
+	}
+	return counts, nil
+}
+
+func (s *sqlStore) UpdateComment(ctx context.Context, comment entities.Comment) (entities.Comment, error) {
+	rows, err := s.repository.UpdateComment(ctx, repositories.UpdateCommentParams{
+		Body:      comment.Body,
+		Resolved:  comment.Resolved,
+		CommentID: comment.ID,
+	})
+	if err != nil {
+		return entities.Comment{}, err
+	}
+	if rows == 0 {
+		return entities.Comment{}, ErrCommentNotFound
+	}
+	return s.GetComment(ctx, comment.ID)
+}
+
+func (s *sqlStore) DeleteComment(ctx context.Context, id string) error {
+	rows, err := s.repository.DeleteComment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) ListRevisions(ctx context.Context, id string) ([]entities.Revision, error) {
+	rows, err := s.repository.FindNoteRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]entities.Revision, 0, len(rows))
+	for _, r := range rows {
+		revisions = append(revisions, entities.Revision{
+			Revision:    int(r.Revision),
+			NoteID:      r.NoteID,
+			Title:       r.Title,
+			ContentType: r.ContentType,
+			CreatedAt:   r.CreatedAt.Time,
+		})
+	}
+	return revisions, nil
+}
+
+func (s *sqlStore) GetRevision(ctx context.Context, id string, revision int) (entities.Revision, error) {
+	r, err := s.repository.FindNoteRevision(ctx, repositories.FindNoteRevisionParams{
+		NoteID:   id,
+		Revision: int32(revision),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Revision{}, ErrNotFound
+		}
+		return entities.Revision{}, err
+	}
+	return entities.Revision{
+		Revision:    int(r.Revision),
+		NoteID:      r.NoteID,
+		Title:       r.Title,
+		Content:     r.Content,
+		ContentType: r.ContentType,
+		CreatedAt:   r.CreatedAt.Time,
+	}, nil
+}
+
+func (s *sqlStore) PruneRevisions(ctx context.Context, id string, keep int) (int64, error) {
+	latest, err := s.repository.FindLatestNoteRevision(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if int(latest) <= keep {
+		return 0, nil
+	}
+	return s.repository.PruneNoteRevisions(ctx, repositories.PruneNoteRevisionsParams{
+		NoteID:   id,
+		Revision: latest - int32(keep),
+	})
+}
+
+func (s *sqlStore) AddOutbox(ctx context.Context, event entities.OutboxEvent) error {
+	return s.repository.CreateOutboxEvent(ctx, repositories.CreateOutboxEventParams{
+		EventID:   event.EventID,
+		UserID:    event.UserID,
+		EventType: event.Type,
+		Payload:   event.Payload,
+	})
+}
+
+func (s *sqlStore) ListOutbox(ctx context.Context, limit int) ([]entities.OutboxEvent, error) {
+	rows, err := s.repository.FindOutboxEvents(ctx, int32(limit))
+	if err != nil {
+		return nil, err
+	}
+	events := make([]entities.OutboxEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, entities.OutboxEvent{
+			ID:        row.ID,
+			EventID:   row.EventID,
+			UserID:    row.UserID,
+			Type:      row.EventType,
+			Payload:   row.Payload,
+			CreatedAt: row.CreatedAt.Time,
+		})
+	}
+	return events, nil
+}
+
+func (s *sqlStore) DeleteOutbox(ctx context.Context, id int64) error {
+	return s.repository.DeleteOutboxEvent(ctx, id)
+}
+
+func (s *sqlStore) ListTags(ctx context.Context, userID string) ([]entities.Tag, error) {
+	rows, err := s.repository.FindTagCounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]entities.Tag, 0, len(rows))
+	for _, row := range rows {
+		tags = append(tags, entities.Tag{Name: row.Name, Notes: int(row.Notes)})
+	}
+	return tags, nil
+}
+
+func (s *sqlStore) RenameTag(ctx context.Context, userID, from, to string) error {
+	return s.withTx(ctx, func(q *repositories.Queries) error {
+		tag, err := findTag(ctx, q, userID, from)
+		if err != nil {
+			return err
+		}
+		if from == to {
+			return nil
+		}
+		if _, err := findTag(ctx, q, userID, to); err == nil {
+			return ErrTagExists
+		} else if !errors.Is(err, ErrTagNotFound) {
+			return err
+		}
+
+		if err := q.RenameTag(ctx, repositories.RenameTagParams{Name: to, ID: tag.ID}); err != nil {
+			return err
+		}
+		seq, err := nextSeq(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		return q.TouchNotesByTagID(ctx, repositories.TouchNotesByTagIDParams{Seq: seq, TagID: tag.ID})
+	})
+}
+
+func (s *sqlStore) MergeTags(ctx context.Context, userID string, from []string, into string) error {
+	return s.withTx(ctx, func(q *repositories.Queries) error {
+		target, err := tagID(ctx, q, userID, into)
+		if err != nil {
+			return err
+		}
+		seq, err := nextSeq(ctx, q, userID)
+		if err != nil {
+			return err
+		}
+		for _, name := range from {
+			if name == into {
+				continue
+			}
+			tag, err := findTag(ctx, q, userID, name)
+			if err != nil {
+				return err
+			}
+			// the notes are touched while they still carry the tag being merged away
+			if err := q.TouchNotesByTagID(ctx, repositories.TouchNotesByTagIDParams{Seq: seq, TagID: tag.ID}); err != nil {
+				return err
+			}
+			if err := q.MergeNoteTags(ctx, repositories.MergeNoteTagsParams{IntoID: target, FromID: tag.ID}); err != nil {
+				return err
+			}
+			if err := q.DeleteNoteTagsByTagID(ctx, tag.ID); err != nil {
+				return err
+			}
+			if err := q.DeleteTag(ctx, tag.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) CreateNotebook(ctx context.Context, notebook entities.Notebook) (entities.Notebook, error) {
+	err := s.repository.CreateNotebook(ctx, repositories.CreateNotebookParams{
+		NotebookID: notebook.ID,
+		UserID:     notebook.UserID,
+		ParentID:   nullString(notebook.ParentID),
+		Name:       notebook.Name,
+	})
+	if err != nil {
+		return entities.Notebook{}, err
+	}
+	return s.GetNotebook(ctx, notebook.ID)
+}
+
+func (s *sqlStore) GetNotebook(ctx context.Context, id string) (entities.Notebook, error) {
+	notebook, err := s.repository.FindNotebookByNotebookID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Notebook{}, ErrNotebookNotFound
+		}
+		return entities.Notebook{}, err
+	}
+	return toNotebook(notebook), nil
+}
+
+func (s *sqlStore) ListNotebooks(ctx context.Context, userID string) ([]entities.Notebook, error) {
+	rows, err := s.repository.FindNotebooksByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	notebooks := make([]entities.Notebook, 0, len(rows))
+	for _, row := range rows {
+		notebooks = append(notebooks, toNotebook(row))
+	}
+	return notebooks, nil
+}
+
+func (s *sqlStore) UpdateNotebook(ctx context.Context, notebook entities.Notebook) (entities.Notebook, error) {
+	rows, err := s.repository.UpdateNotebook(ctx, repositories.UpdateNotebookParams{
+		Name:       notebook.Name,
+		ParentID:   nullString(notebook.ParentID),
+		NotebookID: notebook.ID,
+	})
+	if err != nil {
+		return entities.Notebook{}, err
+	}
+	if rows == 0 {
+		return entities.Notebook{}, ErrNotebookNotFound
+	}
+	return s.GetNotebook(ctx, notebook.ID)
+}
+
+func (s *sqlStore) DeleteNotebook(ctx context.Context, id string) error {
+	return s.withTx(ctx, func(q *repositories.Queries) error {
+		notebook, err := q.FindNotebookByNotebookID(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotebookNotFound
+			}
+			return err
+		}
+		seq, err := nextSeq(ctx, q, notebook.UserID)
+		if err != nil {
+			return err
+		}
+		err = q.MoveNotesToNotebook(ctx, repositories.MoveNotesToNotebookParams{
+			ToNotebookID:   notebook.ParentID,
+			Seq:            seq,
+			FromNotebookID: nullString(id),
+		})
+		if err != nil {
+			return err
+		}
+		err = q.ReparentNotebooks(ctx, repositories.ReparentNotebooksParams{
+			ToParentID:   notebook.ParentID,
+			FromParentID: nullString(id),
+		})
+		if err != nil {
+			return err
+		}
+		return q.DeleteNotebooks(ctx, []string{id})
+	})
+}
+
+func (s *sqlStore) TrashNotebooks(ctx context.Context, ids []string) error {
+	notebookIDs := make([]sql.NullString, 0, len(ids))
+	for _, id := range ids {
+		notebookIDs = append(notebookIDs, nullString(id))
+	}
+	return s.withTx(ctx, func(q *repositories.Queries) error {
+		if len(ids) == 0 {
+			return nil
+		}
+		// the notebooks are a subtree, so they all belong to the same user
+		notebook, err := q.FindNotebookByNotebookID(ctx, ids[0])
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotebookNotFound
+			}
+			return err
+		}
+		seq, err := nextSeq(ctx, q, notebook.UserID)
+		if err != nil {
+			return err
+		}
+		err = q.TrashNotesByNotebookIDs(ctx, repositories.TrashNotesByNotebookIDsParams{Seq: seq, NotebookIds: notebookIDs})
+		if err != nil {
+			return err
+		}
+		return q.DeleteNotebooks(ctx, ids)
+	})
+}
+
+// withTags loads the tags of the note
+func (s *sqlStore) withTags(ctx context.Context, note entities.Note) (entities.Note, error) {
+	notes := []entities.Note{note}
+	if err := loadTags(ctx, s.repository, notes); err != nil {
+		return entities.Note{}, err
+	}
+	return notes[0], nil
+}
+
+// loadTags sets the tags of the notes with a single query
+func loadTags(ctx context.Context, q *repositories.Queries, notes []entities.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(notes))
+	for _, note := range notes {
+		ids = append(ids, note.ID)
+	}
+	rows, err := q.FindTagsByNoteIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[string][]string, len(notes))
+	for _, row := range rows {
+		tags[row.NoteID] = append(tags[row.NoteID], row.Name)
+	}
+	for i := range notes {
+		notes[i].Tags = tags[notes[i].ID]
+		if notes[i].Tags == nil {
+			notes[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// setTags replaces the tags of the note, creating the user's tags it does not have yet
+// and dropping the ones no note carries any more
+func setTags(ctx context.Context, q *repositories.Queries, userID, noteID string, tags []string) error {
+	if err := q.DeleteNoteTags(ctx, noteID); err != nil {
+		return err
+	}
+	for _, name := range tags {
+		id, err := tagID(ctx, q, userID, name)
+		if err != nil {
+			return err
+		}
+		if err := q.CreateNoteTag(ctx, repositories.CreateNoteTagParams{NoteID: noteID, TagID: id}); err != nil {
+			return err
+		}
+	}
+	return q.DeleteUnusedTags(ctx, userID)
+}
+
+// findTag returns the user's tag with the given name or ErrTagNotFound
+func findTag(ctx context.Context, q *repositories.Queries, userID, name string) (repositories.Tag, error) {
+	tag, err := q.FindTagByName(ctx, repositories.FindTagByNameParams{UserID: userID, Name: name})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repositories.Tag{}, fmt.Errorf("%w: %s", ErrTagNotFound, name)
+		}
+		return repositories.Tag{}, err
+	}
+	return tag, nil
+}
+
+// tagID returns the id of the user's tag with the given name, creating it when needed
+func tagID(ctx context.Context, q *repositories.Queries, userID, name string) (int64, error) {
+	tag, err := findTag(ctx, q, userID, name)
+	if err == nil {
+		return tag.ID, nil
+	}
+	if !errors.Is(err, ErrTagNotFound) {
+		return 0, err
+	}
+	return q.CreateTag(ctx, repositories.CreateTagParams{UserID: userID, Name: name})
+}
+
+// withTx runs fn with queries bound to a transaction, which is committed when fn succeeds.
+// Inside WithTx fn joins the transaction of the store instead.
+func (s *sqlStore) withTx(ctx context.Context, fn func(q *repositories.Queries) error) error {
+	if s.tx != nil {
+		return fn(s.repository)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(s.repository.WithTx(tx)); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// conn is what queries built outside of the repository run on
+func (s *sqlStore) conn() repositories.DBTX {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// nextSeq takes the user's next change sequence number. Incrementing the counter locks
+// its row until the transaction ends, so the user's changes commit in sequence order.
+func nextSeq(ctx context.Context, q *repositories.Queries, userID string) (int64, error) {
+	rows, err := q.IncrementNoteSequence(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if rows == 0 {
+		return 1, q.CreateNoteSequence(ctx, userID)
+	}
+	sequence, err := q.FindNoteSequence(ctx, userID)
+	return sequence.Seq, err
+}
+
+// Batches streams every note outside the trash from db to fn, size notes at
+// a time in row order, so the whole table is never held in memory. It is
+// used to rebuild the search index.
+func Batches(ctx context.Context, db *sql.DB, size int, fn func([]entities.Note) error) error {
+	repository := repositories.New(db)
+	return batches(ctx, repository, size, fn, func(lastID int64) ([]repositories.Note, error) {
+		return repository.FindLiveNotesAfterID(ctx, repositories.FindLiveNotesAfterIDParams{
+			ID:    lastID,
+			Limit: int32(size),
+		})
+	})
+}
+
+// ChangedSince streams the notes updated, moved to the trash or restored since
+// the given time like Batches, deleted ones included. It catches the search
+// index up with the writes made while it was being rebuilt.
+func ChangedSince(ctx context.Context, db *sql.DB, since time.Time, size int, fn func([]entities.Note) error) error {
+	repository := repositories.New(db)
+	return batches(ctx, repository, size, fn, func(lastID int64) ([]repositories.Note, error) {
+		return repository.FindNotesChangedAfterID(ctx, repositories.FindNotesChangedAfterIDParams{
+			ID:    lastID,
+			Since: sql.NullTime{Time: since.UTC(), Valid: true},
+			Limit: int32(size),
+		})
+	})
+}
+
+// batches pages through the rows returned by next after the last row id, until a page is not full
+func batches(ctx context.Context, repository *repositories.Queries, size int, fn func([]entities.Note) error, next func(lastID int64) ([]repositories.Note, error)) error {
+	var lastID int64
+	for {
+		rows, err := next(lastID)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		batch := make([]entities.Note, 0, len(rows))
+		for _, row := range rows {
+			batch = append(batch, toEntity(row))
+		}
+		if err := loadTags(ctx, repository, batch); err != nil {
+			return err
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(rows) < size {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// listQuery builds the keyset query for ListNotes. sqlc cannot generate
+// dynamic filters and ordering, so it is put together here.
+func (s *sqlStore) listQuery(params ListParams) (string, []any) {
+	var (
+		b    strings.Builder
+		args []any
+	)
+	b.WriteString(listNotes)
+	if params.Deleted {
+		b.WriteString("deleted_at IS NOT NULL")
+	} else {
+		b.WriteString("deleted_at IS NULL")
+	}
+
+	if params.UserID != "" {
+		b.WriteString("\n  AND user_id = ?")
+		args = append(args, params.UserID)
+	}
+	if params.SharedWith != "" {
+		b.WriteString("\n  AND note_id IN (SELECT note_id FROM note_shares WHERE user_id = ?)")
+		args = append(args, params.SharedWith)
+	}
+	if params.TitlePrefix != "" {
+		b.WriteString("\n  AND title LIKE ? ESCAPE '!'")
+		args = append(args, likePrefix(params.TitlePrefix))
+	}
+	if len(params.Tags) > 0 {
+		b.WriteString("\n  AND note_id IN (SELECT note_tags.note_id\n    FROM note_tags\n    JOIN tags ON tags.id = note_tags.tag_id\n    WHERE tags.name IN (")
+		for i, tag := range params.Tags {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("?")
+			args = append(args, tag)
+		}
+		b.WriteString(")")
+		if params.UserID != "" {
+			b.WriteString("\n      AND tags.user_id = ?")
+			args = append(args, params.UserID)
+		}
+		b.WriteString("\n    GROUP BY note_tags.note_id")
+		if params.TagMode != TagModeAny {
+			b.WriteString("\n    HAVING COUNT(*) = ?")
+			args = append(args, len(params.Tags))
+		}
+		b.WriteString(")")
+	}
+	if len(params.NotebookIDs) > 0 {
+		b.WriteString("\n  AND notebook_id IN (")
+		for i, id := range params.NotebookIDs {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("?")
+			args = append(args, id)
+		}
+		b.WriteString(")")
+	}
+	if !params.CreatedAfter.IsZero() {
+		b.WriteString("\n  AND created_at >= ?")
+		args = append(args, s.timeArg(params.CreatedAfter))
+	}
+	if !params.CreatedBefore.IsZero() {
+		b.WriteString("\n  AND created_at < ?")
+		args = append(args, s.timeArg(params.CreatedBefore))
+	}
+	if !params.UpdatedAfter.IsZero() {
+		b.WriteString("\n  AND updated_at >= ?")
+		args = append(args, s.timeArg(params.UpdatedAfter))
+	}
+	if !params.UpdatedBefore.IsZero() {
+		b.WriteString("\n  AND updated_at < ?")
+		args = append(args, s.timeArg(params.UpdatedBefore))
+	}
+
+	column := sortColumns[params.Sort]
+	op, direction := ">", "ASC"
+	if params.Order == OrderDesc {
+		op, direction = "<", "DESC"
+	}
+	if params.After != nil {
+		value := s.timeArg(params.After.Time)
+		if params.Sort == SortTitle {
+			value = params.After.Title
+		}
+		fmt.Fprintf(&b, "\n  AND (%[1]s %[2]s ? OR (%[1]s = ? AND note_id %[2]s ?))", column, op)
+		args = append(args, value, value, params.After.ID)
+	}
+
+	fmt.Fprintf(&b, "\nORDER BY %[1]s %[2]s, note_id %[2]s\nLIMIT ?", column, direction)
+	args = append(args, params.Limit)
+	return b.String(), args
+}
+
+// timeArg converts a time to a query argument. sqlite keeps timestamps as
+// text, so they are formatted the way CURRENT_TIMESTAMP writes them.
+func (s *sqlStore) timeArg(t time.Time) any {
+	if s.dialect == sqliteDialect {
+		return t.UTC().Format(time.DateTime)
+	}
+	return t
+}
+
+// likePrefix escapes the LIKE wildcards in the prefix, using ! as the escape character
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	return r.Replace(prefix) + "%"
+}
+
+func toEntity(n repositories.Note) entities.Note {
+	note := entities.Note{
+		ID:          n.NoteID,
+		UserID:      n.UserID,
+		Title:       n.Title,
+		Content:     n.Content,
+		ContentType: n.ContentType,
+		NotebookID:  n.NotebookID.String,
+		Version:     int(n.Version),
+		Seq:         n.Seq,
+		CreatedAt:   n.CreatedAt.Time,
+		UpdatedAt:   n.UpdatedAt.Time,
+	}
+	if n.DeletedAt.Valid {
+		note.DeletedAt = &n.DeletedAt.Time
+	}
+	return note
+}
+
+func toNotebook(n repositories.Notebook) entities.Notebook {
+	return entities.Notebook{
+		ID:        n.NotebookID,
+		UserID:    n.UserID,
+		ParentID:  n.ParentID.String,
+		Name:      n.Name,
+		CreatedAt: n.CreatedAt.Time,
+		UpdatedAt: n.UpdatedAt.Time,
+	}
+}
+
+func toShare(s repositories.NoteShare) entities.Share {
+	return entities.Share{
+		NoteID:    s.NoteID,
+		UserID:    s.UserID,
+		Role:      s.Role,
+		CreatedAt: s.CreatedAt.Time,
+		UpdatedAt: s.UpdatedAt.Time,
+	}
+}
+
+func toLink(l repositories.PublicLink) entities.Link {
+	link := entities.Link{
+		ID:           l.LinkID,
+		NoteID:       l.NoteID,
+		TokenHash:    l.TokenHash,
+		PasswordHash: l.PasswordHash.String,
+		Protected:    l.PasswordHash.Valid,
+		Views:        int(l.Views),
+		CreatedAt:    l.CreatedAt.Time,
+	}
+	if l.ExpiresAt.Valid {
+		link.ExpiresAt = &l.ExpiresAt.Time
+	}
+	if l.MaxViews.Valid {
+		maxViews := int(l.MaxViews.Int32)
+		link.MaxViews = &maxViews
+	}
+	return link
+}
+
+func toComment(c repositories.Comment) entities.Comment {
+	comment := entities.Comment{
+		ID:        c.CommentID,
+		NoteID:    c.NoteID,
+		UserID:    c.UserID,
+		ParentID:  c.ParentID.String,
+		Body:      c.Body,
+		Resolved:  c.Resolved,
+		CreatedAt: c.CreatedAt.Time,
+		UpdatedAt: c.UpdatedAt.Time,
+	}
+	if c.DeletedAt.Valid {
+		comment.DeletedAt = &c.DeletedAt.Time
+	}
+	return comment
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,118 @@
+package notes
+
+import (
+	"context"
+	"time"
+
+	"notes/services/entities"
+)
+
+// Sequence is where a user's change sequence stands
+type Sequence struct {
+	// Seq is the sequence number of the user's last change
+	Seq int64
+	// Purged is the highest sequence number a permanently removed note of the user had,
+	// changes since an older one may have missed the removal
+	Purged int64
+}
+
+// Store persists notes for the service. Every write to a note gives it the next number
+// of its owner's change sequence, notes written together share one.
+type Store interface {
+	// WithTx runs fn with a store whose reads and writes all happen in one transaction,
+	// committed when fn succeeds and rolled back otherwise
+	WithTx(ctx context.Context, fn func(store Store) error) error
+	// CreateNote stores the given note and returns the stored copy
+	CreateNote(ctx context.Context, note entities.Note) (entities.Note, error)
+	// GetNote returns the note with the given id or ErrNotFound if it does not exist or is deleted
+	GetNote(ctx context.Context, id string) (entities.Note, error)
+	// GetNoteWithDeleted returns the note with the given id even if it is in the trash
+	GetNoteWithDeleted(ctx context.Context, id string) (entities.Note, error)
+	// ListNotes returns up to params.Limit notes that have not been deleted, or only the deleted
+	// ones when params.Deleted is set, ordered by params.Sort and the note id and starting after params.After.
+	// Notes are returned with their tags, as are the notes of every other method.
+	ListNotes(ctx context.Context, params ListParams) ([]entities.Note, error)
+	// UpdateNote replaces the title, content and tags of an existing note, keeping the
+	// previous version as a new revision in the same transaction. It fails with
+	// ErrVersionConflict unless note.Version is the stored version, which it increments.
+	UpdateNote(ctx context.Context, note entities.Note) (entities.Note, error)
+	// DeleteNote moves the note with the given id and version to the trash
+	DeleteNote(ctx context.Context, id string, version int) error
+	// RestoreNote takes the note with the given id out of the trash
+	RestoreNote(ctx context.Context, id string) (entities.Note, error)
+	// PurgeNote permanently removes the note with the given id and version with its revisions,
+	// shares, links and comments, whether it is in the trash or not
+	PurgeNote(ctx context.Context, id string, version int) error
+	// PurgeDeleted permanently removes the notes deleted before the given time with their revisions, shares,
+	// links and comments, as well as the comments deleted before then, and returns how many notes were removed
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	// ListChanges returns the user's notes, including those in the trash, written since the
+	// given sequence number, in sequence order
+	ListChanges(ctx context.Context, userID string, since int64) ([]entities.Note, error)
+	// GetSequence returns where the user's change sequence stands, zero before their first change
+	GetSequence(ctx context.Context, userID string) (Sequence, error)
+	// ListTags returns the user's tags in name order with the number of notes outside the trash that carry them
+	ListTags(ctx context.Context, userID string) ([]entities.Tag, error)
+	// RenameTag renames the user's tag on all of their notes in one transaction. It fails with
+	// ErrTagNotFound when the user has no such tag and ErrTagExists when the new name is taken.
+	RenameTag(ctx context.Context, userID, from, to string) error
+	// MergeTags replaces the from tags with into on all of the user's notes in one transaction,
+	// failing with ErrTagNotFound when the user does not have one of the from tags
+	MergeTags(ctx context.Context, userID string, from []string, into string) error
+	// CreateNotebook stores the given notebook and returns the stored copy
+	CreateNotebook(ctx context.Context, notebook entities.Notebook) (entities.Notebook, error)
+	// GetNotebook returns the notebook with the given id or ErrNotebookNotFound if it does not exist
+	GetNotebook(ctx context.Context, id string) (entities.Notebook, error)
+	// ListNotebooks returns all of the user's notebooks ordered by name
+	ListNotebooks(ctx context.Context, userID string) ([]entities.Notebook, error)
+	// UpdateNotebook replaces the name and parent of an existing notebook
+	UpdateNotebook(ctx context.Context, notebook entities.Notebook) (entities.Notebook, error)
+	// DeleteNotebook removes the notebook with the given id in one transaction, moving its notes,
+	// including those in the trash, and its child notebooks into its parent. The moved notes get a new version.
+	DeleteNotebook(ctx context.Context, id string) error
+	// TrashNotebooks removes the notebooks with the given ids in one transaction, taking their
+	// notes out of them and moving those that are not in the trash yet there
+	TrashNotebooks(ctx context.Context, ids []string) error
+	// ShareNote gives share.UserID share.Role on the note, replacing the role they had, and returns the stored share
+	ShareNote(ctx context.Context, share entities.Share) (entities.Share, error)
+	// GetShare returns the role of the user on the note or ErrShareNotFound if it is not shared with them
+	GetShare(ctx context.Context, noteID, userID string) (entities.Share, error)
+	// ListShares returns the users the note is shared with in the order it was shared with them
+	ListShares(ctx context.Context, noteID string) ([]entities.Share, error)
+	// UnshareNote takes the note away from the user or fails with ErrShareNotFound if it is not shared with them
+	UnshareNote(ctx context.Context, noteID, userID string) error
+	// CreateLink stores a new public link to the note and returns the stored link
+	CreateLink(ctx context.Context, link entities.Link) (entities.Link, error)
+	// GetLinkByHash returns the link with the given token hash or ErrLinkNotFound
+	GetLinkByHash(ctx context.Context, hash string) (entities.Link, error)
+	// ListLinks returns the public links to the note, oldest first
+	ListLinks(ctx context.Context, noteID string) ([]entities.Link, error)
+	// ViewLink counts a view of the link, failing with ErrLinkNotFound once it has used up its views
+	ViewLink(ctx context.Context, id string) error
+	// DeleteLink removes the public link to the note or fails with ErrLinkNotFound
+	DeleteLink(ctx context.Context, noteID, id string) error
+	// CreateComment stores a new comment and returns the stored comment
+	CreateComment(ctx context.Context, comment entities.Comment) (entities.Comment, error)
+	// GetComment returns the comment with the given id or ErrCommentNotFound if it does not exist or was deleted
+	GetComment(ctx context.Context, id string) (entities.Comment, error)
+	// ListComments returns the comments on the note that were not deleted, oldest first
+	ListComments(ctx context.Context, noteID string) ([]entities.Comment, error)
+	// CountComments returns the number of comments that were not deleted on each of the notes, notes without any are left out
+	CountComments(ctx context.Context, noteIDs []string) (map[string]int, error)
+	// UpdateComment replaces the body and resolved flag of the comment or fails with ErrCommentNotFound
+	UpdateComment(ctx context.Context, comment entities.Comment) (entities.Comment, error)
+	// DeleteComment marks the comment and its replies as deleted or fails with ErrCommentNotFound
+	DeleteComment(ctx context.Context, id string) error
+	// ListRevisions returns the revisions of the note without their content, newest first
+	ListRevisions(ctx context.Context, id string) ([]entities.Revision, error)
+	// GetRevision returns the given revision of the note or ErrNotFound if it is not kept
+	GetRevision(ctx context.Context, id string, revision int) (entities.Revision, error)
+	// PruneRevisions removes all but the newest keep revisions of the note and returns how many were removed
+	PruneRevisions(ctx context.Context, id string, keep int) (int64, error)
+	// AddOutbox stores an event for the webhooks, in the transaction of the change it is about when run in WithTx
+	AddOutbox(ctx context.Context, event entities.OutboxEvent) error
+	// ListOutbox returns up to limit events waiting in the outbox, oldest first
+	ListOutbox(ctx context.Context, limit int) ([]entities.OutboxEvent, error)
+	// DeleteOutbox removes the event from the outbox once it was handed to the webhooks
+	DeleteOutbox(ctx context.Context, id int64) error
+}
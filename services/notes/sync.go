@@ -0,0 +1,355 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrInvalidSync is returned when a sync request is invalid
+var ErrInvalidSync = errors.New("invalid sync")
+
+// conflictSuffix is appended to the title of conflict copies
+const conflictSuffix = " (conflict copy)"
+
+// syncWrite is a note written by a sync, whose side effects wait for the transaction to commit
+type syncWrite struct {
+	eventType string
+	note      entities.Note
+}
+
+// GetChanges returns the user's notes that changed since the given sequence number, those in the
+// trash as tombstones. A zero since, or one the changes are no longer known since, returns every
+// note outside the trash instead. Notes shared with the user are not part of their changes.
+func (s *Service) GetChanges(ctx context.Context, userID string, since int64) (entities.SyncChanges, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.GetChanges")
+	defer span.End()
+
+	if since < 0 {
+		return entities.SyncChanges{}, fmt.Errorf("%w: since must not be negative", ErrInvalidSync)
+	}
+
+	changes := entities.SyncChanges{Notes: []entities.Note{}, Deleted: []entities.Tombstone{}}
+	// read in one transaction, so the notes are the changes up to the sequence number returned
+	err := s.store.WithTx(ctx, func(store Store) error {
+		sequence, err := store.GetSequence(ctx, userID)
+		if err != nil {
+			return err
+		}
+		changes.Seq = sequence.Seq
+		// notes purged after the client's sequence number would otherwise go unnoticed
+		full := since == 0 || since < sequence.Purged || since > sequence.Seq
+		changes.Reset = full && since > 0
+		if full {
+			since = 0
+		}
+
+		notes, err := store.ListChanges(ctx, userID, since)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			switch {
+			case note.DeletedAt == nil:
+				changes.Notes = append(changes.Notes, note)
+			case !full:
+				changes.Deleted = append(changes.Deleted, entities.Tombstone{
+					ID:        note.ID,
+					Version:   note.Version,
+					Seq:       note.Seq,
+					DeletedAt: *note.DeletedAt,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return entities.SyncChanges{}, err
+	}
+	span.SetAttributes(attribute.Bool("reset", changes.Reset), attribute.Int("notes", len(changes.Notes)))
+	return changes, nil
+}
+
+// Sync applies the changes a client made while offline in one transaction, so either all or none
+// of them are written. A change based on the stored version of its note, or to a note that does not
+// exist yet, is applied as it is. Otherwise the note changed on both sides and the newest change wins,
+// the content of the other side is kept as a conflict copy owned by the user if it differs.
+// Changes to notes the user cannot see or change fail the whole batch, as they would on their own.
+func (s *Service) Sync(ctx context.Context, userID string, req entities.SyncReq) (entities.SyncResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.Sync")
+	defer span.End()
+
+	if err := validateSync(req); err != nil {
+		return entities.SyncResponse{}, err
+	}
+	span.SetAttributes(attribute.Int("changes", len(req.Changes)))
+
+	var (
+		response entities.SyncResponse
+		writes   []syncWrite
+	)
+	err := s.store.WithTx(ctx, func(store Store) error {
+		tx := *s
+		tx.store = store
+		response.Results = make([]entities.SyncResult, 0, len(req.Changes))
+		for _, change := range req.Changes {
+			result, written, err := tx.syncChange(ctx, userID, change)
+			if err != nil {
+				return fmt.Errorf("note %s: %w", change.ID, err)
+			}
+			for _, write := range written {
+				if err := record(ctx, store, write.eventType, write.note); err != nil {
+					return err
+				}
+			}
+			response.Results = append(response.Results, result)
+			writes = append(writes, written...)
+		}
+		return nil
+	})
+	if err != nil {
+		return entities.SyncResponse{}, err
+	}
+
+	for _, write := range writes {
+		if write.eventType == entities.EventNoteDeleted {
+			s.unindex(ctx, write.note.ID)
+		} else {
+			s.prune(ctx, write.note.ID)
+			s.index(ctx, write.note)
+		}
+		s.publish(ctx, write.eventType, write.note)
+	}
+	return response, nil
+}
+
+// syncChange applies one change, the service's store is bound to the sync's transaction
+func (s *Service) syncChange(ctx context.Context, userID string, change entities.SyncChange) (entities.SyncResult, []syncWrite, error) {
+	result := entities.SyncResult{ID: change.ID, Status: entities.SyncApplied}
+
+	existing, err := s.store.GetNoteWithDeleted(ctx, change.ID)
+	if errors.Is(err, ErrNotFound) {
+		if change.Deleted {
+			return result, nil, nil
+		}
+		note, err := s.syncCreate(ctx, userID, change)
+		if err != nil {
+			return entities.SyncResult{}, nil, err
+		}
+		result.Note = &note
+		return result, []syncWrite{{entities.EventNoteCreated, note}}, nil
+	}
+	if err != nil {
+		return entities.SyncResult{}, nil, err
+	}
+
+	// the role needed to write the change is checked once it is known the change is written
+	if _, err := s.access(ctx, userID, change.ID, true, entities.RoleViewer); err != nil {
+		return entities.SyncResult{}, nil, err
+	}
+
+	if change.Version == existing.Version {
+		note, write, err := s.syncApply(ctx, userID, existing, change)
+		if err != nil {
+			return entities.SyncResult{}, nil, err
+		}
+		result.Note = note
+		return result, write, nil
+	}
+
+	result.Status = entities.SyncConflict
+	var (
+		writes []syncWrite
+		loser  *entities.Note
+	)
+	if change.UpdatedAt.After(changedAt(existing)) {
+		result.Winner = entities.SyncWinnerClient
+		note, write, err := s.syncApply(ctx, userID, existing, change)
+		if err != nil {
+			return entities.SyncResult{}, nil, err
+		}
+		result.Note, writes = note, write
+		if existing.DeletedAt == nil && (change.Deleted || differs(existing, merge(existing, change))) {
+			loser = &existing
+		}
+	} else {
+		result.Winner = entities.SyncWinnerServer
+		if existing.DeletedAt == nil {
+			result.Note = &existing
+		}
+		merged := merge(existing, change)
+		if !change.Deleted && (existing.DeletedAt != nil || differs(existing, merged)) {
+			loser = &merged
+		}
+	}
+
+	if loser != nil {
+		conflictCopy, err := s.conflictCopy(ctx, userID, *loser)
+		if err != nil {
+			return entities.SyncResult{}, nil, err
+		}
+		result.ConflictCopy = &conflictCopy
+		writes = append(writes, syncWrite{entities.EventNoteCreated, conflictCopy})
+	}
+	return result, writes, nil
+}
+
+// syncCreate stores a note the client created offline under the id the client gave it
+func (s *Service) syncCreate(ctx context.Context, userID string, change entities.SyncChange) (entities.Note, error) {
+	now := time.Now()
+	note := merge(entities.Note{
+		ID:          change.ID,
+		UserID:      userID,
+		ContentType: entities.ContentTypePlain,
+		Tags:        []string{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, change)
+	if err := validate(note); err != nil {
+		return entities.Note{}, err
+	}
+	if err := s.checkNotebook(ctx, userID, note, note.NotebookID); err != nil {
+		return entities.Note{}, err
+	}
+	return s.store.CreateNote(ctx, note)
+}
+
+// syncApply writes the change on top of the stored note and returns the note, nil once it is in the trash
+func (s *Service) syncApply(ctx context.Context, userID string, note entities.Note, change entities.SyncChange) (*entities.Note, []syncWrite, error) {
+	// restoring or deleting a note is up to its owner, like it is online
+	role := entities.RoleEditor
+	if change.Deleted || note.DeletedAt != nil {
+		role = entities.RoleOwner
+	}
+	if _, err := s.access(ctx, userID, note.ID, true, role); err != nil {
+		return nil, nil, err
+	}
+
+	if change.Deleted {
+		if note.DeletedAt != nil {
+			return nil, nil, nil
+		}
+		if err := s.store.DeleteNote(ctx, note.ID, note.Version); err != nil {
+			return nil, nil, err
+		}
+		return nil, []syncWrite{{entities.EventNoteDeleted, note}}, nil
+	}
+
+	eventType := entities.EventNoteUpdated
+	if note.DeletedAt != nil {
+		var err error
+		if note, err = s.store.RestoreNote(ctx, note.ID); err != nil {
+			return nil, nil, err
+		}
+		eventType = entities.EventNoteCreated
+	}
+	updated := merge(note, change)
+	if !differs(note, updated) && updated.NotebookID == note.NotebookID && slices.Equal(updated.Tags, note.Tags) {
+		if eventType == entities.EventNoteUpdated {
+			return &note, nil, nil
+		}
+		return &note, []syncWrite{{eventType, note}}, nil
+	}
+	if updated.NotebookID != note.NotebookID {
+		if err := s.checkNotebook(ctx, userID, note, updated.NotebookID); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := validate(updated); err != nil {
+		return nil, nil, err
+	}
+	updated, err := s.store.UpdateNote(ctx, updated)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &updated, []syncWrite{{eventType, updated}}, nil
+}
+
+// conflictCopy saves the losing side of a conflict as a new note of the user. It stays in
+// the notebook of the original only when that belongs to the user too.
+func (s *Service) conflictCopy(ctx context.Context, userID string, loser entities.Note) (entities.Note, error) {
+	title := []rune(loser.Title)
+	if limit := entities.MaxTitleLength - utf8.RuneCountInString(conflictSuffix); len(title) > limit {
+		title = title[:limit]
+	}
+	now := time.Now()
+	note := entities.Note{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		Title:       string(title) + conflictSuffix,
+		Content:     loser.Content,
+		ContentType: loser.ContentType,
+		Tags:        loser.Tags,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if loser.UserID == userID {
+		note.NotebookID = loser.NotebookID
+	}
+	if err := validate(note); err != nil {
+		return entities.Note{}, err
+	}
+	return s.store.CreateNote(ctx, note)
+}
+
+// validateSync checks the changes before any of them is applied
+func validateSync(req entities.SyncReq) error {
+	if len(req.Changes) > entities.MaxSyncChanges {
+		return fmt.Errorf("%w: at most %d changes can be synced at once", ErrInvalidSync, entities.MaxSyncChanges)
+	}
+	seen := make(map[string]bool, len(req.Changes))
+	for _, change := range req.Changes {
+		if err := uuid.Validate(change.ID); err != nil {
+			return fmt.Errorf("%w: %s is not a valid note id", ErrInvalidSync, change.ID)
+		}
+		if seen[change.ID] {
+			return fmt.Errorf("%w: note %s is changed more than once", ErrInvalidSync, change.ID)
+		}
+		seen[change.ID] = true
+		if !change.Deleted && (change.Title == "" || change.Content == "") {
+			return fmt.Errorf("%w: title and content of note %s are required", ErrInvalidSync, change.ID)
+		}
+		if change.UpdatedAt.IsZero() {
+			return fmt.Errorf("%w: updated_at of note %s is required", ErrInvalidSync, change.ID)
+		}
+	}
+	return nil
+}
+
+// merge returns the note with the fields the change sets, leaving the others as they are
+func merge(note entities.Note, change entities.SyncChange) entities.Note {
+	note.Title = change.Title
+	note.Content = change.Content
+	if change.ContentType != "" {
+		note.ContentType = change.ContentType
+	}
+	if change.NotebookID != "" {
+		note.NotebookID = change.NotebookID
+	}
+	if change.Tags != nil {
+		note.Tags = normalizeTags(change.Tags)
+	}
+	return note
+}
+
+// differs reports whether the notes have different content
+func differs(a, b entities.Note) bool {
+	return a.Title != b.Title || a.Content != b.Content || a.ContentType != b.ContentType
+}
+
+// changedAt is when the stored note last changed, which is when it was deleted for notes in the trash
+func changedAt(note entities.Note) time.Time {
+	if note.DeletedAt != nil && note.DeletedAt.After(note.UpdatedAt) {
+		return *note.DeletedAt
+	}
+	return note.UpdatedAt
+}
@@ -0,0 +1,141 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	// ErrTagNotFound is returned when the user has no tag with the given name
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrTagExists is returned when renaming a tag to the name of another of the user's tags
+	ErrTagExists = errors.New("tag already exists")
+)
+
+// ListTags returns the user's tags in name order with the number of notes outside the trash that carry them
+func (s *Service) ListTags(ctx context.Context, userID string) ([]entities.Tag, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ListTags")
+	defer span.End()
+
+	tags, err := s.store.ListTags(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		tags = []entities.Tag{}
+	}
+	return tags, nil
+}
+
+// RenameTag renames one of the user's tags on all of their notes at once
+func (s *Service) RenameTag(ctx context.Context, userID, name string, req entities.TagRename) (entities.Tag, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.RenameTag")
+	defer span.End()
+
+	from, to := normalizeTag(name), normalizeTag(req.Name)
+	if err := validateTags([]string{to}); err != nil {
+		return entities.Tag{}, err
+	}
+	return s.retag(ctx, userID, to, func(store Store) error {
+		return store.RenameTag(ctx, userID, from, to)
+	})
+}
+
+// MergeTags replaces the From tags with the Into tag on all of the user's notes at once.
+// Into is created when the user does not have it yet.
+func (s *Service) MergeTags(ctx context.Context, userID string, req entities.TagMerge) (entities.Tag, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.MergeTags")
+	defer span.End()
+
+	into := normalizeTag(req.Into)
+	if err := validateTags([]string{into}); err != nil {
+		return entities.Tag{}, err
+	}
+	from := normalizeTags(req.From)
+	if len(from) == 0 {
+		return entities.Tag{}, fmt.Errorf("%w: from needs at least one tag", ErrInvalidNote)
+	}
+	span.SetAttributes(attribute.Int("merged", len(from)))
+	return s.retag(ctx, userID, into, func(store Store) error {
+		return store.MergeTags(ctx, userID, from, into)
+	})
+}
+
+// retag renames or merges tags with change, recording an event for each note that carries
+// the tag afterwards in the same transaction. It then refreshes the search index for those
+// notes, announces their change and returns the tag with its count.
+func (s *Service) retag(ctx context.Context, userID, name string, change func(store Store) error) (entities.Tag, error) {
+	var retagged []entities.Note
+	err := s.store.WithTx(ctx, func(store Store) error {
+		if err := change(store); err != nil {
+			return err
+		}
+		tx := *s
+		tx.store = store
+		params := ListParams{UserID: userID, Tags: []string{name}, TagMode: TagModeAll, Sort: SortCreatedAt, Order: OrderAsc, Limit: maxLimit}
+		return tx.eachNote(ctx, params, func(note entities.Note) error {
+			retagged = append(retagged, note)
+			return record(ctx, store, entities.EventNoteUpdated, note)
+		})
+	})
+	if err != nil {
+		return entities.Tag{}, err
+	}
+	for _, note := range retagged {
+		s.index(ctx, note)
+		s.publish(ctx, entities.EventNoteUpdated, note)
+	}
+
+	tags, err := s.store.ListTags(ctx, userID)
+	if err != nil {
+		return entities.Tag{}, err
+	}
+	for _, tag := range tags {
+		if tag.Name == name {
+			return tag, nil
+		}
+	}
+	return entities.Tag{Name: name}, nil
+}
+
+// normalizeTag trims and lowercases a tag name so tags match regardless of case
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// normalizeTags normalizes the tag names, drops the duplicates and sorts them
+func normalizeTags(names []string) []string {
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, normalizeTag(name))
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// validateTags checks normalized tags fit the tags table
+func validateTags(tags []string) error {
+	if len(tags) > entities.MaxTags {
+		return fmt.Errorf("%w: a note can have at most %d tags", ErrInvalidNote, entities.MaxTags)
+	}
+	for _, tag := range tags {
+		switch {
+		case tag == "":
+			return fmt.Errorf("%w: tags cannot be empty", ErrInvalidNote)
+		case !utf8.ValidString(tag):
+			return fmt.Errorf("%w: tags must be valid UTF-8", ErrInvalidNote)
+		case utf8.RuneCountInString(tag) > entities.MaxTagLength:
+			return fmt.Errorf("%w: tags must be at most %d characters", ErrInvalidNote, entities.MaxTagLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package render
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// FormatHTML renders notes to sanitized HTML
+const FormatHTML = "html"
+
+// ErrUnsupportedFormat is returned when a note is rendered to an unknown format
+var ErrUnsupportedFormat = errors.New("unsupported render format")
+
+// Renderer renders note bodies according to their content type. The output
+// is cached per note and invalidated when the note is updated.
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+
+	mu      sync.Mutex
+	size    int
+	budget  int
+	bytes   int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type entry struct {
+	id        string
+	version   int
+	updatedAt time.Time
+	html      string
+}
+
+// New returns a Renderer that caches the output of up to cacheSize notes, as long as it
+// adds up to at most cacheBytes. Outputs larger than cacheBytes are not cached.
+func New(cacheSize, cacheBytes int) *Renderer {
+	return &Renderer{
+		markdown: goldmark.New(
+			// GFM, with table alignment as attributes since the sanitizer drops style on cells
+			goldmark.WithExtensions(
+				extension.Linkify,
+				extension.Strikethrough,
+				extension.TaskList,
+				extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+				highlighting.NewHighlighting(
+					highlighting.WithStyle("github"),
+					highlighting.WithFormatOptions(chromahtml.WithLineNumbers(false)),
+				),
+			),
+		),
+		policy:  policy(),
+		size:    cacheSize,
+		budget:  cacheBytes,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// policy allows the user generated content bluemonday considers safe, plus
+// what the task list and highlighting extensions produce
+func policy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowStyles("color", "background-color", "font-weight", "font-style", "text-decoration").OnElements("span", "pre", "code")
+	return p
+}
+
+// Render returns the note body in the given format, an empty format means HTML
+func (r *Renderer) Render(ctx context.Context, note entities.Note, format string) (string, error) {
+	_, span := tracing.Tracer().Start(ctx, "render.Render")
+	defer span.End()
+
+	if format != "" && format != FormatHTML {
+		return "", fmt.Errorf("%w: %q, only html is supported", ErrUnsupportedFormat, format)
+	}
+
+	cached, ok := r.cached(note)
+	span.SetAttributes(attribute.Bool("cached", ok), attribute.String("content_type", note.ContentType))
+	if ok {
+		return cached, nil
+	}
+
+	var out string
+	if note.ContentType == entities.ContentTypeMarkdown {
+		var buf bytes.Buffer
+		if err := r.markdown.Convert([]byte(note.Content), &buf); err != nil {
+			return "", err
+		}
+		out = r.policy.Sanitize(buf.String())
+	} else {
+		out = "<pre>" + html.EscapeString(note.Content) + "</pre>"
+	}
+
+	r.store(note, out)
+	return out, nil
+}
+
+func (r *Renderer) cached(note entities.Note) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	el, ok := r.entries[note.ID]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*entry)
+	// updated_at is stored to the second, so two edits in the same second only differ in version
+	if e.version != note.Version || !e.updatedAt.Equal(note.UpdatedAt) {
+		return "", false
+	}
+	r.lru.MoveToFront(el)
+	return e.html, true
+}
+
+func (r *Renderer) store(note entities.Note, out string) {
+	// highlighted HTML is several times the size of the note, so a single large note could take the whole budget
+	if r.size <= 0 || len(out) > r.budget {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := &entry{id: note.ID, version: note.Version, updatedAt: note.UpdatedAt, html: out}
+	// an entry for an older version of the note is replaced
+	if el, ok := r.entries[note.ID]; ok {
+		r.bytes -= len(el.Value.(*entry).html)
+		el.Value = e
+		r.lru.MoveToFront(el)
+	} else {
+		r.entries[note.ID] = r.lru.PushFront(e)
+	}
+	r.bytes += len(out)
+	for r.lru.Len() > r.size || r.bytes > r.budget {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		evicted := oldest.Value.(*entry)
+		delete(r.entries, evicted.id)
+		r.bytes -= len(evicted.html)
+	}
+}
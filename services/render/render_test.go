@@ -0,0 +1,153 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+
+	"github.com/stretchr/testify/require"
+)
+
+func markdown(content string) entities.Note {
+	return entities.Note{
+		ID:          "note",
+		Content:     content,
+		ContentType: entities.ContentTypeMarkdown,
+		Version:     1,
+		UpdatedAt:   time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRender_Markdown(t *testing.T) {
+	r := New(10, 1<<20)
+	out, err := r.Render(t.Context(), markdown("# Plan\n\n"+
+		"| task | owner |\n|:-----|------:|\n| ship | me |\n\n"+
+		"- [x] write\n- [ ] review\n\n"+
+		"~~dropped~~ https://example.com\n\n"+
+		"```go\nfunc main() {}\n```\n"), FormatHTML)
+	require.NoError(t, err)
+
+	require.Contains(t, out, "<h1>Plan</h1>")
+	require.Contains(t, out, `<th align="left">task</th>`)
+	require.Contains(t, out, `<td align="right">me</td>`)
+	require.Contains(t, out, `<input checked="" disabled="" type="checkbox"> write`)
+	require.Contains(t, out, `<input disabled="" type="checkbox"> review`)
+	require.Contains(t, out, "<del>dropped</del>")
+	require.Contains(t, out, `<a href="https://example.com" rel="nofollow">`)
+	// the go keyword is highlighted with an inline color
+	require.Regexp(t, `<span style="color: #[0-9a-f]+">func</span>`, out)
+}
+
+func TestRender_Sanitizes(t *testing.T) {
+	r := New(10, 1<<20)
+	tests := map[string]string{
+		"script":         "<script>alert(1)</script>",
+		"event handler":  `<img src="x" onerror="alert(1)">`,
+		"javascript url": "[click](javascript:alert(1))",
+		"iframe":         `<iframe src="https://evil.example"></iframe>`,
+		"style":          `<span style="background:url(javascript:alert(1))">x</span>`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := r.Render(t.Context(), markdown(content), FormatHTML)
+			require.NoError(t, err)
+			for _, bad := range []string{"<script", "onerror", "javascript:", "<iframe", "url("} {
+				require.NotContains(t, out, bad)
+			}
+		})
+	}
+}
+
+func TestRender_Plain(t *testing.T) {
+	out, err := New(10, 1<<20).Render(t.Context(), entities.Note{
+		ID:          "plain",
+		Content:     "# not a heading\n<b>not bold</b>",
+		ContentType: entities.ContentTypePlain,
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, "<pre># not a heading\n&lt;b&gt;not bold&lt;/b&gt;</pre>", out)
+}
+
+func TestRender_UnsupportedFormat(t *testing.T) {
+	_, err := New(10, 1<<20).Render(t.Context(), markdown("text"), "pdf")
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestRender_Cache(t *testing.T) {
+	r := New(2, 1<<20)
+	note := markdown("first")
+	out, err := r.Render(t.Context(), note, FormatHTML)
+	require.NoError(t, err)
+	require.Contains(t, out, "first")
+
+	// the same version is served from the cache, even though the content passed differs
+	note.Content = "changed without a new version"
+	out, err = r.Render(t.Context(), note, FormatHTML)
+	require.NoError(t, err)
+	require.Contains(t, out, "first")
+
+	// a new version renders again and replaces the entry, even within the second updated_at is stored to
+	note.Version++
+	out, err = r.Render(t.Context(), note, FormatHTML)
+	require.NoError(t, err)
+	require.Contains(t, out, "changed")
+	require.Equal(t, 1, r.lru.Len())
+
+	note.Content = "changed again"
+	note.UpdatedAt = note.UpdatedAt.Add(time.Second)
+	out, err = r.Render(t.Context(), note, FormatHTML)
+	require.NoError(t, err)
+	require.Contains(t, out, "again")
+
+	// the least recently used note is evicted
+	for _, id := range []string{"a", "b"} {
+		other := markdown(id)
+		other.ID = id
+		_, err := r.Render(t.Context(), other, FormatHTML)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, r.lru.Len())
+	require.NotContains(t, r.entries, note.ID)
+}
+
+func TestRender_CacheBytes(t *testing.T) {
+	a := markdown(strings.Repeat("a", 100))
+	a.ID = "a"
+	b := markdown(strings.Repeat("b", 100))
+	b.ID = "b"
+	r := New(10, 150)
+	out, err := r.Render(t.Context(), a, FormatHTML)
+	require.NoError(t, err)
+	size := len(out)
+
+	// the least recently used note is evicted once the outputs exceed the budget, not only the count
+	_, err = r.Render(t.Context(), b, FormatHTML)
+	require.NoError(t, err)
+	require.Equal(t, 1, r.lru.Len())
+	require.NotContains(t, r.entries, a.ID)
+	require.Equal(t, size, r.bytes)
+
+	// an output larger than the whole budget is not cached at all
+	large := markdown(strings.Repeat("x", 200))
+	large.ID = "large"
+	_, err = r.Render(t.Context(), large, FormatHTML)
+	require.NoError(t, err)
+	require.NotContains(t, r.entries, large.ID)
+	require.Contains(t, r.entries, b.ID)
+
+	// replacing an entry accounts for the size of the output it replaces
+	b.Version++
+	b.Content = "short"
+	out, err = r.Render(t.Context(), b, FormatHTML)
+	require.NoError(t, err)
+	require.Equal(t, len(out), r.bytes)
+}
+
+func TestRender_NoCache(t *testing.T) {
+	r := New(0, 1<<20)
+	_, err := r.Render(t.Context(), markdown(strings.Repeat("x", 10)), FormatHTML)
+	require.NoError(t, err)
+	require.Zero(t, r.lru.Len())
+}
@@ -0,0 +1,341 @@
+package search
+
+import (
+	"context"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"notes/services/entities"
+)
+
+const (
+	fieldTitle   = "title"
+	fieldContent = "content"
+
+	// BM25 parameters, the same defaults Elasticsearch uses
+	k1 = 1.2
+	b  = 0.75
+
+	titleBoost   = 2
+	fragmentSize = 100
+	maxFragments = 3
+)
+
+var fields = []string{fieldTitle, fieldContent}
+
+type document struct {
+	note   entities.Note
+	terms  map[string]map[string]int // field -> term -> frequency
+	length map[string]int            // field -> number of terms
+}
+
+type memoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]struct{} // term -> ids of the notes containing it
+	lengths  map[string]int                 // field -> total terms across all notes
+}
+
+// NewMemoryIndex returns an in-process inverted index, scored with BM25
+func NewMemoryIndex() Index {
+	return &memoryIndex{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]struct{}),
+		lengths:  make(map[string]int),
+	}
+}
+
+func (m *memoryIndex) IndexNote(_ context.Context, note entities.Note) error {
+	doc := &document{
+		note:   note,
+		terms:  make(map[string]map[string]int),
+		length: make(map[string]int),
+	}
+	for field, text := range map[string]string{fieldTitle: note.Title, fieldContent: note.Content} {
+		doc.terms[field] = make(map[string]int)
+		for _, tok := range tokenize(text) {
+			doc.terms[field][tok.term]++
+			doc.length[field]++
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(note.ID)
+	m.docs[note.ID] = doc
+	for _, field := range fields {
+		m.lengths[field] += doc.length[field]
+		for term := range doc.terms[field] {
+			if m.postings[term] == nil {
+				m.postings[term] = make(map[string]struct{})
+			}
+			m.postings[term][note.ID] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (m *memoryIndex) DeleteNote(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(id)
+	return nil
+}
+
+// remove drops a note from the index, the caller must hold the write lock
+func (m *memoryIndex) remove(id string) {
+	doc, ok := m.docs[id]
+	if !ok {
+		return
+	}
+	for _, field := range fields {
+		m.lengths[field] -= doc.length[field]
+		for term := range doc.terms[field] {
+			delete(m.postings[term], id)
+			if len(m.postings[term]) == 0 {
+				delete(m.postings, term)
+			}
+		}
+	}
+	delete(m.docs, id)
+}
+
+func (m *memoryIndex) Search(_ context.Context, query string, filters Filters) (entities.SearchResults, error) {
+	clauses := ParseQuery(query, filters.Mode)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := float64(len(m.docs))
+	scores := make(map[string]float64)
+	for _, c := range clauses {
+		if c.Excluded {
+			continue
+		}
+		for _, term := range m.terms(c) {
+			ids := m.postings[term]
+			df := float64(len(ids))
+			idf := math.Log(1 + (total-df+0.5)/(df+0.5))
+			for id := range ids {
+				doc := m.docs[id]
+				if !matchesFilters(doc.note, filters) {
+					continue
+				}
+				for _, field := range fields {
+					tf := float64(doc.terms[field][term])
+					if tf == 0 {
+						continue
+					}
+					avg := float64(m.lengths[field]) / total
+					norm := tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.length[field])/avg))
+					score := idf * norm
+					if field == fieldTitle {
+						score *= titleBoost
+					}
+					scores[id] += score
+				}
+			}
+		}
+	}
+
+	match := matcher(clauses)
+	hits := make([]entities.SearchHit, 0, len(scores))
+	for id, score := range scores {
+		doc := m.docs[id]
+		if !doc.satisfies(clauses) {
+			continue
+		}
+		hits = append(hits, entities.SearchHit{
+			Note:       doc.note,
+			Score:      score,
+			Highlights: highlights(doc.note, match),
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Note.ID < hits[j].Note.ID
+	})
+
+	results := entities.SearchResults{Total: len(hits)}
+	if filters.Limit > 0 && len(hits) > filters.Limit {
+		hits = hits[:filters.Limit]
+	}
+	results.Hits = hits
+	return results, nil
+}
+
+// terms returns the indexed terms a clause matches, the caller must hold the read lock
+func (m *memoryIndex) terms(c Clause) []string {
+	if !c.Prefix {
+		if _, ok := m.postings[c.Term]; ok {
+			return []string{c.Term}
+		}
+		return nil
+	}
+	var terms []string
+	for term := range m.postings {
+		if c.matches(term) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// satisfies reports whether the document contains every required clause and none of the excluded ones
+func (d *document) satisfies(clauses []Clause) bool {
+	for _, c := range clauses {
+		if !c.Required && !c.Excluded {
+			continue
+		}
+		if d.contains(c) == c.Excluded {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *document) contains(c Clause) bool {
+	for _, field := range fields {
+		for term := range d.terms[field] {
+			if c.matches(term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesFilters(note entities.Note, filters Filters) bool {
+	if filters.UserID != "" && note.UserID != filters.UserID {
+		return false
+	}
+	if !filters.CreatedAfter.IsZero() && note.CreatedAt.Before(filters.CreatedAfter) {
+		return false
+	}
+	if !filters.CreatedBefore.IsZero() && !note.CreatedAt.Before(filters.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lower cased runs of letters and digits,
+// keeping the byte offsets of each run for highlighting
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+func highlights(note entities.Note, match func(string) bool) map[string][]string {
+	result := make(map[string][]string)
+	if fragments := highlight(note.Title, match, false); len(fragments) > 0 {
+		result[fieldTitle] = fragments
+	}
+	if fragments := highlight(note.Content, match, true); len(fragments) > 0 {
+		result[fieldContent] = fragments
+	}
+	return result
+}
+
+// highlight returns the HTML escaped fragments of text that contain a
+// matching term, with each match wrapped in <em> tags. Unless fragmented the
+// whole text is returned as a single fragment.
+func highlight(text string, match func(string) bool, fragmented bool) []string {
+	tokens := tokenize(text)
+	var matches []token
+	for _, tok := range tokens {
+		if match(tok.term) {
+			matches = append(matches, tok)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	if !fragmented {
+		return []string{render(text, tokens, match, 0, len(text))}
+	}
+
+	type window struct{ start, end int }
+	var windows []window
+	for _, match := range matches {
+		start, end := match.start-fragmentSize/2, match.end+fragmentSize/2
+		// snap the window to token boundaries so no word is cut in half
+		if start <= 0 {
+			start = 0
+		} else {
+			for _, tok := range tokens {
+				if tok.start >= start {
+					start = tok.start
+					break
+				}
+			}
+		}
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			for i := len(tokens) - 1; i >= 0; i-- {
+				if tokens[i].end <= end {
+					end = tokens[i].end
+					break
+				}
+			}
+		}
+		if n := len(windows); n > 0 && start <= windows[n-1].end {
+			windows[n-1].end = max(windows[n-1].end, end)
+			continue
+		}
+		windows = append(windows, window{start, end})
+	}
+
+	var fragments []string
+	for _, w := range windows {
+		if len(fragments) == maxFragments {
+			break
+		}
+		fragments = append(fragments, strings.TrimSpace(render(text, tokens, match, w.start, w.end)))
+	}
+	return fragments
+}
+
+func render(text string, tokens []token, match func(string) bool, start, end int) string {
+	var sb strings.Builder
+	pos := start
+	for _, tok := range tokens {
+		if tok.start < start || tok.end > end || !match(tok.term) {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[pos:tok.start]))
+		sb.WriteString("<em>")
+		sb.WriteString(html.EscapeString(text[tok.start:tok.end]))
+		sb.WriteString("</em>")
+		pos = tok.end
+	}
+	sb.WriteString(html.EscapeString(text[pos:end]))
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+
+	"notes/repositories"
+	"notes/services/entities"
+)
+
+type mysqlIndex struct {
+	repository *repositories.Queries
+}
+
+// NewMySQLIndex returns an Index that searches the notes table through its
+// FULLTEXT index. MySQL keeps the index in step with the table itself, so
+// indexing and deleting are no-ops.
+func NewMySQLIndex(db *sql.DB) Index {
+	return &mysqlIndex{
+		repository: repositories.New(db),
+	}
+}
+
+func (m *mysqlIndex) IndexNote(context.Context, entities.Note) error {
+	return nil
+}
+
+func (m *mysqlIndex) DeleteNote(context.Context, string) error {
+	return nil
+}
+
+func (m *mysqlIndex) Search(ctx context.Context, query string, filters Filters) (entities.SearchResults, error) {
+	limit := filters.Limit
+	if limit == 0 {
+		limit = maxLimit
+	}
+	createdAfter := sql.NullTime{Time: filters.CreatedAfter, Valid: !filters.CreatedAfter.IsZero()}
+	createdBefore := sql.NullTime{Time: filters.CreatedBefore, Valid: !filters.CreatedBefore.IsZero()}
+
+	var rows []repositories.SearchNotesNaturalRow
+	if filters.Mode == ModeBoolean {
+		boolean, err := m.repository.SearchNotesBoolean(ctx, repositories.SearchNotesBooleanParams{
+			Query:         query,
+			UserID:        filters.UserID,
+			CreatedAfter:  createdAfter,
+			CreatedBefore: createdBefore,
+			Limit:         int32(limit),
+		})
+		if err != nil {
+			return entities.SearchResults{}, err
+		}
+		for _, row := range boolean {
+			rows = append(rows, repositories.SearchNotesNaturalRow(row))
+		}
+	} else {
+		var err error
+		rows, err = m.repository.SearchNotesNatural(ctx, repositories.SearchNotesNaturalParams{
+			Query:         query,
+			UserID:        filters.UserID,
+			CreatedAfter:  createdAfter,
+			CreatedBefore: createdBefore,
+			Limit:         int32(limit),
+		})
+		if err != nil {
+			return entities.SearchResults{}, err
+		}
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.NoteID)
+	}
+	tagRows, err := m.repository.FindTagsByNoteIDs(ctx, ids)
+	if err != nil {
+		return entities.SearchResults{}, err
+	}
+	tags := make(map[string][]string, len(rows))
+	for _, row := range tagRows {
+		tags[row.NoteID] = append(tags[row.NoteID], row.Name)
+	}
+
+	// MySQL does not highlight matches, so they are found the same way as in the memory index
+	match := matcher(ParseQuery(query, filters.Mode))
+	results := entities.SearchResults{
+		Hits: make([]entities.SearchHit, 0, len(rows)),
+	}
+	for _, row := range rows {
+		results.Total = int(row.Total)
+		note := entities.Note{
+			ID:          row.NoteID,
+			UserID:      row.UserID,
+			Title:       row.Title,
+			Content:     row.Content,
+			ContentType: row.ContentType,
+			NotebookID:  row.NotebookID.String,
+			Tags:        tags[row.NoteID],
+			Version:     int(row.Version),
+			CreatedAt:   row.CreatedAt.Time,
+			UpdatedAt:   row.UpdatedAt.Time,
+		}
+		results.Hits = append(results.Hits, entities.SearchHit{
+			Note:       note,
+			Score:      row.Score,
+			Highlights: highlights(note, match),
+		})
+	}
+	return results, nil
+}
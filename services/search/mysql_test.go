@@ -0,0 +1,63 @@
+package search
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"notes/services/entities"
+	"notes/services/migrator"
+	"notes/services/notes"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMySQLIndex needs the FULLTEXT index of the MySQL database from docker-compose,
+// run it with TEST_DB_DRIVER=mysql
+func TestMySQLIndex(t *testing.T) {
+	if os.Getenv("TEST_DB_DRIVER") != "mysql" {
+		t.Skip("set TEST_DB_DRIVER=mysql to search the docker-compose database")
+	}
+	ctx := t.Context()
+	dsn := "notes_user:p@ssword@tcp(localhost:3308)/notes?parseTime=true&timeout=5s"
+	db, err := migrator.SetupDB(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	if err := migrator.Migrate(ctx, db, dsn); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		require.NoError(t, err)
+	}
+
+	service := notes.New(notes.NewMySQLStore(db))
+	userID := uuid.NewString()
+	breakfast, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "Breakfast", Content: "oatmeal with blueberries"})
+	require.NoError(t, err)
+	baking, err := service.CreateNote(ctx, userID, entities.NoteReq{Title: "Oatmeal cookies", Content: "oatmeal and raisins"})
+	require.NoError(t, err)
+	_, err = service.CreateNote(ctx, uuid.NewString(), entities.NoteReq{Title: "Oatmeal", Content: "someone else's oatmeal"})
+	require.NoError(t, err)
+
+	index := NewMySQLIndex(db)
+	results, err := index.Search(ctx, "oatmeal", Filters{UserID: userID})
+	require.NoError(t, err)
+	require.Equal(t, 2, results.Total)
+	require.Equal(t, baking.ID, results.Hits[0].Note.ID)
+	require.Greater(t, results.Hits[0].Score, results.Hits[1].Score)
+	require.Equal(t, []string{"<em>Oatmeal</em> cookies"}, results.Hits[0].Highlights["title"])
+
+	results, err = index.Search(ctx, "+oatmeal -raisins", Filters{UserID: userID, Mode: ModeBoolean})
+	require.NoError(t, err)
+	require.Len(t, results.Hits, 1)
+	require.Equal(t, breakfast.ID, results.Hits[0].Note.ID)
+
+	results, err = index.Search(ctx, "blue*", Filters{UserID: userID, Mode: ModeBoolean})
+	require.NoError(t, err)
+	require.Len(t, results.Hits, 1)
+	require.Equal(t, []string{"oatmeal with <em>blueberries</em>"}, results.Hits[0].Highlights["content"])
+
+	require.NoError(t, service.DeleteNote(ctx, userID, breakfast.ID, 0))
+	results, err = index.Search(ctx, "oatmeal", Filters{UserID: userID})
+	require.NoError(t, err)
+	require.Len(t, results.Hits, 1)
+}
@@ -0,0 +1,78 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// ModeNatural matches notes containing any of the words, ranked by relevance
+	ModeNatural = "natural"
+	// ModeBoolean supports the MySQL boolean operators: +word must be present,
+	// -word must be absent and word* matches any word starting with word
+	ModeBoolean = "boolean"
+)
+
+// Clause is a single word of a parsed query
+type Clause struct {
+	Term     string
+	Prefix   bool
+	Required bool
+	Excluded bool
+}
+
+func (c Clause) matches(term string) bool {
+	if c.Prefix {
+		return strings.HasPrefix(term, c.Term)
+	}
+	return term == c.Term
+}
+
+// ParseQuery splits the query into lower cased clauses, operators are only honoured in boolean mode
+func ParseQuery(query, mode string) []Clause {
+	var clauses []Clause
+	if mode != ModeBoolean {
+		for _, tok := range tokenize(query) {
+			clauses = append(clauses, Clause{Term: tok.term})
+		}
+		return clauses
+	}
+
+	for _, word := range strings.Fields(query) {
+		var c Clause
+		switch word[0] {
+		case '+':
+			c.Required = true
+			word = word[1:]
+		case '-':
+			c.Excluded = true
+			word = word[1:]
+		}
+		c.Prefix = strings.HasSuffix(word, "*")
+		word = strings.TrimRightFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		tokens := tokenize(word)
+		for i, tok := range tokens {
+			clause := c
+			clause.Term = tok.term
+			// only the last part of a word like e-mail* is a prefix
+			clause.Prefix = c.Prefix && i == len(tokens)-1
+			clauses = append(clauses, clause)
+		}
+	}
+	return clauses
+}
+
+// matcher reports whether a term should be highlighted for the clauses
+func matcher(clauses []Clause) func(string) bool {
+	return func(term string) bool {
+		for _, c := range clauses {
+			if !c.Excluded && c.matches(term) {
+				return true
+			}
+		}
+		return false
+	}
+}
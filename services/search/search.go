@@ -0,0 +1,93 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// ErrInvalidQuery is returned when a search query is invalid
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// Filters narrow down the notes a search matches
+type Filters struct {
+	UserID string
+	// Mode is ModeNatural or ModeBoolean, empty means ModeNatural
+	Mode          string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Limit         int
+}
+
+// Index is a full-text index of notes. It is satisfied by the in-memory
+// index in this package and by the Elasticsearch client.
+type Index interface {
+	// IndexNote adds the note to the index or replaces it
+	IndexNote(ctx context.Context, note entities.Note) error
+	// DeleteNote removes the note from the index, it is not an error if the note is not indexed
+	DeleteNote(ctx context.Context, id string) error
+	// Search returns the notes matching the query and filters, most relevant first
+	Search(ctx context.Context, query string, filters Filters) (entities.SearchResults, error)
+}
+
+// Service searches the notes of a user
+type Service struct {
+	index Index
+}
+
+// New returns a search service backed by the given index
+func New(index Index) *Service {
+	return &Service{
+		index: index,
+	}
+}
+
+// Search returns the user's notes matching the query
+func (s *Service) Search(ctx context.Context, userID string, query entities.SearchQuery) (entities.SearchResults, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.Search")
+	defer span.End()
+
+	if strings.TrimSpace(query.Query) == "" {
+		return entities.SearchResults{}, fmt.Errorf("%w: q is required", ErrInvalidQuery)
+	}
+	switch query.Mode {
+	case "", ModeNatural, ModeBoolean:
+	default:
+		return entities.SearchResults{}, fmt.Errorf("%w: mode must be natural or boolean", ErrInvalidQuery)
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if limit < 1 || limit > maxLimit {
+		return entities.SearchResults{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, maxLimit)
+	}
+
+	results, err := s.index.Search(ctx, query.Query, Filters{
+		UserID:        userID,
+		Mode:          query.Mode,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		Limit:         limit,
+	})
+	if err != nil {
+		return entities.SearchResults{}, err
+	}
+	if results.Hits == nil {
+		results.Hits = []entities.SearchHit{}
+	}
+	span.SetAttributes(attribute.Int("total", results.Total))
+	return results, nil
+}
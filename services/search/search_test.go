@@ -0,0 +1,173 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryIndex_Ranking(t *testing.T) {
+	ctx := t.Context()
+	index := NewMemoryIndex()
+	notes := []entities.Note{
+		{ID: "1", UserID: "u1", Title: "Weekend plans", Content: "hike and maybe a film"},
+		{ID: "2", UserID: "u1", Title: "Film list", Content: "films to watch this year"},
+		{ID: "3", UserID: "u1", Title: "Groceries", Content: "milk and bread"},
+		{ID: "4", UserID: "u2", Title: "Film club", Content: "film night"},
+	}
+	for _, note := range notes {
+		require.NoError(t, index.IndexNote(ctx, note))
+	}
+
+	results, err := index.Search(ctx, "FILM", Filters{UserID: "u1"})
+	require.NoError(t, err)
+	require.Equal(t, 2, results.Total)
+	// a match in the title outranks a match in the content
+	require.Equal(t, "2", results.Hits[0].Note.ID)
+	require.Equal(t, "1", results.Hits[1].Note.ID)
+	require.Greater(t, results.Hits[0].Score, results.Hits[1].Score)
+	require.Equal(t, []string{"<em>Film</em> list"}, results.Hits[0].Highlights["title"])
+	require.Equal(t, []string{"hike and maybe a <em>film</em>"}, results.Hits[1].Highlights["content"])
+
+	results, err = index.Search(ctx, "film", Filters{UserID: "u1", Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, 2, results.Total)
+	require.Len(t, results.Hits, 1)
+}
+
+func TestMemoryIndex_UpdateAndDelete(t *testing.T) {
+	ctx := t.Context()
+	index := NewMemoryIndex()
+	note := entities.Note{ID: "1", UserID: "u1", Title: "Draft", Content: "first version"}
+	require.NoError(t, index.IndexNote(ctx, note))
+
+	note.Content = "second version"
+	require.NoError(t, index.IndexNote(ctx, note))
+
+	results, err := index.Search(ctx, "first", Filters{})
+	require.NoError(t, err)
+	require.Empty(t, results.Hits)
+
+	results, err = index.Search(ctx, "second", Filters{})
+	require.NoError(t, err)
+	require.Len(t, results.Hits, 1)
+
+	require.NoError(t, index.DeleteNote(ctx, note.ID))
+	require.NoError(t, index.DeleteNote(ctx, note.ID))
+	results, err = index.Search(ctx, "second", Filters{})
+	require.NoError(t, err)
+	require.Empty(t, results.Hits)
+}
+
+func TestMemoryIndex_CreatedFilters(t *testing.T) {
+	ctx := t.Context()
+	index := NewMemoryIndex()
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c"} {
+		require.NoError(t, index.IndexNote(ctx, entities.Note{
+			ID:        id,
+			UserID:    "u1",
+			Title:     "report",
+			Content:   "report",
+			CreatedAt: day.AddDate(0, 0, i),
+		}))
+	}
+
+	results, err := index.Search(ctx, "report", Filters{
+		CreatedAfter:  day.AddDate(0, 0, 1),
+		CreatedBefore: day.AddDate(0, 0, 2),
+	})
+	require.NoError(t, err)
+	require.Len(t, results.Hits, 1)
+	require.Equal(t, "b", results.Hits[0].Note.ID)
+}
+
+func TestHighlight(t *testing.T) {
+	terms := func(term string) bool { return term == "needle" }
+
+	require.Nil(t, highlight("nothing here", terms, true))
+	require.Equal(t, []string{"a &lt;b&gt; <em>Needle</em> &amp; more"}, highlight("a <b> Needle & more", terms, true))
+
+	long := strings.Repeat("hay ", 60) + "needle " + strings.Repeat("hay ", 60) + "needle end"
+	fragments := highlight(long, terms, true)
+	require.Len(t, fragments, 2)
+	for _, fragment := range fragments {
+		require.Contains(t, fragment, "<em>needle</em>")
+		require.LessOrEqual(t, len(fragment), fragmentSize+len("<em></em>")+len("needle"))
+		require.False(t, strings.HasPrefix(fragment, "ay"), fragment)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	require.Equal(t, []Clause{{Term: "plain"}, {Term: "words"}}, ParseQuery("Plain +words", ModeNatural))
+	require.Equal(t, []Clause{
+		{Term: "milk", Required: true},
+		{Term: "bread", Excluded: true},
+		{Term: "egg", Prefix: true},
+		{Term: "e"},
+		{Term: "mail", Prefix: true},
+	}, ParseQuery("+Milk -bread egg* e-mail*", ModeBoolean))
+}
+
+func TestMemoryIndex_BooleanMode(t *testing.T) {
+	ctx := t.Context()
+	index := NewMemoryIndex()
+	for _, note := range []entities.Note{
+		{ID: "1", Title: "Breakfast", Content: "milk and eggs"},
+		{ID: "2", Title: "Baking", Content: "milk and bread"},
+		{ID: "3", Title: "Lunch", Content: "bread and eggplant"},
+	} {
+		require.NoError(t, index.IndexNote(ctx, note))
+	}
+
+	ids := func(query string) []string {
+		results, err := index.Search(ctx, query, Filters{Mode: ModeBoolean})
+		require.NoError(t, err)
+		var ids []string
+		for _, hit := range results.Hits {
+			ids = append(ids, hit.Note.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	require.Equal(t, []string{"1", "2"}, ids("+milk"))
+	require.Equal(t, []string{"1"}, ids("+milk -bread"))
+	require.Equal(t, []string{"1", "3"}, ids("egg*"))
+	require.Equal(t, []string{"3"}, ids("+bread -milk egg*"))
+	require.Empty(t, ids("-milk"))
+
+	results, err := index.Search(ctx, "egg*", Filters{Mode: ModeBoolean})
+	require.NoError(t, err)
+	for _, hit := range results.Hits {
+		require.Contains(t, hit.Highlights["content"][0], "<em>egg")
+	}
+}
+
+func TestService_Search(t *testing.T) {
+	ctx := t.Context()
+	index := NewMemoryIndex()
+	require.NoError(t, index.IndexNote(ctx, entities.Note{ID: "1", UserID: "u1", Title: "mine", Content: "shared word"}))
+	require.NoError(t, index.IndexNote(ctx, entities.Note{ID: "2", UserID: "u2", Title: "theirs", Content: "shared word"}))
+	svc := New(index)
+
+	results, err := svc.Search(ctx, "u1", entities.SearchQuery{Query: "shared"})
+	require.NoError(t, err)
+	require.Len(t, results.Hits, 1)
+	require.Equal(t, "1", results.Hits[0].Note.ID)
+
+	results, err = svc.Search(ctx, "u1", entities.SearchQuery{Query: "absent"})
+	require.NoError(t, err)
+	require.NotNil(t, results.Hits)
+
+	_, err = svc.Search(ctx, "u1", entities.SearchQuery{Query: "  "})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = svc.Search(ctx, "u1", entities.SearchQuery{Query: "shared", Limit: maxLimit + 1})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+	_, err = svc.Search(ctx, "u1", entities.SearchQuery{Query: "shared", Mode: "fuzzy"})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+}
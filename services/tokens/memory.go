@@ -0,0 +1,89 @@
+package tokens
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+)
+
+// memoryStore keeps tokens in a map, so everything is lost once the process exits.
+type memoryStore struct {
+	mu     sync.RWMutex
+	tokens map[string]entities.APIToken
+}
+
+// NewMemoryStore returns a Store that keeps tokens in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		tokens: make(map[string]entities.APIToken),
+	}
+}
+
+func (m *memoryStore) CreateToken(_ context.Context, token entities.APIToken) (entities.APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	token.Token = ""
+	m.tokens[token.ID] = token
+	return token, nil
+}
+
+func (m *memoryStore) GetTokenByHash(_ context.Context, hash string) (entities.APIToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, v := range m.tokens {
+		if v.TokenHash == hash {
+			return v, nil
+		}
+	}
+	return entities.APIToken{}, ErrNotFound
+}
+
+func (m *memoryStore) ListTokens(_ context.Context, userID string) ([]entities.APIToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.APIToken, 0)
+	for _, v := range m.tokens {
+		if v.UserID == userID {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *memoryStore) TouchToken(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	token.LastUsedAt = &now
+	m.tokens[id] = token
+	return nil
+}
+
+func (m *memoryStore) DeleteToken(_ context.Context, userID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.tokens[id]
+	if !ok || token.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.tokens, id)
+	return nil
+}
@@ -0,0 +1,107 @@
+package tokens
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"notes/repositories"
+	"notes/services/entities"
+)
+
+// sqlStore stores tokens through the sqlc repository, it works with both MySQL and sqlite
+type sqlStore struct {
+	repository *repositories.Queries
+}
+
+// NewSQLStore returns a Store backed by a MySQL or sqlite database
+func NewSQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		repository: repositories.New(db),
+	}
+}
+
+func (s *sqlStore) CreateToken(ctx context.Context, token entities.APIToken) (entities.APIToken, error) {
+	params := repositories.CreateAPITokenParams{
+		TokenID:   token.ID,
+		UserID:    token.UserID,
+		Name:      token.Name,
+		TokenHash: token.TokenHash,
+		Scopes:    strings.Join(token.Scopes, ","),
+	}
+	if token.ExpiresAt != nil {
+		params.ExpiresAt = sql.NullTime{Time: token.ExpiresAt.UTC(), Valid: true}
+	}
+
+	id, err := s.repository.CreateAPIToken(ctx, params)
+	if err != nil {
+		return entities.APIToken{}, err
+	}
+
+	created, err := s.repository.FindAPIToken(ctx, id)
+	if err != nil {
+		return entities.APIToken{}, err
+	}
+	return toEntity(created), nil
+}
+
+func (s *sqlStore) GetTokenByHash(ctx context.Context, hash string) (entities.APIToken, error) {
+	token, err := s.repository.FindAPITokenByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.APIToken{}, ErrNotFound
+		}
+		return entities.APIToken{}, err
+	}
+	return toEntity(token), nil
+}
+
+func (s *sqlStore) ListTokens(ctx context.Context, userID string) ([]entities.APIToken, error) {
+	tokens, err := s.repository.FindAPITokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.APIToken, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, toEntity(tokens[i]))
+	}
+	return result, nil
+}
+
+func (s *sqlStore) TouchToken(ctx context.Context, id string) error {
+	return s.repository.TouchAPIToken(ctx, id)
+}
+
+func (s *sqlStore) DeleteToken(ctx context.Context, userID, id string) error {
+	rows, err := s.repository.DeleteAPIToken(ctx, repositories.DeleteAPITokenParams{
+		TokenID: id,
+		UserID:  userID,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func toEntity(t repositories.ApiToken) entities.APIToken {
+	token := entities.APIToken{
+		ID:        t.TokenID,
+		UserID:    t.UserID,
+		Name:      t.Name,
+		Scopes:    strings.Split(t.Scopes, ","),
+		TokenHash: t.TokenHash,
+		CreatedAt: t.CreatedAt.Time,
+	}
+	if t.ExpiresAt.Valid {
+		token.ExpiresAt = &t.ExpiresAt.Time
+	}
+	if t.LastUsedAt.Valid {
+		token.LastUsedAt = &t.LastUsedAt.Time
+	}
+	return token
+}
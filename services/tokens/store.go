@@ -0,0 +1,21 @@
+package tokens
+
+import (
+	"context"
+
+	"notes/services/entities"
+)
+
+// Store persists API tokens
+type Store interface {
+	// CreateToken stores the given token and returns the stored copy
+	CreateToken(ctx context.Context, token entities.APIToken) (entities.APIToken, error)
+	// GetTokenByHash returns the token with the given hash or ErrNotFound
+	GetTokenByHash(ctx context.Context, hash string) (entities.APIToken, error)
+	// ListTokens returns the user's tokens, oldest first
+	ListTokens(ctx context.Context, userID string) ([]entities.APIToken, error)
+	// TouchToken records that the token with the given id was just used
+	TouchToken(ctx context.Context, id string) error
+	// DeleteToken removes the user's token with the given id or returns ErrNotFound
+	DeleteToken(ctx context.Context, userID, id string) error
+}
@@ -0,0 +1,149 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Prefix starts every API token so they can be told apart from session tokens
+	Prefix = "ntk_"
+
+	// ScopeNotesRead allows reading notes
+	ScopeNotesRead = "notes:read"
+	// ScopeNotesWrite allows creating, updating and deleting notes
+	ScopeNotesWrite = "notes:write"
+)
+
+// Scopes are all the scopes a token can be granted
+var Scopes = []string{ScopeNotesRead, ScopeNotesWrite}
+
+var (
+	// ErrNotFound is returned when a token does not exist
+	ErrNotFound = errors.New("token not found")
+	// ErrInvalidToken is returned when a token is unknown or expired
+	ErrInvalidToken = errors.New("invalid api token")
+	// ErrInvalidRequest is returned when a token request is invalid
+	ErrInvalidRequest = errors.New("invalid token request")
+)
+
+// Service issues and verifies API tokens
+type Service struct {
+	store Store
+}
+
+// New returns a tokens service backed by the given store
+func New(store Store) *Service {
+	return &Service{
+		store: store,
+	}
+}
+
+// CreateToken issues a new token for the user. The returned token is the
+// only copy of the secret, it cannot be recovered afterwards.
+func (s *Service) CreateToken(ctx context.Context, userID string, req entities.APITokenReq) (entities.APIToken, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.CreateToken")
+	defer span.End()
+
+	if strings.TrimSpace(req.Name) == "" {
+		return entities.APIToken{}, fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+	if len(req.Scopes) == 0 {
+		return entities.APIToken{}, fmt.Errorf("%w: at least one scope is required", ErrInvalidRequest)
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return entities.APIToken{}, fmt.Errorf("%w: unknown scope %q", ErrInvalidRequest, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return entities.APIToken{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidRequest)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return entities.APIToken{}, err
+	}
+	raw := Prefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	token, err := s.store.CreateToken(ctx, entities.APIToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      req.Name,
+		Scopes:    slices.Compact(scopes),
+		TokenHash: hash(raw),
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return entities.APIToken{}, err
+	}
+	token.Token = raw
+	return token, nil
+}
+
+// ListTokens returns the user's tokens without their secrets
+func (s *Service) ListTokens(ctx context.Context, userID string) ([]entities.APIToken, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ListTokens")
+	defer span.End()
+
+	return s.store.ListTokens(ctx, userID)
+}
+
+// RevokeToken deletes the user's token so it can no longer be used
+func (s *Service) RevokeToken(ctx context.Context, userID, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.RevokeToken")
+	defer span.End()
+
+	return s.store.DeleteToken(ctx, userID, id)
+}
+
+// Authenticate returns the token matching the raw secret if it has not expired
+func (s *Service) Authenticate(ctx context.Context, raw string) (entities.APIToken, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.AuthenticateToken")
+	defer span.End()
+
+	token, err := s.store.GetTokenByHash(ctx, hash(raw))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return entities.APIToken{}, ErrInvalidToken
+		}
+		return entities.APIToken{}, err
+	}
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(time.Now()) {
+		return entities.APIToken{}, ErrInvalidToken
+	}
+
+	if err := s.store.TouchToken(ctx, token.ID); err != nil {
+		slog.ErrorContext(ctx, "failed to record token usage", "token_id", token.ID, "error", err)
+	}
+	return token, nil
+}
+
+// IsAPIToken reports whether the bearer token is an API token rather than a session token
+func IsAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, Prefix)
+}
+
+// hash returns the hex encoded sha256 of the token. Tokens are random and long,
+// so a fast hash is enough and lets them be looked up directly.
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
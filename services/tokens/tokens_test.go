@@ -0,0 +1,89 @@
+package tokens
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/migrator"
+
+	"github.com/stretchr/testify/require"
+)
+
+var store Store
+
+func TestMain(m *testing.M) {
+	code := 1
+
+	db, err := migrator.SetupSQLite(context.TODO(), ":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := migrator.MigrateSQLite(context.TODO(), db); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(code)
+	}()
+	store = NewSQLStore(db)
+	code = m.Run()
+}
+
+func TestTokens(t *testing.T) {
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "sql": store} {
+		t.Run(name, func(t *testing.T) {
+			service := New(store)
+			expiresAt := time.Now().Add(time.Hour)
+			token, err := service.CreateToken(t.Context(), "user-"+name, entities.APITokenReq{
+				Name:      "ci",
+				Scopes:    []string{ScopeNotesWrite, ScopeNotesRead, ScopeNotesRead},
+				ExpiresAt: &expiresAt,
+			})
+			require.NoError(t, err)
+			require.True(t, IsAPIToken(token.Token))
+			require.Equal(t, []string{ScopeNotesRead, ScopeNotesWrite}, token.Scopes)
+			require.NotNil(t, token.ExpiresAt)
+
+			authenticated, err := service.Authenticate(t.Context(), token.Token)
+			require.NoError(t, err)
+			require.Equal(t, token.ID, authenticated.ID)
+			require.Equal(t, "user-"+name, authenticated.UserID)
+
+			list, err := service.ListTokens(t.Context(), "user-"+name)
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			require.Empty(t, list[0].Token)
+			require.NotNil(t, list[0].LastUsedAt)
+
+			require.ErrorIs(t, service.RevokeToken(t.Context(), "someone-else", token.ID), ErrNotFound)
+			require.NoError(t, service.RevokeToken(t.Context(), "user-"+name, token.ID))
+			_, err = service.Authenticate(t.Context(), token.Token)
+			require.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestCreateToken_Invalid(t *testing.T) {
+	service := New(NewMemoryStore())
+	past := time.Now().Add(-time.Hour)
+	for _, req := range []entities.APITokenReq{
+		{Name: "", Scopes: []string{ScopeNotesRead}},
+		{Name: "no scopes"},
+		{Name: "unknown scope", Scopes: []string{"admin"}},
+		{Name: "expired", Scopes: []string{ScopeNotesRead}, ExpiresAt: &past},
+	} {
+		_, err := service.CreateToken(t.Context(), "user", req)
+		require.ErrorIs(t, err, ErrInvalidRequest, req.Name)
+	}
+}
+
+func TestAuthenticate_Unknown(t *testing.T) {
+	_, err := New(NewMemoryStore()).Authenticate(t.Context(), Prefix+"unknown")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
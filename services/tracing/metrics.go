@@ -4,12 +4,21 @@ import (
 	"context"
 	"os"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	api "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 )
 
+// Meter returns the meter
+func Meter() api.Meter {
+	return otel.Meter("notes",
+		api.WithInstrumentationVersion("1.0.0"),
+	)
+}
+
 func setupMetrics(ctx context.Context) (*metric.MeterProvider, error) {
 	metricExporter, err := otlpmetrichttp.New(ctx)
 	if err != nil {
@@ -0,0 +1,61 @@
+package users
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+)
+
+// memoryStore keeps users in a map, so everything is lost once the process exits.
+type memoryStore struct {
+	mu    sync.RWMutex
+	users map[string]entities.User
+}
+
+// NewMemoryStore returns a Store that keeps users in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		users: make(map[string]entities.User),
+	}
+}
+
+func (m *memoryStore) CreateUser(_ context.Context, user entities.User) (entities.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, v := range m.users {
+		if v.Email == user.Email {
+			return entities.User{}, ErrEmailTaken
+		}
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *memoryStore) GetUser(_ context.Context, id string) (entities.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return entities.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (m *memoryStore) GetUserByEmail(_ context.Context, email string) (entities.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, v := range m.users {
+		if v.Email == email {
+			return v, nil
+		}
+	}
+	return entities.User{}, ErrNotFound
+}
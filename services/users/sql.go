@@ -0,0 +1,86 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"notes/repositories"
+	"notes/services/entities"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// sqlStore stores users through the sqlc repository, it works with both MySQL and sqlite
+type sqlStore struct {
+	repository *repositories.Queries
+}
+
+// NewSQLStore returns a Store backed by a MySQL or sqlite database
+func NewSQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		repository: repositories.New(db),
+	}
+}
+
+func (s *sqlStore) CreateUser(ctx context.Context, user entities.User) (entities.User, error) {
+	id, err := s.repository.CreateUser(ctx, repositories.CreateUserParams{
+		UserID:       user.ID,
+		Email:        user.Email,
+		PasswordHash: user.PasswordHash,
+	})
+	if err != nil {
+		if isDuplicate(err) {
+			return entities.User{}, ErrEmailTaken
+		}
+		return entities.User{}, err
+	}
+
+	created, err := s.repository.FindUser(ctx, id)
+	if err != nil {
+		return entities.User{}, err
+	}
+	return toEntity(created), nil
+}
+
+func (s *sqlStore) GetUser(ctx context.Context, id string) (entities.User, error) {
+	user, err := s.repository.FindUserByUserID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.User{}, ErrNotFound
+		}
+		return entities.User{}, err
+	}
+	return toEntity(user), nil
+}
+
+func (s *sqlStore) GetUserByEmail(ctx context.Context, email string) (entities.User, error) {
+	user, err := s.repository.FindUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.User{}, ErrNotFound
+		}
+		return entities.User{}, err
+	}
+	return toEntity(user), nil
+}
+
+// isDuplicate reports whether the error is a unique constraint violation
+func isDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	// the sqlite error type is only available in cgo builds, so match on the message
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+func toEntity(u repositories.User) entities.User {
+	return entities.User{
+		ID:           u.UserID,
+		Email:        u.Email,
+		PasswordHash: u.PasswordHash,
+		CreatedAt:    u.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,17 @@
+package users
+
+import (
+	"context"
+
+	"notes/services/entities"
+)
+
+// Store persists user accounts
+type Store interface {
+	// CreateUser stores the given user and returns the stored copy, or ErrEmailTaken if the email is in use
+	CreateUser(ctx context.Context, user entities.User) (entities.User, error)
+	// GetUser returns the user with the given id or ErrNotFound
+	GetUser(ctx context.Context, id string) (entities.User, error)
+	// GetUserByEmail returns the user with the given email or ErrNotFound
+	GetUserByEmail(ctx context.Context, email string) (entities.User, error)
+}
@@ -0,0 +1,154 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+// dummyHash is compared against on logins for unknown emails, so they take as long as a wrong
+// password and the response time does not tell which emails are registered. It is a bcrypt hash
+// at bcrypt.DefaultCost, like the ones Signup stores.
+var dummyHash = []byte("$2a$10$lnhuw.qtrLiTrQU63lNNm.1TPwpPNglIrQTavjP87Xo6Xw89flvdG")
+
+var (
+	// ErrNotFound is returned when a user does not exist
+	ErrNotFound = errors.New("user not found")
+	// ErrEmailTaken is returned when signing up with an email that is already registered
+	ErrEmailTaken = errors.New("email is already registered")
+	// ErrInvalidUser is returned when a signup request is invalid
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrInvalidCredentials is returned when the email or password do not match
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned when a token is malformed, expired or not signed by us
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// claims are the JWT claims issued for a user, the subject is the user id
+type claims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// Service manages user accounts and the tokens they authenticate with
+type Service struct {
+	store  Store
+	secret []byte
+	ttl    time.Duration
+}
+
+// New returns a users service that signs tokens with the secret, valid for ttl
+func New(store Store, secret []byte, ttl time.Duration) *Service {
+	return &Service{
+		store:  store,
+		secret: secret,
+		ttl:    ttl,
+	}
+}
+
+// Signup creates an account and returns a token for it
+func (s *Service) Signup(ctx context.Context, req entities.SignupReq) (entities.AuthToken, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.Signup")
+	defer span.End()
+
+	email := normalizeEmail(req.Email)
+	if email == "" || !strings.Contains(email, "@") {
+		return entities.AuthToken{}, fmt.Errorf("%w: a valid email is required", ErrInvalidUser)
+	}
+	if len(req.Password) < minPasswordLength {
+		return entities.AuthToken{}, fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, minPasswordLength)
+	}
+	if len(req.Password) > entities.MaxPasswordLength {
+		return entities.AuthToken{}, fmt.Errorf("%w: password must be at most %d bytes", ErrInvalidUser, entities.MaxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return entities.AuthToken{}, err
+	}
+
+	user, err := s.store.CreateUser(ctx, entities.User{
+		ID:           uuid.NewString(),
+		Email:        email,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return entities.AuthToken{}, err
+	}
+	return s.issue(user)
+}
+
+// Login checks the credentials and returns a token for the account
+func (s *Service) Login(ctx context.Context, req entities.LoginReq) (entities.AuthToken, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.Login")
+	defer span.End()
+
+	user, err := s.store.GetUserByEmail(ctx, normalizeEmail(req.Email))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+			return entities.AuthToken{}, ErrInvalidCredentials
+		}
+		return entities.AuthToken{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return entities.AuthToken{}, ErrInvalidCredentials
+	}
+	return s.issue(user)
+}
+
+// Authenticate verifies the token and returns the user it was issued to
+func (s *Service) Authenticate(ctx context.Context, token string) (entities.User, error) {
+	_, span := tracing.Tracer().Start(ctx, "svc.Authenticate")
+	defer span.End()
+
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || c.Subject == "" {
+		return entities.User{}, ErrInvalidToken
+	}
+	return entities.User{
+		ID:    c.Subject,
+		Email: c.Email,
+	}, nil
+}
+
+func (s *Service) issue(user entities.User) (entities.AuthToken, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Email: user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			Issuer:    "notes",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}).SignedString(s.secret)
+	if err != nil {
+		return entities.AuthToken{}, err
+	}
+	return entities.AuthToken{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      user,
+	}, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,105 @@
+package users
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/migrator"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var store Store
+
+func TestMain(m *testing.M) {
+	code := 1
+
+	db, err := migrator.SetupSQLite(context.TODO(), ":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := migrator.MigrateSQLite(context.TODO(), db); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(code)
+	}()
+	store = NewSQLStore(db)
+	code = m.Run()
+}
+
+func TestSignupAndLogin(t *testing.T) {
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "sql": store} {
+		t.Run(name, func(t *testing.T) {
+			service := New(store, []byte("secret"), time.Hour)
+			token, err := service.Signup(t.Context(), entities.SignupReq{Email: " Ada@Example.com", Password: "p@ssword1"})
+			require.NoError(t, err)
+			require.NotEmpty(t, token.Token)
+			require.Equal(t, "ada@example.com", token.User.Email)
+			require.NotEqual(t, "p@ssword1", token.User.PasswordHash)
+
+			_, err = service.Signup(t.Context(), entities.SignupReq{Email: "ada@example.com", Password: "p@ssword2"})
+			require.ErrorIs(t, err, ErrEmailTaken)
+
+			login, err := service.Login(t.Context(), entities.LoginReq{Email: "ADA@example.com", Password: "p@ssword1"})
+			require.NoError(t, err)
+			require.Equal(t, token.User.ID, login.User.ID)
+
+			user, err := service.Authenticate(t.Context(), login.Token)
+			require.NoError(t, err)
+			require.Equal(t, token.User.ID, user.ID)
+			require.Equal(t, "ada@example.com", user.Email)
+
+			_, err = service.Login(t.Context(), entities.LoginReq{Email: "ada@example.com", Password: "wrong"})
+			require.ErrorIs(t, err, ErrInvalidCredentials)
+			_, err = service.Login(t.Context(), entities.LoginReq{Email: "nobody@example.com", Password: "p@ssword1"})
+			require.ErrorIs(t, err, ErrInvalidCredentials)
+			// unknown emails are checked against a hash as costly as a real one
+			cost, err := bcrypt.Cost(dummyHash)
+			require.NoError(t, err)
+			require.Equal(t, bcrypt.DefaultCost, cost)
+		})
+	}
+}
+
+func TestSignup_Invalid(t *testing.T) {
+	service := New(NewMemoryStore(), []byte("secret"), time.Hour)
+	_, err := service.Signup(t.Context(), entities.SignupReq{Email: "not-an-email", Password: "p@ssword1"})
+	require.ErrorIs(t, err, ErrInvalidUser)
+	_, err = service.Signup(t.Context(), entities.SignupReq{Email: "ada@example.com", Password: "short"})
+	require.ErrorIs(t, err, ErrInvalidUser)
+	_, err = service.Signup(t.Context(), entities.SignupReq{Email: "ada@example.com", Password: strings.Repeat("p", entities.MaxPasswordLength+1)})
+	require.ErrorIs(t, err, ErrInvalidUser)
+}
+
+func TestAuthenticate_InvalidTokens(t *testing.T) {
+	service := New(NewMemoryStore(), []byte("secret"), -time.Minute)
+	expired, err := service.Signup(t.Context(), entities.SignupReq{Email: "ada@example.com", Password: "p@ssword1"})
+	require.NoError(t, err)
+	_, err = service.Authenticate(t.Context(), expired.Token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	other, err := New(NewMemoryStore(), []byte("another secret"), time.Hour).
+		Signup(t.Context(), entities.SignupReq{Email: "ada@example.com", Password: "p@ssword1"})
+	require.NoError(t, err)
+	_, err = service.Authenticate(t.Context(), other.Token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Subject:   "someone",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = service.Authenticate(t.Context(), unsigned)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
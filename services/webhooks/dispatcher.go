@@ -0,0 +1,242 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Headers of the requests posted to webhooks
+const (
+	// HeaderID holds the id of the event, which stays the same on every attempt
+	HeaderID = "X-Webhook-ID"
+	// HeaderEvent holds the type of the event
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderTimestamp holds the unix time the request was signed at
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature holds the signature of the request, see Sign
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	// batchSize is the number of events and deliveries handled in one pass of the dispatcher
+	batchSize = 100
+	// maxErrorLength is the longest error kept with a delivery
+	maxErrorLength = 1000
+)
+
+// Start runs the dispatcher every interval until ctx is done
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Dispatch(ctx); err != nil && ctx.Err() == nil {
+					slog.ErrorContext(ctx, "failed to dispatch webhooks", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Dispatch hands the events waiting in the outbox to the webhooks of their users and
+// sends the deliveries that are due. An event leaves the outbox once every webhook has
+// a delivery of it, so events are delivered at least once.
+func (s *Service) Dispatch(ctx context.Context) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.DispatchWebhooks")
+	defer span.End()
+
+	events, err := s.outbox.ListOutbox(ctx, batchSize)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := s.fanOut(ctx, event); err != nil {
+			return err
+		}
+		if err := s.outbox.DeleteOutbox(ctx, event.ID); err != nil {
+			return err
+		}
+	}
+
+	due, err := s.store.ListDueDeliveries(ctx, time.Now(), batchSize)
+	if err != nil {
+		return err
+	}
+	sent := 0
+	for _, delivery := range due {
+		ok, err := s.attempt(ctx, delivery)
+		if err != nil {
+			return err
+		}
+		if ok {
+			sent++
+		}
+	}
+	span.SetAttributes(attribute.Int("events", len(events)), attribute.Int("sent", sent))
+	return nil
+}
+
+// Enqueue hands an event that does not come from the outbox to the user's webhooks, it is sent
+// with the next dispatch. Every webhook gets an event once, however often its id is enqueued.
+func (s *Service) Enqueue(ctx context.Context, userID string, event entities.WebhookEvent) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.EnqueueWebhookEvent")
+	defer span.End()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.fanOut(ctx, entities.OutboxEvent{
+		EventID: event.ID,
+		UserID:  userID,
+		Type:    event.Type,
+		Payload: string(payload),
+	})
+}
+
+// fanOut creates a delivery of the event for each of its user's webhooks
+func (s *Service) fanOut(ctx context.Context, event entities.OutboxEvent) error {
+	webhooks, err := s.store.ListWebhooks(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, webhook := range webhooks {
+		err := s.store.CreateDelivery(ctx, entities.Delivery{
+			ID:            uuid.NewString(),
+			WebhookID:     webhook.ID,
+			EventID:       event.EventID,
+			EventType:     event.Type,
+			Payload:       event.Payload,
+			Status:        entities.DeliveryPending,
+			NextAttemptAt: &now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt claims the delivery and posts it to its webhook, then records the outcome.
+// It reports whether the delivery was sent, deliveries claimed by another dispatcher are skipped.
+func (s *Service) attempt(ctx context.Context, delivery entities.Delivery) (bool, error) {
+	now := time.Now()
+	if err := s.store.ClaimDelivery(ctx, delivery.ID, delivery.Attempts, now.Add(s.lease)); err != nil {
+		if errors.Is(err, ErrClaimed) {
+			return false, nil
+		}
+		return false, err
+	}
+	delivery.Attempts++
+
+	webhook, err := s.store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		// the webhook was deleted along with its deliveries in the meantime
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	status, err := s.send(ctx, webhook, delivery)
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = entities.DeliveryDelivered
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+	case delivery.Attempts >= s.maxAttempts:
+		delivery.Status = entities.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.LastError = errorMessage(err)
+	default:
+		next := time.Now().Add(s.backoffFor(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = errorMessage(err)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", webhook.ID,
+			"attempts", delivery.Attempts, "status", delivery.Status, "error", err)
+	}
+	return true, s.store.UpdateDelivery(ctx, delivery)
+}
+
+// backoffFor returns the wait after the given number of failed attempts
+func (s *Service) backoffFor(attempts int) time.Duration {
+	backoff := s.backoff
+	for i := 1; i < attempts && backoff < s.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, s.maxBackoff)
+}
+
+// send posts the delivery's payload to the webhook, signed with its secret. It returns the
+// status the receiver answered with, and an error unless that was a 2xx status.
+func (s *Service) send(ctx context.Context, webhook entities.Webhook, delivery entities.Delivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.EventID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain a little of the body, so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature of a request to a webhook: the hex encoded HMAC-SHA256 of the
+// timestamp, a dot and the body, keyed with the webhook's secret and prefixed with "sha256=".
+// Receivers compute it the same way and compare it to the X-Webhook-Signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errorMessage shortens the error to what a delivery keeps
+func errorMessage(err error) string {
+	message := err.Error()
+	if utf8.RuneCountInString(message) > maxErrorLength {
+		message = string([]rune(message)[:maxErrorLength])
+	}
+	return message
+}
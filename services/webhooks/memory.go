@@ -0,0 +1,193 @@
+package webhooks
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"notes/services/entities"
+)
+
+// memoryStore keeps webhooks and deliveries in maps, so everything is lost once the process exits.
+type memoryStore struct {
+	mu         sync.RWMutex
+	webhooks   map[string]entities.Webhook
+	deliveries map[string]entities.Delivery
+	// order the deliveries were created in, as their ids are random
+	sequence map[string]int64
+	next     int64
+}
+
+// NewMemoryStore returns a Store that keeps webhooks in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		webhooks:   make(map[string]entities.Webhook),
+		deliveries: make(map[string]entities.Delivery),
+		sequence:   make(map[string]int64),
+	}
+}
+
+func (m *memoryStore) CreateWebhook(_ context.Context, webhook entities.Webhook) (entities.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if webhook.CreatedAt.IsZero() {
+		webhook.CreatedAt = time.Now()
+	}
+	m.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (m *memoryStore) GetWebhook(_ context.Context, id string) (entities.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return entities.Webhook{}, ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (m *memoryStore) ListWebhooks(_ context.Context, userID string) ([]entities.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Webhook, 0)
+	for _, v := range m.webhooks {
+		if v.UserID == userID {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *memoryStore) DeleteWebhook(_ context.Context, userID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok || webhook.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.webhooks, id)
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.WebhookID == id {
+			delete(m.deliveries, deliveryID)
+			delete(m.sequence, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) CreateDelivery(_ context.Context, delivery entities.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, v := range m.deliveries {
+		if v.WebhookID == delivery.WebhookID && v.EventID == delivery.EventID {
+			return nil
+		}
+	}
+	now := time.Now()
+	delivery.CreatedAt, delivery.UpdatedAt = now, now
+	m.deliveries[delivery.ID] = delivery
+	m.next++
+	m.sequence[delivery.ID] = m.next
+	return nil
+}
+
+func (m *memoryStore) ListDueDeliveries(_ context.Context, now time.Time, limit int) ([]entities.Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Delivery, 0)
+	for _, v := range m.deliveries {
+		if v.Status == entities.DeliveryPending && v.NextAttemptAt != nil && !v.NextAttemptAt.After(now) {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].NextAttemptAt.Equal(*result[j].NextAttemptAt) {
+			return result[i].NextAttemptAt.Before(*result[j].NextAttemptAt)
+		}
+		return m.sequence[result[i].ID] < m.sequence[result[j].ID]
+	})
+	return result[:min(limit, len(result))], nil
+}
+
+func (m *memoryStore) ClaimDelivery(_ context.Context, id string, attempts int, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery, ok := m.deliveries[id]
+	if !ok || delivery.Status != entities.DeliveryPending || delivery.Attempts != attempts {
+		return ErrClaimed
+	}
+	delivery.Attempts++
+	delivery.NextAttemptAt = &until
+	delivery.UpdatedAt = time.Now()
+	m.deliveries[id] = delivery
+	return nil
+}
+
+func (m *memoryStore) UpdateDelivery(_ context.Context, delivery entities.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.deliveries[delivery.ID]
+	if !ok {
+		return nil
+	}
+	stored.Status = delivery.Status
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastError = delivery.LastError
+	stored.ResponseStatus = delivery.ResponseStatus
+	stored.UpdatedAt = time.Now()
+	m.deliveries[delivery.ID] = stored
+	return nil
+}
+
+func (m *memoryStore) ListDeliveries(_ context.Context, webhookID, status string, limit int) ([]entities.Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]entities.Delivery, 0)
+	for _, v := range m.deliveries {
+		if v.WebhookID == webhookID && (status == "" || v.Status == status) {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return m.sequence[result[i].ID] > m.sequence[result[j].ID]
+	})
+	return result[:min(limit, len(result))], nil
+}
+
+func (m *memoryStore) ReplayDeliveries(_ context.Context, webhookID string, ids []string, at time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var replayed int64
+	for id, delivery := range m.deliveries {
+		if delivery.WebhookID != webhookID || delivery.Status != entities.DeliveryFailed {
+			continue
+		}
+		if len(ids) > 0 && !slices.Contains(ids, id) {
+			continue
+		}
+		delivery.Status = entities.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = &at
+		delivery.LastError = ""
+		delivery.UpdatedAt = time.Now()
+		m.deliveries[id] = delivery
+		replayed++
+	}
+	return replayed, nil
+}
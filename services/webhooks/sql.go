@@ -0,0 +1,236 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"notes/repositories"
+	"notes/services/entities"
+)
+
+// sqlStore stores webhooks through the sqlc repository, it works with both MySQL and sqlite.
+// Times are written in UTC, so sqlite, which keeps them as text, compares them in order.
+type sqlStore struct {
+	db         *sql.DB
+	repository *repositories.Queries
+}
+
+// NewSQLStore returns a Store backed by a MySQL or sqlite database
+func NewSQLStore(db *sql.DB) Store {
+	return &sqlStore{
+		db:         db,
+		repository: repositories.New(db),
+	}
+}
+
+func (s *sqlStore) CreateWebhook(ctx context.Context, webhook entities.Webhook) (entities.Webhook, error) {
+	err := s.repository.CreateWebhook(ctx, repositories.CreateWebhookParams{
+		WebhookID: webhook.ID,
+		UserID:    webhook.UserID,
+		Url:       webhook.URL,
+		Secret:    webhook.Secret,
+	})
+	if err != nil {
+		return entities.Webhook{}, err
+	}
+	return s.GetWebhook(ctx, webhook.ID)
+}
+
+func (s *sqlStore) GetWebhook(ctx context.Context, id string) (entities.Webhook, error) {
+	webhook, err := s.repository.FindWebhook(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.Webhook{}, ErrNotFound
+		}
+		return entities.Webhook{}, err
+	}
+	return toWebhook(webhook), nil
+}
+
+func (s *sqlStore) ListWebhooks(ctx context.Context, userID string) ([]entities.Webhook, error) {
+	webhooks, err := s.repository.FindWebhooksByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.Webhook, 0, len(webhooks))
+	for i := range webhooks {
+		result = append(result, toWebhook(webhooks[i]))
+	}
+	return result, nil
+}
+
+func (s *sqlStore) DeleteWebhook(ctx context.Context, userID, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	q := s.repository.WithTx(tx)
+	rows, err := q.DeleteWebhook(ctx, repositories.DeleteWebhookParams{
+		WebhookID: id,
+		UserID:    userID,
+	})
+	if err == nil && rows == 0 {
+		err = ErrNotFound
+	}
+	if err == nil {
+		err = q.DeleteWebhookDeliveries(ctx, id)
+	}
+	if err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) CreateDelivery(ctx context.Context, delivery entities.Delivery) error {
+	exists, err := s.hasDelivery(ctx, delivery.WebhookID, delivery.EventID)
+	if err != nil || exists {
+		return err
+	}
+	err = s.repository.CreateWebhookDelivery(ctx, repositories.CreateWebhookDeliveryParams{
+		DeliveryID:    delivery.ID,
+		WebhookID:     delivery.WebhookID,
+		EventID:       delivery.EventID,
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        delivery.Status,
+		NextAttemptAt: nullTime(delivery.NextAttemptAt),
+	})
+	if err != nil {
+		// another dispatcher may have created it in the meantime
+		if exists, _ := s.hasDelivery(ctx, delivery.WebhookID, delivery.EventID); exists {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// hasDelivery reports whether the webhook already has a delivery of the event
+func (s *sqlStore) hasDelivery(ctx context.Context, webhookID, eventID string) (bool, error) {
+	_, err := s.repository.FindWebhookDeliveryByEventID(ctx, repositories.FindWebhookDeliveryByEventIDParams{
+		WebhookID: webhookID,
+		EventID:   eventID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *sqlStore) ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]entities.Delivery, error) {
+	deliveries, err := s.repository.FindDueWebhookDeliveries(ctx, repositories.FindDueWebhookDeliveriesParams{
+		NextAttemptAt: nullTime(&now),
+		Limit:         int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toDeliveries(deliveries), nil
+}
+
+func (s *sqlStore) ClaimDelivery(ctx context.Context, id string, attempts int, until time.Time) error {
+	rows, err := s.repository.ClaimWebhookDelivery(ctx, repositories.ClaimWebhookDeliveryParams{
+		NextAttemptAt: nullTime(&until),
+		DeliveryID:    id,
+		Attempts:      int32(attempts),
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrClaimed
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateDelivery(ctx context.Context, delivery entities.Delivery) error {
+	return s.repository.UpdateWebhookDelivery(ctx, repositories.UpdateWebhookDeliveryParams{
+		Status:         delivery.Status,
+		NextAttemptAt:  nullTime(delivery.NextAttemptAt),
+		LastError:      delivery.LastError,
+		ResponseStatus: int32(delivery.ResponseStatus),
+		DeliveryID:     delivery.ID,
+	})
+}
+
+func (s *sqlStore) ListDeliveries(ctx context.Context, webhookID, status string, limit int) ([]entities.Delivery, error) {
+	var (
+		deliveries []repositories.WebhookDelivery
+		err        error
+	)
+	if status == "" {
+		deliveries, err = s.repository.FindWebhookDeliveries(ctx, repositories.FindWebhookDeliveriesParams{
+			WebhookID: webhookID,
+			Limit:     int32(limit),
+		})
+	} else {
+		deliveries, err = s.repository.FindWebhookDeliveriesByStatus(ctx, repositories.FindWebhookDeliveriesByStatusParams{
+			WebhookID: webhookID,
+			Status:    status,
+			Limit:     int32(limit),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDeliveries(deliveries), nil
+}
+
+func (s *sqlStore) ReplayDeliveries(ctx context.Context, webhookID string, ids []string, at time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return s.repository.ReplayWebhookDeliveries(ctx, repositories.ReplayWebhookDeliveriesParams{
+			NextAttemptAt: nullTime(&at),
+			WebhookID:     webhookID,
+		})
+	}
+	return s.repository.ReplayWebhookDeliveriesByIDs(ctx, repositories.ReplayWebhookDeliveriesByIDsParams{
+		NextAttemptAt: nullTime(&at),
+		WebhookID:     webhookID,
+		DeliveryIds:   ids,
+	})
+}
+
+func toWebhook(w repositories.Webhook) entities.Webhook {
+	return entities.Webhook{
+		ID:        w.WebhookID,
+		UserID:    w.UserID,
+		URL:       w.Url,
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt.Time,
+	}
+}
+
+func toDeliveries(rows []repositories.WebhookDelivery) []entities.Delivery {
+	result := make([]entities.Delivery, 0, len(rows))
+	for _, d := range rows {
+		delivery := entities.Delivery{
+			ID:             d.DeliveryID,
+			WebhookID:      d.WebhookID,
+			EventID:        d.EventID,
+			EventType:      d.EventType,
+			Payload:        d.Payload,
+			Status:         d.Status,
+			Attempts:       int(d.Attempts),
+			LastError:      d.LastError,
+			ResponseStatus: int(d.ResponseStatus),
+			CreatedAt:      d.CreatedAt.Time,
+			UpdatedAt:      d.UpdatedAt.Time,
+		}
+		if d.NextAttemptAt.Valid {
+			delivery.NextAttemptAt = &d.NextAttemptAt.Time
+		}
+		result = append(result, delivery)
+	}
+	return result
+}
+
+// nullTime stores a missing time as NULL and the others in UTC
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
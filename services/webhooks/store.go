@@ -0,0 +1,37 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"notes/services/entities"
+)
+
+// Store persists webhooks and their deliveries
+type Store interface {
+	// CreateWebhook stores the given webhook and returns the stored copy
+	CreateWebhook(ctx context.Context, webhook entities.Webhook) (entities.Webhook, error)
+	// GetWebhook returns the webhook with the given id or ErrNotFound
+	GetWebhook(ctx context.Context, id string) (entities.Webhook, error)
+	// ListWebhooks returns the user's webhooks, oldest first
+	ListWebhooks(ctx context.Context, userID string) ([]entities.Webhook, error)
+	// DeleteWebhook removes the user's webhook with the given id and its deliveries or returns ErrNotFound
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	// CreateDelivery stores the given delivery. A webhook gets one delivery per event, so a
+	// delivery of an event the webhook already has is dropped.
+	CreateDelivery(ctx context.Context, delivery entities.Delivery) error
+	// ListDueDeliveries returns up to limit pending deliveries whose next attempt is due at now, most overdue first
+	ListDueDeliveries(ctx context.Context, now time.Time, limit int) ([]entities.Delivery, error)
+	// ClaimDelivery counts an attempt of the delivery and puts its next attempt off until until, as
+	// long as it is still pending after the given number of attempts. It fails with ErrClaimed when
+	// another dispatcher got to it first.
+	ClaimDelivery(ctx context.Context, id string, attempts int, until time.Time) error
+	// UpdateDelivery stores the outcome of an attempt: the status, next attempt, last error and response status
+	UpdateDelivery(ctx context.Context, delivery entities.Delivery) error
+	// ListDeliveries returns up to limit deliveries of the webhook, newest first. An empty
+	// status returns deliveries in any state.
+	ListDeliveries(ctx context.Context, webhookID, status string, limit int) ([]entities.Delivery, error)
+	// ReplayDeliveries makes the failed deliveries of the webhook with the given ids pending again,
+	// all of its failed deliveries when ids is empty, and returns how many there were
+	ReplayDeliveries(ctx context.Context, webhookID string, ids []string, at time.Time) (int64, error)
+}
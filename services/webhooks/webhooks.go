@@ -0,0 +1,238 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/tracing"
+
+	"github.com/google/uuid"
+)
+
+// SecretPrefix starts every webhook secret
+const SecretPrefix = "whsec_"
+
+// maxDeliveries is the number of deliveries listed at once
+const maxDeliveries = 100
+
+var (
+	// ErrNotFound is returned when a webhook does not exist
+	ErrNotFound = errors.New("webhook not found")
+	// ErrInvalidWebhook is returned when a webhook request is invalid
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrClaimed is returned when a delivery was claimed by another dispatcher
+	ErrClaimed = errors.New("delivery already claimed")
+)
+
+// Outbox holds the note events waiting to be handed to the webhooks
+type Outbox interface {
+	ListOutbox(ctx context.Context, limit int) ([]entities.OutboxEvent, error)
+	DeleteOutbox(ctx context.Context, id int64) error
+}
+
+// Option configures optional settings of the Service
+type Option func(*Service)
+
+// WithClient sends the deliveries with the given client instead of the one from NewClient,
+// which only reaches public addresses
+func WithClient(client *http.Client) Option {
+	return func(s *Service) {
+		s.client = client
+	}
+}
+
+// WithInterval sets how often the dispatcher looks for events and due deliveries
+func WithInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.interval = interval
+	}
+}
+
+// WithMaxAttempts sets how often a delivery is attempted before it is given up as failed
+func WithMaxAttempts(attempts int) Option {
+	return func(s *Service) {
+		s.maxAttempts = attempts
+	}
+}
+
+// WithBackoff sets the wait after the first failed attempt, which doubles with every
+// further attempt up to limit
+func WithBackoff(base, limit time.Duration) Option {
+	return func(s *Service) {
+		s.backoff, s.maxBackoff = base, limit
+	}
+}
+
+// Service registers webhooks and delivers the note events to them
+type Service struct {
+	store       Store
+	outbox      Outbox
+	client      *http.Client
+	interval    time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	// lease is how long a claimed delivery is left alone, so another dispatcher
+	// retries it only when the one sending it stopped
+	lease time.Duration
+}
+
+// New returns a webhooks service backed by the given store, delivering the events in outbox
+func New(store Store, outbox Outbox, opts ...Option) *Service {
+	s := &Service{
+		store:       store,
+		outbox:      outbox,
+		client:      NewClient(10 * time.Second),
+		interval:    5 * time.Second,
+		maxAttempts: 8,
+		backoff:     30 * time.Second,
+		maxBackoff:  time.Hour,
+		lease:       time.Minute,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateWebhook registers a URL the user's note events are posted to. The returned webhook
+// holds the secret the requests are signed with, it is not shown again afterwards.
+func (s *Service) CreateWebhook(ctx context.Context, userID string, req entities.WebhookReq) (entities.Webhook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.CreateWebhook")
+	defer span.End()
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return entities.Webhook{}, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return entities.Webhook{}, err
+	}
+	return s.store.CreateWebhook(ctx, entities.Webhook{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		URL:       req.URL,
+		Secret:    SecretPrefix + base64.RawURLEncoding.EncodeToString(secret),
+		CreatedAt: time.Now(),
+	})
+}
+
+// ListWebhooks returns the user's webhooks without their secrets
+func (s *Service) ListWebhooks(ctx context.Context, userID string) ([]entities.Webhook, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ListWebhooks")
+	defer span.End()
+
+	webhooks, err := s.store.ListWebhooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the user's webhook along with its deliveries
+func (s *Service) DeleteWebhook(ctx context.Context, userID, id string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.DeleteWebhook")
+	defer span.End()
+
+	return s.store.DeleteWebhook(ctx, userID, id)
+}
+
+// TestWebhook posts a ping event to the user's webhook right away and returns how it went.
+// The delivery is not kept and not retried.
+func (s *Service) TestWebhook(ctx context.Context, userID, id string) (entities.Delivery, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.TestWebhook")
+	defer span.End()
+
+	webhook, err := s.webhook(ctx, userID, id)
+	if err != nil {
+		return entities.Delivery{}, err
+	}
+	event := entities.WebhookEvent{
+		ID:     uuid.NewString(),
+		Type:   entities.EventPing,
+		UserID: userID,
+		Time:   time.Now().UTC(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return entities.Delivery{}, err
+	}
+
+	now := time.Now()
+	delivery := entities.Delivery{
+		ID:        uuid.NewString(),
+		WebhookID: webhook.ID,
+		EventID:   event.ID,
+		EventType: event.Type,
+		Payload:   string(payload),
+		Status:    entities.DeliveryDelivered,
+		Attempts:  1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	status, err := s.send(ctx, webhook, delivery)
+	delivery.ResponseStatus = status
+	if err != nil {
+		delivery.Status = entities.DeliveryFailed
+		delivery.LastError = errorMessage(err)
+	}
+	return delivery, nil
+}
+
+// ListDeliveries returns the latest deliveries of the user's webhook, newest first,
+// only those in the given state unless status is empty
+func (s *Service) ListDeliveries(ctx context.Context, userID, id, status string) ([]entities.Delivery, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ListDeliveries")
+	defer span.End()
+
+	switch status {
+	case "", entities.DeliveryPending, entities.DeliveryDelivered, entities.DeliveryFailed:
+	default:
+		return nil, fmt.Errorf("%w: status must be pending, delivered or failed", ErrInvalidWebhook)
+	}
+	if _, err := s.webhook(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	return s.store.ListDeliveries(ctx, id, status, maxDeliveries)
+}
+
+// ReplayDeliveries queues failed deliveries of the user's webhook to be sent again with a
+// fresh set of attempts, all of them unless the request names some
+func (s *Service) ReplayDeliveries(ctx context.Context, userID, id string, req entities.ReplayReq) (entities.ReplayResponse, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "svc.ReplayDeliveries")
+	defer span.End()
+
+	if _, err := s.webhook(ctx, userID, id); err != nil {
+		return entities.ReplayResponse{}, err
+	}
+	replayed, err := s.store.ReplayDeliveries(ctx, id, req.DeliveryIDs, time.Now())
+	if err != nil {
+		return entities.ReplayResponse{}, err
+	}
+	return entities.ReplayResponse{Replayed: replayed}, nil
+}
+
+// webhook returns the webhook if it belongs to the user, ErrNotFound otherwise
+func (s *Service) webhook(ctx context.Context, userID, id string) (entities.Webhook, error) {
+	webhook, err := s.store.GetWebhook(ctx, id)
+	if err != nil {
+		return entities.Webhook{}, err
+	}
+	if webhook.UserID != userID {
+		return entities.Webhook{}, ErrNotFound
+	}
+	return webhook, nil
+}
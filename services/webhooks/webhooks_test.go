@@ -0,0 +1,285 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"notes/services/entities"
+	"notes/services/migrator"
+	"notes/services/notes"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	store      Store
+	notesStore notes.Store
+)
+
+func TestMain(m *testing.M) {
+	code := 1
+
+	db, err := migrator.SetupSQLite(context.TODO(), ":memory:")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := migrator.MigrateSQLite(context.TODO(), db); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(code)
+	}()
+	store = NewSQLStore(db)
+	notesStore = notes.NewSQLiteStore(db)
+	code = m.Run()
+}
+
+// receiver records the requests posted to it and answers them with status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *receiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func (r *receiver) last() (*http.Request, []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[len(r.requests)-1], r.bodies[len(r.bodies)-1]
+}
+
+func TestWebhooks(t *testing.T) {
+	stores := map[string]struct {
+		webhooks Store
+		notes    notes.Store
+	}{
+		"memory": {NewMemoryStore(), notes.NewMemoryStore()},
+		"sql":    {store, notesStore},
+	}
+	for name, stores := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			userID := "webhooks-" + name
+			notesService := notes.New(stores.notes)
+			recv := &receiver{status: http.StatusOK}
+			server := httptest.NewServer(recv)
+			t.Cleanup(server.Close)
+
+			// the receiver listens on loopback, which the default client refuses
+			service := New(stores.webhooks, notesService, WithClient(server.Client()),
+				WithMaxAttempts(2), WithBackoff(time.Millisecond, time.Millisecond))
+
+			_, err := service.CreateWebhook(ctx, userID, entities.WebhookReq{URL: "ftp://example.com"})
+			require.ErrorIs(t, err, ErrInvalidWebhook)
+
+			webhook, err := service.CreateWebhook(ctx, userID, entities.WebhookReq{URL: server.URL})
+			require.NoError(t, err)
+			require.Contains(t, webhook.Secret, SecretPrefix)
+
+			listed, err := service.ListWebhooks(ctx, userID)
+			require.NoError(t, err)
+			require.Len(t, listed, 1)
+			require.Equal(t, webhook.ID, listed[0].ID)
+			require.Empty(t, listed[0].Secret)
+
+			// the event is written with the note and delivered signed
+			note, err := notesService.CreateNote(ctx, userID, entities.NoteReq{Title: "title", Content: "content"})
+			require.NoError(t, err)
+			require.NoError(t, service.Dispatch(ctx))
+			require.Equal(t, 1, recv.received())
+
+			req, body := recv.last()
+			require.Equal(t, entities.EventNoteCreated, req.Header.Get(HeaderEvent))
+			require.Equal(t, Sign(webhook.Secret, req.Header.Get(HeaderTimestamp), body), req.Header.Get(HeaderSignature))
+			var event entities.WebhookEvent
+			require.NoError(t, json.Unmarshal(body, &event))
+			require.Equal(t, req.Header.Get(HeaderID), event.ID)
+			require.Equal(t, note.ID, event.NoteID)
+			require.Equal(t, userID, event.UserID)
+			require.NotNil(t, event.Note)
+			require.Equal(t, "title", event.Note.Title)
+
+			outbox, err := notesService.ListOutbox(ctx, 10)
+			require.NoError(t, err)
+			require.Empty(t, outbox)
+
+			// nothing is sent twice
+			require.NoError(t, service.Dispatch(ctx))
+			require.Equal(t, 1, recv.received())
+
+			// failed attempts are retried until the delivery is given up
+			recv.setStatus(http.StatusInternalServerError)
+			require.NoError(t, notesService.DeleteNote(ctx, userID, note.ID, 0))
+			require.NoError(t, service.Dispatch(ctx))
+			require.Equal(t, 2, recv.received())
+
+			pending, err := service.ListDeliveries(ctx, userID, webhook.ID, entities.DeliveryPending)
+			require.NoError(t, err)
+			require.Len(t, pending, 1)
+			require.Equal(t, entities.EventNoteDeleted, pending[0].EventType)
+			require.Equal(t, 1, pending[0].Attempts)
+			require.Equal(t, http.StatusInternalServerError, pending[0].ResponseStatus)
+
+			time.Sleep(10 * time.Millisecond)
+			require.NoError(t, service.Dispatch(ctx))
+			require.Equal(t, 3, recv.received())
+
+			failed, err := service.ListDeliveries(ctx, userID, webhook.ID, entities.DeliveryFailed)
+			require.NoError(t, err)
+			require.Len(t, failed, 1)
+			require.Equal(t, 2, failed[0].Attempts)
+			require.NotEmpty(t, failed[0].LastError)
+			require.Nil(t, failed[0].NextAttemptAt)
+
+			// dead letters wait for a replay
+			time.Sleep(10 * time.Millisecond)
+			require.NoError(t, service.Dispatch(ctx))
+			require.Equal(t, 3, recv.received())
+
+			recv.setStatus(http.StatusNoContent)
+			replayed, err := service.ReplayDeliveries(ctx, userID, webhook.ID, entities.ReplayReq{DeliveryIDs: []string{failed[0].ID}})
+			require.NoError(t, err)
+			require.Equal(t, int64(1), replayed.Replayed)
+			require.NoError(t, service.Dispatch(ctx))
+			require.Equal(t, 4, recv.received())
+			_, body = recv.last()
+			var deleted entities.WebhookEvent
+			require.NoError(t, json.Unmarshal(body, &deleted))
+			require.Equal(t, entities.EventNoteDeleted, deleted.Type)
+			require.Equal(t, note.ID, deleted.NoteID)
+			require.Nil(t, deleted.Note)
+
+			deliveries, err := service.ListDeliveries(ctx, userID, webhook.ID, "")
+			require.NoError(t, err)
+			require.Len(t, deliveries, 2)
+			require.Equal(t, failed[0].ID, deliveries[0].ID)
+			for _, delivery := range deliveries {
+				require.Equal(t, entities.DeliveryDelivered, delivery.Status)
+			}
+
+			_, err = service.ListDeliveries(ctx, userID, webhook.ID, "unknown")
+			require.ErrorIs(t, err, ErrInvalidWebhook)
+
+			// pings are sent right away and not kept
+			ping, err := service.TestWebhook(ctx, userID, webhook.ID)
+			require.NoError(t, err)
+			require.Equal(t, entities.DeliveryDelivered, ping.Status)
+			require.Equal(t, http.StatusNoContent, ping.ResponseStatus)
+			req, _ = recv.last()
+			require.Equal(t, entities.EventPing, req.Header.Get(HeaderEvent))
+
+			recv.setStatus(http.StatusGone)
+			ping, err = service.TestWebhook(ctx, userID, webhook.ID)
+			require.NoError(t, err)
+			require.Equal(t, entities.DeliveryFailed, ping.Status)
+			require.Equal(t, http.StatusGone, ping.ResponseStatus)
+
+			// other users cannot see or change the webhook
+			_, err = service.TestWebhook(ctx, "someone-else", webhook.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = service.ListDeliveries(ctx, "someone-else", webhook.ID, "")
+			require.ErrorIs(t, err, ErrNotFound)
+			require.ErrorIs(t, service.DeleteWebhook(ctx, "someone-else", webhook.ID), ErrNotFound)
+
+			require.NoError(t, service.DeleteWebhook(ctx, userID, webhook.ID))
+			require.ErrorIs(t, service.DeleteWebhook(ctx, userID, webhook.ID), ErrNotFound)
+			listed, err = service.ListWebhooks(ctx, userID)
+			require.NoError(t, err)
+			require.Empty(t, listed)
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"93.184.215.14":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"100.64.0.1":      false,
+		"169.254.169.254": false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"0.0.0.0":         false,
+		"::":              false,
+		"::ffff:10.0.0.1": false,
+	} {
+		require.Equal(t, ok, allowed(netip.MustParseAddr(addr)), addr)
+	}
+
+	recv := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	service := New(NewMemoryStore(), nil)
+	webhook, err := service.CreateWebhook(t.Context(), "client", entities.WebhookReq{URL: server.URL})
+	require.NoError(t, err)
+	ping, err := service.TestWebhook(t.Context(), "client", webhook.ID)
+	require.NoError(t, err)
+	require.Equal(t, entities.DeliveryFailed, ping.Status)
+	require.Zero(t, ping.ResponseStatus)
+	require.Contains(t, ping.LastError, ErrForbiddenAddress.Error())
+	require.Zero(t, recv.received())
+
+	// redirects are not followed
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+	client := NewClient(time.Second)
+	client.Transport = redirect.Client().Transport
+	resp, err := client.Get(redirect.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Zero(t, recv.received())
+}
+
+func TestBackoff(t *testing.T) {
+	service := New(NewMemoryStore(), nil, WithBackoff(time.Second, 10*time.Second))
+	require.Equal(t, time.Second, service.backoffFor(1))
+	require.Equal(t, 2*time.Second, service.backoffFor(2))
+	require.Equal(t, 8*time.Second, service.backoffFor(4))
+	require.Equal(t, 10*time.Second, service.backoffFor(5))
+	require.Equal(t, 10*time.Second, service.backoffFor(60))
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" keyed with "secret"
+	require.Equal(t, "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163", Sign("secret", "1700000000", []byte("{}")))
+	require.NotEqual(t, Sign("secret", "1700000000", []byte("{}")), Sign("secret", "1700000001", []byte("{}")))
+}